@@ -0,0 +1,32 @@
+// Progressive enhancements for the server-rendered pages. Everything works
+// without this file.
+"use strict";
+
+// Chirp forms show how many characters are left. Characters are counted as
+// code points, like the server does.
+for (const area of document.querySelectorAll("textarea[data-counter]")) {
+  const max = Number(area.getAttribute("maxlength"));
+  // maxlength counts UTF-16 units; the counter enforces the real limit.
+  area.removeAttribute("maxlength");
+  const counter = document.createElement("span");
+  counter.className = "counter";
+  counter.setAttribute("aria-live", "polite");
+  area.after(counter);
+  const button = area.form.querySelector("button[type=submit]");
+  const update = () => {
+    const left = max - [...area.value].length;
+    counter.textContent = String(left);
+    counter.classList.toggle("over", left < 0);
+    button.disabled = left < 0;
+  };
+  area.addEventListener("input", update);
+  update();
+}
+
+// Ctrl+Enter posts the chirp.
+document.addEventListener("keydown", (event) => {
+  const area = event.target;
+  if (area.matches?.("textarea[data-counter]") && event.key === "Enter" && (event.ctrlKey || event.metaKey)) {
+    area.form.requestSubmit();
+  }
+});
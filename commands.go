@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/RemcoVeens/httpserver/internal/config"
+)
+
+// command is a subcommand of the server binary.
+type command struct {
+	usage string
+	help  string
+	run   func(ctx context.Context, args []string) error
+}
+
+// commands are the subcommands. Without one the binary serves, as it did
+// before it had any.
+var commands map[string]command
+
+func init() {
+	commands = map[string]command{
+		"serve":   {"serve [-config file] [-migrate] [-print-config]", "Run the server.", runServe},
+		"migrate": {"migrate [-config file] up|down|status", "Apply, roll back or list the schema migrations.", runMigrate},
+		"seed":    {"seed [-users n] [-chirps n] [-seed n]", "Fill a dev database with fake users and chirps.", runSeed},
+		"user":    {"user [-config file] create|promote|disable", "Create an account, make it an admin, or disable it.", runUser},
+		"help":    {"help", "Show this help.", func(context.Context, []string) error { printUsage(); return nil }},
+	}
+}
+
+// usageError is a mistake in the command line.
+type usageError struct{ msg string }
+
+func (e usageError) Error() string { return e.msg }
+
+func usagef(format string, args ...any) error {
+	return usageError{fmt.Sprintf(format, args...)}
+}
+
+// parseFlags parses args into fs, turning mistakes into usage errors.
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return usageError{err.Error()}
+	}
+	return nil
+}
+
+// errPrinted is returned by loadConfig after -print-config printed the
+// configuration; the command has nothing left to do.
+var errPrinted = errors.New("configuration printed")
+
+// loadConfig adds the configuration flags to fs, parses args into it and
+// loads the configuration. It does not validate it, as commands need
+// different parts of it, except when -print-config is given.
+func loadConfig(fs *flag.FlagSet, args []string) (*config.Config, error) {
+	l := config.NewLoader(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return nil, err
+	}
+	cfg, err := l.Load(os.Getenv)
+	if err != nil {
+		return nil, usageError{err.Error()}
+	}
+	if l.Print() {
+		if err := cfg.Write(os.Stdout); err != nil {
+			return nil, err
+		}
+		if err := cfg.Validate(); err != nil {
+			return nil, err
+		}
+		return nil, errPrinted
+	}
+	return cfg, nil
+}
+
+func printUsage() {
+	w := os.Stderr
+	fmt.Fprintf(w, "usage: %s <command> [flags]\n\n", os.Args[0])
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "  %s\n      %s\n", commands[name].usage, commands[name].help)
+	}
+}
@@ -0,0 +1,195 @@
+// Package apierror renders API errors as RFC 9457 problem details:
+//
+//	HTTP/1.1 404 Not Found
+//	Content-Type: application/problem+json
+//
+//	{
+//	  "type": "https://github.com/RemcoVeens/httpserver/blob/main/docs/errors.md#not_found",
+//	  "title": "Not found",
+//	  "status": 404,
+//	  "code": "not_found",
+//	  "detail": "chirp not found",
+//	  "instance": "/api/chirps/..."
+//	}
+//
+// code is stable and is what clients should switch on; detail is for
+// humans. Internal errors are logged and answered with a generic problem,
+// so database errors and the like never reach clients.
+package apierror
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+)
+
+// ContentType is the media type of problem responses.
+const ContentType = "application/problem+json"
+
+// TypeBase prefixes a code to form the problem type URI, which documents it.
+const TypeBase = "https://github.com/RemcoVeens/httpserver/blob/main/docs/errors.md#"
+
+// Code identifies a kind of error. Codes are part of the API: they are
+// never renamed, and each one always comes with the same status.
+type Code string
+
+const (
+	InvalidJSON          Code = "invalid_json"
+	InvalidRequest       Code = "invalid_request"
+	PlanLimit            Code = "plan_limit"
+	Unauthenticated      Code = "unauthenticated"
+	InvalidCredentials   Code = "invalid_credentials"
+	CSRFFailed           Code = "csrf_failed"
+	Forbidden            Code = "forbidden"
+	NotFound             Code = "not_found"
+	MethodNotAllowed     Code = "method_not_allowed"
+	Conflict             Code = "conflict"
+	PayloadTooLarge      Code = "payload_too_large"
+	UnsupportedMediaType Code = "unsupported_media_type"
+	RateLimited          Code = "rate_limited"
+	Internal             Code = "internal"
+	Unavailable          Code = "unavailable"
+)
+
+type kind struct {
+	status int
+	title  string
+}
+
+var kinds = map[Code]kind{
+	InvalidJSON:          {400, "Malformed JSON body"},
+	InvalidRequest:       {400, "Invalid request"},
+	PlanLimit:            {400, "Over the limit of your plan"},
+	Unauthenticated:      {401, "Authentication required"},
+	InvalidCredentials:   {401, "Wrong email or password"},
+	CSRFFailed:           {403, "Missing or invalid CSRF token"},
+	Forbidden:            {403, "Forbidden"},
+	NotFound:             {404, "Not found"},
+	MethodNotAllowed:     {405, "Method not allowed"},
+	Conflict:             {409, "Conflict"},
+	PayloadTooLarge:      {413, "Payload too large"},
+	UnsupportedMediaType: {415, "Unsupported media type"},
+	RateLimited:          {429, "Too many requests"},
+	Internal:             {500, "Internal server error"},
+	Unavailable:          {503, "Service unavailable"},
+}
+
+// Codes lists every code.
+func Codes() []Code {
+	return []Code{
+		InvalidJSON, InvalidRequest, PlanLimit, Unauthenticated,
+		InvalidCredentials, CSRFFailed, Forbidden, NotFound, MethodNotAllowed,
+		Conflict, PayloadTooLarge, UnsupportedMediaType, RateLimited, Internal,
+		Unavailable,
+	}
+}
+
+// Status returns the HTTP status of code; unknown codes are 500.
+func (c Code) Status() int {
+	if k, ok := kinds[c]; ok {
+		return k.status
+	}
+	return 500
+}
+
+// Title returns the summary every problem with code shares.
+func (c Code) Title() string {
+	if k, ok := kinds[c]; ok {
+		return k.title
+	}
+	return kinds[Internal].title
+}
+
+// Problem is the body of an error response.
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Code     Code   `json:"code"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+}
+
+// Error is an error to report to a client. Detail is shown to the client;
+// Err, the cause, is only logged.
+type Error struct {
+	Code   Code
+	Detail string
+	Err    error
+}
+
+// New returns an error with code and a detail for the client.
+func New(code Code, detail string) *Error {
+	return &Error{Code: code, Detail: detail}
+}
+
+// Errorf is New with a formatted detail.
+func Errorf(code Code, format string, args ...any) *Error {
+	return &Error{Code: code, Detail: fmt.Sprintf(format, args...)}
+}
+
+// Wrap returns an error with code and detail caused by err. err is logged
+// for codes with a 5xx status and is never shown to the client.
+func Wrap(code Code, err error, detail string) *Error {
+	return &Error{Code: code, Detail: detail, Err: err}
+}
+
+func (e *Error) Error() string {
+	msg := string(e.Code)
+	if e.Detail != "" {
+		msg += ": " + e.Detail
+	}
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Problem returns the problem e is reported as, for a request to instance.
+func (e *Error) Problem(instance string) Problem {
+	return Problem{
+		Type:     TypeBase + string(e.Code),
+		Title:    e.Code.Title(),
+		Status:   e.Code.Status(),
+		Code:     e.Code,
+		Detail:   e.Detail,
+		Instance: instance,
+	}
+}
+
+// Write responds to r with err. An *Error anywhere in err's chain is
+// reported as is; anything else is an internal error. Errors with a 5xx
+// status are logged with their cause.
+func Write(w http.ResponseWriter, r *http.Request, err error) {
+	var e *Error
+	if !errors.As(err, &e) {
+		e = &Error{Code: Internal, Err: err}
+	}
+	if e.Code.Status() >= 500 {
+		log.Printf("%s %s: %s", r.Method, r.URL.Path, err)
+	}
+	// A Problem only holds strings and an int, so it always marshals.
+	dat, _ := json.Marshal(e.Problem(r.URL.Path))
+	w.Header().Set("Content-Type", ContentType)
+	w.Header().Del("Content-Length")
+	w.WriteHeader(e.Code.Status())
+	w.Write(dat)
+}
+
+// Parse decodes a problem response body.
+func Parse(body []byte) (Problem, error) {
+	var p Problem
+	if err := json.Unmarshal(body, &p); err != nil {
+		return Problem{}, fmt.Errorf("could not parse problem: %w", err)
+	}
+	if p.Code == "" || p.Status == 0 {
+		return Problem{}, errors.New("not a problem response")
+	}
+	return p, nil
+}
@@ -0,0 +1,98 @@
+package apierror_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/RemcoVeens/httpserver/internal/apierror"
+)
+
+func TestWrite(t *testing.T) {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/api/chirps/123", nil)
+	err := fmt.Errorf("looking up chirp: %w", apierror.New(apierror.NotFound, "chirp not found"))
+	apierror.Write(rec, req, err)
+
+	if rec.Code != 404 {
+		t.Errorf("status %d, want 404", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != apierror.ContentType {
+		t.Errorf("Content-Type %q, want %q", ct, apierror.ContentType)
+	}
+	p, err := apierror.Parse(rec.Body.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := apierror.Problem{
+		Type:     apierror.TypeBase + "not_found",
+		Title:    "Not found",
+		Status:   404,
+		Code:     apierror.NotFound,
+		Detail:   "chirp not found",
+		Instance: "/api/chirps/123",
+	}
+	if p != want {
+		t.Errorf("problem %+v, want %+v", p, want)
+	}
+}
+
+func TestWriteHidesInternalErrors(t *testing.T) {
+	for _, err := range []error{
+		errors.New("pq: password authentication failed for user chirpy"),
+		apierror.Wrap(apierror.Internal, errors.New("pq: password authentication failed for user chirpy"), ""),
+	} {
+		rec := httptest.NewRecorder()
+		apierror.Write(rec, httptest.NewRequest("POST", "/api/chirps", nil), err)
+		if rec.Code != 500 {
+			t.Errorf("%v: status %d, want 500", err, rec.Code)
+		}
+		if strings.Contains(rec.Body.String(), "pq:") {
+			t.Errorf("%v: cause leaked: %s", err, rec.Body)
+		}
+		p, perr := apierror.Parse(rec.Body.Bytes())
+		if perr != nil || p.Code != apierror.Internal {
+			t.Errorf("%v: got %+v, %v", err, p, perr)
+		}
+	}
+}
+
+func TestCodes(t *testing.T) {
+	seen := map[apierror.Code]bool{}
+	for _, c := range apierror.Codes() {
+		if seen[c] {
+			t.Errorf("%s listed twice", c)
+		}
+		seen[c] = true
+		if s := c.Status(); s < 400 || s > 599 {
+			t.Errorf("%s: status %d is not an error", c, s)
+		}
+		if c.Title() == "" {
+			t.Errorf("%s has no title", c)
+		}
+	}
+	if s := apierror.Code("nonsense").Status(); s != 500 {
+		t.Errorf("unknown code: status %d, want 500", s)
+	}
+}
+
+func TestErrorUnwraps(t *testing.T) {
+	cause := errors.New("boom")
+	err := apierror.Wrap(apierror.Unavailable, cause, "try again later")
+	if !errors.Is(err, cause) {
+		t.Errorf("%v does not unwrap to its cause", err)
+	}
+	if got := err.Error(); got != "unavailable: try again later: boom" {
+		t.Errorf("Error() = %q", got)
+	}
+}
+
+func TestParseRejectsOtherBodies(t *testing.T) {
+	for _, body := range []string{"", "not json", `{"error":"nope"}`} {
+		if _, err := apierror.Parse([]byte(body)); err == nil {
+			t.Errorf("Parse(%q) succeeded", body)
+		}
+	}
+}
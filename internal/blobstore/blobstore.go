@@ -0,0 +1,43 @@
+// Package blobstore stores opaque blobs, such as uploaded media, by key.
+//
+// Keys are slash-separated paths like "media/<id>". Local keeps blobs in a
+// directory; S3 keeps them in a bucket of any S3-compatible service, such
+// as AWS S3 or MinIO.
+package blobstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ErrNotFound is returned by Get for keys that hold no blob.
+var ErrNotFound = errors.New("blob not found")
+
+// Store is where blobs live.
+type Store interface {
+	// Put stores size bytes read from r under key, replacing any blob
+	// already there.
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Get opens the blob under key. The caller closes it.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes the blob under key. Deleting a missing blob is not an
+	// error.
+	Delete(ctx context.Context, key string) error
+}
+
+// ValidateKey rejects keys that are empty, absolute or not clean, so a key
+// always names the same blob in every Store.
+func ValidateKey(key string) error {
+	if key == "" || strings.HasPrefix(key, "/") || strings.HasSuffix(key, "/") {
+		return fmt.Errorf("invalid blob key %q", key)
+	}
+	for seg := range strings.SplitSeq(key, "/") {
+		if seg == "" || seg == "." || seg == ".." || strings.ContainsAny(seg, "\\\x00") {
+			return fmt.Errorf("invalid blob key %q", key)
+		}
+	}
+	return nil
+}
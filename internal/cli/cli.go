@@ -0,0 +1,204 @@
+// Package cli implements chirpy, the command-line client of the API:
+//
+//	chirpy login [-email address]
+//	chirpy logout
+//	chirpy whoami
+//	chirpy post [-reply-to id] text...
+//	chirpy delete id...
+//	chirpy timeline [-n count] [-author handle] [-tag hashtag] [-follow]
+//	chirpy export [-file path]
+//
+// Every command takes -server and -o (table, json or plain). Tokens are
+// kept in the user config directory and renewed as they expire. The exit
+// status tells scripts what went wrong; see the Exit constants.
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/RemcoVeens/httpserver/pkg/chirpyclient"
+)
+
+// Exit statuses. They are part of the interface: scripts depend on them.
+const (
+	ExitOK = 0
+	// ExitError is anything not covered below, such as a failed write.
+	ExitError = 1
+	// ExitUsage is a wrong command, flag or argument.
+	ExitUsage = 2
+	// ExitAuth means not being logged in, a session that ended, or wrong
+	// credentials.
+	ExitAuth = 3
+	// ExitNotFound means the chirp or user does not exist.
+	ExitNotFound = 4
+	// ExitRejected means the server refused the request, for instance a
+	// chirp over the plan's length or a permission it needs.
+	ExitRejected = 5
+	// ExitUnavailable means the server could not be reached, failed or
+	// kept rate limiting the requests.
+	ExitUnavailable = 6
+)
+
+// DefaultServer is used when neither -server, CHIRPY_SERVER nor a login
+// says where the server is.
+const DefaultServer = "http://localhost:8080"
+
+// Env is what a command runs with.
+type Env struct {
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+	// Getenv reads the environment: CHIRPY_SERVER and CHIRPY_CONFIG_DIR,
+	// which overrides where the credentials are kept.
+	Getenv func(string) string
+}
+
+// command is a subcommand. run returns an error for the exit status to be
+// derived from.
+type command struct {
+	usage string
+	help  string
+	run   func(ctx context.Context, s *session, args []string) error
+}
+
+var commands = map[string]command{
+	"login":    {"login [-email address]", "Log in; the password is read from the terminal or stdin.", runLogin},
+	"logout":   {"logout", "Revoke the stored tokens and forget them.", runLogout},
+	"whoami":   {"whoami", "Show the logged in account.", runWhoami},
+	"post":     {"post [-reply-to id] text...", "Post a chirp; with text - it is read from stdin.", runPost},
+	"delete":   {"delete id...", "Delete chirps of yours.", runDelete},
+	"timeline": {"timeline [-n count] [-author handle] [-tag hashtag] [-follow]", "Show the latest chirps, oldest first; -follow keeps printing new ones.", runTimeline},
+	"export":   {"export [-file path]", "Write your account, chirps and webhooks as JSON.", runExport},
+}
+
+// usageError is a mistake in the command line.
+type usageError struct{ msg string }
+
+func (e usageError) Error() string { return e.msg }
+
+func usagef(format string, args ...any) error {
+	return usageError{fmt.Sprintf(format, args...)}
+}
+
+// Run runs the command line args, without the program name, and returns the
+// exit status.
+func Run(ctx context.Context, args []string, env Env) int {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		printUsage(env.Stderr)
+		if len(args) == 0 {
+			return ExitUsage
+		}
+		return ExitOK
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(env.Stderr, "chirpy: unknown command %q\n", args[0])
+		printUsage(env.Stderr)
+		return ExitUsage
+	}
+	s := &session{env: env, usage: cmd.usage}
+	err := cmd.run(ctx, s, args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return ExitOK
+	}
+	if err != nil {
+		fmt.Fprintf(env.Stderr, "chirpy %s: %s\n", args[0], err)
+	}
+	return exitStatus(err)
+}
+
+func printUsage(w io.Writer) {
+	fmt.Fprintln(w, "usage: chirpy <command> [-server url] [-o table|json|plain] [flags]")
+	fmt.Fprintln(w)
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(w, "  %s\n      %s\n", commands[name].usage, commands[name].help)
+	}
+}
+
+// exitStatus maps an error to the exit status scripts can rely on.
+func exitStatus(err error) int {
+	var apiErr *chirpyclient.Error
+	var netErr net.Error
+	switch {
+	case err == nil, errors.Is(err, context.Canceled):
+		return ExitOK
+	case errors.As(err, new(usageError)):
+		return ExitUsage
+	case errors.Is(err, errNotLoggedIn):
+		return ExitAuth
+	case errors.As(err, &apiErr):
+		switch {
+		case apiErr.Code == chirpyclient.Unauthenticated, apiErr.Code == chirpyclient.InvalidCredentials:
+			return ExitAuth
+		case apiErr.Code == chirpyclient.NotFound:
+			return ExitNotFound
+		case apiErr.Code == chirpyclient.RateLimited, apiErr.StatusCode >= 500:
+			return ExitUnavailable
+		}
+		return ExitRejected
+	case errors.As(err, &netErr):
+		return ExitUnavailable
+	}
+	return ExitError
+}
+
+// flags returns the flag set of a command, with the flags every command
+// takes.
+func (s *session) flags(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet("chirpy "+name, flag.ContinueOnError)
+	fs.SetOutput(s.env.Stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(s.env.Stderr, "usage: chirpy %s\n", s.usage)
+		fs.PrintDefaults()
+	}
+	fs.StringVar(&s.server, "server", "", "server `url` (default $CHIRPY_SERVER, the one logged in to, or "+DefaultServer+")")
+	fs.StringVar(&s.format, "o", "table", "output `format`: table, json or plain")
+	return fs
+}
+
+// parse parses args into fs and checks the flags every command takes.
+func (s *session) parse(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return err
+		}
+		return usageError{err.Error()}
+	}
+	switch s.format {
+	case "table", "json", "plain":
+	default:
+		return usagef("unknown output format %q", s.format)
+	}
+	return nil
+}
+
+// configDir is where the credentials are kept.
+func (s *session) configDir() (string, error) {
+	if dir := s.env.Getenv("CHIRPY_CONFIG_DIR"); dir != "" {
+		return dir, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "chirpy"), nil
+}
+
+// joinArgs joins the words of a chirp given as arguments.
+func joinArgs(args []string) string {
+	return strings.Join(args, " ")
+}
@@ -0,0 +1,137 @@
+package cli_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/RemcoVeens/httpserver/internal/apierror"
+	"github.com/RemcoVeens/httpserver/internal/cli"
+)
+
+// fakeAPI answers the requests the tests make, with one account.
+func fakeAPI(t *testing.T) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	writeJSON := func(w http.ResponseWriter, v any) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(v)
+	}
+	user := map[string]any{"id": "8e2f5f2a-5b8e-4b8e-9d5e-1b2c3d4e5f60", "email": "ann@example.com", "handle": "ann"}
+	mux.HandleFunc("POST /api/login", func(w http.ResponseWriter, r *http.Request) {
+		var body struct{ Email, Password string }
+		json.NewDecoder(r.Body).Decode(&body)
+		if body.Email != "ann@example.com" || body.Password != "hunter2" {
+			apierror.Write(w, r, apierror.New(apierror.InvalidCredentials, "wrong email or password"))
+			return
+		}
+		writeJSON(w, map[string]any{"id": user["id"], "email": user["email"], "handle": user["handle"], "token": "access", "refresh_token": "refresh"})
+	})
+	mux.HandleFunc("GET /api/users/me", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer access" {
+			apierror.Write(w, r, apierror.New(apierror.Unauthenticated, "log in"))
+			return
+		}
+		writeJSON(w, user)
+	})
+	mux.HandleFunc("DELETE /api/chirps/{id}", func(w http.ResponseWriter, r *http.Request) {
+		apierror.Write(w, r, apierror.New(apierror.NotFound, "no such chirp"))
+	})
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+// run runs chirpy with args against srv, keeping credentials in dir.
+func run(t *testing.T, srv *httptest.Server, dir, stdin string, args ...string) (code int, stdout, stderr string) {
+	t.Helper()
+	var out, errOut bytes.Buffer
+	env := cli.Env{
+		Stdin:  strings.NewReader(stdin),
+		Stdout: &out,
+		Stderr: &errOut,
+		Getenv: func(key string) string {
+			switch key {
+			case "CHIRPY_SERVER":
+				return srv.URL
+			case "CHIRPY_CONFIG_DIR":
+				return dir
+			}
+			return ""
+		},
+	}
+	code = cli.Run(context.Background(), args, env)
+	return code, out.String(), errOut.String()
+}
+
+func TestLogin(t *testing.T) {
+	srv := fakeAPI(t)
+	dir := t.TempDir()
+
+	if code, _, _ := run(t, srv, dir, "", "whoami"); code != cli.ExitAuth {
+		t.Errorf("whoami before login exited %d, want %d", code, cli.ExitAuth)
+	}
+	if code, _, stderr := run(t, srv, dir, "ann@example.com\nwrong\n", "login"); code != cli.ExitAuth {
+		t.Errorf("login with a wrong password exited %d, want %d: %s", code, cli.ExitAuth, stderr)
+	}
+	if code, _, stderr := run(t, srv, dir, "hunter2\n", "login", "-email", "ann@example.com"); code != cli.ExitOK {
+		t.Fatalf("login exited %d: %s", code, stderr)
+	}
+	info, err := os.Stat(filepath.Join(dir, "credentials.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0o600 {
+		t.Errorf("credentials are readable by others: %v", perm)
+	}
+
+	code, stdout, stderr := run(t, srv, dir, "", "whoami", "-o", "json")
+	if code != cli.ExitOK {
+		t.Fatalf("whoami exited %d: %s", code, stderr)
+	}
+	var user struct{ Handle string }
+	if err := json.Unmarshal([]byte(stdout), &user); err != nil || user.Handle != "ann" {
+		t.Errorf("whoami printed %q", stdout)
+	}
+	code, stdout, _ = run(t, srv, dir, "", "whoami", "-o", "plain")
+	if code != cli.ExitOK || !strings.HasPrefix(stdout, "8e2f5f2a-5b8e-4b8e-9d5e-1b2c3d4e5f60\tann\tann@example.com\t") {
+		t.Errorf("whoami -o plain printed %q", stdout)
+	}
+}
+
+func TestExitStatus(t *testing.T) {
+	srv := fakeAPI(t)
+	dir := t.TempDir()
+	if code, _, stderr := run(t, srv, dir, "hunter2\n", "login", "-email", "ann@example.com"); code != cli.ExitOK {
+		t.Fatalf("login exited %d: %s", code, stderr)
+	}
+	for _, tt := range []struct {
+		args []string
+		want int
+	}{
+		{[]string{"delete", "00000000-0000-0000-0000-000000000001"}, cli.ExitNotFound},
+		{[]string{"delete", "not-an-id"}, cli.ExitUsage},
+		{[]string{"whoami", "-bogus"}, cli.ExitUsage},
+		{[]string{"whoami", "-o", "yaml"}, cli.ExitUsage},
+		{[]string{"frobnicate"}, cli.ExitUsage},
+		{[]string{"post"}, cli.ExitUsage},
+		{[]string{"whoami", "-server", "http://127.0.0.1:1"}, cli.ExitAuth},
+	} {
+		if code, _, stderr := run(t, srv, dir, "", tt.args...); code != tt.want {
+			t.Errorf("%v exited %d, want %d: %s", tt.args, code, tt.want, stderr)
+		}
+	}
+
+	// A server that cannot be reached.
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+	if code, _, stderr := run(t, down, t.TempDir(), "", "timeline"); code != cli.ExitUnavailable {
+		t.Errorf("timeline of a server that is down exited %d, want %d: %s", code, cli.ExitUnavailable, stderr)
+	}
+}
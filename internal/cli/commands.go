@@ -0,0 +1,323 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/RemcoVeens/httpserver/pkg/chirpyclient"
+	"github.com/google/uuid"
+	"golang.org/x/term"
+)
+
+func runLogin(ctx context.Context, s *session, args []string) error {
+	fs := s.flags("login")
+	email := fs.String("email", "", "email `address`; asked for when not given")
+	if err := s.parse(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return usagef("unexpected argument %q", fs.Arg(0))
+	}
+	in := bufio.NewReader(s.env.Stdin)
+	if *email == "" {
+		fmt.Fprint(s.env.Stderr, "Email: ")
+		line, err := readLine(in)
+		if err != nil {
+			return err
+		}
+		*email = line
+	}
+	password, err := s.readPassword(in)
+	if err != nil {
+		return err
+	}
+
+	c, err := s.client(false)
+	if err != nil {
+		return err
+	}
+	user, err := c.Login(ctx, *email, password)
+	if err != nil {
+		return err
+	}
+	tokens := c.Tokens()
+	s.creds = credentials{
+		Server:       c.BaseURL,
+		Email:        user.Email,
+		AccessToken:  tokens.Access,
+		RefreshToken: tokens.Refresh,
+	}
+	if err := s.save(); err != nil {
+		return err
+	}
+	fmt.Fprintf(s.env.Stderr, "Logged in to %s as @%s.\n", c.BaseURL, user.Handle)
+	return nil
+}
+
+// readPassword reads the password without echoing it from a terminal, or
+// as the next line of stdin when it is piped in.
+func (s *session) readPassword(in *bufio.Reader) (string, error) {
+	if f, ok := s.env.Stdin.(*os.File); ok && term.IsTerminal(int(f.Fd())) {
+		fmt.Fprint(s.env.Stderr, "Password: ")
+		password, err := term.ReadPassword(int(f.Fd()))
+		fmt.Fprintln(s.env.Stderr)
+		if err != nil {
+			return "", fmt.Errorf("could not read the password: %w", err)
+		}
+		return string(password), nil
+	}
+	return readLine(in)
+}
+
+func readLine(in *bufio.Reader) (string, error) {
+	line, err := in.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", usagef("expected a line on stdin: %v", err)
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+func runLogout(ctx context.Context, s *session, args []string) error {
+	fs := s.flags("logout")
+	if err := s.parse(fs, args); err != nil {
+		return err
+	}
+	c, err := s.client(true)
+	if errors.Is(err, errNotLoggedIn) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	// A token the server no longer knows needs no revoking.
+	if err := c.Revoke(ctx); err != nil && !errors.Is(err, chirpyclient.Unauthenticated) {
+		return err
+	}
+	return s.forget()
+}
+
+func runWhoami(ctx context.Context, s *session, args []string) error {
+	fs := s.flags("whoami")
+	if err := s.parse(fs, args); err != nil {
+		return err
+	}
+	c, err := s.client(true)
+	if err != nil {
+		return err
+	}
+	user, err := c.Me(ctx)
+	if err != nil {
+		return err
+	}
+	return s.printUser(user)
+}
+
+func runPost(ctx context.Context, s *session, args []string) error {
+	fs := s.flags("post")
+	replyTo := fs.String("reply-to", "", "`id` of the chirp to reply to")
+	if err := s.parse(fs, args); err != nil {
+		return err
+	}
+	params := chirpyclient.CreateChirpParams{Body: joinArgs(fs.Args())}
+	if params.Body == "-" {
+		data, err := io.ReadAll(s.env.Stdin)
+		if err != nil {
+			return fmt.Errorf("could not read stdin: %w", err)
+		}
+		params.Body = strings.TrimRight(string(data), "\r\n")
+	}
+	if strings.TrimSpace(params.Body) == "" {
+		return usagef("nothing to post")
+	}
+	if *replyTo != "" {
+		id, err := uuid.Parse(*replyTo)
+		if err != nil {
+			return usagef("invalid chirp id %q", *replyTo)
+		}
+		params.ReplyTo = uuid.NullUUID{UUID: id, Valid: true}
+	}
+	c, err := s.client(true)
+	if err != nil {
+		return err
+	}
+	chirp, err := c.CreateChirp(ctx, params)
+	if err != nil {
+		return err
+	}
+	return s.printChirps([]chirpyclient.Chirp{chirp})
+}
+
+func runDelete(ctx context.Context, s *session, args []string) error {
+	fs := s.flags("delete")
+	if err := s.parse(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return usagef("no chirp ids given")
+	}
+	ids := make([]uuid.UUID, fs.NArg())
+	for i, arg := range fs.Args() {
+		id, err := uuid.Parse(arg)
+		if err != nil {
+			return usagef("invalid chirp id %q", arg)
+		}
+		ids[i] = id
+	}
+	c, err := s.client(true)
+	if err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if err := c.DeleteChirp(ctx, id); err != nil {
+			return fmt.Errorf("%s: %w", id, err)
+		}
+	}
+	return nil
+}
+
+func runTimeline(ctx context.Context, s *session, args []string) error {
+	fs := s.flags("timeline")
+	n := fs.Int("n", 20, "number of chirps to show")
+	author := fs.String("author", "", "only chirps of the user with `handle`")
+	tag := fs.String("tag", "", "only chirps with `hashtag`")
+	follow := fs.Bool("follow", false, "keep printing new chirps until interrupted")
+	if err := s.parse(fs, args); err != nil {
+		return err
+	}
+	switch {
+	case fs.NArg() > 0:
+		return usagef("unexpected argument %q", fs.Arg(0))
+	case *n < 0:
+		return usagef("-n must not be negative")
+	case *author != "" && *tag != "":
+		return usagef("-author and -tag cannot be combined")
+	}
+	*author = strings.TrimPrefix(*author, "@")
+	*tag = strings.TrimPrefix(*tag, "#")
+
+	// The timeline can be read without logging in, but then blocks and
+	// mutes are not applied.
+	c, err := s.client(false)
+	if err != nil {
+		return err
+	}
+	var stream chirpyclient.StreamParams
+	var chirps []chirpyclient.Chirp
+	switch {
+	case *author != "":
+		profile, err := c.GetProfile(ctx, *author)
+		if err != nil {
+			return err
+		}
+		stream.AuthorID = profile.ID
+		chirps, err = c.ListChirps(ctx, chirpyclient.ListChirpsParams{AuthorID: profile.ID, Newest: true})
+		if err != nil {
+			return err
+		}
+	case *tag != "":
+		stream.Hashtags = []string{*tag}
+		chirps, err = c.HashtagChirps(ctx, *tag)
+	default:
+		chirps, err = c.ListChirps(ctx, chirpyclient.ListChirpsParams{Newest: true})
+	}
+	if err != nil {
+		return err
+	}
+	// Both lists come newest first; the latest n are shown oldest first.
+	chirps = chirps[:min(*n, len(chirps))]
+	slices.Reverse(chirps)
+	if !*follow {
+		return s.printChirps(chirps)
+	}
+
+	// Following prints a chirp per line as it comes in, as JSON lines with
+	// -o json.
+	p := s.printer("ID", "AUTHOR", "POSTED", "BODY")
+	enc := json.NewEncoder(s.env.Stdout)
+	show := func(chirp chirpyclient.Chirp) error {
+		if s.format == "json" {
+			return enc.Encode(chirp)
+		}
+		p.row(chirpRow(chirp)...)
+		return p.flush()
+	}
+	for _, chirp := range chirps {
+		if err := show(chirp); err != nil {
+			return err
+		}
+	}
+	for e, err := range c.StreamChirps(ctx, stream) {
+		if err != nil {
+			return err
+		}
+		switch e.Type {
+		case chirpyclient.ChirpCreated:
+			if err := show(e.Chirp); err != nil {
+				return err
+			}
+		case chirpyclient.StreamReset:
+			fmt.Fprintln(s.env.Stderr, "chirpy: fell behind the stream; some chirps were skipped")
+		}
+	}
+	return nil
+}
+
+// export is what runExport writes.
+type export struct {
+	ExportedAt time.Time              `json:"exported_at"`
+	Server     string                 `json:"server"`
+	User       chirpyclient.User      `json:"user"`
+	Chirps     []chirpyclient.Chirp   `json:"chirps"`
+	Webhooks   []chirpyclient.Webhook `json:"webhooks"`
+}
+
+func runExport(ctx context.Context, s *session, args []string) error {
+	fs := s.flags("export")
+	file := fs.String("file", "", "write to `path` instead of stdout")
+	if err := s.parse(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		return usagef("unexpected argument %q", fs.Arg(0))
+	}
+	c, err := s.client(true)
+	if err != nil {
+		return err
+	}
+	out := export{ExportedAt: time.Now().UTC(), Server: c.BaseURL}
+	if out.User, err = c.Me(ctx); err != nil {
+		return err
+	}
+	if out.Chirps, err = c.ListChirps(ctx, chirpyclient.ListChirpsParams{AuthorID: out.User.ID}); err != nil {
+		return err
+	}
+	if out.Webhooks, err = c.ListWebhooks(ctx); err != nil {
+		return err
+	}
+	if out.Chirps == nil {
+		out.Chirps = []chirpyclient.Chirp{}
+	}
+	if out.Webhooks == nil {
+		out.Webhooks = []chirpyclient.Webhook{}
+	}
+	// An export is JSON whatever -o says.
+	if *file == "" {
+		return s.printJSON(out)
+	}
+	data, err := json.MarshalIndent(out, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(*file, append(data, '\n'), 0o600); err != nil {
+		return fmt.Errorf("could not write the export: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,96 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/RemcoVeens/httpserver/pkg/chirpyclient"
+)
+
+// printer writes rows in the output format: aligned columns under a
+// header for table, tab-separated fields for plain. JSON is written with
+// printJSON instead.
+type printer struct {
+	w      io.Writer
+	tw     *tabwriter.Writer
+	header []string
+}
+
+func (s *session) printer(header ...string) *printer {
+	p := &printer{w: s.env.Stdout}
+	if s.format == "table" {
+		p.tw = tabwriter.NewWriter(s.env.Stdout, 0, 4, 2, ' ', 0)
+		p.header = header
+	}
+	return p
+}
+
+func (p *printer) row(fields ...string) {
+	for i, f := range fields {
+		fields[i] = oneLine(f)
+	}
+	if p.tw == nil {
+		fmt.Fprintln(p.w, strings.Join(fields, "\t"))
+		return
+	}
+	if p.header != nil {
+		fmt.Fprintln(p.tw, strings.Join(p.header, "\t"))
+		p.header = nil
+	}
+	fmt.Fprintln(p.tw, strings.Join(fields, "\t"))
+}
+
+func (p *printer) flush() error {
+	if p.tw == nil {
+		return nil
+	}
+	return p.tw.Flush()
+}
+
+// oneLine keeps a field from breaking the row it is in.
+func oneLine(s string) string {
+	return strings.NewReplacer("\r\n", " ", "\n", " ", "\t", " ").Replace(s)
+}
+
+func (s *session) printJSON(v any) error {
+	enc := json.NewEncoder(s.env.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func (s *session) printUser(u chirpyclient.User) error {
+	if s.format == "json" {
+		return s.printJSON(u)
+	}
+	p := s.printer("ID", "HANDLE", "EMAIL", "CHIRPY RED", "JOINED")
+	p.row(u.ID.String(), u.Handle, u.Email, fmt.Sprint(u.IsChirpyRed), u.CreatedAt.Format(time.RFC3339))
+	return p.flush()
+}
+
+func (s *session) printChirps(chirps []chirpyclient.Chirp) error {
+	if s.format == "json" {
+		if chirps == nil {
+			chirps = []chirpyclient.Chirp{}
+		}
+		return s.printJSON(chirps)
+	}
+	p := s.printer("ID", "AUTHOR", "POSTED", "BODY")
+	for _, c := range chirps {
+		p.row(chirpRow(c)...)
+	}
+	return p.flush()
+}
+
+func chirpRow(c chirpyclient.Chirp) []string {
+	author := c.Author.Handle
+	if author == "" {
+		author = c.UserID.String()
+	} else {
+		author = "@" + author
+	}
+	return []string{c.ID.String(), author, c.CreatedAt.Local().Format(time.DateTime), c.Body}
+}
@@ -0,0 +1,140 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/RemcoVeens/httpserver/pkg/chirpyclient"
+)
+
+var errNotLoggedIn = errors.New("not logged in; run chirpy login")
+
+// credentialsFile is the name of the file the tokens are kept in, in the
+// config directory.
+const credentialsFile = "credentials.json"
+
+// credentials are what login stores.
+type credentials struct {
+	Server       string `json:"server"`
+	Email        string `json:"email"`
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
+// session is the state of one command.
+type session struct {
+	env Env
+	// usage is the synopsis of the command.
+	usage string
+	// server and format are set by the flags every command takes.
+	server string
+	format string
+
+	creds credentials
+}
+
+func (s *session) credentialsPath() (string, error) {
+	dir, err := s.configDir()
+	if err != nil {
+		return "", fmt.Errorf("could not find the config directory: %w", err)
+	}
+	return filepath.Join(dir, credentialsFile), nil
+}
+
+// load reads the stored credentials, if any.
+func (s *session) load() error {
+	path, err := s.credentialsPath()
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("could not read credentials: %w", err)
+	}
+	if err := json.Unmarshal(data, &s.creds); err != nil {
+		return fmt.Errorf("could not parse %s: %w", path, err)
+	}
+	return nil
+}
+
+// save writes the credentials where only the user can read them.
+func (s *session) save() error {
+	path, err := s.credentialsPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("could not create the config directory: %w", err)
+	}
+	data, err := json.MarshalIndent(s.creds, "", "  ")
+	if err != nil {
+		return err
+	}
+	// Write and rename, so an interrupted write never loses the tokens.
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0o600); err != nil {
+		return fmt.Errorf("could not save credentials: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("could not save credentials: %w", err)
+	}
+	return nil
+}
+
+// forget removes the stored credentials.
+func (s *session) forget() error {
+	path, err := s.credentialsPath()
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("could not remove credentials: %w", err)
+	}
+	s.creds = credentials{}
+	return nil
+}
+
+// serverURL picks the server: -server, then CHIRPY_SERVER, then the one
+// logged in to.
+func (s *session) serverURL() string {
+	switch {
+	case s.server != "":
+		return s.server
+	case s.env.Getenv("CHIRPY_SERVER") != "":
+		return s.env.Getenv("CHIRPY_SERVER")
+	case s.creds.Server != "":
+		return s.creds.Server
+	}
+	return DefaultServer
+}
+
+// client returns a client of the server carrying the stored tokens, if
+// there are any for it, and saving them again whenever they are renewed.
+// With required it fails without them.
+func (s *session) client(required bool) (*chirpyclient.Client, error) {
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	c := chirpyclient.New(s.serverURL())
+	if s.creds.RefreshToken == "" || s.creds.Server != c.BaseURL {
+		if required {
+			return nil, errNotLoggedIn
+		}
+		return c, nil
+	}
+	c.SetTokens(chirpyclient.Tokens{Access: s.creds.AccessToken, Refresh: s.creds.RefreshToken})
+	c.OnTokens = func(t chirpyclient.Tokens) {
+		s.creds.AccessToken, s.creds.RefreshToken = t.Access, t.Refresh
+		if err := s.save(); err != nil {
+			fmt.Fprintf(s.env.Stderr, "chirpy: %s\n", err)
+		}
+	}
+	return c, nil
+}
@@ -0,0 +1,248 @@
+// Package config holds the configuration of the server.
+//
+// Every setting can come from a YAML or TOML file, the environment or a
+// flag. Later sources win: defaults, then the file, then the environment,
+// then flags. The file is named by -config or $CHIRPY_CONFIG; its format
+// follows its extension. Keys in the file are the yaml and toml tags of
+// Config, nested in sections; flags are the keys joined by dashes, such as
+// -http-read-timeout, unless a flag tag names them.
+//
+// Validate reports every invalid setting at once, naming the file key and
+// the environment variable to fix.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// MinSecretLength is the shortest SECRET accepted; JWTs are signed with
+// it.
+const MinSecretLength = 32
+
+// Config is the configuration of the server.
+type Config struct {
+	// Platform is "dev" while developing: it enables the reset endpoint,
+	// serves the web app from disk and checks responses against the API
+	// description.
+	Platform         string   `yaml:"platform" toml:"platform" env:"PLATFORM" help:"dev or prod"`
+	Listen           string   `yaml:"listen" toml:"listen" env:"LISTEN_ADDR" help:"address to listen on"`
+	Secret           string   `yaml:"secret" toml:"secret" env:"SECRET" secret:"true" help:"key JWTs are signed with"`
+	EntitlementsFile string   `yaml:"entitlements_file" toml:"entitlements_file" env:"ENTITLEMENTS_FILE" help:"JSON file with the limits of each plan"`
+	MigrateOnStart   bool     `yaml:"migrate_on_start" toml:"migrate_on_start" env:"MIGRATE_ON_START" flag:"migrate" help:"apply pending migrations before serving"`
+	Database         Database `yaml:"database" toml:"database"`
+	HTTP             HTTP     `yaml:"http" toml:"http"`
+	Tokens           Tokens   `yaml:"tokens" toml:"tokens"`
+	Media            Media    `yaml:"media" toml:"media"`
+	Polka            Polka    `yaml:"polka" toml:"polka"`
+}
+
+// Database configures the connection pool.
+type Database struct {
+	URL             string        `yaml:"url" toml:"url" env:"DB_URL" secret:"url" help:"Postgres URL or connection string"`
+	MaxOpenConns    int           `yaml:"max_open_conns" toml:"max_open_conns" env:"DB_MAX_OPEN_CONNS" help:"most connections open at once, 0 for no limit"`
+	MaxIdleConns    int           `yaml:"max_idle_conns" toml:"max_idle_conns" env:"DB_MAX_IDLE_CONNS" help:"most idle connections kept"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime" toml:"conn_max_lifetime" env:"DB_CONN_MAX_LIFETIME" help:"how long a connection is reused, 0 for ever"`
+	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time" toml:"conn_max_idle_time" env:"DB_CONN_MAX_IDLE_TIME" help:"how long a connection may stay idle, 0 for ever"`
+}
+
+// HTTP bounds how long the server waits on clients. Zero means no limit,
+// except for ShutdownTimeout, where it means not to wait.
+type HTTP struct {
+	ReadTimeout       time.Duration `yaml:"read_timeout" toml:"read_timeout" env:"HTTP_READ_TIMEOUT" help:"time to read a whole request"`
+	ReadHeaderTimeout time.Duration `yaml:"read_header_timeout" toml:"read_header_timeout" env:"HTTP_READ_HEADER_TIMEOUT" help:"time to read request headers"`
+	WriteTimeout      time.Duration `yaml:"write_timeout" toml:"write_timeout" env:"HTTP_WRITE_TIMEOUT" help:"time to write a response; streams extend their own"`
+	IdleTimeout       time.Duration `yaml:"idle_timeout" toml:"idle_timeout" env:"HTTP_IDLE_TIMEOUT" help:"how long an idle keep-alive connection stays open"`
+	// ShutdownTimeout bounds how long stopping waits for requests, streams
+	// and background work to finish.
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" toml:"shutdown_timeout" env:"HTTP_SHUTDOWN_TIMEOUT" help:"time to finish requests and background work when stopping"`
+}
+
+// Tokens sets how long tokens are valid.
+type Tokens struct {
+	AccessTTL  time.Duration `yaml:"access_ttl" toml:"access_ttl" env:"ACCESS_TOKEN_TTL" help:"lifetime of access tokens"`
+	RefreshTTL time.Duration `yaml:"refresh_ttl" toml:"refresh_ttl" env:"REFRESH_TOKEN_TTL" help:"lifetime of refresh tokens and browser sessions"`
+}
+
+// Media configures where uploads are stored and how they are processed.
+type Media struct {
+	Store   string `yaml:"store" toml:"store" env:"BLOB_STORE" help:"local or s3"`
+	Dir     string `yaml:"dir" toml:"dir" env:"MEDIA_DIR" help:"directory of the local store"`
+	Workers int    `yaml:"workers" toml:"workers" env:"MEDIA_WORKERS" help:"uploads processed at once"`
+	S3      S3     `yaml:"s3" toml:"s3"`
+}
+
+// S3 is the bucket of the s3 store.
+type S3 struct {
+	Endpoint  string `yaml:"endpoint" toml:"endpoint" env:"S3_ENDPOINT" help:"S3 endpoint URL"`
+	Region    string `yaml:"region" toml:"region" env:"S3_REGION" help:"S3 region"`
+	Bucket    string `yaml:"bucket" toml:"bucket" env:"S3_BUCKET" help:"S3 bucket"`
+	AccessKey string `yaml:"access_key" toml:"access_key" env:"S3_ACCESS_KEY" help:"S3 access key"`
+	SecretKey string `yaml:"secret_key" toml:"secret_key" env:"S3_SECRET_KEY" secret:"true" help:"S3 secret key"`
+}
+
+// Polka holds the credentials of the payment provider's webhooks.
+type Polka struct {
+	Key    string `yaml:"key" toml:"key" env:"POLKA_KEY" secret:"true" help:"API key Polka sends"`
+	Secret string `yaml:"secret" toml:"secret" env:"POLKA_SECRET" secret:"true" help:"key Polka signs webhooks with"`
+}
+
+// Default returns the configuration before any source is applied.
+func Default() Config {
+	return Config{
+		Platform: "prod",
+		Listen:   ":8080",
+		Database: Database{
+			MaxOpenConns:    25,
+			MaxIdleConns:    10,
+			ConnMaxLifetime: 30 * time.Minute,
+			ConnMaxIdleTime: 5 * time.Minute,
+		},
+		HTTP: HTTP{
+			ReadTimeout:       time.Minute,
+			ReadHeaderTimeout: 5 * time.Second,
+			WriteTimeout:      time.Minute,
+			IdleTimeout:       2 * time.Minute,
+			ShutdownTimeout:   30 * time.Second,
+		},
+		Tokens: Tokens{
+			AccessTTL:  time.Hour,
+			RefreshTTL: 60 * 24 * time.Hour,
+		},
+		Media: Media{
+			Store:   "local",
+			Dir:     "media",
+			Workers: 4,
+		},
+	}
+}
+
+// Validate reports every invalid setting of c.
+func (c *Config) Validate() error {
+	var v validator
+	switch c.Platform {
+	case "dev", "prod":
+	default:
+		v.fail("platform", "PLATFORM", "must be dev or prod, not %q", c.Platform)
+	}
+	if _, _, err := net.SplitHostPort(c.Listen); err != nil {
+		v.fail("listen", "LISTEN_ADDR", "must be host:port or :port: %v", err)
+	}
+	if len(c.Secret) < MinSecretLength {
+		v.fail("secret", "SECRET", "must be at least %d bytes, not %d; generate one with: openssl rand -base64 48", MinSecretLength, len(c.Secret))
+	}
+	v.add(c.Database.Validate())
+	for _, d := range []struct {
+		key, env string
+		value    time.Duration
+	}{
+		{"http.read_timeout", "HTTP_READ_TIMEOUT", c.HTTP.ReadTimeout},
+		{"http.read_header_timeout", "HTTP_READ_HEADER_TIMEOUT", c.HTTP.ReadHeaderTimeout},
+		{"http.write_timeout", "HTTP_WRITE_TIMEOUT", c.HTTP.WriteTimeout},
+		{"http.idle_timeout", "HTTP_IDLE_TIMEOUT", c.HTTP.IdleTimeout},
+		{"http.shutdown_timeout", "HTTP_SHUTDOWN_TIMEOUT", c.HTTP.ShutdownTimeout},
+	} {
+		if d.value < 0 {
+			v.fail(d.key, d.env, "must not be negative")
+		}
+	}
+	if c.Tokens.AccessTTL < time.Second {
+		v.fail("tokens.access_ttl", "ACCESS_TOKEN_TTL", "must be at least 1s, not %s", c.Tokens.AccessTTL)
+	}
+	if c.Tokens.RefreshTTL < c.Tokens.AccessTTL {
+		v.fail("tokens.refresh_ttl", "REFRESH_TOKEN_TTL", "must not be shorter than tokens.access_ttl")
+	}
+	switch c.Media.Store {
+	case "local":
+		if c.Media.Dir == "" {
+			v.fail("media.dir", "MEDIA_DIR", "must be set for the local store")
+		}
+	case "s3":
+		if c.Media.S3.Endpoint == "" || c.Media.S3.Region == "" || c.Media.S3.Bucket == "" {
+			v.fail("media.s3", "S3_ENDPOINT, S3_REGION, S3_BUCKET", "endpoint, region and bucket must be set for the s3 store")
+		}
+	default:
+		v.fail("media.store", "BLOB_STORE", "must be local or s3, not %q", c.Media.Store)
+	}
+	if c.Media.Workers < 1 {
+		v.fail("media.workers", "MEDIA_WORKERS", "must be at least 1")
+	}
+	return v.err()
+}
+
+// Validate reports the invalid settings of d.
+func (d Database) Validate() error {
+	var v validator
+	switch {
+	case d.URL == "":
+		v.fail("database.url", "DB_URL", "must be set")
+	case strings.Contains(d.URL, "://"):
+		if _, err := pq.ParseURL(d.URL); err != nil {
+			v.fail("database.url", "DB_URL", "is not a Postgres URL: %v", redactError(err, d.URL))
+		}
+	case !strings.Contains(d.URL, "="):
+		v.fail("database.url", "DB_URL", "must be a postgres:// URL or a key=value connection string")
+	}
+	if d.MaxOpenConns < 0 {
+		v.fail("database.max_open_conns", "DB_MAX_OPEN_CONNS", "must not be negative")
+	}
+	if d.MaxIdleConns < 0 {
+		v.fail("database.max_idle_conns", "DB_MAX_IDLE_CONNS", "must not be negative")
+	}
+	if d.MaxOpenConns > 0 && d.MaxIdleConns > d.MaxOpenConns {
+		v.fail("database.max_idle_conns", "DB_MAX_IDLE_CONNS", "must not exceed database.max_open_conns (%d)", d.MaxOpenConns)
+	}
+	if d.ConnMaxLifetime < 0 {
+		v.fail("database.conn_max_lifetime", "DB_CONN_MAX_LIFETIME", "must not be negative")
+	}
+	if d.ConnMaxIdleTime < 0 {
+		v.fail("database.conn_max_idle_time", "DB_CONN_MAX_IDLE_TIME", "must not be negative")
+	}
+	return v.err()
+}
+
+// redactError keeps the password in url out of err's message.
+func redactError(err error, url string) string {
+	msg := err.Error()
+	if redacted := redactURL(url); redacted != url {
+		msg = strings.ReplaceAll(msg, url, redacted)
+	}
+	return msg
+}
+
+// validator collects the problems of a configuration.
+type validator struct {
+	problems []string
+}
+
+func (v *validator) fail(key, env, format string, args ...any) {
+	v.problems = append(v.problems, fmt.Sprintf("%s (%s) %s", key, env, fmt.Sprintf(format, args...)))
+}
+
+func (v *validator) add(err error) {
+	var inv *InvalidError
+	if errors.As(err, &inv) {
+		v.problems = append(v.problems, inv.Problems...)
+	}
+}
+
+func (v *validator) err() error {
+	if len(v.problems) == 0 {
+		return nil
+	}
+	return &InvalidError{Problems: v.problems}
+}
+
+// InvalidError lists what is wrong with a configuration.
+type InvalidError struct {
+	Problems []string
+}
+
+func (e *InvalidError) Error() string {
+	return "invalid configuration:\n  " + strings.Join(e.Problems, "\n  ")
+}
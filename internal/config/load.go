@@ -0,0 +1,251 @@
+package config
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// FileEnv names the config file when -config is not given.
+const FileEnv = "CHIRPY_CONFIG"
+
+// Loader loads a Config from the flags it adds to a flag set, the
+// environment and the config file.
+type Loader struct {
+	file  string
+	print bool
+	// flags are the settings given as flags, applied in the order given.
+	flags []setting
+}
+
+type setting struct {
+	path  string
+	value string
+}
+
+// NewLoader adds -config, -print-config and a flag for every setting to fs.
+// Parse fs before calling Load.
+func NewLoader(fs *flag.FlagSet) *Loader {
+	l := &Loader{}
+	fs.StringVar(&l.file, "config", "", "YAML or TOML config `file` (default $"+FileEnv+")")
+	fs.BoolVar(&l.print, "print-config", false, "print the configuration, secrets redacted, and exit")
+	defaults := Default()
+	for _, f := range fields(&defaults) {
+		usage := fmt.Sprintf("%s ($%s)", f.help, f.env)
+		set := func(s string) error {
+			// Check the value now, so mistakes are reported as usage errors.
+			if err := parseInto(reflect.New(f.value.Type()).Elem(), s); err != nil {
+				return err
+			}
+			l.flags = append(l.flags, setting{f.path, s})
+			return nil
+		}
+		if f.value.Kind() == reflect.Bool {
+			fs.BoolFunc(f.flag, usage, set)
+			continue
+		}
+		if def := f.value; !def.IsZero() {
+			usage += fmt.Sprintf(" (default %v)", def.Interface())
+		}
+		fs.Func(f.flag, usage, set)
+	}
+	return l
+}
+
+// Print reports whether -print-config was given.
+func (l *Loader) Print() bool { return l.print }
+
+// Load returns the configuration: the defaults, overridden by the config
+// file, then by the environment read with getenv, then by the flags. It
+// does not validate it; see Validate.
+func (l *Loader) Load(getenv func(string) string) (*Config, error) {
+	c := Default()
+	file := l.file
+	if file == "" {
+		file = getenv(FileEnv)
+	}
+	if file != "" {
+		if err := decodeFile(file, &c); err != nil {
+			return nil, err
+		}
+	}
+	byPath := map[string]field{}
+	for _, f := range fields(&c) {
+		byPath[f.path] = f
+		if s, ok := lookupEnv(getenv, f.env); ok {
+			if err := parseInto(f.value, s); err != nil {
+				return nil, fmt.Errorf("$%s: %w", f.env, err)
+			}
+		}
+	}
+	for _, s := range l.flags {
+		f := byPath[s.path]
+		if err := parseInto(f.value, s.value); err != nil {
+			return nil, fmt.Errorf("-%s: %w", f.flag, err)
+		}
+	}
+	return &c, nil
+}
+
+// lookupEnv reads name, treating empty variables as unset.
+func lookupEnv(getenv func(string) string, name string) (string, bool) {
+	s := getenv(name)
+	return s, s != ""
+}
+
+// decodeFile reads the config file at path into c. Unknown keys are errors,
+// so typos do not go unnoticed.
+func decodeFile(path string, c *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("could not read config file: %w", err)
+	}
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err := dec.Decode(c); err != nil && err != io.EOF {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	case ".toml":
+		md, err := toml.Decode(string(data), c)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if undecoded := md.Undecoded(); len(undecoded) > 0 {
+			return fmt.Errorf("%s: unknown key %s", path, undecoded[0])
+		}
+	default:
+		return fmt.Errorf("%s: the config file must end in .yaml, .yml or .toml, not %q", path, ext)
+	}
+	return nil
+}
+
+// field is a setting of Config.
+type field struct {
+	// path is the file keys joined by dots, such as http.read_timeout.
+	path   string
+	flag   string
+	env    string
+	help   string
+	secret string
+	value  reflect.Value
+}
+
+// fields returns the settings of c, addressable so they can be set.
+func fields(c *Config) []field {
+	var out []field
+	var walk func(v reflect.Value, prefix string)
+	walk = func(v reflect.Value, prefix string) {
+		t := v.Type()
+		for i := range t.NumField() {
+			sf := t.Field(i)
+			path := prefix + sf.Tag.Get("yaml")
+			if sf.Type.Kind() == reflect.Struct {
+				walk(v.Field(i), path+".")
+				continue
+			}
+			name := sf.Tag.Get("flag")
+			if name == "" {
+				name = strings.NewReplacer(".", "-", "_", "-").Replace(path)
+			}
+			out = append(out, field{
+				path:   path,
+				flag:   name,
+				env:    sf.Tag.Get("env"),
+				help:   sf.Tag.Get("help"),
+				secret: sf.Tag.Get("secret"),
+				value:  v.Field(i),
+			})
+		}
+	}
+	walk(reflect.ValueOf(c).Elem(), "")
+	return out
+}
+
+var durationType = reflect.TypeFor[time.Duration]()
+
+// parseInto sets v, a setting, from s.
+func parseInto(v reflect.Value, s string) error {
+	switch {
+	case v.Type() == durationType:
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q; use a number with a unit, such as 30s or 1h", s)
+		}
+		v.SetInt(int64(d))
+	case v.Kind() == reflect.String:
+		v.SetString(s)
+	case v.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", s)
+		}
+		v.SetBool(b)
+	case v.Kind() == reflect.Int:
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", s)
+		}
+		v.SetInt(int64(n))
+	default:
+		panic("config: unsupported setting type " + v.Type().String())
+	}
+	return nil
+}
+
+// Redacted returns a copy of c with its secrets hidden: passwords in URLs
+// are replaced and other secrets shown only as set or not.
+func (c *Config) Redacted() Config {
+	r := *c
+	for _, f := range fields(&r) {
+		switch {
+		case f.secret == "url":
+			f.value.SetString(redactURL(f.value.String()))
+		case f.secret != "" && f.value.String() != "":
+			f.value.SetString("REDACTED")
+		}
+	}
+	return r
+}
+
+// redactURL hides the password in a postgres:// URL or a key=value
+// connection string.
+func redactURL(s string) string {
+	if strings.Contains(s, "://") {
+		u, err := url.Parse(s)
+		if err != nil {
+			return "REDACTED"
+		}
+		return u.Redacted()
+	}
+	words := strings.Fields(s)
+	for i, w := range words {
+		if key, _, ok := strings.Cut(w, "="); ok && key == "password" {
+			words[i] = "password=xxxxx"
+		}
+	}
+	return strings.Join(words, " ")
+}
+
+// Write writes c, with its secrets redacted, as YAML a config file can be
+// made from.
+func (c *Config) Write(w io.Writer) error {
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(c.Redacted()); err != nil {
+		return err
+	}
+	return enc.Close()
+}
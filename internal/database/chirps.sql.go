@@ -12,23 +12,24 @@ import (
 )
 
 const createChirp = `-- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, reply_to_id)
 VALUES (
     gen_random_uuid(),
     NOW(),
     NOW(),
-    $1, $2
+    $1, $2, $3
 )
-RETURNING id, created_at, updated_at, body, user_id
+RETURNING id, created_at, updated_at, body, user_id, reply_to_id
 `
 
 type CreateChirpParams struct {
-	Body   string    `json:"body"`
-	UserID uuid.UUID `json:"user_id"`
+	Body      string        `json:"body"`
+	UserID    uuid.UUID     `json:"user_id"`
+	ReplyToID uuid.NullUUID `json:"reply_to_id"`
 }
 
 func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, createChirp, arg.Body, arg.UserID)
+	row := q.db.QueryRowContext(ctx, createChirp, arg.Body, arg.UserID, arg.ReplyToID)
 	var i Chirp
 	err := row.Scan(
 		&i.ID,
@@ -36,6 +37,7 @@ func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.ReplyToID,
 	)
 	return i, err
 }
@@ -50,7 +52,7 @@ func (q *Queries) DeleteChirpFromID(ctx context.Context, id uuid.UUID) error {
 }
 
 const getChirpFromId = `-- name: GetChirpFromId :one
-SELECT id, created_at, updated_at, body, user_id FROM chirps WHERE id = $1
+SELECT id, created_at, updated_at, body, user_id, reply_to_id FROM chirps WHERE id = $1
 `
 
 func (q *Queries) GetChirpFromId(ctx context.Context, id uuid.UUID) (Chirp, error) {
@@ -62,16 +64,75 @@ func (q *Queries) GetChirpFromId(ctx context.Context, id uuid.UUID) (Chirp, erro
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.ReplyToID,
 	)
 	return i, err
 }
 
+const getChirpReplies = `-- name: GetChirpReplies :many
+SELECT c.id, c.created_at, c.updated_at, c.body, c.user_id, c.reply_to_id FROM chirps c
+WHERE c.reply_to_id = $1
+AND NOT EXISTS (
+    SELECT 1 FROM blocks b
+    WHERE (b.blocker_id = c.user_id AND b.blocked_id = $2)
+       OR (b.blocker_id = $2 AND b.blocked_id = c.user_id)
+)
+AND NOT EXISTS (
+    SELECT 1 FROM mutes m WHERE m.muter_id = $2 AND m.muted_id = c.user_id
+)
+ORDER BY c.created_at
+`
+
+type GetChirpRepliesParams struct {
+	ReplyToID uuid.NullUUID `json:"reply_to_id"`
+	ViewerID  uuid.UUID     `json:"viewer_id"`
+}
+
+func (q *Queries) GetChirpReplies(ctx context.Context, arg GetChirpRepliesParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpReplies, arg.ReplyToID, arg.ViewerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.ReplyToID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
 const getChirps = `-- name: GetChirps :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps ORDER BY created_at
+SELECT c.id, c.created_at, c.updated_at, c.body, c.user_id, c.reply_to_id FROM chirps c
+WHERE NOT EXISTS (
+    SELECT 1 FROM blocks b
+    WHERE (b.blocker_id = c.user_id AND b.blocked_id = $1)
+       OR (b.blocker_id = $1 AND b.blocked_id = c.user_id)
+)
+AND NOT EXISTS (
+    SELECT 1 FROM mutes m WHERE m.muter_id = $1 AND m.muted_id = c.user_id
+)
+ORDER BY c.created_at
 `
 
-func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getChirps)
+func (q *Queries) GetChirps(ctx context.Context, viewerID uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirps, viewerID)
 	if err != nil {
 		return nil, err
 	}
@@ -85,6 +146,7 @@ func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.ReplyToID,
 		); err != nil {
 			return nil, err
 		}
@@ -100,11 +162,23 @@ func (q *Queries) GetChirps(ctx context.Context) ([]Chirp, error) {
 }
 
 const getChirpsFromAuthorID = `-- name: GetChirpsFromAuthorID :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps WHERE user_id = $1 ORDER BY created_at
+SELECT c.id, c.created_at, c.updated_at, c.body, c.user_id, c.reply_to_id FROM chirps c
+WHERE c.user_id = $1
+AND NOT EXISTS (
+    SELECT 1 FROM blocks b
+    WHERE (b.blocker_id = c.user_id AND b.blocked_id = $2)
+       OR (b.blocker_id = $2 AND b.blocked_id = c.user_id)
+)
+ORDER BY c.created_at
 `
 
-func (q *Queries) GetChirpsFromAuthorID(ctx context.Context, userID uuid.UUID) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getChirpsFromAuthorID, userID)
+type GetChirpsFromAuthorIDParams struct {
+	UserID   uuid.UUID `json:"user_id"`
+	ViewerID uuid.UUID `json:"viewer_id"`
+}
+
+func (q *Queries) GetChirpsFromAuthorID(ctx context.Context, arg GetChirpsFromAuthorIDParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getChirpsFromAuthorID, arg.UserID, arg.ViewerID)
 	if err != nil {
 		return nil, err
 	}
@@ -118,6 +192,7 @@ func (q *Queries) GetChirpsFromAuthorID(ctx context.Context, userID uuid.UUID) (
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.ReplyToID,
 		); err != nil {
 			return nil, err
 		}
@@ -131,3 +206,81 @@ func (q *Queries) GetChirpsFromAuthorID(ctx context.Context, userID uuid.UUID) (
 	}
 	return items, nil
 }
+
+const getLatestChirps = `-- name: GetLatestChirps :many
+SELECT c.id, c.created_at, c.updated_at, c.body, c.user_id, c.reply_to_id FROM chirps c
+WHERE NOT EXISTS (
+    SELECT 1 FROM blocks b
+    WHERE (b.blocker_id = c.user_id AND b.blocked_id = $1)
+       OR (b.blocker_id = $1 AND b.blocked_id = c.user_id)
+)
+AND NOT EXISTS (
+    SELECT 1 FROM mutes m WHERE m.muter_id = $1 AND m.muted_id = c.user_id
+)
+ORDER BY c.created_at DESC
+LIMIT $2
+`
+
+type GetLatestChirpsParams struct {
+	ViewerID  uuid.UUID `json:"viewer_id"`
+	MaxChirps int32     `json:"max_chirps"`
+}
+
+func (q *Queries) GetLatestChirps(ctx context.Context, arg GetLatestChirpsParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getLatestChirps, arg.ViewerID, arg.MaxChirps)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.ReplyToID,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getVisibleChirpFromId = `-- name: GetVisibleChirpFromId :one
+SELECT c.id, c.created_at, c.updated_at, c.body, c.user_id, c.reply_to_id FROM chirps c
+WHERE c.id = $1
+AND NOT EXISTS (
+    SELECT 1 FROM blocks b
+    WHERE (b.blocker_id = c.user_id AND b.blocked_id = $2)
+       OR (b.blocker_id = $2 AND b.blocked_id = c.user_id)
+)
+`
+
+type GetVisibleChirpFromIdParams struct {
+	ID       uuid.UUID `json:"id"`
+	ViewerID uuid.UUID `json:"viewer_id"`
+}
+
+func (q *Queries) GetVisibleChirpFromId(ctx context.Context, arg GetVisibleChirpFromIdParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, getVisibleChirpFromId, arg.ID, arg.ViewerID)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.ReplyToID,
+	)
+	return i, err
+}
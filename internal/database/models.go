@@ -6,17 +6,142 @@ package database
 
 import (
 	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+type Admin struct {
+	UserID    uuid.UUID `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type Block struct {
+	BlockerID uuid.UUID `json:"blocker_id"`
+	BlockedID uuid.UUID `json:"blocked_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
 type Chirp struct {
-	ID        uuid.UUID `json:"id"`
+	ID        uuid.UUID     `json:"id"`
+	CreatedAt time.Time     `json:"created_at"`
+	UpdatedAt time.Time     `json:"updated_at"`
+	Body      string        `json:"body"`
+	UserID    uuid.UUID     `json:"user_id"`
+	ReplyToID uuid.NullUUID `json:"reply_to_id"`
+}
+
+type ChirpHashtag struct {
+	ChirpID   uuid.UUID `json:"chirp_id"`
+	Tag       string    `json:"tag"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type ChirpMention struct {
+	ChirpID     uuid.UUID     `json:"chirp_id"`
+	Handle      string        `json:"handle"`
+	UserID      uuid.NullUUID `json:"user_id"`
+	StartOffset int32         `json:"start_offset"`
+	EndOffset   int32         `json:"end_offset"`
+}
+
+type Conversation struct {
+	ID          uuid.UUID     `json:"id"`
+	CreatedAt   time.Time     `json:"created_at"`
+	UpdatedAt   time.Time     `json:"updated_at"`
+	IsGroup     bool          `json:"is_group"`
+	DirectUserA uuid.NullUUID `json:"direct_user_a"`
+	DirectUserB uuid.NullUUID `json:"direct_user_b"`
+}
+
+type ConversationMember struct {
+	ConversationID    uuid.UUID `json:"conversation_id"`
+	UserID            uuid.UUID `json:"user_id"`
+	JoinedAt          time.Time `json:"joined_at"`
+	LastReadMessageID int64     `json:"last_read_message_id"`
+}
+
+type DisabledUser struct {
+	UserID     uuid.UUID `json:"user_id"`
+	Reason     string    `json:"reason"`
+	DisabledAt time.Time `json:"disabled_at"`
+}
+
+type EventPayload struct {
+	ID        int64           `json:"id"`
+	Payload   json.RawMessage `json:"payload"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+type InboundEvent struct {
+	ID              int64           `json:"id"`
+	Provider        string          `json:"provider"`
+	ProviderEventID sql.NullString  `json:"provider_event_id"`
+	EventType       string          `json:"event_type"`
+	Payload         json.RawMessage `json:"payload"`
+	Status          string          `json:"status"`
+	Attempts        int32           `json:"attempts"`
+	NextAttemptAt   time.Time       `json:"next_attempt_at"`
+	LastError       sql.NullString  `json:"last_error"`
+	ReceivedAt      time.Time       `json:"received_at"`
+	ProcessedAt     sql.NullTime    `json:"processed_at"`
+	Subject         sql.NullString  `json:"subject"`
+}
+
+type MediaFile struct {
+	ID              uuid.UUID      `json:"id"`
+	UserID          uuid.UUID      `json:"user_id"`
+	ChirpID         uuid.NullUUID  `json:"chirp_id"`
+	Position        int16          `json:"position"`
+	StorageKey      string         `json:"storage_key"`
+	ContentType     string         `json:"content_type"`
+	SizeBytes       int64          `json:"size_bytes"`
+	CreatedAt       time.Time      `json:"created_at"`
+	AttachedAt      sql.NullTime   `json:"attached_at"`
+	Status          string         `json:"status"`
+	Attempts        int32          `json:"attempts"`
+	NextAttemptAt   time.Time      `json:"next_attempt_at"`
+	Width           sql.NullInt32  `json:"width"`
+	Height          sql.NullInt32  `json:"height"`
+	Blurhash        sql.NullString `json:"blurhash"`
+	ProcessingError sql.NullString `json:"processing_error"`
+	ProcessedAt     sql.NullTime   `json:"processed_at"`
+}
+
+type MediaVariant struct {
+	MediaID     uuid.UUID `json:"media_id"`
+	Name        string    `json:"name"`
+	StorageKey  string    `json:"storage_key"`
+	ContentType string    `json:"content_type"`
+	Width       int32     `json:"width"`
+	Height      int32     `json:"height"`
+	SizeBytes   int64     `json:"size_bytes"`
+}
+
+type Message struct {
+	ID             int64     `json:"id"`
+	ConversationID uuid.UUID `json:"conversation_id"`
+	SenderID       uuid.UUID `json:"sender_id"`
+	Body           string    `json:"body"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+type Mute struct {
+	MuterID   uuid.UUID `json:"muter_id"`
+	MutedID   uuid.UUID `json:"muted_id"`
 	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	Body      string    `json:"body"`
-	UserID    uuid.UUID `json:"user_id"`
+}
+
+type Notification struct {
+	ID        int64         `json:"id"`
+	UserID    uuid.UUID     `json:"user_id"`
+	ActorID   uuid.UUID     `json:"actor_id"`
+	Type      string        `json:"type"`
+	ChirpID   uuid.NullUUID `json:"chirp_id"`
+	GroupKey  string        `json:"group_key"`
+	CreatedAt time.Time     `json:"created_at"`
+	ReadAt    sql.NullTime  `json:"read_at"`
 }
 
 type RefreshToken struct {
@@ -28,6 +153,16 @@ type RefreshToken struct {
 	RevokedAt sql.NullTime `json:"revoked_at"`
 }
 
+type Subscription struct {
+	ID               uuid.UUID    `json:"id"`
+	UserID           uuid.UUID    `json:"user_id"`
+	Plan             string       `json:"plan"`
+	Status           string       `json:"status"`
+	CurrentPeriodEnd sql.NullTime `json:"current_period_end"`
+	CreatedAt        time.Time    `json:"created_at"`
+	UpdatedAt        time.Time    `json:"updated_at"`
+}
+
 type User struct {
 	ID             uuid.UUID `json:"id"`
 	CreatedAt      time.Time `json:"created_at"`
@@ -35,4 +170,40 @@ type User struct {
 	Email          string    `json:"email"`
 	HashedPassword string    `json:"hashed_password"`
 	IsChirpyRed    bool      `json:"is_chirpy_red"`
+	Handle         string    `json:"handle"`
+	DisplayName    string    `json:"display_name"`
+	Bio            string    `json:"bio"`
+	AvatarUrl      string    `json:"avatar_url"`
+}
+
+type Webhook struct {
+	ID         uuid.UUID `json:"id"`
+	UserID     uuid.UUID `json:"user_id"`
+	Url        string    `json:"url"`
+	Secret     string    `json:"secret"`
+	EventTypes []string  `json:"event_types"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+type WebhookDelivery struct {
+	ID            int64           `json:"id"`
+	WebhookID     uuid.UUID       `json:"webhook_id"`
+	EventType     string          `json:"event_type"`
+	Payload       json.RawMessage `json:"payload"`
+	Status        string          `json:"status"`
+	Attempts      int32           `json:"attempts"`
+	NextAttemptAt time.Time       `json:"next_attempt_at"`
+	LastError     sql.NullString  `json:"last_error"`
+	CreatedAt     time.Time       `json:"created_at"`
+	DeliveredAt   sql.NullTime    `json:"delivered_at"`
+}
+
+type WebhookDeliveryAttempt struct {
+	ID          int64         `json:"id"`
+	DeliveryID  int64         `json:"delivery_id"`
+	AttemptedAt time.Time     `json:"attempted_at"`
+	StatusCode  sql.NullInt32 `json:"status_code"`
+	Error       string        `json:"error"`
+	DurationMs  int32         `json:"duration_ms"`
 }
@@ -0,0 +1,21 @@
+package database
+
+import (
+	"database/sql"
+
+	"github.com/RemcoVeens/httpserver/internal/config"
+)
+
+// Open returns a pool of connections to the database of cfg. Like
+// sql.Open, it does not connect until the pool is first used.
+func Open(cfg config.Database) (*sql.DB, error) {
+	db, err := sql.Open("postgres", cfg.URL)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+	return db, nil
+}
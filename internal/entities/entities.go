@@ -0,0 +1,130 @@
+// Package entities extracts #hashtags and @mentions from chirp bodies.
+//
+// Offsets are expressed in runes, not bytes, so clients can slice the body
+// the same way regardless of how they encode it. Start is inclusive and End
+// exclusive, and both cover the leading '#' or '@'.
+package entities
+
+import (
+	"strings"
+	"unicode"
+
+	"github.com/RemcoVeens/httpserver/internal/handles"
+	"github.com/google/uuid"
+)
+
+const MaxTagLength = 100
+
+type Hashtag struct {
+	Tag   string `json:"tag"`
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+}
+
+type Mention struct {
+	Handle string        `json:"handle"`
+	UserID uuid.NullUUID `json:"user_id"`
+	Start  int           `json:"start"`
+	End    int           `json:"end"`
+}
+
+type Entities struct {
+	Hashtags []Hashtag `json:"hashtags"`
+	Mentions []Mention `json:"mentions"`
+}
+
+// Parse returns every hashtag and mention in body in the order they appear.
+// Tags and handles are lower-cased. A sigil only starts an entity when it is
+// at the start of the body or follows a non-word rune, so e-mail addresses
+// and things like "c#" are left alone.
+func Parse(body string) Entities {
+	e := Entities{Hashtags: []Hashtag{}, Mentions: []Mention{}}
+	runes := []rune(body)
+	for i := 0; i < len(runes); i++ {
+		sigil := runes[i]
+		if sigil != '#' && sigil != '@' {
+			continue
+		}
+		if i > 0 && isWordRune(runes[i-1]) {
+			continue
+		}
+		j := i + 1
+		if sigil == '#' {
+			for j < len(runes) && isWordRune(runes[j]) {
+				j++
+			}
+			word := runes[i+1 : j]
+			if len(word) == 0 || len(word) > MaxTagLength || !hasLetter(word) {
+				i = j - 1
+				continue
+			}
+			e.Hashtags = append(e.Hashtags, Hashtag{
+				Tag:   strings.ToLower(string(word)),
+				Start: i,
+				End:   j,
+			})
+		} else {
+			for j < len(runes) && handles.IsHandleRune(runes[j]) {
+				j++
+			}
+			word := runes[i+1 : j]
+			// A handle that runs into other word runes (e.g. "@bob¡") is
+			// not a mention of "bob".
+			if len(word) == 0 || len(word) > handles.MaxLength || (j < len(runes) && isWordRune(runes[j])) {
+				i = j - 1
+				continue
+			}
+			e.Mentions = append(e.Mentions, Mention{
+				Handle: strings.ToLower(string(word)),
+				Start:  i,
+				End:    j,
+			})
+		}
+		i = j - 1
+	}
+	return e
+}
+
+// Tags returns the distinct tags in e, in order of first appearance.
+func (e Entities) Tags() []string {
+	return distinct(len(e.Hashtags), func(i int) string { return e.Hashtags[i].Tag })
+}
+
+// Handles returns the distinct mentioned handles in e, in order of first
+// appearance.
+func (e Entities) Handles() []string {
+	return distinct(len(e.Mentions), func(i int) string { return e.Mentions[i].Handle })
+}
+
+// NormalizeTag lower-cases tag and strips a leading '#', so path values such
+// as "%23Go" and "go" refer to the same tag.
+func NormalizeTag(tag string) string {
+	return strings.ToLower(strings.TrimPrefix(tag, "#"))
+}
+
+func distinct(n int, at func(int) string) []string {
+	seen := make(map[string]bool, n)
+	out := make([]string, 0, n)
+	for i := range n {
+		v := at(i)
+		if seen[v] {
+			continue
+		}
+		seen[v] = true
+		out = append(out, v)
+	}
+	return out
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func hasLetter(word []rune) bool {
+	for _, r := range word {
+		if unicode.IsLetter(r) {
+			return true
+		}
+	}
+	return false
+}
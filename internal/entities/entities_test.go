@@ -0,0 +1,58 @@
+package entities_test
+
+import (
+	"testing"
+
+	"github.com/RemcoVeens/httpserver/internal/entities"
+)
+
+func TestParseHashtags(t *testing.T) {
+	e := entities.Parse("Loving #Go and #go_lang, not c# or #123 #")
+	if len(e.Hashtags) != 2 {
+		t.Fatalf("expected 2 hashtags, got %d: %+v", len(e.Hashtags), e.Hashtags)
+	}
+	if e.Hashtags[0].Tag != "go" || e.Hashtags[0].Start != 7 || e.Hashtags[0].End != 10 {
+		t.Errorf("unexpected first hashtag: %+v", e.Hashtags[0])
+	}
+	if e.Hashtags[1].Tag != "go_lang" {
+		t.Errorf("unexpected second hashtag: %+v", e.Hashtags[1])
+	}
+}
+
+func TestParseMentions(t *testing.T) {
+	e := entities.Parse("hi @Alice, mail bob@example.com and @carol_1")
+	if len(e.Mentions) != 2 {
+		t.Fatalf("expected 2 mentions, got %d: %+v", len(e.Mentions), e.Mentions)
+	}
+	if e.Mentions[0].Handle != "alice" || e.Mentions[0].Start != 3 || e.Mentions[0].End != 9 {
+		t.Errorf("unexpected first mention: %+v", e.Mentions[0])
+	}
+	if e.Mentions[1].Handle != "carol_1" {
+		t.Errorf("unexpected second mention: %+v", e.Mentions[1])
+	}
+}
+
+// TestParseRuneOffsets makes sure offsets count runes rather than bytes.
+func TestParseRuneOffsets(t *testing.T) {
+	e := entities.Parse("héllo 🐦 #café")
+	if len(e.Hashtags) != 1 {
+		t.Fatalf("expected 1 hashtag, got %+v", e.Hashtags)
+	}
+	h := e.Hashtags[0]
+	if h.Tag != "café" || h.Start != 8 || h.End != 13 {
+		t.Errorf("unexpected hashtag: %+v", h)
+	}
+	if got := string([]rune("héllo 🐦 #café")[h.Start:h.End]); got != "#café" {
+		t.Errorf("offsets slice to %q", got)
+	}
+}
+
+func TestTagsAndHandlesAreDistinct(t *testing.T) {
+	e := entities.Parse("#a #A #b @x @X")
+	if tags := e.Tags(); len(tags) != 2 || tags[0] != "a" || tags[1] != "b" {
+		t.Errorf("unexpected tags: %v", tags)
+	}
+	if handles := e.Handles(); len(handles) != 1 || handles[0] != "x" {
+		t.Errorf("unexpected handles: %v", handles)
+	}
+}
@@ -0,0 +1,167 @@
+// Package entitlements decides what a user's plan allows.
+//
+// Limits per plan come from a JSON file so they can be changed without a
+// release; default.json is used when no file is given. Every plan in
+// Plans must be configured:
+//
+//	{
+//	  "plans": {
+//	    "free":       {"max_chirp_length": 140, "edit_window": "0s", ...},
+//	    "chirpy_red": {"max_chirp_length": 400, "edit_window": "15m", ...}
+//	  }
+//	}
+//
+// Handlers ask through the Entitlements interface and never look at
+// is_chirpy_red themselves.
+package entitlements
+
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/RemcoVeens/httpserver/internal/database"
+)
+
+// Plans.
+const (
+	Free = "free"
+	Red  = "chirpy_red"
+)
+
+// Plans lists every plan a user can be on.
+var Plans = []string{Free, Red}
+
+//go:embed default.json
+var defaultConfig []byte
+
+// Limits are what a plan allows. Zero means the feature is not available.
+type Limits struct {
+	MaxChirpLength int
+	// EditWindow is configured per plan but not enforced yet: chirps can
+	// not be edited.
+	EditWindow       time.Duration
+	MaxMediaPerChirp int
+	// MaxScheduledChirps is configured per plan but not enforced yet:
+	// chirps can not be scheduled.
+	MaxScheduledChirps int
+	RequestsPerMinute  int
+}
+
+// Entitlements tells handlers what a user may do.
+type Entitlements interface {
+	// Limits returns the limits of user's plan. The zero User stands for
+	// anonymous callers, who get the free plan.
+	Limits(user database.User) Limits
+}
+
+// PlanFor returns the plan user is on.
+func PlanFor(user database.User) string {
+	if user.IsChirpyRed {
+		return Red
+	}
+	return Free
+}
+
+// Table is an Entitlements backed by a fixed set of limits per plan.
+type Table map[string]Limits
+
+func (t Table) Limits(user database.User) Limits {
+	return t[PlanFor(user)]
+}
+
+// Default returns the limits shipped with Chirpy.
+func Default() Table {
+	t, err := Parse(defaultConfig)
+	if err != nil {
+		panic(fmt.Sprintf("entitlements: invalid default.json: %s", err))
+	}
+	return t
+}
+
+// Load reads limits from the JSON file at path, or returns Default if path
+// is empty.
+func Load(path string) (Table, error) {
+	if path == "" {
+		return Default(), nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("could not read entitlements: %w", err)
+	}
+	t, err := Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("invalid entitlements in %s: %w", path, err)
+	}
+	return t, nil
+}
+
+// limitsFile mirrors Limits with durations written as strings such as
+// "15m".
+type limitsFile struct {
+	MaxChirpLength     *int   `json:"max_chirp_length"`
+	EditWindow         string `json:"edit_window"`
+	MaxMediaPerChirp   *int   `json:"max_media_per_chirp"`
+	MaxScheduledChirps *int   `json:"max_scheduled_chirps"`
+	RequestsPerMinute  *int   `json:"requests_per_minute"`
+}
+
+// Parse decodes and validates a limits file. Every plan must be present and
+// set every limit, so a typo can not silently grant or remove a feature.
+func Parse(data []byte) (Table, error) {
+	var file struct {
+		Plans map[string]limitsFile `json:"plans"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, err
+	}
+	t := Table{}
+	for _, plan := range Plans {
+		f, ok := file.Plans[plan]
+		if !ok {
+			return nil, fmt.Errorf("plan %q is missing", plan)
+		}
+		l, err := f.limits()
+		if err != nil {
+			return nil, fmt.Errorf("plan %q: %w", plan, err)
+		}
+		t[plan] = l
+	}
+	for plan := range file.Plans {
+		if _, ok := t[plan]; !ok {
+			return nil, fmt.Errorf("unknown plan %q", plan)
+		}
+	}
+	return t, nil
+}
+
+func (f limitsFile) limits() (Limits, error) {
+	if f.MaxChirpLength == nil || f.MaxMediaPerChirp == nil || f.MaxScheduledChirps == nil ||
+		f.RequestsPerMinute == nil || f.EditWindow == "" {
+		return Limits{}, errors.New("every limit must be set")
+	}
+	window, err := time.ParseDuration(f.EditWindow)
+	if err != nil {
+		return Limits{}, fmt.Errorf("edit_window: %w", err)
+	}
+	l := Limits{
+		MaxChirpLength:     *f.MaxChirpLength,
+		EditWindow:         window,
+		MaxMediaPerChirp:   *f.MaxMediaPerChirp,
+		MaxScheduledChirps: *f.MaxScheduledChirps,
+		RequestsPerMinute:  *f.RequestsPerMinute,
+	}
+	if l.MaxChirpLength < 1 {
+		return Limits{}, errors.New("max_chirp_length must be positive")
+	}
+	if l.RequestsPerMinute < 1 {
+		return Limits{}, errors.New("requests_per_minute must be positive")
+	}
+	if l.EditWindow < 0 || l.MaxMediaPerChirp < 0 || l.MaxScheduledChirps < 0 {
+		return Limits{}, errors.New("limits can not be negative")
+	}
+	return l, nil
+}
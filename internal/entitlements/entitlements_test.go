@@ -0,0 +1,61 @@
+package entitlements_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/RemcoVeens/httpserver/internal/database"
+	"github.com/RemcoVeens/httpserver/internal/entitlements"
+)
+
+func TestDefault(t *testing.T) {
+	d := entitlements.Default()
+	free := d.Limits(database.User{})
+	red := d.Limits(database.User{IsChirpyRed: true})
+	if free.MaxChirpLength != 140 || free.EditWindow != 0 {
+		t.Errorf("unexpected free limits %+v", free)
+	}
+	if red.MaxChirpLength <= free.MaxChirpLength || red.EditWindow != 15*time.Minute || red.MaxMediaPerChirp != 4 {
+		t.Errorf("unexpected red limits %+v", red)
+	}
+}
+
+const plan = `{"max_chirp_length": 10, "edit_window": "1m", "max_media_per_chirp": 0, "max_scheduled_chirps": 0, "requests_per_minute": 5}`
+
+func TestParseRejects(t *testing.T) {
+	cases := map[string]string{
+		"missing plan":     `{"plans": {"free": ` + plan + `}}`,
+		"unknown plan":     `{"plans": {"free": ` + plan + `, "chirpy_red": ` + plan + `, "gold": ` + plan + `}}`,
+		"missing limit":    `{"plans": {"free": {"max_chirp_length": 10}, "chirpy_red": ` + plan + `}}`,
+		"bad duration":     `{"plans": {"free": ` + strings.Replace(plan, `"1m"`, `"soon"`, 1) + `, "chirpy_red": ` + plan + `}}`,
+		"negative":         `{"plans": {"free": ` + strings.Replace(plan, `"max_media_per_chirp": 0`, `"max_media_per_chirp": -1`, 1) + `, "chirpy_red": ` + plan + `}}`,
+		"zero chirp limit": `{"plans": {"free": ` + strings.Replace(plan, `"max_chirp_length": 10`, `"max_chirp_length": 0`, 1) + `, "chirpy_red": ` + plan + `}}`,
+		"not json":         `plans: free`,
+	}
+	for name, data := range cases {
+		if _, err := entitlements.Parse([]byte(data)); err == nil {
+			t.Errorf("%s: accepted", name)
+		}
+	}
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "entitlements.json")
+	data := `{"plans": {"free": ` + plan + `, "chirpy_red": ` + plan + `}}`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	table, err := entitlements.Load(path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if l := table.Limits(database.User{}); l.MaxChirpLength != 10 || l.EditWindow != time.Minute || l.RequestsPerMinute != 5 {
+		t.Errorf("unexpected limits %+v", l)
+	}
+	if _, err := entitlements.Load(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Errorf("missing file accepted")
+	}
+}
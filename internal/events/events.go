@@ -0,0 +1,118 @@
+// Package events carries Chirpy events between replicas through Postgres
+// LISTEN/NOTIFY.
+//
+// Handlers call Emit with the Queries of the transaction that makes the
+// change, so the notification is sent if and only if that transaction
+// commits. Every replica runs a Listener that receives the notifications,
+// its own included, and publishes them to its local pubsub.Broker, where the
+// SSE stream and the WebSocket gateway pick them up.
+//
+// # Large payloads
+//
+// NOTIFY payloads must stay under 8000 bytes. Larger events are written to
+// the event_payloads table in the same transaction and the notification only
+// carries the row id; listeners load the payload by that reference. Rows are
+// pruned after PayloadRetention.
+//
+// # Ordering and delivery
+//
+// Postgres delivers notifications from one transaction in the order they
+// were sent, and notifications from different transactions in commit order,
+// so every replica sees the same events in the same order. Delivery is at
+// most once: events committed while a listener is disconnected are lost to
+// that replica, and a listener that reconnects only logs the gap. The ids a
+// broker assigns are local to its replica, so an SSE client resuming with
+// Last-Event-ID on a different replica may miss or repeat events.
+//
+// # Webhooks
+//
+// Emit also queues a webhook delivery for every endpoint subscribed to the
+// event type, in the same transaction. Unlike notifications these rows are
+// durable; the webhooks package delivers them with retries. Endpoints only
+// get events their owner may see: public events, about chirps and new
+// profiles, unless the owner and the user the event is about blocked one
+// another, and other events only when they are about the owner.
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/RemcoVeens/httpserver/internal/database"
+	"github.com/RemcoVeens/httpserver/internal/pubsub"
+	"github.com/google/uuid"
+)
+
+const (
+	// Channel is the Postgres notification channel all events use.
+	Channel = "chirpy_events"
+
+	// MaxNotifyPayload is the largest payload sent inline. Postgres rejects
+	// payloads of 8000 bytes or more; the margin leaves room for the
+	// envelope.
+	MaxNotifyPayload = 7900
+)
+
+// envelope is the NOTIFY payload. Exactly one of Data and Ref is set.
+type envelope struct {
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data,omitempty"`
+	Ref  int64           `json:"ref,omitempty"`
+}
+
+// publicEvents are the events about what anyone may see. Others, such as a
+// change of plan, are for the user they are about only.
+var publicEvents = map[string]bool{
+	pubsub.ChirpCreated: true,
+	pubsub.ChirpDeleted: true,
+	pubsub.UserCreated:  true,
+}
+
+// Store is the part of database.Queries Emit needs.
+type Store interface {
+	NotifyEvent(ctx context.Context, arg database.NotifyEventParams) error
+	CreateEventPayload(ctx context.Context, payload json.RawMessage) (int64, error)
+	EnqueueWebhookDeliveries(ctx context.Context, arg database.EnqueueWebhookDeliveriesParams) error
+}
+
+// Emit sends eventType with data to every replica, and queues it for the
+// webhooks subscribed to it, once the surrounding transaction commits. q must
+// be bound to that transaction. userID is the user the event is about: the
+// author of a chirp, the recipient of a notification or the user whose
+// account changed.
+func Emit(ctx context.Context, q Store, eventType string, userID uuid.UUID, data any) error {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("could not marshal %s event: %w", eventType, err)
+	}
+	payload, err := json.Marshal(envelope{Type: eventType, Data: raw})
+	if err != nil {
+		return fmt.Errorf("could not marshal %s event: %w", eventType, err)
+	}
+	if len(payload) > MaxNotifyPayload {
+		id, err := q.CreateEventPayload(ctx, raw)
+		if err != nil {
+			return fmt.Errorf("could not store %s payload: %w", eventType, err)
+		}
+		payload, err = json.Marshal(envelope{Type: eventType, Ref: id})
+		if err != nil {
+			return fmt.Errorf("could not marshal %s event: %w", eventType, err)
+		}
+	}
+	if err := q.NotifyEvent(ctx, database.NotifyEventParams{
+		Channel: Channel,
+		Payload: string(payload),
+	}); err != nil {
+		return fmt.Errorf("could not notify %s: %w", eventType, err)
+	}
+	if err := q.EnqueueWebhookDeliveries(ctx, database.EnqueueWebhookDeliveriesParams{
+		EventType: eventType,
+		Payload:   raw,
+		UserID:    userID,
+		Public:    publicEvents[eventType],
+	}); err != nil {
+		return fmt.Errorf("could not queue %s webhooks: %w", eventType, err)
+	}
+	return nil
+}
@@ -0,0 +1,216 @@
+package events
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/RemcoVeens/httpserver/internal/database"
+	"github.com/RemcoVeens/httpserver/internal/pubsub"
+	"github.com/google/uuid"
+	_ "github.com/lib/pq"
+)
+
+// fakeStore stands in for a transaction: it records notifications and keeps
+// referenced payloads in memory.
+type fakeStore struct {
+	notified []database.NotifyEventParams
+	payloads map[int64]json.RawMessage
+	webhooks []database.EnqueueWebhookDeliveriesParams
+}
+
+func (f *fakeStore) NotifyEvent(_ context.Context, arg database.NotifyEventParams) error {
+	f.notified = append(f.notified, arg)
+	return nil
+}
+
+func (f *fakeStore) CreateEventPayload(_ context.Context, payload json.RawMessage) (int64, error) {
+	if f.payloads == nil {
+		f.payloads = map[int64]json.RawMessage{}
+	}
+	id := int64(len(f.payloads) + 1)
+	f.payloads[id] = payload
+	return id, nil
+}
+
+func (f *fakeStore) EnqueueWebhookDeliveries(_ context.Context, arg database.EnqueueWebhookDeliveriesParams) error {
+	f.webhooks = append(f.webhooks, arg)
+	return nil
+}
+
+func (f *fakeStore) GetEventPayload(_ context.Context, id int64) (json.RawMessage, error) {
+	return f.payloads[id], nil
+}
+
+func (f *fakeStore) DeleteEventPayloadsBefore(context.Context, time.Time) error {
+	return nil
+}
+
+// roundTrip emits data and feeds the resulting notification to a listener,
+// returning what the local broker publishes.
+func roundTrip(t *testing.T, store *fakeStore, data any) pubsub.Event {
+	t.Helper()
+	if err := Emit(context.Background(), store, pubsub.ChirpCreated, uuid.New(), data); err != nil {
+		t.Fatalf("Emit failed: %v", err)
+	}
+	n := store.notified[len(store.notified)-1]
+	if n.Channel != Channel {
+		t.Fatalf("notified on %q, want %q", n.Channel, Channel)
+	}
+	if len(n.Payload) > MaxNotifyPayload {
+		t.Fatalf("notify payload is %d bytes, over the limit", len(n.Payload))
+	}
+	broker := pubsub.NewBroker(10, 10)
+	sub, _, _ := broker.Subscribe(0, nil)
+	defer sub.Close()
+	if err := NewListener("", store, broker).handle(context.Background(), n.Payload); err != nil {
+		t.Fatalf("handle failed: %v", err)
+	}
+	return <-sub.C()
+}
+
+func TestSmallEventsAreSentInline(t *testing.T) {
+	store := &fakeStore{}
+	e := roundTrip(t, store, map[string]string{"body": "hello"})
+	if len(store.payloads) != 0 {
+		t.Errorf("small event should not be stored by reference")
+	}
+	if e.Type != pubsub.ChirpCreated || string(e.Data) != `{"body":"hello"}` {
+		t.Errorf("unexpected event: %s %s", e.Type, e.Data)
+	}
+}
+
+func TestLargeEventsAreSentByReference(t *testing.T) {
+	store := &fakeStore{}
+	body := strings.Repeat("x", 10000)
+	e := roundTrip(t, store, map[string]string{"body": body})
+	if len(store.payloads) != 1 {
+		t.Fatalf("large event should be stored by reference")
+	}
+	var got map[string]string
+	if err := json.Unmarshal(e.Data, &got); err != nil || got["body"] != body {
+		t.Errorf("payload did not survive the reference round trip")
+	}
+}
+
+func TestHandleRejectsGarbage(t *testing.T) {
+	l := NewListener("", &fakeStore{}, pubsub.NewBroker(1, 1))
+	if err := l.handle(context.Background(), "not json"); err == nil {
+		t.Errorf("expected an error for an invalid payload")
+	}
+}
+
+func TestEmitQueuesWebhooks(t *testing.T) {
+	store := &fakeStore{}
+	body := strings.Repeat("x", 10000)
+	roundTrip(t, store, map[string]string{"body": body})
+	if len(store.webhooks) != 1 {
+		t.Fatalf("expected one webhook enqueue, got %d", len(store.webhooks))
+	}
+	w := store.webhooks[0]
+	var got map[string]string
+	if w.EventType != pubsub.ChirpCreated || json.Unmarshal(w.Payload, &got) != nil || got["body"] != body {
+		t.Errorf("webhooks should get the full payload, even when notify sends a reference")
+	}
+}
+
+func TestEmitScopesWebhooks(t *testing.T) {
+	store := &fakeStore{}
+	author, user := uuid.New(), uuid.New()
+	if err := Emit(context.Background(), store, pubsub.ChirpCreated, author, map[string]string{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := Emit(context.Background(), store, pubsub.UserUpgraded, user, map[string]string{}); err != nil {
+		t.Fatal(err)
+	}
+	if w := store.webhooks[0]; w.UserID != author || !w.Public {
+		t.Errorf("chirp.created should be public and about its author: %+v", w)
+	}
+	if w := store.webhooks[1]; w.UserID != user || w.Public {
+		t.Errorf("user.upgraded should be private to the user: %+v", w)
+	}
+}
+
+// TestWebhooksOnlyGetVisibleEvents checks against a real database that
+// endpoints get no events about users who blocked their owner, nor the
+// private events of other users. Point CHIRPY_TEST_DB_URL at a migrated,
+// disposable database to run it.
+func TestWebhooksOnlyGetVisibleEvents(t *testing.T) {
+	dbURL := os.Getenv("CHIRPY_TEST_DB_URL")
+	if dbURL == "" {
+		t.Skip("CHIRPY_TEST_DB_URL is not set")
+	}
+	db, err := sql.Open("postgres", dbURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+	q := database.New(db)
+
+	newUser := func() database.User {
+		t.Helper()
+		handle := "u" + strings.ReplaceAll(uuid.NewString(), "-", "")[:12]
+		u, err := q.CreateUser(ctx, database.CreateUserParams{Email: handle + "@example.com", HashedPassword: "x", Handle: handle})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return u
+	}
+	newHook := func(owner database.User) database.Webhook {
+		t.Helper()
+		hook, err := q.CreateWebhook(ctx, database.CreateWebhookParams{
+			UserID:     owner.ID,
+			Url:        "https://example.com/hook",
+			Secret:     "whsec_test",
+			EventTypes: []string{pubsub.ChirpCreated, pubsub.UserUpgraded},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return hook
+	}
+	emit := func(eventType string, about database.User) {
+		t.Helper()
+		err := database.RunInTx(ctx, db, func(q *database.Queries) error {
+			return Emit(ctx, q, eventType, about.ID, map[string]any{"user_id": about.ID})
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	deliveries := func(hook database.Webhook) []string {
+		t.Helper()
+		ds, err := q.GetWebhookDeliveries(ctx, database.GetWebhookDeliveriesParams{WebhookID: hook.ID, MaxDeliveries: 100})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var types []string
+		for _, d := range ds {
+			types = append(types, d.EventType)
+		}
+		return types
+	}
+
+	author, blocked, stranger := newUser(), newUser(), newUser()
+	if err := q.BlockUser(ctx, database.BlockUserParams{BlockerID: author.ID, BlockedID: blocked.ID}); err != nil {
+		t.Fatal(err)
+	}
+	authorHook, blockedHook, strangerHook := newHook(author), newHook(blocked), newHook(stranger)
+	emit(pubsub.ChirpCreated, author)
+	emit(pubsub.UserUpgraded, author)
+
+	if got := deliveries(blockedHook); len(got) != 0 {
+		t.Errorf("the blocked user's endpoint got %v", got)
+	}
+	if got := deliveries(strangerHook); len(got) != 1 || got[0] != pubsub.ChirpCreated {
+		t.Errorf("another user's endpoint got %v, want only the chirp", got)
+	}
+	if got := deliveries(authorHook); len(got) != 2 {
+		t.Errorf("the author's endpoint got %v, want both events", got)
+	}
+}
@@ -0,0 +1,116 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/RemcoVeens/httpserver/internal/pubsub"
+	"github.com/lib/pq"
+)
+
+const (
+	minReconnectInterval = time.Second
+	maxReconnectInterval = time.Minute
+	pingInterval         = 90 * time.Second
+	pruneInterval        = 10 * time.Minute
+
+	// PayloadRetention is how long referenced payloads are kept. It only
+	// has to cover the time between commit and every listener loading it.
+	PayloadRetention = time.Hour
+)
+
+// PayloadStore is the part of database.Queries a Listener needs.
+type PayloadStore interface {
+	GetEventPayload(ctx context.Context, id int64) (json.RawMessage, error)
+	DeleteEventPayloadsBefore(ctx context.Context, createdAt time.Time) error
+}
+
+// Listener feeds events from Postgres into a local broker.
+type Listener struct {
+	dbURL  string
+	store  PayloadStore
+	broker *pubsub.Broker
+}
+
+func NewListener(dbURL string, store PayloadStore, broker *pubsub.Broker) *Listener {
+	return &Listener{dbURL: dbURL, store: store, broker: broker}
+}
+
+// Run listens until ctx is cancelled. The underlying connection is
+// re-established automatically with exponential backoff; Run only returns
+// early if the channel can not be listened on at all.
+func (l *Listener) Run(ctx context.Context) error {
+	pl := pq.NewListener(l.dbURL, minReconnectInterval, maxReconnectInterval, func(ev pq.ListenerEventType, err error) {
+		switch ev {
+		case pq.ListenerEventDisconnected:
+			log.Printf("event listener disconnected: %s", err)
+		case pq.ListenerEventReconnected:
+			log.Printf("event listener reconnected; events sent while it was down were missed")
+		case pq.ListenerEventConnectionAttemptFailed:
+			log.Printf("event listener could not connect: %s", err)
+		}
+	})
+	defer pl.Close()
+	// Listen waits for a connection while the database is down; closing
+	// the listener ends the wait.
+	stop := context.AfterFunc(ctx, func() { pl.Close() })
+	defer stop()
+	if err := pl.Listen(Channel); err != nil {
+		if ctx.Err() != nil {
+			return nil
+		}
+		return fmt.Errorf("could not listen on %s: %w", Channel, err)
+	}
+
+	ping := time.NewTicker(pingInterval)
+	defer ping.Stop()
+	prune := time.NewTicker(pruneInterval)
+	defer prune.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case n := <-pl.Notify:
+			// A nil notification signals a reconnect, which the event
+			// callback has already logged.
+			if n == nil {
+				continue
+			}
+			// Once the broker is closed the server is shutting down and
+			// nobody is listening.
+			if err := l.handle(ctx, n.Extra); err != nil && !errors.Is(err, pubsub.ErrClosed) {
+				log.Printf("could not handle event: %s", err)
+			}
+		case <-ping.C:
+			go pl.Ping()
+		case <-prune.C:
+			if err := l.store.DeleteEventPayloadsBefore(ctx, time.Now().Add(-PayloadRetention)); err != nil {
+				log.Printf("could not prune event payloads: %s", err)
+			}
+		}
+	}
+}
+
+// handle decodes one notification payload and publishes it locally.
+func (l *Listener) handle(ctx context.Context, payload string) error {
+	var env envelope
+	if err := json.Unmarshal([]byte(payload), &env); err != nil {
+		return fmt.Errorf("invalid payload: %w", err)
+	}
+	data := env.Data
+	if env.Ref != 0 {
+		var err error
+		data, err = l.store.GetEventPayload(ctx, env.Ref)
+		if err != nil {
+			return fmt.Errorf("could not load %s payload %d: %w", env.Type, env.Ref, err)
+		}
+	}
+	if _, err := l.broker.Publish(env.Type, data); err != nil {
+		return fmt.Errorf("could not publish %s: %w", env.Type, err)
+	}
+	return nil
+}
@@ -0,0 +1,339 @@
+// Package gateway serves the /api/ws WebSocket endpoint: one bidirectional
+// connection over which a client subscribes to its timeline, its
+// notifications and individual chirp threads. The message protocol is
+// described in docs/websocket.md.
+//
+// Each connection holds a single broker subscription, so events reach the
+// client in broker order whatever channels they match. Outgoing frames go
+// through a bounded per-connection queue drained by one writer goroutine; a
+// client that lets the queue fill up is disconnected rather than allowed to
+// hold events back for everyone else.
+package gateway
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/RemcoVeens/httpserver/internal/apierror"
+	"github.com/RemcoVeens/httpserver/internal/pubsub"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	DefaultPingInterval = 30 * time.Second
+	DefaultPongWait     = 60 * time.Second
+	DefaultWriteWait    = 10 * time.Second
+	DefaultQueueSize    = 64
+
+	maxMessageSize = 4096
+)
+
+// Authenticator returns the id of the user making the upgrade request.
+// Errors that are not an *apierror.Error are reported as unauthenticated.
+type Authenticator func(r *http.Request) (uuid.UUID, error)
+
+// HiddenAuthors returns the users whose chirps must not reach userID, i.e.
+// everyone they blocked, muted or were blocked by.
+type HiddenAuthors func(ctx context.Context, userID uuid.UUID) ([]uuid.UUID, error)
+
+// ChirpVisible reports whether userID may see chirpID, by the rules of
+// GET /api/chirps/{chirp_id}. A chirp that does not exist is not visible.
+type ChirpVisible func(ctx context.Context, userID, chirpID uuid.UUID) (bool, error)
+
+type Gateway struct {
+	Broker        *pubsub.Broker
+	Authenticate  Authenticator
+	HiddenAuthors HiddenAuthors
+	ChirpVisible  ChirpVisible
+	PingInterval  time.Duration
+	PongWait      time.Duration
+	WriteWait     time.Duration
+	QueueSize     int
+
+	upgrader websocket.Upgrader
+}
+
+func New(broker *pubsub.Broker, authenticate Authenticator, hidden HiddenAuthors, visible ChirpVisible) *Gateway {
+	return &Gateway{
+		Broker:        broker,
+		Authenticate:  authenticate,
+		HiddenAuthors: hidden,
+		ChirpVisible:  visible,
+		PingInterval:  DefaultPingInterval,
+		PongWait:      DefaultPongWait,
+		WriteWait:     DefaultWriteWait,
+		QueueSize:     DefaultQueueSize,
+	}
+}
+
+func (g *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	userID, err := g.Authenticate(r)
+	if err != nil {
+		var e *apierror.Error
+		if !errors.As(err, &e) {
+			err = apierror.Wrap(apierror.Unauthenticated, err, "could not authenticate")
+		}
+		apierror.Write(w, r, err)
+		return
+	}
+	hidden := map[uuid.UUID]bool{}
+	if g.HiddenAuthors != nil {
+		ids, err := g.HiddenAuthors(r.Context(), userID)
+		if err != nil {
+			apierror.Write(w, r, fmt.Errorf("could not load blocks: %w", err))
+			return
+		}
+		for _, id := range ids {
+			hidden[id] = true
+		}
+	}
+	ws, err := g.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade has already written the error response.
+		return
+	}
+	c := &conn{
+		gateway: g,
+		ws:      ws,
+		userID:  userID,
+		hidden:  hidden,
+		subs:    map[subscription]bool{},
+		send:    make(chan ServerMessage, g.QueueSize),
+		done:    make(chan struct{}),
+	}
+	sub, _, _ := g.Broker.Subscribe(0, c.wants)
+	defer sub.Close()
+	go c.writeLoop()
+	go c.pump(sub)
+	c.readLoop(r.Context())
+	c.close(websocket.CloseNormalClosure, "")
+}
+
+type conn struct {
+	gateway *Gateway
+	ws      *websocket.Conn
+	userID  uuid.UUID
+	hidden  map[uuid.UUID]bool
+
+	mu   sync.Mutex
+	subs map[subscription]bool
+
+	send      chan ServerMessage
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+// eventData holds the payload fields used for routing. For chirp events
+// UserID is the author and ReplyToID the chirp replied to, for notification
+// events UserID is the recipient.
+type eventData struct {
+	ID        uuid.UUID     `json:"id"`
+	UserID    uuid.UUID     `json:"user_id"`
+	ReplyToID uuid.NullUUID `json:"reply_to_id"`
+}
+
+func (c *conn) matches(s subscription, e pubsub.Event, d eventData) bool {
+	switch s.channel {
+	case ChannelTimeline:
+		return (e.Type == pubsub.ChirpCreated || e.Type == pubsub.ChirpDeleted) && !c.hidden[d.UserID]
+	case ChannelChirp:
+		inThread := d.ID == s.chirpID || (d.ReplyToID.Valid && d.ReplyToID.UUID == s.chirpID)
+		return (e.Type == pubsub.ChirpCreated || e.Type == pubsub.ChirpDeleted) && !c.hidden[d.UserID] && inThread
+	case ChannelNotifications:
+		return e.Type == pubsub.NotificationCreated && d.UserID == c.userID
+	}
+	return false
+}
+
+// matching returns the subscriptions e should be delivered on.
+func (c *conn) matching(e pubsub.Event) []subscription {
+	var d eventData
+	if err := json.Unmarshal(e.Data, &d); err != nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var out []subscription
+	for s := range c.subs {
+		if c.matches(s, e, d) {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// wants is the broker filter, so events nobody on this connection listens
+// to never take up room in the subscription buffer.
+func (c *conn) wants(e pubsub.Event) bool {
+	return len(c.matching(e)) > 0
+}
+
+// pump moves broker events into the send queue until the subscription ends.
+func (c *conn) pump(sub *pubsub.Subscription) {
+	for e := range sub.C() {
+		for _, s := range c.matching(e) {
+			if !c.enqueue(ServerMessage{
+				Type:    TypeEvent,
+				Channel: s.channel,
+				ChirpID: s.chirpIDPtr(),
+				Event:   e.Type,
+				EventID: e.ID,
+				Data:    e.Data,
+			}) {
+				return
+			}
+		}
+	}
+	if sub.Dropped() {
+		c.close(websocket.CloseTryAgainLater, "too slow")
+		return
+	}
+	c.close(websocket.CloseGoingAway, "server shutting down")
+}
+
+// enqueue queues msg for the writer without blocking. When the queue is full
+// the client is not reading fast enough and the connection is closed.
+func (c *conn) enqueue(msg ServerMessage) bool {
+	select {
+	case <-c.done:
+		return false
+	default:
+	}
+	select {
+	case c.send <- msg:
+		return true
+	default:
+		log.Printf("closing websocket for %s: send queue full", c.userID)
+		c.close(websocket.CloseTryAgainLater, "send queue full")
+		return false
+	}
+}
+
+func (c *conn) writeLoop() {
+	ping := time.NewTicker(c.gateway.PingInterval)
+	defer ping.Stop()
+	for {
+		select {
+		case <-c.done:
+			return
+		case msg := <-c.send:
+			c.ws.SetWriteDeadline(time.Now().Add(c.gateway.WriteWait))
+			if err := c.ws.WriteJSON(msg); err != nil {
+				c.close(websocket.CloseAbnormalClosure, "")
+				return
+			}
+		case <-ping.C:
+			if err := c.ws.WriteControl(websocket.PingMessage, nil, time.Now().Add(c.gateway.WriteWait)); err != nil {
+				c.close(websocket.CloseAbnormalClosure, "")
+				return
+			}
+		}
+	}
+}
+
+func (c *conn) readLoop(ctx context.Context) {
+	c.ws.SetReadLimit(maxMessageSize)
+	c.ws.SetReadDeadline(time.Now().Add(c.gateway.PongWait))
+	c.ws.SetPongHandler(func(string) error {
+		return c.ws.SetReadDeadline(time.Now().Add(c.gateway.PongWait))
+	})
+	for {
+		kind, raw, err := c.ws.ReadMessage()
+		if err != nil {
+			return
+		}
+		c.ws.SetReadDeadline(time.Now().Add(c.gateway.PongWait))
+		if kind != websocket.TextMessage {
+			c.enqueue(ServerMessage{Type: TypeError, Error: "only text messages are supported"})
+			continue
+		}
+		var msg ClientMessage
+		if err := json.Unmarshal(raw, &msg); err != nil {
+			c.enqueue(ServerMessage{Type: TypeError, Error: "invalid JSON message"})
+			continue
+		}
+		if !c.handle(ctx, msg) {
+			return
+		}
+	}
+}
+
+func (c *conn) handle(ctx context.Context, msg ClientMessage) bool {
+	switch msg.Type {
+	case TypePing:
+		return c.enqueue(ServerMessage{Type: TypePong, ID: msg.ID})
+	case TypeSubscribe, TypeUnsubscribe:
+		s, errMsg := parseSubscription(msg)
+		if errMsg == "" && msg.Type == TypeSubscribe && s.channel == ChannelChirp {
+			errMsg = c.checkChirp(ctx, s.chirpID)
+		}
+		if errMsg != "" {
+			return c.enqueue(ServerMessage{Type: TypeError, ID: msg.ID, Error: errMsg})
+		}
+		c.mu.Lock()
+		if msg.Type == TypeSubscribe {
+			c.subs[s] = true
+		} else {
+			delete(c.subs, s)
+		}
+		c.mu.Unlock()
+		return c.enqueue(ServerMessage{Type: TypeAck, ID: msg.ID, Channel: s.channel, ChirpID: s.chirpIDPtr()})
+	default:
+		return c.enqueue(ServerMessage{Type: TypeError, ID: msg.ID, Error: "unknown message type"})
+	}
+}
+
+// checkChirp returns why the connection's user may not follow the thread of
+// chirpID, or "" if they may. Replies to a chirp by someone who blocked them
+// would otherwise reach them.
+func (c *conn) checkChirp(ctx context.Context, chirpID uuid.UUID) string {
+	if c.gateway.ChirpVisible == nil {
+		return ""
+	}
+	visible, err := c.gateway.ChirpVisible(ctx, c.userID, chirpID)
+	if err != nil {
+		log.Printf("could not check chirp %s for the gateway: %s", chirpID, err)
+		return "could not load the chirp"
+	}
+	if !visible {
+		return "chirp not found"
+	}
+	return ""
+}
+
+func parseSubscription(msg ClientMessage) (subscription, string) {
+	switch msg.Channel {
+	case ChannelTimeline, ChannelNotifications:
+		return subscription{channel: msg.Channel}, ""
+	case ChannelChirp:
+		if msg.ChirpID == uuid.Nil {
+			return subscription{}, "chirp_id is required for the chirp channel"
+		}
+		return subscription{channel: ChannelChirp, chirpID: msg.ChirpID}, ""
+	default:
+		return subscription{}, "unknown channel"
+	}
+}
+
+// close sends a close frame with code and reason, then tears the connection
+// down. Only the first call has any effect.
+func (c *conn) close(code int, reason string) {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		if code != websocket.CloseAbnormalClosure {
+			c.ws.WriteControl(
+				websocket.CloseMessage,
+				websocket.FormatCloseMessage(code, reason),
+				time.Now().Add(c.gateway.WriteWait),
+			)
+		}
+		c.ws.Close()
+	})
+}
@@ -0,0 +1,299 @@
+package gateway_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/RemcoVeens/httpserver/internal/gateway"
+	"github.com/RemcoVeens/httpserver/internal/pubsub"
+	"github.com/google/uuid"
+	"github.com/gorilla/websocket"
+)
+
+// testAuth treats the bearer token as the user id, so tests can connect as
+// any user without JWTs.
+func testAuth(r *http.Request) (uuid.UUID, error) {
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if token == "" {
+		return uuid.Nil, errors.New("no token")
+	}
+	return uuid.Parse(token)
+}
+
+type harness struct {
+	broker *pubsub.Broker
+	server *httptest.Server
+	hidden []uuid.UUID
+	// invisible holds the chirps no user may see.
+	invisible map[uuid.UUID]bool
+}
+
+func newHarness(t *testing.T, configure func(*gateway.Gateway)) *harness {
+	t.Helper()
+	h := &harness{broker: pubsub.NewBroker(16, 16), invisible: map[uuid.UUID]bool{}}
+	g := gateway.New(h.broker, testAuth, func(context.Context, uuid.UUID) ([]uuid.UUID, error) {
+		return h.hidden, nil
+	}, func(_ context.Context, _, chirpID uuid.UUID) (bool, error) {
+		return !h.invisible[chirpID], nil
+	})
+	if configure != nil {
+		configure(g)
+	}
+	h.server = httptest.NewServer(g)
+	t.Cleanup(h.server.Close)
+	return h
+}
+
+func (h *harness) dial(t *testing.T, user uuid.UUID) *websocket.Conn {
+	t.Helper()
+	url := "ws" + strings.TrimPrefix(h.server.URL, "http")
+	header := http.Header{"Authorization": {"Bearer " + user.String()}}
+	ws, _, err := websocket.DefaultDialer.Dial(url, header)
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	t.Cleanup(func() { ws.Close() })
+	return ws
+}
+
+func send(t *testing.T, ws *websocket.Conn, msg map[string]any) {
+	t.Helper()
+	if err := ws.WriteJSON(msg); err != nil {
+		t.Fatalf("write failed: %v", err)
+	}
+}
+
+func read(t *testing.T, ws *websocket.Conn) gateway.ServerMessage {
+	t.Helper()
+	ws.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var msg gateway.ServerMessage
+	if err := ws.ReadJSON(&msg); err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	return msg
+}
+
+func subscribe(t *testing.T, ws *websocket.Conn, msg map[string]any) {
+	t.Helper()
+	msg["type"] = gateway.TypeSubscribe
+	send(t, ws, msg)
+	if ack := read(t, ws); ack.Type != gateway.TypeAck {
+		t.Fatalf("expected ack, got %+v", ack)
+	}
+}
+
+func TestRejectsUnauthenticated(t *testing.T) {
+	h := newHarness(t, nil)
+	url := "ws" + strings.TrimPrefix(h.server.URL, "http")
+	_, resp, err := websocket.DefaultDialer.Dial(url, nil)
+	if err == nil {
+		t.Fatalf("expected dial to fail")
+	}
+	if resp == nil || resp.StatusCode != 401 {
+		t.Fatalf("expected 401, got %v", resp)
+	}
+}
+
+func TestPingPong(t *testing.T) {
+	h := newHarness(t, nil)
+	ws := h.dial(t, uuid.New())
+	send(t, ws, map[string]any{"type": "ping", "id": "p1"})
+	if msg := read(t, ws); msg.Type != gateway.TypePong || msg.ID != "p1" {
+		t.Fatalf("expected pong p1, got %+v", msg)
+	}
+}
+
+func TestTimelineSubscription(t *testing.T) {
+	h := newHarness(t, nil)
+	ws := h.dial(t, uuid.New())
+	subscribe(t, ws, map[string]any{"channel": "timeline", "id": "s1"})
+
+	author := uuid.New()
+	h.broker.Publish(pubsub.ChirpCreated, map[string]any{"id": uuid.New(), "user_id": author, "body": "hi"})
+	msg := read(t, ws)
+	if msg.Type != gateway.TypeEvent || msg.Channel != gateway.ChannelTimeline || msg.Event != pubsub.ChirpCreated {
+		t.Fatalf("unexpected message: %+v", msg)
+	}
+	if msg.EventID != 1 {
+		t.Errorf("expected event id 1, got %d", msg.EventID)
+	}
+
+	send(t, ws, map[string]any{"type": "unsubscribe", "channel": "timeline"})
+	if ack := read(t, ws); ack.Type != gateway.TypeAck {
+		t.Fatalf("expected ack, got %+v", ack)
+	}
+	h.broker.Publish(pubsub.ChirpCreated, map[string]any{"id": uuid.New(), "user_id": author})
+	send(t, ws, map[string]any{"type": "ping"})
+	if msg := read(t, ws); msg.Type != gateway.TypePong {
+		t.Fatalf("expected no more events after unsubscribe, got %+v", msg)
+	}
+}
+
+func TestTimelineHidesBlockedAuthors(t *testing.T) {
+	h := newHarness(t, nil)
+	blocked := uuid.New()
+	h.hidden = []uuid.UUID{blocked}
+	ws := h.dial(t, uuid.New())
+	subscribe(t, ws, map[string]any{"channel": "timeline"})
+
+	h.broker.Publish(pubsub.ChirpCreated, map[string]any{"id": uuid.New(), "user_id": blocked})
+	visible := uuid.New()
+	h.broker.Publish(pubsub.ChirpCreated, map[string]any{"id": uuid.New(), "user_id": visible})
+	if msg := read(t, ws); msg.EventID != 2 {
+		t.Fatalf("expected only the visible chirp, got %+v", msg)
+	}
+}
+
+func TestNotificationsOnlyForRecipient(t *testing.T) {
+	h := newHarness(t, nil)
+	me := uuid.New()
+	ws := h.dial(t, me)
+	subscribe(t, ws, map[string]any{"channel": "notifications"})
+
+	h.broker.Publish(pubsub.NotificationCreated, map[string]any{"user_id": uuid.New(), "type": "mention"})
+	h.broker.Publish(pubsub.NotificationCreated, map[string]any{"user_id": me, "type": "mention"})
+	msg := read(t, ws)
+	if msg.Channel != gateway.ChannelNotifications || msg.EventID != 2 {
+		t.Fatalf("expected my notification only, got %+v", msg)
+	}
+}
+
+func TestChirpThreadSubscription(t *testing.T) {
+	h := newHarness(t, nil)
+	ws := h.dial(t, uuid.New())
+
+	send(t, ws, map[string]any{"type": "subscribe", "channel": "chirp", "id": "bad"})
+	if msg := read(t, ws); msg.Type != gateway.TypeError || msg.ID != "bad" {
+		t.Fatalf("expected error without chirp_id, got %+v", msg)
+	}
+
+	chirp := uuid.New()
+	subscribe(t, ws, map[string]any{"channel": "chirp", "chirp_id": chirp})
+	h.broker.Publish(pubsub.ChirpDeleted, map[string]any{"id": uuid.New(), "user_id": uuid.New()})
+	h.broker.Publish(pubsub.ChirpDeleted, map[string]any{"id": chirp, "user_id": uuid.New()})
+	msg := read(t, ws)
+	if msg.Channel != gateway.ChannelChirp || msg.ChirpID == nil || *msg.ChirpID != chirp || msg.EventID != 2 {
+		t.Fatalf("unexpected message: %+v", msg)
+	}
+}
+
+func TestChirpThreadIncludesReplies(t *testing.T) {
+	h := newHarness(t, nil)
+	ws := h.dial(t, uuid.New())
+
+	chirp, reply := uuid.New(), uuid.New()
+	subscribe(t, ws, map[string]any{"channel": "chirp", "chirp_id": chirp})
+	h.broker.Publish(pubsub.ChirpCreated, map[string]any{"id": uuid.New(), "user_id": uuid.New(), "reply_to_id": uuid.New()})
+	h.broker.Publish(pubsub.ChirpCreated, map[string]any{"id": reply, "user_id": uuid.New(), "reply_to_id": chirp})
+	msg := read(t, ws)
+	if msg.Channel != gateway.ChannelChirp || msg.Event != pubsub.ChirpCreated || msg.EventID != 2 {
+		t.Fatalf("the reply was not delivered: %+v", msg)
+	}
+	var data struct {
+		ID uuid.UUID `json:"id"`
+	}
+	if err := json.Unmarshal(msg.Data, &data); err != nil || data.ID != reply {
+		t.Errorf("got %s, want the reply", msg.Data)
+	}
+}
+
+func TestChirpThreadRequiresVisibleChirp(t *testing.T) {
+	h := newHarness(t, nil)
+	ws := h.dial(t, uuid.New())
+	chirp := uuid.New()
+	h.invisible[chirp] = true
+	send(t, ws, map[string]any{"type": "subscribe", "id": "s1", "channel": "chirp", "chirp_id": chirp})
+	if msg := read(t, ws); msg.Type != gateway.TypeError || msg.ID != "s1" {
+		t.Fatalf("expected an error for a chirp the user can not see, got %+v", msg)
+	}
+
+	// Replies to it do not arrive, while those to a visible chirp do.
+	other := uuid.New()
+	subscribe(t, ws, map[string]any{"channel": "chirp", "chirp_id": other})
+	h.broker.Publish(pubsub.ChirpCreated, map[string]any{"id": uuid.New(), "user_id": uuid.New(), "reply_to_id": chirp})
+	h.broker.Publish(pubsub.ChirpCreated, map[string]any{"id": uuid.New(), "user_id": uuid.New(), "reply_to_id": other})
+	if msg := read(t, ws); msg.EventID != 2 || msg.ChirpID == nil || *msg.ChirpID != other {
+		t.Fatalf("expected only the reply to the visible chirp, got %+v", msg)
+	}
+}
+
+func TestUnknownMessages(t *testing.T) {
+	h := newHarness(t, nil)
+	ws := h.dial(t, uuid.New())
+	send(t, ws, map[string]any{"type": "dance"})
+	if msg := read(t, ws); msg.Type != gateway.TypeError {
+		t.Fatalf("expected error for unknown type, got %+v", msg)
+	}
+	send(t, ws, map[string]any{"type": "subscribe", "channel": "everything"})
+	if msg := read(t, ws); msg.Type != gateway.TypeError {
+		t.Fatalf("expected error for unknown channel, got %+v", msg)
+	}
+	ws.WriteMessage(websocket.TextMessage, []byte("{not json"))
+	if msg := read(t, ws); msg.Type != gateway.TypeError {
+		t.Fatalf("expected error for invalid JSON, got %+v", msg)
+	}
+}
+
+// TestSlowClientIsDisconnected fills a one-slot send queue while the client
+// is not reading and expects the server to close the connection.
+func TestSlowClientIsDisconnected(t *testing.T) {
+	h := newHarness(t, func(g *gateway.Gateway) {
+		g.QueueSize = 1
+		g.WriteWait = 50 * time.Millisecond
+	})
+	ws := h.dial(t, uuid.New())
+	subscribe(t, ws, map[string]any{"channel": "timeline"})
+
+	payload := map[string]any{"user_id": uuid.New(), "body": strings.Repeat("x", 64*1024)}
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) && h.broker.Subscribers() > 0 {
+		h.broker.Publish(pubsub.ChirpCreated, payload)
+	}
+	if h.broker.Subscribers() != 0 {
+		t.Fatalf("slow client was never disconnected")
+	}
+	ws.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		if _, _, err := ws.ReadMessage(); err != nil {
+			return
+		}
+	}
+}
+
+func TestServerPingsAreAnswered(t *testing.T) {
+	h := newHarness(t, func(g *gateway.Gateway) {
+		g.PingInterval = 20 * time.Millisecond
+		g.PongWait = 250 * time.Millisecond
+	})
+	ws := h.dial(t, uuid.New())
+	pings := make(chan struct{}, 10)
+	ws.SetPingHandler(func(data string) error {
+		select {
+		case pings <- struct{}{}:
+		default:
+		}
+		return ws.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(time.Second))
+	})
+	go func() {
+		for {
+			if _, _, err := ws.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+	// Outlive several pong waits; the connection must stay up because the
+	// default client answers every ping.
+	time.Sleep(time.Second)
+	if len(pings) == 0 {
+		t.Fatalf("expected server pings")
+	}
+	if h.broker.Subscribers() != 1 {
+		t.Fatalf("connection should still be open")
+	}
+}
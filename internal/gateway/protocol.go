@@ -0,0 +1,66 @@
+package gateway
+
+import (
+	"encoding/json"
+
+	"github.com/google/uuid"
+)
+
+// Message types sent by clients.
+const (
+	TypeSubscribe   = "subscribe"
+	TypeUnsubscribe = "unsubscribe"
+	TypePing        = "ping"
+)
+
+// Message types sent by the server.
+const (
+	TypeAck   = "ack"
+	TypeEvent = "event"
+	TypeError = "error"
+	TypePong  = "pong"
+)
+
+// Channels a client can subscribe to.
+const (
+	ChannelTimeline      = "timeline"
+	ChannelNotifications = "notifications"
+	ChannelChirp         = "chirp"
+)
+
+// ClientMessage is a frame sent by the client. ID is optional and echoed
+// back in the ack or error for the message. ChirpID is required for the
+// chirp channel and ignored otherwise.
+type ClientMessage struct {
+	Type    string    `json:"type"`
+	ID      string    `json:"id,omitempty"`
+	Channel string    `json:"channel,omitempty"`
+	ChirpID uuid.UUID `json:"chirp_id,omitempty"`
+}
+
+// ServerMessage is a frame sent by the server. Events carry the broker event
+// type and id plus the event payload in Data.
+type ServerMessage struct {
+	Type    string          `json:"type"`
+	ID      string          `json:"id,omitempty"`
+	Channel string          `json:"channel,omitempty"`
+	ChirpID *uuid.UUID      `json:"chirp_id,omitempty"`
+	Event   string          `json:"event,omitempty"`
+	EventID uint64          `json:"event_id,omitempty"`
+	Data    json.RawMessage `json:"data,omitempty"`
+	Error   string          `json:"error,omitempty"`
+}
+
+// subscription identifies one channel a connection listens to.
+type subscription struct {
+	channel string
+	chirpID uuid.UUID
+}
+
+func (s subscription) chirpIDPtr() *uuid.UUID {
+	if s.channel != ChannelChirp {
+		return nil
+	}
+	id := s.chirpID
+	return &id
+}
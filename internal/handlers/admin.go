@@ -0,0 +1,184 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/RemcoVeens/httpserver/internal/apierror"
+	"github.com/RemcoVeens/httpserver/internal/database"
+)
+
+const (
+	defaultInboundEventLimit = 50
+	maxInboundEventLimit     = 200
+)
+
+// requireAdmin resolves the authenticated caller and checks that they are an
+// admin. It writes the error response itself and reports ok=false when the
+// request cannot continue.
+func (cfg *APIConfig) requireAdmin(w http.ResponseWriter, r *http.Request) (database.User, bool) {
+	user, ok := cfg.currentUser(w, r)
+	if !ok {
+		return database.User{}, false
+	}
+	admin, err := cfg.Queries.IsAdmin(r.Context(), user.ID)
+	if err != nil {
+		apierror.Write(w, r, fmt.Errorf("error checking admin: %w", err))
+		return database.User{}, false
+	}
+	if !admin {
+		apierror.Write(w, r, apierror.New(apierror.Forbidden, "admins only"))
+		return database.User{}, false
+	}
+	return user, true
+}
+
+type inboundEventResponse struct {
+	ID              int64           `json:"id"`
+	Provider        string          `json:"provider"`
+	ProviderEventID *string         `json:"provider_event_id"`
+	EventType       string          `json:"event_type"`
+	Payload         json.RawMessage `json:"payload,omitempty"`
+	Status          string          `json:"status"`
+	Attempts        int32           `json:"attempts"`
+	NextAttemptAt   *time.Time      `json:"next_attempt_at"`
+	LastError       *string         `json:"last_error"`
+	ReceivedAt      time.Time       `json:"received_at"`
+	ProcessedAt     *time.Time      `json:"processed_at"`
+}
+
+func newInboundEventResponse(ev database.InboundEvent) inboundEventResponse {
+	resp := inboundEventResponse{
+		ID:         ev.ID,
+		Provider:   ev.Provider,
+		EventType:  ev.EventType,
+		Status:     ev.Status,
+		Attempts:   ev.Attempts,
+		ReceivedAt: ev.ReceivedAt,
+	}
+	if ev.ProviderEventID.Valid {
+		resp.ProviderEventID = &ev.ProviderEventID.String
+	}
+	if ev.Status == "pending" {
+		resp.NextAttemptAt = &ev.NextAttemptAt
+	}
+	if ev.LastError.Valid {
+		resp.LastError = &ev.LastError.String
+	}
+	if ev.ProcessedAt.Valid {
+		resp.ProcessedAt = &ev.ProcessedAt.Time
+	}
+	return resp
+}
+
+// GetInboundEvents lists received webhooks newest first, without their
+// payloads. ?status= and ?provider= filter the list; pass the id of the last
+// event as ?cursor= for the next page.
+func (cfg *APIConfig) GetInboundEvents(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if _, ok := cfg.requireAdmin(w, r); !ok {
+		return
+	}
+	params := database.GetInboundEventsParams{
+		BeforeID:  math.MaxInt64,
+		MaxEvents: defaultInboundEventLimit,
+	}
+	query := r.URL.Query()
+	switch status := query.Get("status"); status {
+	case "":
+	case "pending", "processed", "dead":
+		params.Status = nullString(&status)
+	default:
+		apierror.Write(w, r, apierror.New(apierror.InvalidRequest, "status must be pending, processed or dead"))
+		return
+	}
+	if provider := query.Get("provider"); provider != "" {
+		params.Provider = nullString(&provider)
+	}
+	if v := query.Get("cursor"); v != "" {
+		before, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			apierror.Write(w, r, apierror.Errorf(apierror.InvalidRequest, "invalid cursor: %s", err))
+			return
+		}
+		params.BeforeID = before
+	}
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxInboundEventLimit {
+			apierror.Write(w, r, apierror.Errorf(apierror.InvalidRequest, "limit must be between 1 and %d", maxInboundEventLimit))
+			return
+		}
+		params.MaxEvents = int32(n)
+	}
+	evs, err := cfg.Queries.GetInboundEvents(r.Context(), params)
+	if err != nil {
+		apierror.Write(w, r, fmt.Errorf("error fetching events: %w", err))
+		return
+	}
+	resp := make([]inboundEventResponse, len(evs))
+	for i, ev := range evs {
+		resp[i] = newInboundEventResponse(ev)
+	}
+	dat, err := json.Marshal(resp)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	w.WriteHeader(200)
+	w.Write(dat)
+}
+
+// GetInboundEvent returns one received webhook including its payload.
+func (cfg *APIConfig) GetInboundEvent(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if _, ok := cfg.requireAdmin(w, r); !ok {
+		return
+	}
+	id, err := strconv.ParseInt(r.PathValue("event_id"), 10, 64)
+	if err != nil {
+		apierror.Write(w, r, apierror.Errorf(apierror.InvalidRequest, "could not parse event id: %s", err))
+		return
+	}
+	ev, err := cfg.Queries.GetInboundEvent(r.Context(), id)
+	if err != nil {
+		apierror.Write(w, r, lookupError(err, "event not found"))
+		return
+	}
+	resp := newInboundEventResponse(ev)
+	resp.Payload = ev.Payload
+	dat, err := json.Marshal(resp)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	w.WriteHeader(200)
+	w.Write(dat)
+}
+
+// ReplayInboundEvent queues a dead or processed event again with a fresh set
+// of attempts.
+func (cfg *APIConfig) ReplayInboundEvent(w http.ResponseWriter, r *http.Request) {
+	if _, ok := cfg.requireAdmin(w, r); !ok {
+		return
+	}
+	id, err := strconv.ParseInt(r.PathValue("event_id"), 10, 64)
+	if err != nil {
+		apierror.Write(w, r, apierror.Errorf(apierror.InvalidRequest, "could not parse event id: %s", err))
+		return
+	}
+	n, err := cfg.Queries.ReplayInboundEvent(r.Context(), id)
+	if err != nil {
+		apierror.Write(w, r, fmt.Errorf("could not replay event: %w", err))
+		return
+	}
+	if n == 0 {
+		apierror.Write(w, r, apierror.New(apierror.Conflict, "event does not exist or is already pending"))
+		return
+	}
+	w.WriteHeader(202)
+}
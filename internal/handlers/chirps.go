@@ -0,0 +1,262 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/RemcoVeens/httpserver/internal/apierror"
+	"github.com/RemcoVeens/httpserver/internal/database"
+	"github.com/RemcoVeens/httpserver/internal/entities"
+	"github.com/RemcoVeens/httpserver/internal/events"
+	"github.com/RemcoVeens/httpserver/internal/notifications"
+	"github.com/RemcoVeens/httpserver/internal/pubsub"
+	"github.com/google/uuid"
+)
+
+// chirpAuthor is the minimal public view of a user embedded in chirps.
+type chirpAuthor struct {
+	ID          uuid.UUID `json:"id"`
+	Handle      string    `json:"handle"`
+	DisplayName string    `json:"display_name"`
+	AvatarUrl   string    `json:"avatar_url"`
+}
+
+// chirpResponse is how a chirp is rendered by every chirp endpoint.
+type chirpResponse struct {
+	database.Chirp
+	Author   chirpAuthor       `json:"author"`
+	Entities entities.Entities `json:"entities"`
+	Media    []mediaResponse   `json:"media"`
+}
+
+// errReplyUnavailable is returned by createChirp when the chirp replied to
+// does not exist or is hidden from the author by a block.
+var errReplyUnavailable = errors.New("chirp replied to not found")
+
+// newChirp is what a user posts.
+type newChirp struct {
+	Body string
+	// Media are attached in order; each must be an unattached upload of
+	// the author.
+	Media   []uuid.UUID
+	ReplyTo uuid.NullUUID
+}
+
+// createChirp stores a chirp together with the hashtags and mentions parsed
+// from its body, notifies the mentioned users and the author of the chirp
+// replied to, and emits chirp.created, all in a single transaction. Mentions
+// of handles that do not belong to anyone are kept with a null user id.
+// errMediaUnavailable and errReplyUnavailable report unusable references.
+func (cfg *APIConfig) createChirp(ctx context.Context, author database.User, in newChirp) (chirpResponse, error) {
+	parsed := entities.Parse(in.Body)
+	var resp chirpResponse
+	err := database.RunInTx(ctx, cfg.DB, func(q *database.Queries) error {
+		var parent database.Chirp
+		if in.ReplyTo.Valid {
+			var err error
+			parent, err = q.GetVisibleChirpFromId(ctx, database.GetVisibleChirpFromIdParams{
+				ID:       in.ReplyTo.UUID,
+				ViewerID: author.ID,
+			})
+			if errors.Is(err, sql.ErrNoRows) {
+				return fmt.Errorf("%w: %s", errReplyUnavailable, in.ReplyTo.UUID)
+			}
+			if err != nil {
+				return fmt.Errorf("could not get chirp replied to: %w", err)
+			}
+		}
+		chirp, err := q.CreateChirp(ctx, database.CreateChirpParams{
+			Body:      in.Body,
+			UserID:    author.ID,
+			ReplyToID: in.ReplyTo,
+		})
+		if err != nil {
+			return fmt.Errorf("could not create chirp: %w", err)
+		}
+		attached := []mediaResponse{}
+		for i, id := range in.Media {
+			n, err := q.AttachMediaFile(ctx, database.AttachMediaFileParams{
+				ChirpID:  uuid.NullUUID{UUID: chirp.ID, Valid: true},
+				Position: int16(i),
+				ID:       id,
+				UserID:   author.ID,
+			})
+			if err != nil {
+				return fmt.Errorf("could not attach media %s: %w", id, err)
+			}
+			if n == 0 {
+				return fmt.Errorf("%w: %s", errMediaUnavailable, id)
+			}
+		}
+		if len(in.Media) > 0 {
+			files, err := q.GetMediaForChirps(ctx, []uuid.UUID{chirp.ID})
+			if err != nil {
+				return fmt.Errorf("could not get media: %w", err)
+			}
+			if attached, err = cfg.mediaResponses(ctx, q, files); err != nil {
+				return err
+			}
+		}
+		for _, tag := range parsed.Tags() {
+			if err := q.AddChirpHashtag(ctx, database.AddChirpHashtagParams{
+				ChirpID:   chirp.ID,
+				Tag:       tag,
+				CreatedAt: chirp.CreatedAt,
+			}); err != nil {
+				return fmt.Errorf("could not store hashtag %q: %w", tag, err)
+			}
+		}
+		resp = chirpResponse{
+			Chirp: chirp,
+			Author: chirpAuthor{
+				ID:          author.ID,
+				Handle:      author.Handle,
+				DisplayName: author.DisplayName,
+				AvatarUrl:   author.AvatarUrl,
+			},
+			Entities: parsed,
+			Media:    attached,
+		}
+		chirpID := uuid.NullUUID{UUID: chirp.ID, Valid: true}
+		if in.ReplyTo.Valid {
+			if err := notify(ctx, q, parent.UserID, author.ID, notifications.Reply, chirpID); err != nil {
+				return err
+			}
+		}
+		if len(parsed.Mentions) == 0 {
+			return events.Emit(ctx, q, pubsub.ChirpCreated, author.ID, resp)
+		}
+		resolved, err := q.GetUserIDsFromHandles(ctx, parsed.Handles())
+		if err != nil {
+			return fmt.Errorf("could not resolve mentions: %w", err)
+		}
+		ids := make(map[string]uuid.UUID, len(resolved))
+		for _, u := range resolved {
+			ids[u.Handle] = u.ID
+		}
+		for i, m := range parsed.Mentions {
+			if id, ok := ids[m.Handle]; ok {
+				m.UserID = uuid.NullUUID{UUID: id, Valid: true}
+			}
+			if err := q.AddChirpMention(ctx, database.AddChirpMentionParams{
+				ChirpID:     chirp.ID,
+				Handle:      m.Handle,
+				UserID:      m.UserID,
+				StartOffset: int32(m.Start),
+				EndOffset:   int32(m.End),
+			}); err != nil {
+				return fmt.Errorf("could not store mention %q: %w", m.Handle, err)
+			}
+			parsed.Mentions[i] = m
+		}
+		for _, u := range resolved {
+			if err := notify(ctx, q, u.ID, author.ID, notifications.Mention, chirpID); err != nil {
+				return err
+			}
+		}
+		return events.Emit(ctx, q, pubsub.ChirpCreated, author.ID, resp)
+	})
+	if err != nil {
+		return chirpResponse{}, err
+	}
+	return resp, nil
+}
+
+// chirpResponses attaches authors, entities and media to chirps. Hashtags are
+// re-parsed from the body; mentions come from chirp_mentions so they carry
+// the resolved user ids.
+func (cfg *APIConfig) chirpResponses(ctx context.Context, chirps []database.Chirp) ([]chirpResponse, error) {
+	ids := make([]uuid.UUID, len(chirps))
+	authorIDs := make([]uuid.UUID, 0, len(chirps))
+	seen := map[uuid.UUID]bool{}
+	for i, c := range chirps {
+		ids[i] = c.ID
+		if !seen[c.UserID] {
+			seen[c.UserID] = true
+			authorIDs = append(authorIDs, c.UserID)
+		}
+	}
+	mentions := map[uuid.UUID][]entities.Mention{}
+	authors := map[uuid.UUID]chirpAuthor{}
+	attached := map[uuid.UUID][]mediaResponse{}
+	if len(ids) > 0 {
+		rows, err := cfg.Queries.GetMentionsForChirps(ctx, ids)
+		if err != nil {
+			return nil, fmt.Errorf("could not get mentions: %w", err)
+		}
+		for _, m := range rows {
+			mentions[m.ChirpID] = append(mentions[m.ChirpID], entities.Mention{
+				Handle: m.Handle,
+				UserID: m.UserID,
+				Start:  int(m.StartOffset),
+				End:    int(m.EndOffset),
+			})
+		}
+		files, err := cfg.Queries.GetMediaForChirps(ctx, ids)
+		if err != nil {
+			return nil, fmt.Errorf("could not get media: %w", err)
+		}
+		rendered, err := cfg.mediaResponses(ctx, cfg.Queries, files)
+		if err != nil {
+			return nil, err
+		}
+		for i, f := range files {
+			attached[f.ChirpID.UUID] = append(attached[f.ChirpID.UUID], rendered[i])
+		}
+		users, err := cfg.Queries.GetAuthorsFromIDs(ctx, authorIDs)
+		if err != nil {
+			return nil, fmt.Errorf("could not get authors: %w", err)
+		}
+		for _, u := range users {
+			authors[u.ID] = chirpAuthor(u)
+		}
+	}
+	out := make([]chirpResponse, len(chirps))
+	for i, c := range chirps {
+		e := entities.Parse(c.Body)
+		e.Mentions = mentions[c.ID]
+		if e.Mentions == nil {
+			e.Mentions = []entities.Mention{}
+		}
+		m := attached[c.ID]
+		if m == nil {
+			m = []mediaResponse{}
+		}
+		out[i] = chirpResponse{Chirp: c, Author: authors[c.UserID], Entities: e, Media: m}
+	}
+	return out, nil
+}
+
+// GetChirpReplies returns the direct replies to {chirp_id}, oldest first,
+// leaving out authors the caller blocked or muted.
+func (cfg *APIConfig) GetChirpReplies(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	chirp, ok := cfg.visibleChirp(w, r, "chirp_id")
+	if !ok {
+		return
+	}
+	replies, err := cfg.Queries.GetChirpReplies(r.Context(), database.GetChirpRepliesParams{
+		ReplyToID: uuid.NullUUID{UUID: chirp.ID, Valid: true},
+		ViewerID:  cfg.viewerID(r),
+	})
+	if err != nil {
+		apierror.Write(w, r, fmt.Errorf("could not fetch replies: %w", err))
+		return
+	}
+	resp, err := cfg.chirpResponses(r.Context(), replies)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	dat, err := json.Marshal(resp)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	w.WriteHeader(200)
+	w.Write(dat)
+}
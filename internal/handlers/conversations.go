@@ -0,0 +1,374 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"github.com/RemcoVeens/httpserver/internal/apierror"
+	"github.com/RemcoVeens/httpserver/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	maxConversationMembers = 10
+	maxMessageLength       = 1000
+	defaultMessageLimit    = 50
+	maxMessageLimit        = 200
+)
+
+type lastMessage struct {
+	ID        int64     `json:"id"`
+	SenderID  uuid.UUID `json:"sender_id"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type conversationResponse struct {
+	ID          uuid.UUID     `json:"id"`
+	IsGroup     bool          `json:"is_group"`
+	CreatedAt   time.Time     `json:"created_at"`
+	UpdatedAt   time.Time     `json:"updated_at"`
+	Members     []chirpAuthor `json:"members"`
+	LastMessage *lastMessage  `json:"last_message"`
+	UnreadCount int64         `json:"unread_count"`
+}
+
+// conversationMember resolves the {conversation_id} path value and checks the
+// caller belongs to it. Non-members get a 404 so conversation ids can not be
+// probed. It writes the error response itself and reports ok=false when the
+// request cannot continue.
+func (cfg *APIConfig) conversationMember(w http.ResponseWriter, r *http.Request) (database.User, uuid.UUID, bool) {
+	user, ok := cfg.currentUser(w, r)
+	if !ok {
+		return database.User{}, uuid.Nil, false
+	}
+	conversationID, err := uuid.Parse(r.PathValue("conversation_id"))
+	if err != nil {
+		apierror.Write(w, r, apierror.New(apierror.NotFound, "conversation not found"))
+		return database.User{}, uuid.Nil, false
+	}
+	member, err := cfg.Queries.IsConversationMember(r.Context(), database.IsConversationMemberParams{
+		ConversationID: conversationID,
+		UserID:         user.ID,
+	})
+	if err != nil {
+		apierror.Write(w, r, fmt.Errorf("error checking membership: %w", err))
+		return database.User{}, uuid.Nil, false
+	}
+	if !member {
+		apierror.Write(w, r, apierror.New(apierror.NotFound, "conversation not found"))
+		return database.User{}, uuid.Nil, false
+	}
+	return user, conversationID, true
+}
+
+// StartConversation opens a conversation between the caller and member_ids.
+// Starting a one-to-one conversation that already exists returns the
+// existing one instead of creating a duplicate.
+func (cfg *APIConfig) StartConversation(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	type input struct {
+		MemberIDs []uuid.UUID `json:"member_ids"`
+	}
+	user, ok := cfg.currentUser(w, r)
+	if !ok {
+		return
+	}
+	var params input
+	if err := decodeJSON(r, &params); err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	var others []uuid.UUID
+	for _, id := range params.MemberIDs {
+		if id != user.ID && !slices.Contains(others, id) {
+			others = append(others, id)
+		}
+	}
+	if len(others) == 0 {
+		apierror.Write(w, r, apierror.New(apierror.InvalidRequest, "a conversation needs at least one other member"))
+		return
+	}
+	if len(others)+1 > maxConversationMembers {
+		apierror.Write(w, r, apierror.Errorf(apierror.InvalidRequest, "a conversation can have at most %d members", maxConversationMembers))
+		return
+	}
+	for _, id := range others {
+		if _, err := cfg.Queries.GetUserFromId(r.Context(), id); err != nil {
+			apierror.Write(w, r, lookupError(err, fmt.Sprintf("user %s not found", id)))
+			return
+		}
+	}
+	blocked, err := cfg.Queries.HasBlockWithUsers(r.Context(), database.HasBlockWithUsersParams{
+		UserID:   user.ID,
+		OtherIds: others,
+	})
+	if err != nil {
+		apierror.Write(w, r, fmt.Errorf("error checking blocks: %w", err))
+		return
+	}
+	if blocked {
+		apierror.Write(w, r, apierror.New(apierror.Forbidden, "you can not message one or more of these users"))
+		return
+	}
+
+	status := 201
+	var conversation database.Conversation
+	err = database.RunInTx(r.Context(), cfg.DB, func(q *database.Queries) error {
+		var err error
+		if len(others) > 1 {
+			conversation, err = q.CreateConversation(r.Context(), true)
+		} else {
+			pair := database.CreateDirectConversationParams{UserA: user.ID, UserB: others[0]}
+			conversation, err = q.CreateDirectConversation(r.Context(), pair)
+			if errors.Is(err, sql.ErrNoRows) {
+				// The pair already has a conversation, perhaps one a
+				// concurrent request has just created; the insert waited
+				// for it to commit.
+				status = 200
+				conversation, err = q.GetDirectConversation(r.Context(), database.GetDirectConversationParams(pair))
+				if err != nil {
+					return fmt.Errorf("could not get conversation: %w", err)
+				}
+				return nil
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("could not create conversation: %w", err)
+		}
+		for _, id := range append([]uuid.UUID{user.ID}, others...) {
+			if err := q.AddConversationMember(r.Context(), database.AddConversationMemberParams{
+				ConversationID: conversation.ID,
+				UserID:         id,
+			}); err != nil {
+				return fmt.Errorf("could not add member: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		apierror.Write(w, r, fmt.Errorf("error creating conversation: %w", err))
+		return
+	}
+	members, err := cfg.Queries.GetMembersForConversations(r.Context(), []uuid.UUID{conversation.ID})
+	if err != nil {
+		apierror.Write(w, r, fmt.Errorf("error fetching members: %w", err))
+		return
+	}
+	resp := conversationResponse{
+		ID:        conversation.ID,
+		IsGroup:   conversation.IsGroup,
+		CreatedAt: conversation.CreatedAt,
+		UpdatedAt: conversation.UpdatedAt,
+		Members:   make([]chirpAuthor, 0, len(members)),
+	}
+	for _, m := range members {
+		resp.Members = append(resp.Members, chirpAuthor{m.ID, m.Handle, m.DisplayName, m.AvatarUrl})
+	}
+	dat, err := json.Marshal(resp)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	w.WriteHeader(status)
+	w.Write(dat)
+}
+
+func (cfg *APIConfig) GetConversations(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	user, ok := cfg.currentUser(w, r)
+	if !ok {
+		return
+	}
+	rows, err := cfg.Queries.GetConversationsForUser(r.Context(), user.ID)
+	if err != nil {
+		apierror.Write(w, r, fmt.Errorf("error fetching conversations: %w", err))
+		return
+	}
+	ids := make([]uuid.UUID, len(rows))
+	for i, c := range rows {
+		ids[i] = c.ID
+	}
+	members := map[uuid.UUID][]chirpAuthor{}
+	if len(ids) > 0 {
+		memberRows, err := cfg.Queries.GetMembersForConversations(r.Context(), ids)
+		if err != nil {
+			apierror.Write(w, r, fmt.Errorf("error fetching members: %w", err))
+			return
+		}
+		for _, m := range memberRows {
+			members[m.ConversationID] = append(members[m.ConversationID], chirpAuthor{m.ID, m.Handle, m.DisplayName, m.AvatarUrl})
+		}
+	}
+	resp := make([]conversationResponse, len(rows))
+	for i, c := range rows {
+		resp[i] = conversationResponse{
+			ID:          c.ID,
+			IsGroup:     c.IsGroup,
+			CreatedAt:   c.CreatedAt,
+			UpdatedAt:   c.UpdatedAt,
+			Members:     members[c.ID],
+			UnreadCount: c.UnreadCount,
+		}
+		if c.LastMessageID.Valid {
+			resp[i].LastMessage = &lastMessage{
+				ID:        c.LastMessageID.Int64,
+				SenderID:  c.LastMessageSenderID.UUID,
+				Body:      c.LastMessageBody.String,
+				CreatedAt: c.LastMessageAt.Time,
+			}
+		}
+	}
+	dat, err := json.Marshal(resp)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	w.WriteHeader(200)
+	w.Write(dat)
+}
+
+// SendMessage posts a message to a conversation. It is refused while any
+// block exists between the sender and another member.
+func (cfg *APIConfig) SendMessage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	type input struct {
+		Body string `json:"body"`
+	}
+	user, conversationID, ok := cfg.conversationMember(w, r)
+	if !ok {
+		return
+	}
+	var params input
+	if err := decodeJSON(r, &params); err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	if params.Body == "" || utf8.RuneCountInString(params.Body) > maxMessageLength {
+		apierror.Write(w, r, apierror.Errorf(apierror.InvalidRequest, "message must be between 1 and %d characters", maxMessageLength))
+		return
+	}
+	memberIDs, err := cfg.Queries.GetConversationMemberIDs(r.Context(), conversationID)
+	if err != nil {
+		apierror.Write(w, r, fmt.Errorf("error fetching members: %w", err))
+		return
+	}
+	blocked, err := cfg.Queries.HasBlockWithUsers(r.Context(), database.HasBlockWithUsersParams{
+		UserID:   user.ID,
+		OtherIds: memberIDs,
+	})
+	if err != nil {
+		apierror.Write(w, r, fmt.Errorf("error checking blocks: %w", err))
+		return
+	}
+	if blocked {
+		apierror.Write(w, r, apierror.New(apierror.Forbidden, "you can not message this conversation"))
+		return
+	}
+	var message database.Message
+	err = database.RunInTx(r.Context(), cfg.DB, func(q *database.Queries) error {
+		var err error
+		message, err = q.CreateMessage(r.Context(), database.CreateMessageParams{
+			ConversationID: conversationID,
+			SenderID:       user.ID,
+			Body:           params.Body,
+		})
+		if err != nil {
+			return fmt.Errorf("could not create message: %w", err)
+		}
+		if err := q.TouchConversation(r.Context(), conversationID); err != nil {
+			return fmt.Errorf("could not update conversation: %w", err)
+		}
+		return q.MarkConversationRead(r.Context(), database.MarkConversationReadParams{
+			MessageID:      message.ID,
+			ConversationID: conversationID,
+			UserID:         user.ID,
+		})
+	})
+	if err != nil {
+		apierror.Write(w, r, fmt.Errorf("error sending message: %w", err))
+		return
+	}
+	dat, err := json.Marshal(message)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	w.WriteHeader(201)
+	w.Write(dat)
+}
+
+// GetMessages pages backwards through a conversation, newest first. Pass the
+// next_cursor of one page as ?before= to get older messages. Fetching the
+// newest page marks the conversation read for the caller.
+func (cfg *APIConfig) GetMessages(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	user, conversationID, ok := cfg.conversationMember(w, r)
+	if !ok {
+		return
+	}
+	before := int64(math.MaxInt64)
+	if v := r.URL.Query().Get("before"); v != "" {
+		var err error
+		before, err = strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			apierror.Write(w, r, apierror.Errorf(apierror.InvalidRequest, "invalid cursor: %s", err))
+			return
+		}
+	}
+	limit := defaultMessageLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxMessageLimit {
+			apierror.Write(w, r, apierror.Errorf(apierror.InvalidRequest, "limit must be between 1 and %d", maxMessageLimit))
+			return
+		}
+		limit = n
+	}
+	messages, err := cfg.Queries.GetMessages(r.Context(), database.GetMessagesParams{
+		ConversationID: conversationID,
+		BeforeID:       before,
+		MaxMessages:    int32(limit),
+	})
+	if err != nil {
+		apierror.Write(w, r, fmt.Errorf("error fetching messages: %w", err))
+		return
+	}
+	if messages == nil {
+		messages = []database.Message{}
+	}
+	if r.URL.Query().Get("before") == "" && len(messages) > 0 {
+		if err := cfg.Queries.MarkConversationRead(r.Context(), database.MarkConversationReadParams{
+			MessageID:      messages[0].ID,
+			ConversationID: conversationID,
+			UserID:         user.ID,
+		}); err != nil {
+			apierror.Write(w, r, fmt.Errorf("could not mark conversation read: %w", err))
+			return
+		}
+	}
+	type response struct {
+		Messages   []database.Message `json:"messages"`
+		NextCursor *string            `json:"next_cursor"`
+	}
+	resp := response{Messages: messages}
+	if len(messages) == limit {
+		next := strconv.FormatInt(messages[len(messages)-1].ID, 10)
+		resp.NextCursor = &next
+	}
+	dat, err := json.Marshal(resp)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	w.WriteHeader(200)
+	w.Write(dat)
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/RemcoVeens/httpserver/internal/apierror"
+	"github.com/RemcoVeens/httpserver/internal/database"
+)
+
+// errUnauthenticated is wrapped by the errors of GetUserFromBearerToken for
+// requests without a usable access token.
+var errUnauthenticated = errors.New("missing, invalid or expired access token")
+
+// currentUser authenticates r like GetUserFromBearerToken. It writes the
+// problem itself and reports ok=false when the request can not continue.
+func (cfg *APIConfig) currentUser(w http.ResponseWriter, r *http.Request) (database.User, bool) {
+	user, err := cfg.GetUserFromBearerToken(r)
+	if err != nil {
+		apierror.Write(w, r, authError(err))
+		return database.User{}, false
+	}
+	return user, true
+}
+
+// authError reports an error of GetUserFromBearerToken. Tokens of users
+// that no longer exist are unauthenticated too; anything else is internal.
+func authError(err error) error {
+	switch {
+	case errors.Is(err, errCSRF):
+		return apierror.New(apierror.CSRFFailed, errCSRF.Error())
+	case errors.Is(err, errUnauthenticated), errors.Is(err, sql.ErrNoRows):
+		return apierror.New(apierror.Unauthenticated, errUnauthenticated.Error())
+	default:
+		return apierror.Wrap(apierror.Internal, err, "")
+	}
+}
+
+// decodeJSON decodes r's body into v.
+func decodeJSON(r *http.Request, v any) error {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		return bodyError(err)
+	}
+	return nil
+}
+
+// bodyError reports an error reading or decoding a request body.
+func bodyError(err error) error {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return apierror.Errorf(apierror.PayloadTooLarge, "the body can be at most %d bytes", tooLarge.Limit)
+	}
+	return apierror.Errorf(apierror.InvalidJSON, "could not decode body: %s", err)
+}
+
+// lookupError reports an error fetching the resource a request names:
+// missing rows are not found, anything else is an internal error.
+func lookupError(err error, notFound string) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return apierror.New(apierror.NotFound, notFound)
+	}
+	return err
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/RemcoVeens/httpserver/internal/apierror"
+	"github.com/RemcoVeens/httpserver/internal/database"
+	"github.com/RemcoVeens/httpserver/internal/entities"
+)
+
+const (
+	defaultTrendingWindow = 24 * time.Hour
+	maxTrendingWindow     = 7 * 24 * time.Hour
+	defaultTrendingLimit  = 10
+	maxTrendingLimit      = 50
+)
+
+func (cfg *APIConfig) GetHashtagChirps(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	tag := entities.NormalizeTag(r.PathValue("tag"))
+	if tag == "" {
+		apierror.Write(w, r, apierror.New(apierror.InvalidRequest, "please provide a hashtag"))
+		return
+	}
+	chirps, err := cfg.Queries.GetChirpsForHashtag(r.Context(), database.GetChirpsForHashtagParams{
+		Tag:      tag,
+		ViewerID: cfg.viewerID(r),
+	})
+	if err != nil {
+		apierror.Write(w, r, fmt.Errorf("error fetching chirps: %w", err))
+		return
+	}
+	resp, err := cfg.chirpResponses(r.Context(), chirps)
+	if err != nil {
+		apierror.Write(w, r, fmt.Errorf("error fetching chirps: %w", err))
+		return
+	}
+	dat, err := json.Marshal(resp)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	w.WriteHeader(200)
+	w.Write(dat)
+}
+
+// GetTrendingHashtags ranks tags by how many chirps used them within the
+// sliding window ending now, by the database clock that dated the tags. The
+// window defaults to 24h and can be set with ?window=<duration> up to a week;
+// ?limit caps the number of tags returned.
+func (cfg *APIConfig) GetTrendingHashtags(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	window := defaultTrendingWindow
+	if v := r.URL.Query().Get("window"); v != "" {
+		d, err := time.ParseDuration(v)
+		if err != nil || d <= 0 || d > maxTrendingWindow {
+			apierror.Write(w, r, apierror.Errorf(apierror.InvalidRequest, "window must be a duration between 0 and %s", maxTrendingWindow))
+			return
+		}
+		window = d
+	}
+	limit := defaultTrendingLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxTrendingLimit {
+			apierror.Write(w, r, apierror.Errorf(apierror.InvalidRequest, "limit must be between 1 and %d", maxTrendingLimit))
+			return
+		}
+		limit = n
+	}
+	tags, err := cfg.Queries.GetTrendingHashtags(r.Context(), database.GetTrendingHashtagsParams{
+		WindowSeconds: window.Seconds(),
+		MaxTags:       int32(limit),
+	})
+	if err != nil {
+		apierror.Write(w, r, fmt.Errorf("error fetching trending hashtags: %w", err))
+		return
+	}
+	if tags == nil {
+		tags = []database.GetTrendingHashtagsRow{}
+	}
+	dat, err := json.Marshal(tags)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	w.WriteHeader(200)
+	w.Write(dat)
+}
@@ -1,34 +1,86 @@
 package handlers
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net/http"
 	"sync/atomic"
 	"time"
+	"unicode/utf8"
 
+	"github.com/RemcoVeens/httpserver/internal/apierror"
 	"github.com/RemcoVeens/httpserver/internal/auth"
+	"github.com/RemcoVeens/httpserver/internal/blobstore"
 	"github.com/RemcoVeens/httpserver/internal/database"
+	"github.com/RemcoVeens/httpserver/internal/entitlements"
+	"github.com/RemcoVeens/httpserver/internal/events"
+	"github.com/RemcoVeens/httpserver/internal/handles"
+	"github.com/RemcoVeens/httpserver/internal/media"
+	"github.com/RemcoVeens/httpserver/internal/polka"
+	"github.com/RemcoVeens/httpserver/internal/pubsub"
+	"github.com/RemcoVeens/httpserver/internal/ratelimit"
+	"github.com/RemcoVeens/httpserver/internal/web"
 	"github.com/google/uuid"
 )
 
 type APIConfig struct {
 	fileserverHits atomic.Int32
+	DB             *sql.DB
 	Queries        *database.Queries
+	Broker         *pubsub.Broker
 	Platform       string
 	Secret         string
 	PolkaKey       string
+	PolkaSecret    string
+	Entitlements   entitlements.Entitlements
+	Limiter        *ratelimit.Limiter
+	Blobs          blobstore.Store
+	Processor      *media.Processor
+	Pages          *web.Renderer
+	// AccessTTL and RefreshTTL are the lifetimes of the tokens issued; zero
+	// means an hour and 60 days.
+	AccessTTL  time.Duration
+	RefreshTTL time.Duration
 }
 
+// maxPolkaBody bounds Polka webhook bodies, which are read whole to verify
+// their signature.
+const maxPolkaBody = 64 << 10
+
+// GetUserFromBearerToken returns the caller, authenticated with the access
+// token in the Authorization header or, for browsers, the session cookie.
 func (cfg *APIConfig) GetUserFromBearerToken(r *http.Request) (database.User, error) {
-	tokn, err := auth.GetBearerToken(r.Header)
-	user_id, err := auth.ValidateJWT(tokn, cfg.Secret)
+	tokn, err := cfg.accessToken(r)
 	if err != nil {
 		return database.User{}, fmt.Errorf("could not get user from token: %w", err)
 	}
-	return cfg.Queries.GetUserFromId(r.Context(), user_id)
+	return cfg.userFromAccessToken(r.Context(), tokn)
+}
+
+func (cfg *APIConfig) userFromAccessToken(ctx context.Context, token string) (database.User, error) {
+	user_id, err := auth.ValidateJWT(token, cfg.Secret)
+	if err != nil {
+		return database.User{}, fmt.Errorf("%w: %w", errUnauthenticated, err)
+	}
+	return cfg.Queries.GetActiveUserFromId(ctx, user_id)
+}
+
+// viewerID returns the id of the authenticated caller, or uuid.Nil for
+// anonymous requests, so read queries can apply block and mute rules.
+func (cfg *APIConfig) viewerID(r *http.Request) uuid.UUID {
+	if _, _, err := requestToken(r); err != nil {
+		return uuid.Nil
+	}
+	user, err := cfg.GetUserFromBearerToken(r)
+	if err != nil {
+		return uuid.Nil
+	}
+	return user.ID
 }
 func (cfg *APIConfig) MiddlewareMetricsInc(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -41,16 +93,17 @@ func (cfg *APIConfig) MiddlewareMetricsInc(next http.Handler) http.Handler {
 }
 func (cfg *APIConfig) Reset(w http.ResponseWriter, r *http.Request) {
 	if cfg.Platform != "dev" {
-		w.WriteHeader(401)
+		apierror.Write(w, r, apierror.New(apierror.Forbidden, "reset is only available on the dev platform"))
 		return
 	}
 	cfg.fileserverHits.Store(0)
 	if err := cfg.Queries.DeleteAllUsers(r.Context()); err != nil {
-		log.Fatalln("could not reset users: %w", err)
+		apierror.Write(w, r, fmt.Errorf("could not reset users: %w", err))
+		return
 	}
 }
 func (cfg *APIConfig) HitCounterHandler(w http.ResponseWriter, r *http.Request) {
-	r.Header.Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 	w.WriteHeader(200)
 	w.Write(fmt.Appendf(
 		[]byte(""),
@@ -64,39 +117,39 @@ func (cfg *APIConfig) UpdateUserHandel(w http.ResponseWriter, r *http.Request) {
 		Email    string `json:"email"`
 		Password string `json:"password"`
 	}
-	decoder := json.NewDecoder(r.Body)
 	var params input
 	status := 200
-	if err := decoder.Decode(&params); err != nil {
-		w.WriteHeader(401)
-		w.Write(fmt.Appendf([]byte(""), "Error getting user input: %s", err))
+	user, ok := cfg.currentUser(w, r)
+	if !ok {
 		return
 	}
-	user, err := cfg.GetUserFromBearerToken(r)
-	if err != nil {
-		w.WriteHeader(401)
-		w.Write(fmt.Appendf([]byte(""), "Error getting user: %s", err))
+	if err := decodeJSON(r, &params); err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	if params.Email == "" || params.Password == "" {
+		apierror.Write(w, r, apierror.New(apierror.InvalidRequest, "email and password are required"))
 		return
 	}
 	hp, err := auth.HashPassword(params.Password)
 	if err != nil {
-		w.WriteHeader(500)
-		w.Write(fmt.Appendf([]byte(""), "error hasing password: %s", err))
+		apierror.Write(w, r, fmt.Errorf("could not hash password: %w", err))
 		return
 	}
 	if err = cfg.Queries.UpdateUser(r.Context(), database.UpdateUserParams{
 		Email:          params.Email,
 		HashedPassword: hp,
 		ID:             user.ID,
-	}); err != nil {
-		w.WriteHeader(500)
-		w.Write(fmt.Appendf([]byte(""), "could not update user: %s", err))
+	}); isUniqueViolation(err) {
+		apierror.Write(w, r, apierror.New(apierror.Conflict, "email is already taken"))
+		return
+	} else if err != nil {
+		apierror.Write(w, r, fmt.Errorf("could not update user: %w", err))
 		return
 	}
 	NewUser, err := cfg.Queries.GetUserFromEmail(r.Context(), params.Email)
 	if err != nil {
-		w.WriteHeader(500)
-		w.Write(fmt.Appendf([]byte(""), "could not get updated user: %s", err))
+		apierror.Write(w, r, fmt.Errorf("could not get updated user: %w", err))
 		return
 	}
 	var m map[string]any
@@ -104,6 +157,10 @@ func (cfg *APIConfig) UpdateUserHandel(w http.ResponseWriter, r *http.Request) {
 	json.Unmarshal(tempJSON, &m)
 	delete(m, "hashed_password")
 	dat, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
 	log.Println(user.Email, "status:", status)
 	w.WriteHeader(status)
 	w.Write(dat)
@@ -113,27 +170,41 @@ func (cfg *APIConfig) CreateUserHandel(w http.ResponseWriter, r *http.Request) {
 	type input struct {
 		Email    string `json:"email"`
 		Password string `json:"password"`
+		Handle   string `json:"handle"`
 	}
-	decoder := json.NewDecoder(r.Body)
 	var params input
-	var user database.User
 	status := 201
-	if err := decoder.Decode(&params); err != nil {
-		user = database.User{}
-		status = 400
+	if err := decodeJSON(r, &params); err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	if params.Email == "" || params.Password == "" {
+		apierror.Write(w, r, apierror.New(apierror.InvalidRequest, "email and password are required"))
+		return
+	}
+	if params.Handle == "" {
+		params.Handle = handles.Generate()
+	} else if err := handles.Validate(params.Handle); err != nil {
+		apierror.Write(w, r, apierror.Errorf(apierror.InvalidRequest, "invalid handle: %s", err))
+		return
 	}
 	pass, err := auth.HashPassword(params.Password)
 	if err != nil {
-		w.WriteHeader(500)
-		w.Write([]byte(fmt.Sprintf("could not hash password: %s", err)))
+		apierror.Write(w, r, fmt.Errorf("could not hash password: %w", err))
 		return
 	}
-	user, err = cfg.Queries.CreateUser(r.Context(), database.CreateUserParams{
+	user, err := cfg.CreateUser(r.Context(), database.CreateUserParams{
 		Email:          params.Email,
 		HashedPassword: pass,
+		Handle:         params.Handle,
 	})
+	if isUniqueViolation(err) {
+		apierror.Write(w, r, apierror.New(apierror.Conflict, "email or handle is already taken"))
+		return
+	}
 	if err != nil {
-		log.Printf("could not create user: %s", err)
+		apierror.Write(w, r, fmt.Errorf("could not create user: %w", err))
+		return
 	}
 
 	tempJSON, _ := json.Marshal(user)
@@ -142,76 +213,102 @@ func (cfg *APIConfig) CreateUserHandel(w http.ResponseWriter, r *http.Request) {
 	delete(m, "hashed_password")
 	dat, err := json.MarshalIndent(m, "", "  ")
 	if err != nil {
-		w.WriteHeader(500)
-		w.Write([]byte(fmt.Sprintf("Error marshalling JSON: %s", err)))
+		apierror.Write(w, r, err)
 		return
 	}
 	log.Println(user.Email, "has justy been created. status:", status)
 	w.WriteHeader(status)
 	w.Write(dat)
 }
+
+// CreateUser stores a user and emits user.created in one transaction.
+func (cfg *APIConfig) CreateUser(ctx context.Context, params database.CreateUserParams) (database.User, error) {
+	var user database.User
+	err := database.RunInTx(ctx, cfg.DB, func(q *database.Queries) error {
+		var err error
+		user, err = q.CreateUser(ctx, params)
+		if err != nil {
+			return err
+		}
+		return events.Emit(ctx, q, pubsub.UserCreated, user.ID, newPublicProfile(user))
+	})
+	return user, err
+}
 func (cfg *APIConfig) LoginHandler(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	type input struct {
 		Email    string `json:"email"`
 		Password string `json:"password"`
+		// UseCookies starts a browser session instead of returning the
+		// tokens.
+		UseCookies bool `json:"use_cookies"`
 	}
-	decoder := json.NewDecoder(r.Body)
 	var params input
-	var user database.User
 	status := 200
-	if err := decoder.Decode(&params); err != nil {
-		user = database.User{}
-		status = 400
+	if err := decodeJSON(r, &params); err != nil {
+		apierror.Write(w, r, err)
+		return
 	}
 	user, err := cfg.Queries.GetUserFromEmail(r.Context(), params.Email)
-	if err != nil {
-		w.WriteHeader(500)
-		w.Write(fmt.Appendf([]byte(""), "user not found: %s", err))
+	if errors.Is(err, sql.ErrNoRows) {
+		apierror.Write(w, r, apierror.New(apierror.InvalidCredentials, "wrong email or password"))
 		return
 	}
-	hp, err := auth.HashPassword(params.Password)
 	if err != nil {
-		w.WriteHeader(500)
-		w.Write(fmt.Appendf([]byte(""), "could not hash : %s", err))
+		apierror.Write(w, r, fmt.Errorf("could not get user: %w", err))
 		return
 	}
 	ok, err := auth.CheckPasswordHash(params.Password, user.HashedPassword)
 	if err != nil {
-		w.WriteHeader(500)
-		w.Write(fmt.Appendf([]byte(""), "could not hash password: %s", err))
+		apierror.Write(w, r, fmt.Errorf("could not check password: %w", err))
 		return
 	}
 	if !ok {
-		log.Print(user.HashedPassword)
-		log.Print(hp)
-		w.WriteHeader(401)
-		w.Write(fmt.Appendf([]byte(""), "could not authenticate: %s", err))
+		apierror.Write(w, r, apierror.New(apierror.InvalidCredentials, "wrong email or password"))
 		return
 	}
-	jwtToken, err := auth.MakeJWT(user.ID, cfg.Secret, time.Duration(3600))
+	disabled, err := cfg.Queries.IsDisabled(r.Context(), user.ID)
 	if err != nil {
-		w.WriteHeader(500)
-		w.Write(fmt.Appendf([]byte(""), "Error making jwt token: %s", err))
+		apierror.Write(w, r, fmt.Errorf("could not check account: %w", err))
+		return
+	}
+	if disabled {
+		apierror.Write(w, r, apierror.New(apierror.Forbidden, "this account is disabled"))
 		return
 	}
 	tempJSON, _ := json.Marshal(user)
-	RToken, _ := auth.MakeRefreshToken()
-	RefToken, err := cfg.Queries.GenerateToken(r.Context(), database.GenerateTokenParams{
-		Token:     RToken,
-		UserID:    user.ID,
-		ExpiresAt: time.Now().AddDate(0, 0, 60),
-		RevokedAt: sql.NullTime{Time: time.Time{}, Valid: false},
-	})
 	var m map[string]any
 	json.Unmarshal(tempJSON, &m)
 	delete(m, "hashed_password")
-	m["token"] = jwtToken
-	m["refresh_token"] = RefToken.Token
+	if params.UseCookies {
+		if err := cfg.startSession(w, r, user.ID); err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+		m["csrf_token"] = web.CSRFToken(w, r, cfg.secureCookies())
+	} else {
+		jwtToken, err := auth.MakeJWT(user.ID, cfg.Secret, cfg.accessTTL()/time.Second)
+		if err != nil {
+			apierror.Write(w, r, fmt.Errorf("could not make access token: %w", err))
+			return
+		}
+		RToken, _ := auth.MakeRefreshToken()
+		RefToken, err := cfg.Queries.GenerateToken(r.Context(), database.GenerateTokenParams{
+			Token:     RToken,
+			UserID:    user.ID,
+			ExpiresAt: time.Now().Add(cfg.refreshTTL()),
+			RevokedAt: sql.NullTime{Time: time.Time{}, Valid: false},
+		})
+		if err != nil {
+			apierror.Write(w, r, fmt.Errorf("could not store refresh token: %w", err))
+			return
+		}
+		m["token"] = jwtToken
+		m["refresh_token"] = RefToken.Token
+	}
 	dat, err := json.MarshalIndent(m, "", "  ")
 	if err != nil {
-		w.WriteHeader(500)
-		w.Write(fmt.Appendf([]byte(""), "Error marshalling JSON: %s", err))
+		apierror.Write(w, r, err)
 		return
 	}
 	log.Println(params.Email, "just logged in")
@@ -219,6 +316,7 @@ func (cfg *APIConfig) LoginHandler(w http.ResponseWriter, r *http.Request) {
 	w.Write(dat)
 }
 func (cfg *APIConfig) GetChirps(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
 	AuthorID := r.URL.Query().Get("author_id")
 	Sort := r.URL.Query().Get("sort")
 	var Order string
@@ -232,16 +330,19 @@ func (cfg *APIConfig) GetChirps(w http.ResponseWriter, r *http.Request) {
 	}
 	var err error
 	var chirps []database.Chirp
+	viewer := cfg.viewerID(r)
 	if AuthorID == "" {
-		chirps, err = cfg.Queries.GetChirps(r.Context())
+		chirps, err = cfg.Queries.GetChirps(r.Context(), viewer)
 	} else {
-		userId, err := uuid.Parse(AuthorID)
-		if err != nil {
-			w.WriteHeader(400)
-			w.Write(fmt.Appendf([]byte(""), "Error parsing author id: %s", err))
+		userId, parseErr := uuid.Parse(AuthorID)
+		if parseErr != nil {
+			apierror.Write(w, r, apierror.Errorf(apierror.InvalidRequest, "invalid author_id: %s", parseErr))
 			return
 		}
-		chirps, err = cfg.Queries.GetChirpsFromAuthorID(r.Context(), userId)
+		chirps, err = cfg.Queries.GetChirpsFromAuthorID(r.Context(), database.GetChirpsFromAuthorIDParams{
+			UserID:   userId,
+			ViewerID: viewer,
+		})
 	}
 	if Order == "desc" {
 		for i, j := 0, len(chirps)-1; i < j; i, j = i+1, j-1 {
@@ -250,251 +351,280 @@ func (cfg *APIConfig) GetChirps(w http.ResponseWriter, r *http.Request) {
 	}
 	status := 200
 	if err != nil {
-		w.WriteHeader(500)
-		w.Write(fmt.Appendf([]byte(""), "Error fetching chirps: %s", err))
+		apierror.Write(w, r, fmt.Errorf("could not fetch chirps: %w", err))
+		return
+	}
+	resp, err := cfg.chirpResponses(r.Context(), chirps)
+	if err != nil {
+		apierror.Write(w, r, err)
 		return
 	}
-	dat, err := json.Marshal(chirps)
+	dat, err := json.Marshal(resp)
 	if err != nil {
-		w.WriteHeader(500)
-		w.Write(fmt.Appendf([]byte(""), "Error marshalling JSON: %s", err))
+		apierror.Write(w, r, err)
 		return
 	}
 	w.WriteHeader(status)
 	w.Write(dat)
 }
 func (cfg *APIConfig) GetChirp(w http.ResponseWriter, r *http.Request) {
-	chirpID := r.PathValue("chirp_id")
-	if chirpID == "" {
-		w.WriteHeader(400)
-		w.Write([]byte("please provide a chirp id to fetch"))
+	w.Header().Set("Content-Type", "application/json")
+	chirp, ok := cfg.visibleChirp(w, r, "chirp_id")
+	if !ok {
 		return
 	}
-	uuid, err := uuid.Parse(chirpID)
+	resp, err := cfg.chirpResponses(r.Context(), []database.Chirp{chirp})
 	if err != nil {
-		w.WriteHeader(404)
-		w.Write(fmt.Appendf([]byte(""), "could not make id from parameter: %s", err))
+		apierror.Write(w, r, err)
 		return
 	}
-	chirps, err := cfg.Queries.GetChirpFromId(r.Context(), uuid)
-	status := 200
+	dat, err := json.Marshal(resp[0])
 	if err != nil {
-		w.WriteHeader(404)
-		w.Write(fmt.Appendf([]byte(""), "Error fetching chirps: %s", err))
+		apierror.Write(w, r, err)
 		return
 	}
-	dat, err := json.Marshal(chirps)
+	w.WriteHeader(200)
+	w.Write(dat)
+}
+
+// visibleChirp resolves the chirp in path value name, as seen by the
+// caller. Chirps hidden by a block are not found. It writes the problem
+// itself and reports ok=false when the request can not continue.
+func (cfg *APIConfig) visibleChirp(w http.ResponseWriter, r *http.Request, name string) (database.Chirp, bool) {
+	id, err := uuid.Parse(r.PathValue(name))
+	if err != nil {
+		apierror.Write(w, r, apierror.New(apierror.NotFound, "chirp not found"))
+		return database.Chirp{}, false
+	}
+	chirp, err := cfg.Queries.GetVisibleChirpFromId(r.Context(), database.GetVisibleChirpFromIdParams{
+		ID:       id,
+		ViewerID: cfg.viewerID(r),
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		apierror.Write(w, r, apierror.New(apierror.NotFound, "chirp not found"))
+		return database.Chirp{}, false
+	}
 	if err != nil {
-		w.WriteHeader(500)
-		w.Write(fmt.Appendf([]byte(""), "Error marshalling JSON: %s", err))
-		return
+		apierror.Write(w, r, fmt.Errorf("could not fetch chirp: %w", err))
+		return database.Chirp{}, false
 	}
-	w.WriteHeader(status)
-	w.Write(dat)
+	return chirp, true
 }
 func (cfg *APIConfig) RemoveChirp(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
 	status := 204
-	chirpID := r.PathValue("chirpID")
-	if chirpID == "" {
-		w.WriteHeader(400)
-		w.Write([]byte("please provide a chirp id to delete"))
+	user, ok := cfg.currentUser(w, r)
+	if !ok {
 		return
 	}
-	uuid, err := uuid.Parse(chirpID)
+	uuid, err := uuid.Parse(r.PathValue("chirp_id"))
 	if err != nil {
-		w.WriteHeader(404)
-		w.Write(fmt.Appendf([]byte(""), "could not process id: %v", err))
+		apierror.Write(w, r, apierror.New(apierror.NotFound, "chirp not found"))
 		return
 	}
 	chirp, err := cfg.Queries.GetChirpFromId(r.Context(), uuid)
-	if err != nil {
-		w.WriteHeader(404)
-		w.Write(fmt.Appendf([]byte(""), "Error fetching chirps: %s", err))
-		return
-	}
-	dat, err := json.Marshal(chirp)
-	if err != nil {
-		w.WriteHeader(500)
-		w.Write(fmt.Appendf([]byte(""), "Error marshalling JSON: %s", err))
-		return
-	}
-	tokn, err := auth.GetBearerToken(r.Header)
-	if err != nil {
-		w.WriteHeader(401)
-		w.Write(fmt.Appendf([]byte(""), "Error getting token: %s", err))
+	if errors.Is(err, sql.ErrNoRows) {
+		apierror.Write(w, r, apierror.New(apierror.NotFound, "chirp not found"))
 		return
 	}
-	user_id, err := auth.ValidateJWT(tokn, cfg.Secret)
 	if err != nil {
-		w.WriteHeader(401)
-		w.Write(fmt.Appendf([]byte(""), "Error validation: %s", err))
-		return
-	}
-	user, err := cfg.Queries.GetUserFromId(r.Context(), user_id)
-	if err != nil {
-		w.WriteHeader(401)
-		w.Write(fmt.Appendf([]byte(""), "Error getting user from id: %s", err))
+		apierror.Write(w, r, fmt.Errorf("could not fetch chirp: %w", err))
 		return
 	}
 	if user.ID != chirp.UserID {
-		w.WriteHeader(403)
-		w.Write(fmt.Appendf([]byte(""), "This is not yours to delete"))
+		apierror.Write(w, r, apierror.New(apierror.Forbidden, "you can only delete your own chirps"))
 		return
 	}
-	err = cfg.Queries.DeleteChirpFromID(r.Context(), chirp.ID)
+	err = database.RunInTx(r.Context(), cfg.DB, func(q *database.Queries) error {
+		if err := q.DeleteChirpFromID(r.Context(), chirp.ID); err != nil {
+			return err
+		}
+		return events.Emit(r.Context(), q, pubsub.ChirpDeleted, chirp.UserID, map[string]any{"id": chirp.ID, "user_id": chirp.UserID, "reply_to_id": chirp.ReplyToID})
+	})
 	if err != nil {
-		w.WriteHeader(500)
-		w.Write(fmt.Appendf([]byte(""), "Error deleting chirp: %s", err))
+		apierror.Write(w, r, fmt.Errorf("could not delete chirp: %w", err))
 		return
 	}
 	w.WriteHeader(status)
-	w.Write(dat)
 }
 func (cfg *APIConfig) Chirps(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	type input struct {
-		Body string `json:"body"`
+		Body      string        `json:"body"`
+		Media     []uuid.UUID   `json:"media"`
+		ReplyToID uuid.NullUUID `json:"reply_to_id"`
 	}
-	decoder := json.NewDecoder(r.Body)
 	var params input
 	status := 201
-	if err := decoder.Decode(&params); err != nil {
-		w.Write(fmt.Appendf([]byte(""), "Error marshalling JSON: %s", err))
-		w.WriteHeader(500)
+	user, ok := cfg.currentUser(w, r)
+	if !ok {
 		return
 	}
-	token_string, err := auth.GetBearerToken(r.Header)
-	if err != nil {
-		w.WriteHeader(403)
-		w.Write(fmt.Appendf([]byte(""), "Could not get token: %s", err))
+	if err := decodeJSON(r, &params); err != nil {
+		apierror.Write(w, r, err)
 		return
 	}
-	UserID, err := auth.ValidateJWT(token_string, cfg.Secret)
-	if err != nil {
-		w.WriteHeader(401)
-		w.Write(fmt.Appendf([]byte(""), "Validation error: %s", err))
+	if limit := cfg.Entitlements.Limits(user).MaxChirpLength; utf8.RuneCountInString(params.Body) > limit {
+		apierror.Write(w, r, apierror.Errorf(apierror.PlanLimit, "chirp can be at most %d characters on your plan", limit))
 		return
 	}
-
-	user, err := cfg.Queries.GetUserFromId(r.Context(), UserID)
-	if err != nil {
-		log.Printf("Could not get user from id: %s", UserID)
+	if limit := cfg.Entitlements.Limits(user).MaxMediaPerChirp; len(params.Media) > limit {
+		apierror.Write(w, r, apierror.Errorf(apierror.PlanLimit, "chirps can have at most %d media on your plan", limit))
+		return
 	}
-	chirp, err := cfg.Queries.CreateChirp(r.Context(), database.CreateChirpParams{
-		Body:   params.Body,
-		UserID: user.ID,
+	chirp, err := cfg.createChirp(r.Context(), user, newChirp{
+		Body:    params.Body,
+		Media:   params.Media,
+		ReplyTo: params.ReplyToID,
 	})
+	if errors.Is(err, errMediaUnavailable) || errors.Is(err, errReplyUnavailable) {
+		apierror.Write(w, r, apierror.New(apierror.InvalidRequest, err.Error()))
+		return
+	}
 	if err != nil {
-		log.Printf("Could not create chirp (%s) from user: %s", params.Body, UserID)
+		apierror.Write(w, r, fmt.Errorf("could not create chirp for user %s: %w", user.ID, err))
+		return
 	}
 	dat, err := json.Marshal(chirp)
 	if err != nil {
-		w.WriteHeader(500)
-		w.Write(fmt.Appendf([]byte(""), "Error marshalling JSON: %s", err))
+		apierror.Write(w, r, err)
 		return
 	}
 	w.WriteHeader(status)
 	w.Write(dat)
 }
+
+// RefreshHandel issues a new access token for the refresh token in the
+// Authorization header, so clients can renew an expired access token.
+// Browser sessions are rotated instead: both cookies are replaced and the
+// old refresh token is revoked.
 func (cfg *APIConfig) RefreshHandel(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	status := 200
-	user, err := cfg.GetUserFromBearerToken(r)
+	if r.Header.Get("Authorization") == "" && refreshToken(r) != "" {
+		if !web.CheckCSRFHeader(r) {
+			apierror.Write(w, r, apierror.New(apierror.CSRFFailed, errCSRF.Error()))
+			return
+		}
+		s, err := cfg.rotateSession(r.Context(), refreshToken(r))
+		if errors.Is(err, errSessionEnded) {
+			apierror.Write(w, r, apierror.New(apierror.Unauthenticated, "the session has ended; log in again"))
+			return
+		}
+		if err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+		cfg.setSessionCookies(w, s)
+		w.WriteHeader(204)
+		return
+	}
+	tokn, err := auth.GetBearerToken(r.Header)
 	if err != nil {
-		w.WriteHeader(401)
-		w.Write(fmt.Appendf([]byte(""), "Error getting user: %s", err))
+		apierror.Write(w, r, apierror.New(apierror.Unauthenticated, "the refresh token must be sent in the Authorization header"))
+		return
+	}
+	rt, err := cfg.Queries.GetTokenFromToken(r.Context(), tokn)
+	if errors.Is(err, sql.ErrNoRows) || err == nil && (rt.RevokedAt.Valid || time.Now().After(rt.ExpiresAt)) {
+		apierror.Write(w, r, apierror.New(apierror.Unauthenticated, "the refresh token is invalid, revoked or expired; log in again"))
+		return
+	}
+	if err != nil {
+		apierror.Write(w, r, fmt.Errorf("could not get refresh token: %w", err))
 		return
 	}
-	w.WriteHeader(status)
 	type response struct {
 		Token string `json:"token"`
 	}
-	new_token, err := auth.MakeJWT(user.ID, cfg.Secret, 3600)
+	new_token, err := auth.MakeJWT(rt.UserID, cfg.Secret, cfg.accessTTL()/time.Second)
+	if err != nil {
+		apierror.Write(w, r, fmt.Errorf("could not make access token: %w", err))
+		return
+	}
 	dat, err := json.Marshal(response{
 		Token: new_token,
 	})
 	if err != nil {
-		w.WriteHeader(500)
-		w.Write(fmt.Appendf([]byte(""), "Error marshalling JSON: %s", err))
+		apierror.Write(w, r, err)
 		return
 	}
+	w.WriteHeader(status)
 	w.Write(dat)
 }
+
+// RevokeHandel revokes the refresh token in the Authorization header or,
+// for browsers, ends the session.
 func (cfg *APIConfig) RevokeHandel(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
+	if r.Header.Get("Authorization") == "" && refreshToken(r) != "" {
+		if !web.CheckCSRFHeader(r) {
+			apierror.Write(w, r, apierror.New(apierror.CSRFFailed, errCSRF.Error()))
+			return
+		}
+		if err := cfg.Queries.RevokeToken(r.Context(), refreshToken(r)); err != nil {
+			apierror.Write(w, r, fmt.Errorf("could not revoke token: %w", err))
+			return
+		}
+		web.ClearSession(w, cfg.secureCookies())
+		w.WriteHeader(204)
+		return
+	}
 	tokn, err := auth.GetBearerToken(r.Header)
 	if err != nil {
-		w.WriteHeader(401)
-		w.Write(fmt.Appendf([]byte(""), "Error getting token: %s", err))
+		apierror.Write(w, r, apierror.New(apierror.Unauthenticated, "the refresh token must be sent in the Authorization header"))
 		return
 	}
 	err = cfg.Queries.RevokeToken(r.Context(), tokn)
 	if err != nil {
-		w.WriteHeader(500)
-		w.Write(fmt.Appendf([]byte(""), "could not revoke token: %s", err))
+		apierror.Write(w, r, fmt.Errorf("could not revoke token: %w", err))
 		return
 	}
 	w.WriteHeader(204)
 }
+
+// PolkaWebhook authenticates Polka with a signature or, failing that, the
+// API key (see package polka), stores the event in the inbox and
+// acknowledges it; the inbox worker applies it later. An event whose id was
+// already received is acknowledged the same way but not stored again, so
+// Polka's retries succeed.
 func (cfg *APIConfig) PolkaWebhook(w http.ResponseWriter, r *http.Request) {
-	type input struct {
-		Event string `json:"event"`
-		Data  struct {
-			UserID string `json:"user_id"`
-		} `json:"data"`
-	}
-	var params input
-	if err := json.NewDecoder(r.Body).Decode(&params); err != nil {
-		w.WriteHeader(500)
-		w.Write(fmt.Appendf([]byte(""), "Error paring input: %s", err))
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxPolkaBody))
+	if err != nil {
+		apierror.Write(w, r, bodyError(err))
 		return
 	}
-	polkaApiKey, err := auth.GetAPIKey(r.Header)
+	verifier := polka.Verifier{Secret: cfg.PolkaSecret, APIKey: cfg.PolkaKey}
+	signed, err := verifier.Verify(r.Header, body, time.Now())
 	if err != nil {
-		w.WriteHeader(401)
-		w.Write(fmt.Appendf([]byte(""), "Error getting API key: %s", err))
+		apierror.Write(w, r, apierror.Errorf(apierror.Unauthenticated, "could not authenticate webhook: %s", err))
 		return
 	}
-	log.Printf("%s and %s", polkaApiKey, cfg.PolkaKey)
-	if polkaApiKey != cfg.PolkaKey {
-		w.WriteHeader(401)
-		w.Write([]byte("Invalid API key"))
+	event, err := polka.Parse(body)
+	if err != nil {
+		apierror.Write(w, r, apierror.Errorf(apierror.InvalidRequest, "invalid event: %s", err))
 		return
 	}
-	switch params.Event {
-	case "user.upgraded":
-		userID, err := uuid.Parse(params.Data.UserID)
-		if err != nil {
-			w.WriteHeader(500)
-			w.Write(fmt.Appendf([]byte(""), "Error parsing user ID: %s", err))
-			return
-		}
-		user, err := cfg.Queries.GetUserFromId(r.Context(), userID)
-		if err != nil {
-			w.WriteHeader(404)
-			w.Write(fmt.Appendf([]byte(""), "Error getting user: %s", err))
-			return
-		}
-		err = cfg.Queries.UpgradeUserFromID(r.Context(), user.ID)
-		if err != nil {
-			w.WriteHeader(500)
-			w.Write(fmt.Appendf([]byte(""), "Error upgrading user: %s", err))
-			return
-		}
-		log.Printf("upgraded %s to red!", user.Email)
-		w.WriteHeader(204)
-		w.Write(fmt.Appendf([]byte(""), "user(%s) has been upgrade", user.Email))
+	if signed && event.ID == "" {
+		apierror.Write(w, r, apierror.New(apierror.InvalidRequest, "signed events must have an id"))
 		return
-	default:
-		w.WriteHeader(204)
-		w.Write(fmt.Appendf([]byte(""), "Unknown event: %s", params.Event))
+	}
+	n, err := cfg.Queries.CreateInboundEvent(r.Context(), database.CreateInboundEventParams{
+		Provider:        polka.Provider,
+		ProviderEventID: sql.NullString{String: event.ID, Valid: event.ID != ""},
+		EventType:       event.Event,
+		Payload:         body,
+		Subject:         sql.NullString{String: event.Data.UserID, Valid: event.Data.UserID != ""},
+	})
+	if err != nil {
+		apierror.Write(w, r, fmt.Errorf("could not store event: %w", err))
 		return
-
 	}
+	if n == 0 {
+		log.Printf("polka: event %s was already received", event.ID)
+	}
+	w.WriteHeader(204)
 }
 func HealthCodeHandler(w http.ResponseWriter, r *http.Request) {
-	r.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
 	w.WriteHeader(200)
 	_, err := w.Write([]byte("OK"))
 	if err != nil {
@@ -0,0 +1,277 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/RemcoVeens/httpserver/internal/apierror"
+	"github.com/RemcoVeens/httpserver/internal/blobstore"
+	"github.com/RemcoVeens/httpserver/internal/database"
+	"github.com/RemcoVeens/httpserver/internal/media"
+	"github.com/google/uuid"
+)
+
+// errMediaUnavailable is returned by createChirp when a media id is not an
+// unattached upload of the author, or failed processing.
+var errMediaUnavailable = errors.New("media not found or already used")
+
+// mediaResponse is how an upload is rendered, on its own and in chirps.
+// Dimensions, blurhash and variants are set once processing is done.
+type mediaResponse struct {
+	ID          uuid.UUID              `json:"id"`
+	Url         string                 `json:"url"`
+	ContentType string                 `json:"content_type"`
+	SizeBytes   int64                  `json:"size_bytes"`
+	Status      string                 `json:"status"`
+	Width       *int32                 `json:"width"`
+	Height      *int32                 `json:"height"`
+	Blurhash    *string                `json:"blurhash"`
+	Variants    []mediaVariantResponse `json:"variants"`
+	CreatedAt   time.Time              `json:"created_at"`
+}
+
+type mediaVariantResponse struct {
+	Name   string `json:"name"`
+	Url    string `json:"url"`
+	Width  int32  `json:"width"`
+	Height int32  `json:"height"`
+}
+
+func newMediaResponse(m database.MediaFile, variants []database.MediaVariant) mediaResponse {
+	resp := mediaResponse{
+		ID:          m.ID,
+		Url:         mediaURL(m.ID, "original"),
+		ContentType: m.ContentType,
+		SizeBytes:   m.SizeBytes,
+		Status:      m.Status,
+		Variants:    []mediaVariantResponse{},
+		CreatedAt:   m.CreatedAt,
+	}
+	if m.Width.Valid {
+		resp.Width = &m.Width.Int32
+	}
+	if m.Height.Valid {
+		resp.Height = &m.Height.Int32
+	}
+	if m.Blurhash.Valid {
+		resp.Blurhash = &m.Blurhash.String
+	}
+	for _, v := range variants {
+		resp.Variants = append(resp.Variants, mediaVariantResponse{
+			Name:   v.Name,
+			Url:    mediaURL(m.ID, v.Name),
+			Width:  v.Width,
+			Height: v.Height,
+		})
+	}
+	return resp
+}
+
+func mediaURL(id uuid.UUID, variant string) string {
+	return "/api/media/" + id.String() + "/" + variant
+}
+
+// mediaResponses renders files with their variants.
+func (cfg *APIConfig) mediaResponses(ctx context.Context, q *database.Queries, files []database.MediaFile) ([]mediaResponse, error) {
+	out := make([]mediaResponse, 0, len(files))
+	if len(files) == 0 {
+		return out, nil
+	}
+	ids := make([]uuid.UUID, len(files))
+	for i, f := range files {
+		ids[i] = f.ID
+	}
+	rows, err := q.GetMediaVariants(ctx, ids)
+	if err != nil {
+		return nil, fmt.Errorf("could not get media variants: %w", err)
+	}
+	variants := map[uuid.UUID][]database.MediaVariant{}
+	for _, v := range rows {
+		variants[v.MediaID] = append(variants[v.MediaID], v)
+	}
+	for _, f := range files {
+		out = append(out, newMediaResponse(f, variants[f.ID]))
+	}
+	return out, nil
+}
+
+// UploadMedia stores the image in the "file" part of a multipart form and
+// queues it for processing. The type is sniffed from the content; the
+// upload must be attached to a chirp within media.GCAge or it is deleted.
+func (cfg *APIConfig) UploadMedia(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	user, ok := cfg.currentUser(w, r)
+	if !ok {
+		return
+	}
+	// Leave room for the multipart framing around the file.
+	r.Body = http.MaxBytesReader(w, r.Body, media.MaxSize+64<<10)
+	mr, err := r.MultipartReader()
+	if err != nil {
+		apierror.Write(w, r, apierror.Errorf(apierror.InvalidRequest, "expected a multipart form: %s", err))
+		return
+	}
+	var data []byte
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			writeUploadError(w, r, err)
+			return
+		}
+		if part.FormName() != "file" {
+			continue
+		}
+		data, err = io.ReadAll(io.LimitReader(part, media.MaxSize+1))
+		if err != nil {
+			writeUploadError(w, r, err)
+			return
+		}
+		break
+	}
+	if data == nil {
+		apierror.Write(w, r, apierror.New(apierror.InvalidRequest, "missing file"))
+		return
+	}
+	if len(data) > media.MaxSize {
+		apierror.Write(w, r, apierror.Errorf(apierror.PayloadTooLarge, "files can be at most %d bytes", media.MaxSize))
+		return
+	}
+	contentType, err := media.Sniff(data)
+	if err != nil {
+		apierror.Write(w, r, apierror.Errorf(apierror.UnsupportedMediaType, "%s; allowed: %v", err, media.ContentTypes))
+		return
+	}
+	id := uuid.New()
+	key := media.Key(id)
+	if err := cfg.Blobs.Put(r.Context(), key, bytes.NewReader(data), int64(len(data)), contentType); err != nil {
+		apierror.Write(w, r, fmt.Errorf("could not store media: %w", err))
+		return
+	}
+	file, err := cfg.Queries.CreateMediaFile(r.Context(), database.CreateMediaFileParams{
+		ID:          id,
+		UserID:      user.ID,
+		StorageKey:  key,
+		ContentType: contentType,
+		SizeBytes:   int64(len(data)),
+	})
+	if err != nil {
+		if err := cfg.Blobs.Delete(r.Context(), key); err != nil {
+			log.Printf("could not delete blob %s: %s", key, err)
+		}
+		apierror.Write(w, r, fmt.Errorf("could not create media: %w", err))
+		return
+	}
+	cfg.Processor.Notify()
+	dat, err := json.Marshal(newMediaResponse(file, nil))
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	w.WriteHeader(201)
+	w.Write(dat)
+}
+
+func writeUploadError(w http.ResponseWriter, r *http.Request, err error) {
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		apierror.Write(w, r, apierror.Errorf(apierror.PayloadTooLarge, "files can be at most %d bytes", media.MaxSize))
+		return
+	}
+	apierror.Write(w, r, apierror.Errorf(apierror.InvalidRequest, "could not read upload: %s", err))
+}
+
+// GetMedia renders an upload, so clients can follow its processing. Ids
+// are unguessable, so media are shown to anyone who has one, like the
+// chirps they belong to.
+func (cfg *APIConfig) GetMedia(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	file, ok := cfg.mediaFromPath(w, r)
+	if !ok {
+		return
+	}
+	resp, err := cfg.mediaResponses(r.Context(), cfg.Queries, []database.MediaFile{file})
+	if err != nil {
+		apierror.Write(w, r, fmt.Errorf("error getting media: %w", err))
+		return
+	}
+	dat, err := json.Marshal(resp[0])
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	w.WriteHeader(200)
+	w.Write(dat)
+}
+
+// GetMediaFile serves the cleaned upload ("original") or one of its
+// thumbnails. Nothing is served before processing is done, as until then
+// the stored upload may still carry metadata such as its location.
+func (cfg *APIConfig) GetMediaFile(w http.ResponseWriter, r *http.Request) {
+	file, ok := cfg.mediaFromPath(w, r)
+	if !ok {
+		return
+	}
+	if file.Status != media.StatusReady {
+		apierror.Write(w, r, apierror.Errorf(apierror.NotFound, "media is %s", file.Status))
+		return
+	}
+	key, contentType, size := file.StorageKey, file.ContentType, file.SizeBytes
+	if name := r.PathValue("variant"); name != "original" {
+		variants, err := cfg.Queries.GetMediaVariants(r.Context(), []uuid.UUID{file.ID})
+		if err != nil {
+			apierror.Write(w, r, fmt.Errorf("error getting media: %w", err))
+			return
+		}
+		key = ""
+		for _, v := range variants {
+			if v.Name == name {
+				key, contentType, size = v.StorageKey, v.ContentType, v.SizeBytes
+			}
+		}
+		if key == "" {
+			apierror.Write(w, r, apierror.New(apierror.NotFound, "no such variant"))
+			return
+		}
+	}
+	blob, err := cfg.Blobs.Get(r.Context(), key)
+	if errors.Is(err, blobstore.ErrNotFound) {
+		apierror.Write(w, r, apierror.New(apierror.NotFound, "media not found"))
+		return
+	}
+	if err != nil {
+		apierror.Write(w, r, fmt.Errorf("could not read media: %w", err))
+		return
+	}
+	defer blob.Close()
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Content-Length", fmt.Sprint(size))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	io.Copy(w, blob)
+}
+
+// mediaFromPath resolves {media_id}. It writes the error response itself
+// and reports ok=false when the request cannot continue.
+func (cfg *APIConfig) mediaFromPath(w http.ResponseWriter, r *http.Request) (database.MediaFile, bool) {
+	id, err := uuid.Parse(r.PathValue("media_id"))
+	if err != nil {
+		apierror.Write(w, r, apierror.Errorf(apierror.InvalidRequest, "could not parse media id: %s", err))
+		return database.MediaFile{}, false
+	}
+	file, err := cfg.Queries.GetMediaFile(r.Context(), id)
+	if err != nil {
+		apierror.Write(w, r, lookupError(err, "media not found"))
+		return database.MediaFile{}, false
+	}
+	return file, true
+}
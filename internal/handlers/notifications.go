@@ -0,0 +1,215 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/RemcoVeens/httpserver/internal/apierror"
+	"github.com/RemcoVeens/httpserver/internal/database"
+	"github.com/RemcoVeens/httpserver/internal/events"
+	"github.com/RemcoVeens/httpserver/internal/notifications"
+	"github.com/RemcoVeens/httpserver/internal/pubsub"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultNotificationLimit = 20
+	maxNotificationLimit     = 100
+)
+
+// notify records a notification for recipient and emits
+// notification.created for it. It must be called with the Queries of the
+// transaction that creates the interaction, so all of it is committed
+// together. Self-notifications and notifications from users the recipient
+// has blocked are dropped by the query.
+func notify(ctx context.Context, q *database.Queries, recipient, actor uuid.UUID, t notifications.Type, chirpID uuid.NullUUID) error {
+	n, err := q.CreateNotification(ctx, database.CreateNotificationParams{
+		UserID:   recipient,
+		ActorID:  actor,
+		Type:     string(t),
+		ChirpID:  chirpID,
+		GroupKey: notifications.GroupKey(t, chirpID),
+	})
+	if err != nil {
+		return fmt.Errorf("could not create %s notification: %w", t, err)
+	}
+	if n == 0 {
+		return nil
+	}
+	return events.Emit(ctx, q, pubsub.NotificationCreated, recipient, notificationEvent{
+		UserID:  recipient,
+		ActorID: actor,
+		Type:    string(t),
+		ChirpID: chirpID,
+	})
+}
+
+// notificationEvent is the payload of notification.created; UserID is the
+// recipient.
+type notificationEvent struct {
+	UserID  uuid.UUID     `json:"user_id"`
+	ActorID uuid.UUID     `json:"actor_id"`
+	Type    string        `json:"type"`
+	ChirpID uuid.NullUUID `json:"chirp_id"`
+}
+
+type notificationGroup struct {
+	Type       string        `json:"type"`
+	ChirpID    uuid.NullUUID `json:"chirp_id"`
+	Summary    string        `json:"summary"`
+	ActorCount int64         `json:"actor_count"`
+	Actors     []chirpAuthor `json:"actors"`
+	Unread     bool          `json:"unread"`
+	LatestAt   time.Time     `json:"latest_at"`
+	Cursor     string        `json:"cursor"`
+}
+
+// GetNotifications returns the caller's notifications grouped and newest
+// first. Pages are keyed on the newest notification id of each group: pass
+// the next_cursor of one page as ?cursor= to get the next.
+func (cfg *APIConfig) GetNotifications(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	user, ok := cfg.currentUser(w, r)
+	if !ok {
+		return
+	}
+	before := int64(math.MaxInt64)
+	if v := r.URL.Query().Get("cursor"); v != "" {
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			apierror.Write(w, r, apierror.Errorf(apierror.InvalidRequest, "invalid cursor: %s", err))
+			return
+		}
+		before = n
+	}
+	limit := defaultNotificationLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxNotificationLimit {
+			apierror.Write(w, r, apierror.Errorf(apierror.InvalidRequest, "limit must be between 1 and %d", maxNotificationLimit))
+			return
+		}
+		limit = n
+	}
+	groups, err := cfg.Queries.GetNotificationGroups(r.Context(), database.GetNotificationGroupsParams{
+		UserID:    user.ID,
+		BeforeID:  before,
+		MaxGroups: int32(limit),
+	})
+	if err != nil {
+		apierror.Write(w, r, fmt.Errorf("error fetching notifications: %w", err))
+		return
+	}
+	var actorIDs []uuid.UUID
+	for _, g := range groups {
+		actorIDs = append(actorIDs, g.RecentActorIds...)
+	}
+	actors := map[uuid.UUID]chirpAuthor{}
+	if len(actorIDs) > 0 {
+		rows, err := cfg.Queries.GetAuthorsFromIDs(r.Context(), actorIDs)
+		if err != nil {
+			apierror.Write(w, r, fmt.Errorf("error fetching actors: %w", err))
+			return
+		}
+		for _, a := range rows {
+			actors[a.ID] = chirpAuthor(a)
+		}
+	}
+	type response struct {
+		Notifications []notificationGroup `json:"notifications"`
+		NextCursor    *string             `json:"next_cursor"`
+	}
+	resp := response{Notifications: make([]notificationGroup, 0, len(groups))}
+	for _, g := range groups {
+		n := notificationGroup{
+			Type:       g.Type,
+			ChirpID:    g.ChirpID,
+			ActorCount: g.ActorCount,
+			Actors:     make([]chirpAuthor, 0, len(g.RecentActorIds)),
+			Unread:     g.Unread,
+			LatestAt:   g.LatestAt,
+			Cursor:     strconv.FormatInt(g.LatestID, 10),
+		}
+		handles := make([]string, 0, len(g.RecentActorIds))
+		for _, id := range g.RecentActorIds {
+			if a, ok := actors[id]; ok {
+				n.Actors = append(n.Actors, a)
+				handles = append(handles, a.Handle)
+			}
+		}
+		n.Summary = notifications.Summary(notifications.Type(g.Type), handles, int(g.ActorCount))
+		resp.Notifications = append(resp.Notifications, n)
+	}
+	if len(groups) == limit {
+		next := resp.Notifications[len(groups)-1].Cursor
+		resp.NextCursor = &next
+	}
+	dat, err := json.Marshal(resp)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	w.WriteHeader(200)
+	w.Write(dat)
+}
+
+// MarkNotificationsRead marks every notification up to and including the
+// given cursor as read. Without a cursor all notifications are marked read.
+func (cfg *APIConfig) MarkNotificationsRead(w http.ResponseWriter, r *http.Request) {
+	type input struct {
+		Cursor string `json:"cursor"`
+	}
+	user, ok := cfg.currentUser(w, r)
+	if !ok {
+		return
+	}
+	var params input
+	if r.ContentLength != 0 {
+		if err := decodeJSON(r, &params); err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+	}
+	upTo := int64(math.MaxInt64)
+	if params.Cursor != "" {
+		n, err := strconv.ParseInt(params.Cursor, 10, 64)
+		if err != nil {
+			apierror.Write(w, r, apierror.Errorf(apierror.InvalidRequest, "invalid cursor: %s", err))
+			return
+		}
+		upTo = n
+	}
+	if err := cfg.Queries.MarkNotificationsRead(r.Context(), database.MarkNotificationsReadParams{
+		UserID: user.ID,
+		UpToID: upTo,
+	}); err != nil {
+		apierror.Write(w, r, fmt.Errorf("could not mark notifications read: %w", err))
+		return
+	}
+	w.WriteHeader(204)
+}
+
+func (cfg *APIConfig) GetUnreadNotificationCount(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	user, ok := cfg.currentUser(w, r)
+	if !ok {
+		return
+	}
+	count, err := cfg.Queries.CountUnreadNotifications(r.Context(), user.ID)
+	if err != nil {
+		apierror.Write(w, r, fmt.Errorf("error counting notifications: %w", err))
+		return
+	}
+	dat, err := json.Marshal(map[string]int64{"unread": count})
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	w.WriteHeader(200)
+	w.Write(dat)
+}
@@ -0,0 +1,411 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/RemcoVeens/httpserver/internal/auth"
+	"github.com/RemcoVeens/httpserver/internal/database"
+	"github.com/RemcoVeens/httpserver/internal/handles"
+	"github.com/RemcoVeens/httpserver/internal/web"
+	"github.com/google/uuid"
+)
+
+const (
+	// timelineSize is the number of chirps on the home page.
+	timelineSize = 50
+	// maxThreadDepth bounds how many chirps above the one shown a thread
+	// page walks up.
+	maxThreadDepth = 20
+	// maxFormBody bounds the bodies of the web UI's forms.
+	maxFormBody = 64 << 10
+)
+
+// The pages of the web UI share the query layer with the JSON API. Browsers
+// authenticate with the session cookies (see session.go); every form post
+// is checked against the CSRF cookie (see package web).
+
+// sessionUser returns the user r is logged in as, or nil. Pages check the
+// CSRF token of their forms themselves.
+func (cfg *APIConfig) sessionUser(r *http.Request) *database.User {
+	token, _, err := requestToken(r)
+	if err != nil {
+		return nil
+	}
+	user, err := cfg.userFromAccessToken(r.Context(), token)
+	if err != nil {
+		return nil
+	}
+	return &user
+}
+
+// page returns the parts of a web.Page every page shares. Logged-in users
+// get the chirp form.
+func (cfg *APIConfig) page(w http.ResponseWriter, r *http.Request, viewer *database.User, title string) web.Page {
+	p := web.Page{
+		Title: title,
+		CSRF:  web.CSRFToken(w, r, cfg.secureCookies()),
+	}
+	if viewer != nil {
+		p.Viewer = &web.Viewer{Handle: viewer.Handle, DisplayName: viewer.DisplayName}
+		p.Compose = &web.Compose{MaxLength: cfg.Entitlements.Limits(*viewer).MaxChirpLength}
+	}
+	return p
+}
+
+// renderError renders the error page with status.
+func (cfg *APIConfig) renderError(w http.ResponseWriter, r *http.Request, viewer *database.User, status int, title, message string) {
+	p := cfg.page(w, r, viewer, title)
+	p.Compose = nil
+	p.Data = message
+	cfg.Pages.Render(w, status, "error", p)
+}
+
+func idOf(user *database.User) uuid.UUID {
+	if user == nil {
+		return uuid.Nil
+	}
+	return user.ID
+}
+
+// safeNext returns next if it is a path on this site, and "/" otherwise, so
+// the login form can not be used to redirect elsewhere.
+func safeNext(next string) string {
+	if !strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/\\") {
+		return "/"
+	}
+	return next
+}
+
+// TimelinePage renders the newest chirps.
+func (cfg *APIConfig) TimelinePage(w http.ResponseWriter, r *http.Request) {
+	viewer := cfg.sessionUser(r)
+	chirps, err := cfg.Queries.GetLatestChirps(r.Context(), database.GetLatestChirpsParams{
+		ViewerID:  idOf(viewer),
+		MaxChirps: timelineSize,
+	})
+	if err != nil {
+		cfg.renderError(w, r, viewer, 500, "Something went wrong", "The timeline could not be loaded.")
+		return
+	}
+	resp, err := cfg.chirpResponses(r.Context(), chirps)
+	if err != nil {
+		cfg.renderError(w, r, viewer, 500, "Something went wrong", "The timeline could not be loaded.")
+		return
+	}
+	p := cfg.page(w, r, viewer, "")
+	p.Data = map[string]any{"Chirps": resp}
+	cfg.Pages.Render(w, 200, "timeline", p)
+}
+
+// ThreadPage renders {chirp_id} below the chirps it replies to and above
+// its replies.
+func (cfg *APIConfig) ThreadPage(w http.ResponseWriter, r *http.Request) {
+	viewer := cfg.sessionUser(r)
+	id, err := uuid.Parse(r.PathValue("chirp_id"))
+	if err != nil {
+		cfg.renderError(w, r, viewer, 404, "Not found", "There is no such chirp.")
+		return
+	}
+	chirp, err := cfg.Queries.GetVisibleChirpFromId(r.Context(), database.GetVisibleChirpFromIdParams{
+		ID:       id,
+		ViewerID: idOf(viewer),
+	})
+	if err != nil {
+		cfg.renderError(w, r, viewer, 404, "Not found", "There is no such chirp.")
+		return
+	}
+	ancestors, err := cfg.ancestors(r.Context(), chirp, idOf(viewer))
+	if err != nil {
+		cfg.renderError(w, r, viewer, 500, "Something went wrong", "The thread could not be loaded.")
+		return
+	}
+	replies, err := cfg.Queries.GetChirpReplies(r.Context(), database.GetChirpRepliesParams{
+		ReplyToID: uuid.NullUUID{UUID: chirp.ID, Valid: true},
+		ViewerID:  idOf(viewer),
+	})
+	if err != nil {
+		cfg.renderError(w, r, viewer, 500, "Something went wrong", "The thread could not be loaded.")
+		return
+	}
+	thread, err := cfg.chirpResponses(r.Context(), append(ancestors, chirp))
+	if err != nil {
+		cfg.renderError(w, r, viewer, 500, "Something went wrong", "The thread could not be loaded.")
+		return
+	}
+	replyResp, err := cfg.chirpResponses(r.Context(), replies)
+	if err != nil {
+		cfg.renderError(w, r, viewer, 500, "Something went wrong", "The thread could not be loaded.")
+		return
+	}
+	focus := thread[len(thread)-1]
+	p := cfg.page(w, r, viewer, "@"+focus.Author.Handle)
+	if p.Compose != nil {
+		p.Compose.ReplyTo = chirp.ID.String()
+	}
+	p.Data = map[string]any{
+		"Ancestors": thread[:len(thread)-1],
+		"Chirp":     focus,
+		"Replies":   replyResp,
+	}
+	cfg.Pages.Render(w, 200, "thread", p)
+}
+
+// ancestors returns the chirps chirp replies to, oldest first. The walk
+// stops at a deleted or hidden chirp, or after maxThreadDepth chirps.
+func (cfg *APIConfig) ancestors(ctx context.Context, chirp database.Chirp, viewer uuid.UUID) ([]database.Chirp, error) {
+	var out []database.Chirp
+	for next := chirp.ReplyToID; next.Valid && len(out) < maxThreadDepth; {
+		parent, err := cfg.Queries.GetVisibleChirpFromId(ctx, database.GetVisibleChirpFromIdParams{
+			ID:       next.UUID,
+			ViewerID: viewer,
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("could not get chirp replied to: %w", err)
+		}
+		out = append(out, parent)
+		next = parent.ReplyToID
+	}
+	slices.Reverse(out)
+	return out, nil
+}
+
+// ProfilePage renders {handle}'s profile and chirps, newest first. Users
+// who blocked the viewer, or whom the viewer blocked, are not found.
+func (cfg *APIConfig) ProfilePage(w http.ResponseWriter, r *http.Request) {
+	viewer := cfg.sessionUser(r)
+	user, err := cfg.Queries.GetUserFromHandle(r.Context(), r.PathValue("handle"))
+	if err != nil {
+		cfg.renderError(w, r, viewer, 404, "Not found", "There is no such user.")
+		return
+	}
+	if viewer != nil {
+		blocked, err := cfg.Queries.HasBlockWithUsers(r.Context(), database.HasBlockWithUsersParams{
+			UserID:   viewer.ID,
+			OtherIds: []uuid.UUID{user.ID},
+		})
+		if err != nil {
+			cfg.renderError(w, r, viewer, 500, "Something went wrong", "The profile could not be loaded.")
+			return
+		}
+		if blocked {
+			cfg.renderError(w, r, viewer, 404, "Not found", "There is no such user.")
+			return
+		}
+	}
+	chirps, err := cfg.Queries.GetChirpsFromAuthorID(r.Context(), database.GetChirpsFromAuthorIDParams{
+		UserID:   user.ID,
+		ViewerID: idOf(viewer),
+	})
+	if err != nil {
+		cfg.renderError(w, r, viewer, 500, "Something went wrong", "The profile could not be loaded.")
+		return
+	}
+	slices.Reverse(chirps)
+	resp, err := cfg.chirpResponses(r.Context(), chirps)
+	if err != nil {
+		cfg.renderError(w, r, viewer, 500, "Something went wrong", "The profile could not be loaded.")
+		return
+	}
+	title := user.DisplayName
+	if title == "" {
+		title = "@" + user.Handle
+	}
+	p := cfg.page(w, r, viewer, title)
+	p.Compose = nil
+	p.Data = map[string]any{"Profile": newPublicProfile(user), "Chirps": resp}
+	cfg.Pages.Render(w, 200, "profile", p)
+}
+
+// LoginPage renders the login form. ?next= is where to go afterwards.
+func (cfg *APIConfig) LoginPage(w http.ResponseWriter, r *http.Request) {
+	viewer := cfg.sessionUser(r)
+	next := safeNext(r.URL.Query().Get("next"))
+	if viewer != nil {
+		http.Redirect(w, r, next, http.StatusSeeOther)
+		return
+	}
+	p := cfg.page(w, r, nil, "Log in")
+	p.Data = map[string]any{"Email": "", "Next": next}
+	cfg.Pages.Render(w, 200, "login", p)
+}
+
+// Login checks the form's email and password and starts a session.
+func (cfg *APIConfig) Login(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxFormBody)
+	if !web.CheckCSRF(r) {
+		cfg.renderError(w, r, nil, 403, "Form expired", "The form expired. Please go back and try again.")
+		return
+	}
+	email := r.PostFormValue("email")
+	next := safeNext(r.PostFormValue("next"))
+	user, err := cfg.Queries.GetUserFromEmail(r.Context(), email)
+	ok := false
+	if err == nil {
+		ok, err = auth.CheckPasswordHash(r.PostFormValue("password"), user.HashedPassword)
+	}
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		log.Printf("could not check password for %s: %s", email, err)
+	}
+	if !ok {
+		p := cfg.page(w, r, nil, "Log in")
+		p.Error = "Wrong email or password."
+		p.Data = map[string]any{"Email": email, "Next": next}
+		cfg.Pages.Render(w, 401, "login", p)
+		return
+	}
+	disabled, err := cfg.Queries.IsDisabled(r.Context(), user.ID)
+	if err != nil {
+		log.Print(err)
+		cfg.renderError(w, r, nil, 500, "Something went wrong", "You could not be logged in. Please try again.")
+		return
+	}
+	if disabled {
+		p := cfg.page(w, r, nil, "Log in")
+		p.Error = "This account is disabled."
+		p.Data = map[string]any{"Email": email, "Next": next}
+		cfg.Pages.Render(w, 403, "login", p)
+		return
+	}
+	if err := cfg.startSession(w, r, user.ID); err != nil {
+		log.Print(err)
+		cfg.renderError(w, r, nil, 500, "Something went wrong", "You could not be logged in. Please try again.")
+		return
+	}
+	http.Redirect(w, r, next, http.StatusSeeOther)
+}
+
+// SignupPage renders the signup form.
+func (cfg *APIConfig) SignupPage(w http.ResponseWriter, r *http.Request) {
+	if cfg.sessionUser(r) != nil {
+		http.Redirect(w, r, "/", http.StatusSeeOther)
+		return
+	}
+	p := cfg.page(w, r, nil, "Sign up")
+	p.Data = map[string]any{"Email": "", "Handle": ""}
+	cfg.Pages.Render(w, 200, "signup", p)
+}
+
+// Signup creates an account from the form and logs it in.
+func (cfg *APIConfig) Signup(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxFormBody)
+	if !web.CheckCSRF(r) {
+		cfg.renderError(w, r, nil, 403, "Form expired", "The form expired. Please go back and try again.")
+		return
+	}
+	email := strings.TrimSpace(r.PostFormValue("email"))
+	handle := strings.TrimSpace(r.PostFormValue("handle"))
+	password := r.PostFormValue("password")
+	fail := func(status int, msg string) {
+		p := cfg.page(w, r, nil, "Sign up")
+		p.Error = msg
+		p.Data = map[string]any{"Email": email, "Handle": handle}
+		cfg.Pages.Render(w, status, "signup", p)
+	}
+	if email == "" || password == "" {
+		fail(400, "Email and password are required.")
+		return
+	}
+	params := database.CreateUserParams{Email: email, Handle: handle}
+	if params.Handle == "" {
+		params.Handle = handles.Generate()
+	} else if err := handles.Validate(handle); err != nil {
+		fail(400, fmt.Sprintf("Invalid handle: %s.", err))
+		return
+	}
+	hp, err := auth.HashPassword(password)
+	if err != nil {
+		fail(500, "Your account could not be created. Please try again.")
+		return
+	}
+	params.HashedPassword = hp
+	user, err := cfg.CreateUser(r.Context(), params)
+	if isUniqueViolation(err) {
+		fail(409, "That email or handle is already taken.")
+		return
+	}
+	if err != nil {
+		log.Printf("could not create user: %s", err)
+		fail(500, "Your account could not be created. Please try again.")
+		return
+	}
+	if err := cfg.startSession(w, r, user.ID); err != nil {
+		log.Print(err)
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// Logout ends the session.
+func (cfg *APIConfig) Logout(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxFormBody)
+	if !web.CheckCSRF(r) {
+		cfg.renderError(w, r, cfg.sessionUser(r), 403, "Form expired", "The form expired. Please go back and try again.")
+		return
+	}
+	if token := refreshToken(r); token != "" {
+		if err := cfg.Queries.RevokeToken(r.Context(), token); err != nil {
+			log.Printf("could not revoke session: %s", err)
+		}
+	}
+	web.ClearSession(w, cfg.secureCookies())
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// PostChirp posts the chirp form, optionally as a reply to reply_to, and
+// shows the thread it belongs to.
+func (cfg *APIConfig) PostChirp(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxFormBody)
+	viewer := cfg.sessionUser(r)
+	if !web.CheckCSRF(r) {
+		cfg.renderError(w, r, viewer, 403, "Form expired", "The form expired. Please go back and try again.")
+		return
+	}
+	if viewer == nil {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+	in := newChirp{Body: r.PostFormValue("body")}
+	if v := r.PostFormValue("reply_to"); v != "" {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			cfg.renderError(w, r, viewer, 400, "Could not post", "The chirp replied to does not exist.")
+			return
+		}
+		in.ReplyTo = uuid.NullUUID{UUID: id, Valid: true}
+	}
+	if strings.TrimSpace(in.Body) == "" {
+		cfg.renderError(w, r, viewer, 400, "Could not post", "Your chirp is empty.")
+		return
+	}
+	if limit := cfg.Entitlements.Limits(*viewer).MaxChirpLength; utf8.RuneCountInString(in.Body) > limit {
+		cfg.renderError(w, r, viewer, 400, "Could not post", fmt.Sprintf("Chirps can be at most %d characters on your plan.", limit))
+		return
+	}
+	chirp, err := cfg.createChirp(r.Context(), *viewer, in)
+	if errors.Is(err, errReplyUnavailable) {
+		cfg.renderError(w, r, viewer, 400, "Could not post", "The chirp replied to does not exist.")
+		return
+	}
+	if err != nil {
+		log.Printf("Could not create chirp from user %s: %s", viewer.ID, err)
+		cfg.renderError(w, r, viewer, 500, "Could not post", "Your chirp could not be posted. Please try again.")
+		return
+	}
+	if in.ReplyTo.Valid {
+		http.Redirect(w, r, "/c/"+in.ReplyTo.UUID.String()+"#chirp-"+chirp.ID.String(), http.StatusSeeOther)
+		return
+	}
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/RemcoVeens/httpserver/internal/apierror"
+	"github.com/RemcoVeens/httpserver/internal/auth"
+	"github.com/RemcoVeens/httpserver/internal/database"
+	"github.com/google/uuid"
+)
+
+// MiddlewareRateLimit limits /api/ requests to the requests_per_minute of
+// the caller's plan. Authenticated callers are limited per user, anonymous
+// ones per remote address. Health checks and Polka webhooks are not limited.
+func (cfg *APIConfig) MiddlewareRateLimit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := r.URL.Path
+		if !strings.HasPrefix(path, "/api/") || path == "/api/healthz" || strings.HasPrefix(path, "/api/polka/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+		key, userID := rateLimitKey(r, cfg.Secret)
+		ok, wait := cfg.Limiter.Allow(key, time.Now(), func() int {
+			var user database.User
+			if userID != uuid.Nil {
+				// Unknown users fall back to the free plan.
+				user, _ = cfg.Queries.GetUserFromId(r.Context(), userID)
+			}
+			return cfg.Entitlements.Limits(user).RequestsPerMinute
+		})
+		if !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			apierror.Write(w, r, apierror.New(apierror.RateLimited, "rate limit exceeded"))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// rateLimitKey returns the bucket key for r and the user id it belongs to,
+// or uuid.Nil for anonymous requests. Only the token's signature is checked,
+// so the key costs no database lookup.
+func rateLimitKey(r *http.Request, secret string) (string, uuid.UUID) {
+	token, _, err := requestToken(r)
+	if err != nil {
+		token = r.URL.Query().Get("access_token")
+	}
+	if token != "" {
+		if id, err := auth.ValidateJWT(token, secret); err == nil {
+			return "user:" + id.String(), id
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host, uuid.Nil
+}
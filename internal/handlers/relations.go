@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/RemcoVeens/httpserver/internal/apierror"
+	"github.com/RemcoVeens/httpserver/internal/database"
+	"github.com/google/uuid"
+)
+
+// relationTarget resolves the authenticated caller and the {user_id} path
+// value shared by the block and mute endpoints. It writes the error response
+// itself and reports ok=false when the request cannot continue.
+func (cfg *APIConfig) relationTarget(w http.ResponseWriter, r *http.Request) (database.User, uuid.UUID, bool) {
+	user, ok := cfg.currentUser(w, r)
+	if !ok {
+		return database.User{}, uuid.Nil, false
+	}
+	targetID, err := uuid.Parse(r.PathValue("user_id"))
+	if err != nil {
+		apierror.Write(w, r, apierror.Errorf(apierror.InvalidRequest, "could not parse user id: %s", err))
+		return database.User{}, uuid.Nil, false
+	}
+	if targetID == user.ID {
+		apierror.Write(w, r, apierror.New(apierror.InvalidRequest, "you can not do this to yourself"))
+		return database.User{}, uuid.Nil, false
+	}
+	if _, err := cfg.Queries.GetUserFromId(r.Context(), targetID); err != nil {
+		apierror.Write(w, r, lookupError(err, "user not found"))
+		return database.User{}, uuid.Nil, false
+	}
+	return user, targetID, true
+}
+
+func (cfg *APIConfig) BlockUser(w http.ResponseWriter, r *http.Request) {
+	user, targetID, ok := cfg.relationTarget(w, r)
+	if !ok {
+		return
+	}
+	if err := cfg.Queries.BlockUser(r.Context(), database.BlockUserParams{
+		BlockerID: user.ID,
+		BlockedID: targetID,
+	}); err != nil {
+		apierror.Write(w, r, fmt.Errorf("could not block user: %w", err))
+		return
+	}
+	w.WriteHeader(204)
+}
+
+func (cfg *APIConfig) UnblockUser(w http.ResponseWriter, r *http.Request) {
+	user, targetID, ok := cfg.relationTarget(w, r)
+	if !ok {
+		return
+	}
+	if err := cfg.Queries.UnblockUser(r.Context(), database.UnblockUserParams{
+		BlockerID: user.ID,
+		BlockedID: targetID,
+	}); err != nil {
+		apierror.Write(w, r, fmt.Errorf("could not unblock user: %w", err))
+		return
+	}
+	w.WriteHeader(204)
+}
+
+func (cfg *APIConfig) MuteUser(w http.ResponseWriter, r *http.Request) {
+	user, targetID, ok := cfg.relationTarget(w, r)
+	if !ok {
+		return
+	}
+	if err := cfg.Queries.MuteUser(r.Context(), database.MuteUserParams{
+		MuterID: user.ID,
+		MutedID: targetID,
+	}); err != nil {
+		apierror.Write(w, r, fmt.Errorf("could not mute user: %w", err))
+		return
+	}
+	w.WriteHeader(204)
+}
+
+func (cfg *APIConfig) UnmuteUser(w http.ResponseWriter, r *http.Request) {
+	user, targetID, ok := cfg.relationTarget(w, r)
+	if !ok {
+		return
+	}
+	if err := cfg.Queries.UnmuteUser(r.Context(), database.UnmuteUserParams{
+		MuterID: user.ID,
+		MutedID: targetID,
+	}); err != nil {
+		apierror.Write(w, r, fmt.Errorf("could not unmute user: %w", err))
+		return
+	}
+	w.WriteHeader(204)
+}
+
+func (cfg *APIConfig) GetBlocks(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	user, ok := cfg.currentUser(w, r)
+	if !ok {
+		return
+	}
+	blocks, err := cfg.Queries.GetBlockedUsers(r.Context(), user.ID)
+	if err != nil {
+		apierror.Write(w, r, fmt.Errorf("error fetching blocks: %w", err))
+		return
+	}
+	if blocks == nil {
+		blocks = []database.GetBlockedUsersRow{}
+	}
+	dat, err := json.Marshal(blocks)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	w.WriteHeader(200)
+	w.Write(dat)
+}
+
+func (cfg *APIConfig) GetMutes(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	user, ok := cfg.currentUser(w, r)
+	if !ok {
+		return
+	}
+	mutes, err := cfg.Queries.GetMutedUsers(r.Context(), user.ID)
+	if err != nil {
+		apierror.Write(w, r, fmt.Errorf("error fetching mutes: %w", err))
+		return
+	}
+	if mutes == nil {
+		mutes = []database.GetMutedUsersRow{}
+	}
+	dat, err := json.Marshal(mutes)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	w.WriteHeader(200)
+	w.Write(dat)
+}
@@ -0,0 +1,254 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/RemcoVeens/httpserver/internal/auth"
+	"github.com/RemcoVeens/httpserver/internal/database"
+	"github.com/RemcoVeens/httpserver/internal/web"
+	"github.com/google/uuid"
+)
+
+// Browsers can keep their tokens in cookies instead of script-readable
+// storage: logging in with use_cookies, or through the web UI, starts a
+// session (see package web for the cookies). Requests without an
+// Authorization header are then authenticated with the access cookie, and
+// MiddlewareSession renews it from the refresh token when it expires,
+// rotating the refresh token as it goes.
+
+const (
+	// defaultAccessTTL is how long access tokens are valid unless
+	// APIConfig.AccessTTL says otherwise.
+	defaultAccessTTL = time.Hour
+	// defaultRefreshTTL is how long a refresh token, and so a session,
+	// stays valid when it is not used, unless APIConfig.RefreshTTL says
+	// otherwise.
+	defaultRefreshTTL = 60 * 24 * time.Hour
+	// rotationGrace is how long a rotated refresh token still yields
+	// access tokens, for requests that were sent with it before the new
+	// cookies arrived.
+	rotationGrace = 30 * time.Second
+)
+
+var (
+	// errCSRF is returned for cookie-authenticated unsafe requests that do
+	// not echo the CSRF token.
+	errCSRF = errors.New("cookie-authenticated requests must send the CSRF token in " + web.CSRFHeader)
+	// errSessionEnded is returned by rotateSession for refresh tokens that
+	// are unknown, expired or revoked.
+	errSessionEnded = errors.New("session ended")
+)
+
+// session is a pair of tokens issued to a browser. Refresh is empty when
+// only the access token was renewed.
+type session struct {
+	UserID         uuid.UUID
+	Access         string
+	AccessExpires  time.Time
+	Refresh        string
+	RefreshExpires time.Time
+}
+
+type sessionKey struct{}
+
+// renewedSession returns the session MiddlewareSession renewed for r, if
+// any.
+func renewedSession(r *http.Request) (session, bool) {
+	s, ok := r.Context().Value(sessionKey{}).(session)
+	return s, ok
+}
+
+// requestToken returns the access token r carries, from the Authorization
+// header or else the session, and whether it came from the session.
+func requestToken(r *http.Request) (string, bool, error) {
+	if r.Header.Get("Authorization") != "" {
+		token, err := auth.GetBearerToken(r.Header)
+		if err != nil {
+			return "", false, fmt.Errorf("%w: %w", errUnauthenticated, err)
+		}
+		return token, false, nil
+	}
+	if s, ok := renewedSession(r); ok {
+		return s.Access, true, nil
+	}
+	if token := web.AccessToken(r); token != "" {
+		return token, true, nil
+	}
+	return "", false, errUnauthenticated
+}
+
+// refreshToken returns the refresh token of r's session, or "".
+func refreshToken(r *http.Request) string {
+	if s, ok := renewedSession(r); ok && s.Refresh != "" {
+		return s.Refresh
+	}
+	return web.SessionToken(r)
+}
+
+// accessToken returns the access token r is authenticated with. Tokens
+// from a session cookie are only accepted for unsafe methods when the
+// request echoes the CSRF token.
+func (cfg *APIConfig) accessToken(r *http.Request) (string, error) {
+	token, cookie, err := requestToken(r)
+	if err != nil {
+		return "", err
+	}
+	if cookie && !safeMethod(r.Method) && !web.CheckCSRFHeader(r) {
+		return "", errCSRF
+	}
+	return token, nil
+}
+
+func safeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+// secureCookies reports whether cookies must only be sent over HTTPS.
+func (cfg *APIConfig) secureCookies() bool {
+	return cfg.Platform != "dev"
+}
+
+// newSession issues a refresh token and an access token for userID.
+func (cfg *APIConfig) newSession(ctx context.Context, q *database.Queries, userID uuid.UUID) (session, error) {
+	token, err := auth.MakeRefreshToken()
+	if err != nil {
+		return session{}, fmt.Errorf("could not make refresh token: %w", err)
+	}
+	rt, err := q.GenerateToken(ctx, database.GenerateTokenParams{
+		Token:     token,
+		UserID:    userID,
+		ExpiresAt: time.Now().Add(cfg.refreshTTL()),
+	})
+	if err != nil {
+		return session{}, fmt.Errorf("could not store refresh token: %w", err)
+	}
+	s := session{UserID: userID, Refresh: rt.Token, RefreshExpires: rt.ExpiresAt}
+	if err := cfg.renewAccess(&s); err != nil {
+		return session{}, err
+	}
+	return s, nil
+}
+
+func (cfg *APIConfig) accessTTL() time.Duration {
+	if cfg.AccessTTL > 0 {
+		return cfg.AccessTTL
+	}
+	return defaultAccessTTL
+}
+
+func (cfg *APIConfig) refreshTTL() time.Duration {
+	if cfg.RefreshTTL > 0 {
+		return cfg.RefreshTTL
+	}
+	return defaultRefreshTTL
+}
+
+func (cfg *APIConfig) renewAccess(s *session) error {
+	access, err := auth.MakeJWT(s.UserID, cfg.Secret, cfg.accessTTL()/time.Second)
+	if err != nil {
+		return fmt.Errorf("could not make access token: %w", err)
+	}
+	s.Access = access
+	s.AccessExpires = time.Now().Add(cfg.accessTTL())
+	return nil
+}
+
+// startSession logs userID in on this browser.
+func (cfg *APIConfig) startSession(w http.ResponseWriter, r *http.Request, userID uuid.UUID) error {
+	s, err := cfg.newSession(r.Context(), cfg.Queries, userID)
+	if err != nil {
+		return err
+	}
+	cfg.setSessionCookies(w, s)
+	return nil
+}
+
+func (cfg *APIConfig) setSessionCookies(w http.ResponseWriter, s session) {
+	if s.Refresh == "" {
+		web.SetAccess(w, s.Access, s.AccessExpires, cfg.secureCookies())
+		return
+	}
+	web.SetSession(w, s.Access, s.AccessExpires, s.Refresh, s.RefreshExpires, cfg.secureCookies())
+}
+
+// rotateSession revokes refresh and issues a new session in its place. A
+// token rotated less than rotationGrace ago only yields a new access token;
+// any other revoked, expired or unknown token is errSessionEnded.
+func (cfg *APIConfig) rotateSession(ctx context.Context, refresh string) (session, error) {
+	var s session
+	err := database.RunInTx(ctx, cfg.DB, func(q *database.Queries) error {
+		old, err := q.RevokeActiveToken(ctx, refresh)
+		if err != nil {
+			return err
+		}
+		s, err = cfg.newSession(ctx, q, old.UserID)
+		return err
+	})
+	if !errors.Is(err, sql.ErrNoRows) {
+		return s, err
+	}
+	rt, err := cfg.Queries.GetTokenFromToken(ctx, refresh)
+	if errors.Is(err, sql.ErrNoRows) {
+		return session{}, errSessionEnded
+	}
+	if err != nil {
+		return session{}, fmt.Errorf("could not get refresh token: %w", err)
+	}
+	if !rt.RevokedAt.Valid || time.Since(rt.RevokedAt.Time) > rotationGrace || time.Now().After(rt.ExpiresAt) {
+		return session{}, errSessionEnded
+	}
+	s = session{UserID: rt.UserID}
+	if err := cfg.renewAccess(&s); err != nil {
+		return session{}, err
+	}
+	return s, nil
+}
+
+// MiddlewareSession renews browser sessions whose access cookie expired,
+// so cookie-authenticated clients never have to call /api/refresh
+// themselves. Requests with an Authorization header are left alone.
+func (cfg *APIConfig) MiddlewareSession(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		refresh := web.SessionToken(r)
+		if r.Header.Get("Authorization") != "" || refresh == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if access := web.AccessToken(r); access != "" {
+			if _, err := auth.ValidateJWT(access, cfg.Secret); err == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+		s, err := cfg.rotateSession(r.Context(), refresh)
+		if err != nil {
+			if !errors.Is(err, errSessionEnded) {
+				log.Printf("could not renew session: %s", err)
+			}
+			// The cookies are left alone: a request racing a rotation must
+			// not clear the cookies the rotation just set.
+			next.ServeHTTP(w, r)
+			return
+		}
+		cfg.setSessionCookies(w, s)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), sessionKey{}, s)))
+	})
+}
+
+// sameOrigin reports whether r was sent by a page of this site, going by
+// its Origin header.
+func sameOrigin(r *http.Request) bool {
+	u, err := url.Parse(r.Header.Get("Origin"))
+	return err == nil && u.Host != "" && u.Host == r.Host
+}
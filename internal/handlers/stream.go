@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/RemcoVeens/httpserver/internal/apierror"
+	"github.com/RemcoVeens/httpserver/internal/entities"
+	"github.com/RemcoVeens/httpserver/internal/pubsub"
+	"github.com/google/uuid"
+)
+
+const (
+	streamHeartbeat    = 15 * time.Second
+	streamWriteTimeout = 10 * time.Second
+)
+
+// streamChirp is the part of a chirp event the stream filters on. It matches
+// both chirpResponse and the smaller chirp.deleted payload.
+type streamChirp struct {
+	UserID   uuid.UUID         `json:"user_id"`
+	Entities entities.Entities `json:"entities"`
+}
+
+// chirpFilter builds the subscription filter for a stream. Chirps by authors
+// in hidden (blocks in either direction, and mutes) are never sent. When tags
+// is set only chirp.created events carrying one of them pass; deletions are
+// always passed so clients can drop chirps they already show.
+func chirpFilter(authorID uuid.UUID, tags []string, hidden map[uuid.UUID]bool) pubsub.Filter {
+	return func(e pubsub.Event) bool {
+		if e.Type != pubsub.ChirpCreated && e.Type != pubsub.ChirpDeleted {
+			return false
+		}
+		var c streamChirp
+		if err := json.Unmarshal(e.Data, &c); err != nil {
+			return false
+		}
+		if hidden[c.UserID] || (authorID != uuid.Nil && c.UserID != authorID) {
+			return false
+		}
+		if len(tags) == 0 || e.Type == pubsub.ChirpDeleted {
+			return true
+		}
+		for _, h := range c.Entities.Hashtags {
+			for _, tag := range tags {
+				if h.Tag == tag {
+					return true
+				}
+			}
+		}
+		return false
+	}
+}
+
+// StreamChirps pushes chirp events as Server-Sent Events. Filters are
+// ?author_id= and ?hashtag= (repeatable or comma separated). A client that
+// reconnects with Last-Event-ID is first sent the events it missed, as far as
+// the broker's replay buffer reaches; if it had fallen further behind a
+// "reset" event tells it to refetch instead. Clients that can not keep up are
+// disconnected.
+func (cfg *APIConfig) StreamChirps(w http.ResponseWriter, r *http.Request) {
+	if cfg.Broker == nil {
+		apierror.Write(w, r, apierror.New(apierror.Unavailable, "streaming is not available"))
+		return
+	}
+	var authorID uuid.UUID
+	if v := r.URL.Query().Get("author_id"); v != "" {
+		var err error
+		authorID, err = uuid.Parse(v)
+		if err != nil {
+			apierror.Write(w, r, apierror.Errorf(apierror.InvalidRequest, "error parsing author id: %s", err))
+			return
+		}
+	}
+	var tags []string
+	for _, v := range r.URL.Query()["hashtag"] {
+		for _, tag := range strings.Split(v, ",") {
+			if tag = entities.NormalizeTag(strings.TrimSpace(tag)); tag != "" {
+				tags = append(tags, tag)
+			}
+		}
+	}
+	var lastID uint64
+	if v := r.Header.Get("Last-Event-ID"); v != "" {
+		var err error
+		lastID, err = strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			apierror.Write(w, r, apierror.Errorf(apierror.InvalidRequest, "invalid Last-Event-ID: %s", err))
+			return
+		}
+	}
+	hidden := map[uuid.UUID]bool{}
+	if viewer := cfg.viewerID(r); viewer != uuid.Nil {
+		ids, err := cfg.Queries.GetHiddenAuthorIDs(r.Context(), viewer)
+		if err != nil {
+			apierror.Write(w, r, fmt.Errorf("error fetching blocks: %w", err))
+			return
+		}
+		for _, id := range ids {
+			hidden[id] = true
+		}
+	}
+
+	sub, replay, truncated := cfg.Broker.Subscribe(lastID, chirpFilter(authorID, tags, hidden))
+	defer sub.Close()
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(200)
+
+	write := func(format string, args ...any) bool {
+		rc.SetWriteDeadline(time.Now().Add(streamWriteTimeout))
+		if _, err := fmt.Fprintf(w, format, args...); err != nil {
+			return false
+		}
+		return rc.Flush() == nil
+	}
+	send := func(e pubsub.Event) bool {
+		return write("id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, e.Data)
+	}
+
+	if !write("retry: 3000\n\n") {
+		return
+	}
+	if truncated && !write("event: reset\ndata: {}\n\n") {
+		return
+	}
+	for _, e := range replay {
+		if !send(e) {
+			return
+		}
+	}
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			if !write(": heartbeat\n\n") {
+				return
+			}
+		case e, ok := <-sub.C():
+			if !ok {
+				if sub.Dropped() {
+					log.Printf("dropped slow chirp stream subscriber %s", r.RemoteAddr)
+				}
+				return
+			}
+			if !send(e) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/RemcoVeens/httpserver/internal/apierror"
+	"github.com/google/uuid"
+)
+
+type subscriptionResponse struct {
+	ID               uuid.UUID  `json:"id"`
+	Plan             string     `json:"plan"`
+	Status           string     `json:"status"`
+	CurrentPeriodEnd *time.Time `json:"current_period_end"`
+	CreatedAt        time.Time  `json:"created_at"`
+	UpdatedAt        time.Time  `json:"updated_at"`
+}
+
+// GetSubscriptions returns the caller's Chirpy Red subscriptions, the
+// current one first, followed by the expired ones.
+func (cfg *APIConfig) GetSubscriptions(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	user, ok := cfg.currentUser(w, r)
+	if !ok {
+		return
+	}
+	subs, err := cfg.Queries.GetSubscriptionsForUser(r.Context(), user.ID)
+	if err != nil {
+		apierror.Write(w, r, fmt.Errorf("error fetching subscriptions: %w", err))
+		return
+	}
+	resp := make([]subscriptionResponse, len(subs))
+	for i, s := range subs {
+		resp[i] = subscriptionResponse{
+			ID:        s.ID,
+			Plan:      s.Plan,
+			Status:    s.Status,
+			CreatedAt: s.CreatedAt,
+			UpdatedAt: s.UpdatedAt,
+		}
+		if s.CurrentPeriodEnd.Valid {
+			resp[i].CurrentPeriodEnd = &s.CurrentPeriodEnd.Time
+		}
+	}
+	dat, err := json.Marshal(resp)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	w.WriteHeader(200)
+	w.Write(dat)
+}
@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+	"unicode/utf8"
+
+	"github.com/RemcoVeens/httpserver/internal/apierror"
+	"github.com/RemcoVeens/httpserver/internal/database"
+	"github.com/RemcoVeens/httpserver/internal/handles"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const (
+	maxDisplayNameLength = 50
+	maxBioLength         = 160
+	maxAvatarURLLength   = 2048
+)
+
+// publicProfile is what anyone may see about a user; it never includes the
+// email address.
+type publicProfile struct {
+	ID          uuid.UUID `json:"id"`
+	Handle      string    `json:"handle"`
+	DisplayName string    `json:"display_name"`
+	Bio         string    `json:"bio"`
+	AvatarUrl   string    `json:"avatar_url"`
+	IsChirpyRed bool      `json:"is_chirpy_red"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+func newPublicProfile(u database.User) publicProfile {
+	return publicProfile{
+		ID:          u.ID,
+		Handle:      u.Handle,
+		DisplayName: u.DisplayName,
+		Bio:         u.Bio,
+		AvatarUrl:   u.AvatarUrl,
+		IsChirpyRed: u.IsChirpyRed,
+		CreatedAt:   u.CreatedAt,
+	}
+}
+
+func isUniqueViolation(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == "23505"
+}
+
+// account is the caller's own user, as every endpoint returning it shows it:
+// everything but the password hash.
+type account struct {
+	ID          uuid.UUID `json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	Email       string    `json:"email"`
+	IsChirpyRed bool      `json:"is_chirpy_red"`
+	Handle      string    `json:"handle"`
+	DisplayName string    `json:"display_name"`
+	Bio         string    `json:"bio"`
+	AvatarUrl   string    `json:"avatar_url"`
+}
+
+// GetMe returns the caller's account.
+func (cfg *APIConfig) GetMe(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	user, ok := cfg.currentUser(w, r)
+	if !ok {
+		return
+	}
+	dat, err := json.Marshal(account{
+		ID:          user.ID,
+		CreatedAt:   user.CreatedAt,
+		UpdatedAt:   user.UpdatedAt,
+		Email:       user.Email,
+		IsChirpyRed: user.IsChirpyRed,
+		Handle:      user.Handle,
+		DisplayName: user.DisplayName,
+		Bio:         user.Bio,
+		AvatarUrl:   user.AvatarUrl,
+	})
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	w.WriteHeader(200)
+	w.Write(dat)
+}
+
+func (cfg *APIConfig) GetUserProfile(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	user, err := cfg.Queries.GetUserFromHandle(r.Context(), r.PathValue("handle"))
+	if err != nil {
+		apierror.Write(w, r, lookupError(err, "user not found"))
+		return
+	}
+	if viewer := cfg.viewerID(r); viewer != uuid.Nil {
+		blocked, err := cfg.Queries.HasBlockWithUsers(r.Context(), database.HasBlockWithUsersParams{
+			UserID:   viewer,
+			OtherIds: []uuid.UUID{user.ID},
+		})
+		if err != nil {
+			apierror.Write(w, r, fmt.Errorf("error checking blocks: %w", err))
+			return
+		}
+		if blocked {
+			apierror.Write(w, r, apierror.New(apierror.NotFound, "user not found"))
+			return
+		}
+	}
+	dat, err := json.Marshal(newPublicProfile(user))
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	w.WriteHeader(200)
+	w.Write(dat)
+}
+
+// UpdateProfile applies a partial update: fields left out of the body keep
+// their current value, while an empty string clears them.
+func (cfg *APIConfig) UpdateProfile(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	type input struct {
+		Handle      *string `json:"handle"`
+		DisplayName *string `json:"display_name"`
+		Bio         *string `json:"bio"`
+		AvatarUrl   *string `json:"avatar_url"`
+	}
+	user, ok := cfg.currentUser(w, r)
+	if !ok {
+		return
+	}
+	var params input
+	if err := decodeJSON(r, &params); err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	// A handle sent back unchanged is fine, even a generated one.
+	if params.Handle != nil && *params.Handle != user.Handle {
+		if err := handles.Validate(*params.Handle); err != nil {
+			apierror.Write(w, r, apierror.Errorf(apierror.InvalidRequest, "invalid handle: %s", err))
+			return
+		}
+	}
+	if params.DisplayName != nil && utf8.RuneCountInString(*params.DisplayName) > maxDisplayNameLength {
+		apierror.Write(w, r, apierror.Errorf(apierror.InvalidRequest, "display_name can be at most %d characters", maxDisplayNameLength))
+		return
+	}
+	if params.Bio != nil && utf8.RuneCountInString(*params.Bio) > maxBioLength {
+		apierror.Write(w, r, apierror.Errorf(apierror.InvalidRequest, "bio can be at most %d characters", maxBioLength))
+		return
+	}
+	if params.AvatarUrl != nil && *params.AvatarUrl != "" {
+		if err := validateAvatarURL(*params.AvatarUrl); err != nil {
+			apierror.Write(w, r, apierror.Errorf(apierror.InvalidRequest, "invalid avatar_url: %s", err))
+			return
+		}
+	}
+	updated, err := cfg.Queries.UpdateUserProfile(r.Context(), database.UpdateUserProfileParams{
+		Handle:      nullString(params.Handle),
+		DisplayName: nullString(params.DisplayName),
+		Bio:         nullString(params.Bio),
+		AvatarUrl:   nullString(params.AvatarUrl),
+		ID:          user.ID,
+	})
+	if isUniqueViolation(err) {
+		apierror.Write(w, r, apierror.New(apierror.Conflict, "handle is already taken"))
+		return
+	}
+	if err != nil {
+		apierror.Write(w, r, fmt.Errorf("could not update profile: %w", err))
+		return
+	}
+	dat, err := json.Marshal(newPublicProfile(updated))
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	w.WriteHeader(200)
+	w.Write(dat)
+}
+
+func validateAvatarURL(raw string) error {
+	if len(raw) > maxAvatarURLLength {
+		return fmt.Errorf("must be at most %d bytes", maxAvatarURLLength)
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("must be an absolute http(s) URL")
+	}
+	return nil
+}
+
+func nullString(s *string) sql.NullString {
+	if s == nil {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: *s, Valid: true}
+}
@@ -0,0 +1,292 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/RemcoVeens/httpserver/internal/apierror"
+	"github.com/RemcoVeens/httpserver/internal/database"
+	"github.com/RemcoVeens/httpserver/internal/webhooks"
+	"github.com/google/uuid"
+)
+
+const (
+	maxWebhooksPerUser     = 10
+	defaultDeliveriesLimit = 20
+	maxDeliveriesLimit     = 100
+)
+
+// webhookResponse is how an endpoint is rendered. The secret is only
+// included when the endpoint is created.
+type webhookResponse struct {
+	ID         uuid.UUID `json:"id"`
+	Url        string    `json:"url"`
+	EventTypes []string  `json:"event_types"`
+	Secret     string    `json:"secret,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+func newWebhookResponse(h database.Webhook) webhookResponse {
+	return webhookResponse{
+		ID:         h.ID,
+		Url:        h.Url,
+		EventTypes: h.EventTypes,
+		CreatedAt:  h.CreatedAt,
+	}
+}
+
+type deliveryAttempt struct {
+	AttemptedAt time.Time `json:"attempted_at"`
+	StatusCode  *int32    `json:"status_code"`
+	Error       string    `json:"error"`
+	DurationMs  int32     `json:"duration_ms"`
+}
+
+type deliveryResponse struct {
+	ID            int64             `json:"id"`
+	EventType     string            `json:"event_type"`
+	Payload       json.RawMessage   `json:"payload"`
+	Status        string            `json:"status"`
+	Attempts      []deliveryAttempt `json:"attempts"`
+	NextAttemptAt *time.Time        `json:"next_attempt_at"`
+	LastError     *string           `json:"last_error"`
+	CreatedAt     time.Time         `json:"created_at"`
+	DeliveredAt   *time.Time        `json:"delivered_at"`
+}
+
+// ownWebhook resolves the authenticated caller's {webhook_id}. Endpoints of
+// other users are reported as not found. It writes the error response itself
+// and reports ok=false when the request cannot continue.
+func (cfg *APIConfig) ownWebhook(w http.ResponseWriter, r *http.Request) (database.Webhook, bool) {
+	user, ok := cfg.currentUser(w, r)
+	if !ok {
+		return database.Webhook{}, false
+	}
+	id, err := uuid.Parse(r.PathValue("webhook_id"))
+	if err != nil {
+		apierror.Write(w, r, apierror.Errorf(apierror.InvalidRequest, "could not parse webhook id: %s", err))
+		return database.Webhook{}, false
+	}
+	hook, err := cfg.Queries.GetWebhook(r.Context(), id)
+	if err != nil {
+		apierror.Write(w, r, lookupError(err, "webhook not found"))
+		return database.Webhook{}, false
+	}
+	if hook.UserID != user.ID {
+		apierror.Write(w, r, apierror.New(apierror.NotFound, "webhook not found"))
+		return database.Webhook{}, false
+	}
+	return hook, true
+}
+
+// CreateWebhook registers an endpoint for the caller. The response carries
+// the signing secret; it is not shown again.
+func (cfg *APIConfig) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	type input struct {
+		Url        string   `json:"url"`
+		EventTypes []string `json:"event_types"`
+	}
+	user, ok := cfg.currentUser(w, r)
+	if !ok {
+		return
+	}
+	var params input
+	if err := decodeJSON(r, &params); err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	if err := webhooks.ValidateURL(params.Url); err != nil {
+		apierror.Write(w, r, apierror.Errorf(apierror.InvalidRequest, "invalid url: %s", err))
+		return
+	}
+	types, err := webhooks.ValidateEventTypes(params.EventTypes)
+	if err != nil {
+		apierror.Write(w, r, apierror.Errorf(apierror.InvalidRequest, "invalid event_types: %s", err))
+		return
+	}
+	existing, err := cfg.Queries.GetWebhooksForUser(r.Context(), user.ID)
+	if err != nil {
+		apierror.Write(w, r, fmt.Errorf("error fetching webhooks: %w", err))
+		return
+	}
+	if len(existing) >= maxWebhooksPerUser {
+		apierror.Write(w, r, apierror.Errorf(apierror.Conflict, "you can have at most %d webhooks", maxWebhooksPerUser))
+		return
+	}
+	hook, err := cfg.Queries.CreateWebhook(r.Context(), database.CreateWebhookParams{
+		UserID:     user.ID,
+		Url:        params.Url,
+		Secret:     webhooks.GenerateSecret(),
+		EventTypes: types,
+	})
+	if err != nil {
+		apierror.Write(w, r, fmt.Errorf("could not create webhook: %w", err))
+		return
+	}
+	resp := newWebhookResponse(hook)
+	resp.Secret = hook.Secret
+	dat, err := json.Marshal(resp)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	w.WriteHeader(201)
+	w.Write(dat)
+}
+
+func (cfg *APIConfig) GetWebhooks(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	user, ok := cfg.currentUser(w, r)
+	if !ok {
+		return
+	}
+	hooks, err := cfg.Queries.GetWebhooksForUser(r.Context(), user.ID)
+	if err != nil {
+		apierror.Write(w, r, fmt.Errorf("error fetching webhooks: %w", err))
+		return
+	}
+	resp := make([]webhookResponse, len(hooks))
+	for i, h := range hooks {
+		resp[i] = newWebhookResponse(h)
+	}
+	dat, err := json.Marshal(resp)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	w.WriteHeader(200)
+	w.Write(dat)
+}
+
+// DeleteWebhook removes an endpoint together with its pending deliveries.
+func (cfg *APIConfig) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	hook, ok := cfg.ownWebhook(w, r)
+	if !ok {
+		return
+	}
+	if _, err := cfg.Queries.DeleteWebhook(r.Context(), database.DeleteWebhookParams{
+		ID:     hook.ID,
+		UserID: hook.UserID,
+	}); err != nil {
+		apierror.Write(w, r, fmt.Errorf("could not delete webhook: %w", err))
+		return
+	}
+	w.WriteHeader(204)
+}
+
+// GetWebhookDeliveries lists an endpoint's deliveries newest first, each
+// with its attempts. ?status= narrows the list to pending, delivered or
+// failed deliveries.
+func (cfg *APIConfig) GetWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	hook, ok := cfg.ownWebhook(w, r)
+	if !ok {
+		return
+	}
+	params := database.GetWebhookDeliveriesParams{
+		WebhookID:     hook.ID,
+		MaxDeliveries: defaultDeliveriesLimit,
+	}
+	switch status := r.URL.Query().Get("status"); status {
+	case "":
+	case "pending", "delivered", "failed":
+		params.Status = nullString(&status)
+	default:
+		apierror.Write(w, r, apierror.New(apierror.InvalidRequest, "status must be pending, delivered or failed"))
+		return
+	}
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxDeliveriesLimit {
+			apierror.Write(w, r, apierror.Errorf(apierror.InvalidRequest, "limit must be between 1 and %d", maxDeliveriesLimit))
+			return
+		}
+		params.MaxDeliveries = int32(n)
+	}
+	deliveries, err := cfg.Queries.GetWebhookDeliveries(r.Context(), params)
+	if err != nil {
+		apierror.Write(w, r, fmt.Errorf("error fetching deliveries: %w", err))
+		return
+	}
+	ids := make([]int64, len(deliveries))
+	for i, d := range deliveries {
+		ids[i] = d.ID
+	}
+	attempts := map[int64][]deliveryAttempt{}
+	if len(ids) > 0 {
+		rows, err := cfg.Queries.GetAttemptsForDeliveries(r.Context(), ids)
+		if err != nil {
+			apierror.Write(w, r, fmt.Errorf("error fetching attempts: %w", err))
+			return
+		}
+		for _, a := range rows {
+			da := deliveryAttempt{AttemptedAt: a.AttemptedAt, Error: a.Error, DurationMs: a.DurationMs}
+			if a.StatusCode.Valid {
+				da.StatusCode = &a.StatusCode.Int32
+			}
+			attempts[a.DeliveryID] = append(attempts[a.DeliveryID], da)
+		}
+	}
+	resp := make([]deliveryResponse, len(deliveries))
+	for i, d := range deliveries {
+		dr := deliveryResponse{
+			ID:        d.ID,
+			EventType: d.EventType,
+			Payload:   d.Payload,
+			Status:    d.Status,
+			Attempts:  attempts[d.ID],
+			CreatedAt: d.CreatedAt,
+		}
+		if dr.Attempts == nil {
+			dr.Attempts = []deliveryAttempt{}
+		}
+		if d.Status == "pending" {
+			dr.NextAttemptAt = &d.NextAttemptAt
+		}
+		if d.LastError.Valid {
+			dr.LastError = &d.LastError.String
+		}
+		if d.DeliveredAt.Valid {
+			dr.DeliveredAt = &d.DeliveredAt.Time
+		}
+		resp[i] = dr
+	}
+	dat, err := json.Marshal(resp)
+	if err != nil {
+		apierror.Write(w, r, err)
+		return
+	}
+	w.WriteHeader(200)
+	w.Write(dat)
+}
+
+// ReplayWebhookDelivery queues a failed delivery again with a fresh set of
+// attempts. Deliveries that are not failed are left alone with a 409.
+func (cfg *APIConfig) ReplayWebhookDelivery(w http.ResponseWriter, r *http.Request) {
+	hook, ok := cfg.ownWebhook(w, r)
+	if !ok {
+		return
+	}
+	id, err := strconv.ParseInt(r.PathValue("delivery_id"), 10, 64)
+	if err != nil {
+		apierror.Write(w, r, apierror.Errorf(apierror.InvalidRequest, "could not parse delivery id: %s", err))
+		return
+	}
+	n, err := cfg.Queries.ReplayWebhookDelivery(r.Context(), database.ReplayWebhookDeliveryParams{
+		ID:        id,
+		WebhookID: hook.ID,
+	})
+	if err != nil {
+		apierror.Write(w, r, fmt.Errorf("could not replay delivery: %w", err))
+		return
+	}
+	if n == 0 {
+		apierror.Write(w, r, apierror.New(apierror.Conflict, "only failed deliveries of this webhook can be replayed"))
+		return
+	}
+	w.WriteHeader(202)
+}
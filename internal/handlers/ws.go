@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/RemcoVeens/httpserver/internal/auth"
+	"github.com/RemcoVeens/httpserver/internal/database"
+	"github.com/google/uuid"
+)
+
+// WebSocketUserID authenticates a WebSocket upgrade request with the same
+// access token GetUserFromBearerToken accepts. Browsers can not set headers on
+// WebSocket requests, so the token may also be passed as ?access_token=, or
+// come from the session cookie. Browsers send cookies with WebSocket requests
+// from any site, so the cookie is only used for pages of this one.
+func (cfg *APIConfig) WebSocketUserID(r *http.Request) (uuid.UUID, error) {
+	if r.Header.Get("Authorization") != "" {
+		user, err := cfg.GetUserFromBearerToken(r)
+		if err != nil {
+			return uuid.Nil, authError(err)
+		}
+		return user.ID, nil
+	}
+	token := r.URL.Query().Get("access_token")
+	if token == "" {
+		if cookie, fromSession, _ := requestToken(r); fromSession && sameOrigin(r) {
+			token = cookie
+		}
+	}
+	if token == "" {
+		return uuid.Nil, authError(errUnauthenticated)
+	}
+	userID, err := auth.ValidateJWT(token, cfg.Secret)
+	if err != nil {
+		return uuid.Nil, authError(fmt.Errorf("%w: %w", errUnauthenticated, err))
+	}
+	user, err := cfg.Queries.GetActiveUserFromId(r.Context(), userID)
+	if err != nil {
+		return uuid.Nil, authError(err)
+	}
+	return user.ID, nil
+}
+
+// ChirpVisibleTo reports whether userID can see chirpID, by the same rules
+// as GET /api/chirps/{chirp_id}.
+func (cfg *APIConfig) ChirpVisibleTo(ctx context.Context, userID, chirpID uuid.UUID) (bool, error) {
+	_, err := cfg.Queries.GetVisibleChirpFromId(ctx, database.GetVisibleChirpFromIdParams{
+		ID:       chirpID,
+		ViewerID: userID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+	return err == nil, err
+}
@@ -0,0 +1,87 @@
+// Package handles validates the public @handles users are known by.
+package handles
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	MinLength = 3
+	MaxLength = 30
+
+	// generatedPrefix starts every handle Generate makes. Users can not
+	// pick handles with it, so they can not take one before it is handed
+	// out.
+	generatedPrefix = "user_"
+)
+
+var (
+	ErrLength     = fmt.Errorf("handle must be between %d and %d characters", MinLength, MaxLength)
+	ErrCharacters = errors.New("handle may only contain letters, digits and underscores")
+	ErrReserved   = errors.New("handle is reserved")
+)
+
+// reserved holds names that would collide with routes, impersonate staff or
+// confuse clients. Entries are lower-case; lookups are case-insensitive.
+var reserved = map[string]bool{
+	"about":     true,
+	"admin":     true,
+	"api":       true,
+	"app":       true,
+	"chirpy":    true,
+	"help":      true,
+	"login":     true,
+	"logout":    true,
+	"me":        true,
+	"moderator": true,
+	"null":      true,
+	"polka":     true,
+	"root":      true,
+	"settings":  true,
+	"signup":    true,
+	"staff":     true,
+	"support":   true,
+	"system":    true,
+	"undefined": true,
+}
+
+// Validate reports why handle can not be used, or nil if it can.
+func Validate(handle string) error {
+	if len(handle) < MinLength || len(handle) > MaxLength {
+		return ErrLength
+	}
+	for _, r := range handle {
+		if !IsHandleRune(r) {
+			return ErrCharacters
+		}
+	}
+	if IsReserved(handle) {
+		return ErrReserved
+	}
+	return nil
+}
+
+// IsReserved reports whether handle is reserved, either by name or because
+// it looks like a generated one.
+func IsReserved(handle string) bool {
+	handle = strings.ToLower(handle)
+	return reserved[handle] || strings.HasPrefix(handle, generatedPrefix)
+}
+
+// IsHandleRune reports whether r may appear in a handle.
+func IsHandleRune(r rune) bool {
+	return r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9')
+}
+
+// Generate returns a random placeholder handle for users that signed up
+// without picking one. It has the same shape the profile migration used to
+// backfill existing accounts.
+func Generate() string {
+	key := make([]byte, 6)
+	rand.Read(key)
+	return generatedPrefix + hex.EncodeToString(key)
+}
@@ -0,0 +1,42 @@
+package handles_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/RemcoVeens/httpserver/internal/handles"
+)
+
+func TestValidate(t *testing.T) {
+	cases := []struct {
+		handle string
+		want   error
+	}{
+		{"alice", nil},
+		{"Bob_42", nil},
+		{"ab", handles.ErrLength},
+		{"this_handle_is_way_too_long_to_use", handles.ErrLength},
+		{"no-dashes", handles.ErrCharacters},
+		{"café", handles.ErrCharacters},
+		{"Admin", handles.ErrReserved},
+		{"me", handles.ErrLength},
+		{"support", handles.ErrReserved},
+		{"user_0123456789ab", handles.ErrReserved},
+		{"User_squatter", handles.ErrReserved},
+		{"username", nil},
+	}
+	for _, c := range cases {
+		if err := handles.Validate(c.handle); !errors.Is(err, c.want) {
+			t.Errorf("Validate(%q) = %v, want %v", c.handle, err, c.want)
+		}
+	}
+}
+
+// TestGenerateIsReserved checks that generated handles are well formed but
+// can not be picked by users.
+func TestGenerateIsReserved(t *testing.T) {
+	h := handles.Generate()
+	if err := handles.Validate(h); !errors.Is(err, handles.ErrReserved) {
+		t.Fatalf("Validate(%q) = %v, want ErrReserved", h, err)
+	}
+}
@@ -0,0 +1,64 @@
+package imaging
+
+import "errors"
+
+// GIF block introducers.
+const (
+	gifExtension  = 0x21
+	gifImage      = 0x2C
+	gifTrailer    = 0x3B
+	gifColorTable = 0x80
+)
+
+var errTruncatedGIF = errors.New("truncated GIF")
+
+// gifFrames counts the frames of the GIF in data by walking its blocks,
+// without decoding any pixels.
+func gifFrames(data []byte) (int, error) {
+	if len(data) < 13 || string(data[:3]) != "GIF" {
+		return 0, errors.New("not a GIF file")
+	}
+	i := 13 + colorTableSize(data[10])
+	frames := 0
+	for {
+		if i >= len(data) {
+			return 0, errTruncatedGIF
+		}
+		switch data[i] {
+		case gifExtension:
+			i += 2
+		case gifImage:
+			if i+11 > len(data) {
+				return 0, errTruncatedGIF
+			}
+			// The descriptor, a local color table and the LZW code size
+			// come before the pixel data.
+			i += 10 + colorTableSize(data[i+9]) + 1
+			frames++
+		case gifTrailer:
+			return frames, nil
+		default:
+			return 0, errors.New("unknown GIF block")
+		}
+		// Both kinds of block end in data sub-blocks.
+		for {
+			if i >= len(data) {
+				return 0, errTruncatedGIF
+			}
+			n := int(data[i])
+			i += 1 + n
+			if n == 0 {
+				break
+			}
+		}
+	}
+}
+
+// colorTableSize returns the size in bytes of the color table a GIF
+// descriptor with the given packed field is followed by.
+func colorTableSize(packed byte) int {
+	if packed&gifColorTable == 0 {
+		return 0
+	}
+	return 3 << (packed&7 + 1)
+}
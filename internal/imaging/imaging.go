@@ -0,0 +1,210 @@
+// Package imaging normalises uploaded images without system libraries.
+//
+// Process decodes an upload, applies its EXIF orientation, drops every
+// piece of metadata (EXIF, GPS, XMP, comments) and renders a thumbnail for
+// each of Variants plus a blurhash placeholder. Decoding is pure Go: JPEG,
+// PNG and GIF come from the standard library, WebP from golang.org/x/image.
+//
+// There is no pure-Go WebP encoder, so WebP originals are cleaned by
+// removing their metadata chunks rather than being re-encoded. Thumbnails
+// are always JPEG, or PNG when the image has transparency.
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/draw"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+
+	xdraw "golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// Variant is a thumbnail size. Images are scaled down to fit MaxSize on
+// their longest side, and never scaled up.
+type Variant struct {
+	Name    string
+	MaxSize int
+}
+
+// Variants are rendered for every image, smallest first.
+var Variants = []Variant{
+	{Name: "small", MaxSize: 160},
+	{Name: "medium", MaxSize: 640},
+	{Name: "large", MaxSize: 1280},
+}
+
+const (
+	// MaxPixels bounds the images Process will decode, so a small file can
+	// not claim enormous dimensions and exhaust memory. For a GIF it bounds
+	// all frames together.
+	MaxPixels = 40_000_000
+
+	// MaxFrames bounds the frames of a GIF, however small they are.
+	MaxFrames = 1000
+
+	jpegQuality      = 90
+	thumbnailQuality = 82
+	blurhashSize     = 32
+)
+
+// ErrInvalidImage is returned for uploads that can never be processed.
+var ErrInvalidImage = errors.New("invalid image")
+
+// Output is an encoded image.
+type Output struct {
+	Name        string
+	ContentType string
+	Data        []byte
+	Width       int
+	Height      int
+}
+
+// Result is a processed upload.
+type Result struct {
+	// Original is the upload, upright and without metadata.
+	Original Output
+	// Variants holds one thumbnail per entry of Variants, in order.
+	Variants []Output
+	Blurhash string
+}
+
+// Process normalises the image in data.
+func Process(data []byte) (Result, error) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return Result{}, fmt.Errorf("%w: %s", ErrInvalidImage, err)
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > MaxPixels {
+		return Result{}, fmt.Errorf("%w: %dx%d pixels is too large", ErrInvalidImage, cfg.Width, cfg.Height)
+	}
+
+	var img image.Image
+	original := Output{Name: "original"}
+	switch format {
+	case "jpeg":
+		img, err = jpeg.Decode(bytes.NewReader(data))
+		if err != nil {
+			break
+		}
+		img = orient(img, jpegOrientation(data))
+		original.ContentType = "image/jpeg"
+		original.Data, err = encodeJPEG(img, jpegQuality)
+	case "png":
+		img, err = png.Decode(bytes.NewReader(data))
+		if err != nil {
+			break
+		}
+		original.ContentType = "image/png"
+		original.Data, err = encodePNG(img)
+	case "gif":
+		// Every frame is decoded, and none may be larger than the logical
+		// screen, so check what they add up to first.
+		var frames int
+		frames, err = gifFrames(data)
+		if err != nil {
+			break
+		}
+		if frames > MaxFrames || frames*cfg.Width*cfg.Height > MaxPixels {
+			return Result{}, fmt.Errorf("%w: %d frames of %dx%d pixels is too large", ErrInvalidImage, frames, cfg.Width, cfg.Height)
+		}
+		var g *gif.GIF
+		g, err = gif.DecodeAll(bytes.NewReader(data))
+		if err != nil {
+			break
+		}
+		img = firstFrame(g)
+		// Encoding drops comment and application extensions other than the
+		// loop count, which is where GIFs keep metadata.
+		var buf bytes.Buffer
+		err = gif.EncodeAll(&buf, g)
+		original.ContentType = "image/gif"
+		original.Data = buf.Bytes()
+	case "webp":
+		img, _, err = image.Decode(bytes.NewReader(data))
+		if err != nil {
+			break
+		}
+		original.ContentType = "image/webp"
+		original.Data, err = stripWebP(data)
+	default:
+		return Result{}, fmt.Errorf("%w: unsupported format %s", ErrInvalidImage, format)
+	}
+	if err != nil {
+		return Result{}, fmt.Errorf("%w: %s", ErrInvalidImage, err)
+	}
+	b := img.Bounds()
+	original.Width, original.Height = b.Dx(), b.Dy()
+
+	res := Result{Original: original}
+	for _, v := range Variants {
+		thumb := scale(img, v.MaxSize)
+		out := Output{Name: v.Name, Width: thumb.Bounds().Dx(), Height: thumb.Bounds().Dy()}
+		if thumb.Opaque() {
+			out.ContentType = "image/jpeg"
+			out.Data, err = encodeJPEG(thumb, thumbnailQuality)
+		} else {
+			out.ContentType = "image/png"
+			out.Data, err = encodePNG(thumb)
+		}
+		if err != nil {
+			return Result{}, fmt.Errorf("could not encode %s thumbnail: %w", v.Name, err)
+		}
+		res.Variants = append(res.Variants, out)
+	}
+	xComponents, yComponents := 4, 3
+	if original.Height > original.Width {
+		xComponents, yComponents = 3, 4
+	}
+	res.Blurhash = Blurhash(scale(img, blurhashSize), xComponents, yComponents)
+	return res, nil
+}
+
+// scale fits img into a maxSize square, keeping its aspect ratio.
+func scale(img image.Image, maxSize int) *image.NRGBA {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if w > maxSize || h > maxSize {
+		if w >= h {
+			w, h = maxSize, max(1, h*maxSize/w)
+		} else {
+			w, h = max(1, w*maxSize/h), maxSize
+		}
+	}
+	dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+	if w == b.Dx() && h == b.Dy() {
+		draw.Draw(dst, dst.Bounds(), img, b.Min, draw.Src)
+		return dst
+	}
+	xdraw.CatmullRom.Scale(dst, dst.Bounds(), img, b, xdraw.Src, nil)
+	return dst
+}
+
+// firstFrame renders the first frame of g on its logical screen, since a
+// frame may cover only part of it.
+func firstFrame(g *gif.GIF) image.Image {
+	w, h := g.Config.Width, g.Config.Height
+	frame := g.Image[0]
+	if w == 0 || h == 0 {
+		return frame
+	}
+	canvas := image.NewNRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(canvas, frame.Bounds(), frame, frame.Bounds().Min, draw.Over)
+	return canvas
+}
+
+func encodeJPEG(img image.Image, quality int) ([]byte, error) {
+	var buf bytes.Buffer
+	err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: quality})
+	return buf.Bytes(), err
+}
+
+func encodePNG(img image.Image) ([]byte, error) {
+	var buf bytes.Buffer
+	err := png.Encode(&buf, img)
+	return buf.Bytes(), err
+}
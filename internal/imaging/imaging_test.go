@@ -0,0 +1,216 @@
+package imaging_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/RemcoVeens/httpserver/internal/imaging"
+)
+
+func fill(img *image.NRGBA, r image.Rectangle, c color.NRGBA) {
+	for y := r.Min.Y; y < r.Max.Y; y++ {
+		for x := r.Min.X; x < r.Max.X; x++ {
+			img.SetNRGBA(x, y, c)
+		}
+	}
+}
+
+var (
+	red  = color.NRGBA{R: 255, A: 255}
+	blue = color.NRGBA{B: 255, A: 255}
+)
+
+// exifSegment is an APP1 segment holding a little-endian TIFF with an
+// Orientation tag and a GPS marker that must not survive processing.
+func exifSegment(orientation uint16) []byte {
+	tiff := []byte("II*\x00\x08\x00\x00\x00")
+	tiff = binary.LittleEndian.AppendUint16(tiff, 1)
+	tiff = binary.LittleEndian.AppendUint16(tiff, 0x0112)
+	tiff = binary.LittleEndian.AppendUint16(tiff, 3)
+	tiff = binary.LittleEndian.AppendUint32(tiff, 1)
+	tiff = binary.LittleEndian.AppendUint16(tiff, orientation)
+	tiff = append(tiff, 0, 0, 0, 0, 0, 0)
+	tiff = append(tiff, "GPS 52.37N 4.89E"...)
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+	seg := []byte{0xFF, 0xE1}
+	seg = binary.BigEndian.AppendUint16(seg, uint16(len(payload)+2))
+	return append(seg, payload...)
+}
+
+func TestProcessJPEGOrientation(t *testing.T) {
+	// 8x4: red on the left, blue on the right.
+	img := image.NewNRGBA(image.Rect(0, 0, 8, 4))
+	fill(img, image.Rect(0, 0, 4, 4), red)
+	fill(img, image.Rect(4, 0, 8, 4), blue)
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 100}); err != nil {
+		t.Fatal(err)
+	}
+	data := append(append([]byte{0xFF, 0xD8}, exifSegment(6)...), buf.Bytes()[2:]...)
+
+	res, err := imaging.Process(data)
+	if err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+	o := res.Original
+	if o.ContentType != "image/jpeg" || o.Width != 4 || o.Height != 8 {
+		t.Fatalf("original is %s %dx%d, want image/jpeg 4x8", o.ContentType, o.Width, o.Height)
+	}
+	if bytes.Contains(o.Data, []byte("Exif")) || bytes.Contains(o.Data, []byte("GPS")) {
+		t.Errorf("metadata survived")
+	}
+	out, err := jpeg.Decode(bytes.NewReader(o.Data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Turned clockwise, the left side ends up on top.
+	if r, _, b, _ := out.At(2, 1).RGBA(); r < b {
+		t.Errorf("top is not red")
+	}
+	if r, _, b, _ := out.At(2, 6).RGBA(); b < r {
+		t.Errorf("bottom is not blue")
+	}
+	if len(res.Variants) != len(imaging.Variants) {
+		t.Fatalf("%d variants, want %d", len(res.Variants), len(imaging.Variants))
+	}
+	for _, v := range res.Variants {
+		if v.Width != 4 || v.Height != 8 || v.ContentType != "image/jpeg" {
+			t.Errorf("%s is %s %dx%d; small images must not be scaled up", v.Name, v.ContentType, v.Width, v.Height)
+		}
+	}
+}
+
+// withChunk inserts a PNG chunk right after IHDR.
+func withChunk(data []byte, typ string, body []byte) []byte {
+	chunk := binary.BigEndian.AppendUint32(nil, uint32(len(body)))
+	chunk = append(chunk, typ...)
+	chunk = append(chunk, body...)
+	chunk = binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(chunk[4:]))
+	const ihdrEnd = 8 + 25
+	return append(append(append([]byte{}, data[:ihdrEnd]...), chunk...), data[ihdrEnd:]...)
+}
+
+func TestProcessPNG(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 2000, 1000))
+	fill(img, image.Rect(0, 0, 1000, 1000), red)
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	data := withChunk(buf.Bytes(), "tEXt", []byte("Comment\x00taken at home"))
+
+	res, err := imaging.Process(data)
+	if err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+	if bytes.Contains(res.Original.Data, []byte("taken at home")) {
+		t.Errorf("metadata survived")
+	}
+	if res.Original.Width != 2000 || res.Original.Height != 1000 {
+		t.Errorf("original is %dx%d", res.Original.Width, res.Original.Height)
+	}
+	for i, v := range res.Variants {
+		want := imaging.Variants[i]
+		if v.Name != want.Name || v.Width != want.MaxSize || v.Height != want.MaxSize/2 {
+			t.Errorf("variant %d is %s %dx%d, want %s %dx%d", i, v.Name, v.Width, v.Height, want.Name, want.MaxSize, want.MaxSize/2)
+		}
+		// Half the image is transparent.
+		if v.ContentType != "image/png" {
+			t.Errorf("%s is %s, want image/png", v.Name, v.ContentType)
+		}
+	}
+}
+
+func TestProcessGIFKeepsFrames(t *testing.T) {
+	palette := color.Palette{color.Black, color.White}
+	g := &gif.GIF{LoopCount: 0}
+	for range 3 {
+		g.Image = append(g.Image, image.NewPaletted(image.Rect(0, 0, 10, 10), palette))
+		g.Delay = append(g.Delay, 10)
+	}
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, g); err != nil {
+		t.Fatal(err)
+	}
+	res, err := imaging.Process(buf.Bytes())
+	if err != nil {
+		t.Fatalf("Process: %v", err)
+	}
+	out, err := gif.DecodeAll(bytes.NewReader(res.Original.Data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(out.Image) != 3 {
+		t.Errorf("%d frames, want 3", len(out.Image))
+	}
+}
+
+// gifOf encodes frames tiny frames on a logical screen of width×height.
+func gifOf(t *testing.T, frames, width, height int) []byte {
+	t.Helper()
+	palette := color.Palette{color.Black, color.White}
+	g := &gif.GIF{Config: image.Config{ColorModel: palette, Width: width, Height: height}}
+	for range frames {
+		g.Image = append(g.Image, image.NewPaletted(image.Rect(0, 0, 1, 1), palette))
+		g.Delay = append(g.Delay, 10)
+	}
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, g); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestProcessRejects(t *testing.T) {
+	var buf bytes.Buffer
+	png.Encode(&buf, image.NewNRGBA(image.Rect(0, 0, 1, 1)))
+	huge := bytes.Clone(buf.Bytes())
+	binary.BigEndian.PutUint32(huge[16:], 100000)
+	binary.BigEndian.PutUint32(huge[20:], 100000)
+	binary.BigEndian.PutUint32(huge[29:], crc32.ChecksumIEEE(huge[12:29]))
+
+	cases := map[string][]byte{
+		"not an image": []byte("hello"),
+		"truncated":    buf.Bytes()[:40],
+		"too large":    huge,
+		// Each frame fits, but all of them decoded would not.
+		"too many pixels": gifOf(t, 11, 2000, 2000),
+		"too many frames": gifOf(t, imaging.MaxFrames+1, 1, 1),
+		"truncated gif":   gifOf(t, 3, 10, 10)[:30],
+	}
+	for name, data := range cases {
+		if _, err := imaging.Process(data); !errors.Is(err, imaging.ErrInvalidImage) {
+			t.Errorf("%s: %v, want ErrInvalidImage", name, err)
+		}
+	}
+}
+
+func TestBlurhash(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 16, 8))
+	fill(img, img.Bounds(), color.NRGBA{R: 200, G: 100, B: 50, A: 255})
+	hash := imaging.Blurhash(img, 4, 3)
+	if len(hash) != 6+2*(4*3-1) {
+		t.Fatalf("hash %q has length %d", hash, len(hash))
+	}
+	// Size flag: (4-1) + (3-1)*9 = 21 = "L".
+	if hash[0] != 'L' {
+		t.Errorf("hash %q does not start with L", hash)
+	}
+	const base83 = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz#$%*+,-.:;=?@[]^_{|}~"
+	dc := 0
+	for _, c := range hash[2:6] {
+		dc = dc*83 + strings.IndexRune(base83, c)
+	}
+	if r, g, b := dc>>16, dc>>8&0xFF, dc&0xFF; r != 200 || g != 100 || b != 50 {
+		t.Errorf("average colour is %d,%d,%d, want 200,100,50", r, g, b)
+	}
+}
@@ -0,0 +1,184 @@
+// Package inbox processes webhooks received from other services.
+//
+// Incoming webhooks are stored in inbound_events and acknowledged before
+// anything else happens, deduplicated by the provider's event id. A Worker
+// on every replica claims pending events and runs the provider's Handler in
+// a transaction that also marks the event processed, so an event's effects
+// are committed exactly once. Failures are retried with exponential backoff;
+// after MaxAttempts, or at once for errors wrapped with Permanent, the event
+// is dead-lettered until an admin replays it.
+//
+// Events may name a subject, such as the user a Polka event is about.
+// Events with the same subject are applied one at a time in the order they
+// were received: an event is not claimed while an older one with its subject
+// is pending, retries included. Once the older one is processed or dead the
+// next one goes ahead.
+package inbox
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/RemcoVeens/httpserver/internal/database"
+)
+
+const (
+	// MaxAttempts is how often an event is tried before it is dead.
+	MaxAttempts = 8
+
+	minBackoff = 10 * time.Second
+	maxBackoff = time.Hour
+
+	// leaseDuration hides a claimed event from other workers while it is
+	// processed. An event whose worker died is retried once it runs out.
+	leaseDuration = 5 * time.Minute
+
+	defaultPollInterval = 2 * time.Second
+	defaultBatchSize    = 20
+)
+
+// Handler applies one event. q is bound to the transaction that marks the
+// event processed.
+type Handler func(ctx context.Context, q *database.Queries, ev database.InboundEvent) error
+
+type permanentError struct{ err error }
+
+func (e permanentError) Error() string { return e.err.Error() }
+func (e permanentError) Unwrap() error { return e.err }
+
+// Permanent marks err as one retrying will not fix, such as a malformed
+// payload, so the event is dead-lettered right away.
+func Permanent(err error) error {
+	return permanentError{err}
+}
+
+// IsPermanent reports whether err was wrapped with Permanent.
+func IsPermanent(err error) bool {
+	var p permanentError
+	return errors.As(err, &p)
+}
+
+// Backoff returns how long to wait after the given failed attempt, counting
+// from 1: 10s, 20s, 40s, ... capped at an hour.
+func Backoff(attempt int) time.Duration {
+	d := minBackoff
+	for i := 1; i < attempt && d < maxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, maxBackoff)
+}
+
+// Retry decides what happens after attempt number attempts failed with err:
+// either the event is dead, or it is retried at the returned time.
+func Retry(err error, attempts int32, now time.Time) (dead bool, next time.Time) {
+	if IsPermanent(err) || attempts >= MaxAttempts {
+		return true, time.Time{}
+	}
+	return false, now.Add(Backoff(int(attempts)))
+}
+
+// Worker processes pending inbound events.
+type Worker struct {
+	db           *sql.DB
+	queries      *database.Queries
+	handlers     map[string]Handler
+	PollInterval time.Duration
+	BatchSize    int
+}
+
+// NewWorker returns a Worker that runs handlers[ev.Provider] for each event.
+func NewWorker(db *sql.DB, handlers map[string]Handler) *Worker {
+	return &Worker{
+		db:           db,
+		queries:      database.New(db),
+		handlers:     handlers,
+		PollInterval: defaultPollInterval,
+		BatchSize:    defaultBatchSize,
+	}
+}
+
+// Run processes events until ctx is cancelled. Events it has claimed are
+// processed first, so they need not wait out their lease.
+func (w *Worker) Run(ctx context.Context) {
+	t := time.NewTicker(w.PollInterval)
+	defer t.Stop()
+	for {
+		for ctx.Err() == nil {
+			n, err := w.RunOnce(context.WithoutCancel(ctx))
+			if err != nil {
+				log.Printf("could not process inbound events: %s", err)
+			}
+			if n < w.BatchSize {
+				break
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// RunOnce claims one batch of due events and processes them concurrently.
+// A batch holds at most one event per subject. It returns how many were
+// claimed.
+func (w *Worker) RunOnce(ctx context.Context) (int, error) {
+	evs, err := w.queries.ClaimInboundEvents(ctx, database.ClaimInboundEventsParams{
+		LeaseSeconds: int32(leaseDuration / time.Second),
+		BatchSize:    int32(w.BatchSize),
+	})
+	if err != nil {
+		return 0, fmt.Errorf("could not claim events: %w", err)
+	}
+	var wg sync.WaitGroup
+	for _, ev := range evs {
+		wg.Go(func() {
+			if err := w.process(ctx, ev); err != nil {
+				log.Printf("could not record outcome of inbound event %d: %s", ev.ID, err)
+			}
+		})
+	}
+	wg.Wait()
+	return len(evs), nil
+}
+
+// process runs the handler for one claimed event and records the outcome.
+func (w *Worker) process(ctx context.Context, ev database.InboundEvent) error {
+	handle, ok := w.handlers[ev.Provider]
+	if !ok {
+		return w.fail(ctx, ev, Permanent(fmt.Errorf("no handler for provider %q", ev.Provider)))
+	}
+	err := database.RunInTx(ctx, w.db, func(q *database.Queries) error {
+		if err := handle(ctx, q, ev); err != nil {
+			return err
+		}
+		return q.MarkInboundEventProcessed(ctx, ev.ID)
+	})
+	if err == nil {
+		return nil
+	}
+	return w.fail(ctx, ev, err)
+}
+
+func (w *Worker) fail(ctx context.Context, ev database.InboundEvent, err error) error {
+	lastErr := sql.NullString{String: err.Error(), Valid: true}
+	dead, next := Retry(err, ev.Attempts, time.Now())
+	if dead {
+		log.Printf("inbound %s event %d is dead: %s", ev.Provider, ev.ID, err)
+		return w.queries.MarkInboundEventDead(ctx, database.MarkInboundEventDeadParams{
+			ID:        ev.ID,
+			LastError: lastErr,
+		})
+	}
+	return w.queries.RescheduleInboundEvent(ctx, database.RescheduleInboundEventParams{
+		ID:            ev.ID,
+		NextAttemptAt: next,
+		LastError:     lastErr,
+	})
+}
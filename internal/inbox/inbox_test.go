@@ -0,0 +1,137 @@
+package inbox_test
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/RemcoVeens/httpserver/internal/database"
+	"github.com/RemcoVeens/httpserver/internal/inbox"
+	"github.com/google/uuid"
+	_ "github.com/lib/pq"
+)
+
+func TestBackoff(t *testing.T) {
+	cases := map[int]time.Duration{
+		1:  10 * time.Second,
+		2:  20 * time.Second,
+		4:  80 * time.Second,
+		20: time.Hour,
+	}
+	for attempt, want := range cases {
+		if got := inbox.Backoff(attempt); got != want {
+			t.Errorf("Backoff(%d) = %s, want %s", attempt, got, want)
+		}
+	}
+}
+
+func TestPermanent(t *testing.T) {
+	base := errors.New("bad payload")
+	err := fmt.Errorf("processing: %w", inbox.Permanent(base))
+	if !inbox.IsPermanent(err) {
+		t.Errorf("wrapped permanent error not recognised")
+	}
+	if !errors.Is(err, base) {
+		t.Errorf("Permanent should unwrap to the original error")
+	}
+	if inbox.IsPermanent(base) {
+		t.Errorf("plain error reported as permanent")
+	}
+}
+
+func TestRetry(t *testing.T) {
+	now := time.Now()
+	if dead, next := inbox.Retry(errors.New("db down"), 1, now); dead || !next.Equal(now.Add(10*time.Second)) {
+		t.Errorf("first failure: dead=%v next=%s", dead, next)
+	}
+	if dead, _ := inbox.Retry(errors.New("db down"), inbox.MaxAttempts, now); !dead {
+		t.Errorf("last attempt should dead-letter")
+	}
+	if dead, _ := inbox.Retry(inbox.Permanent(errors.New("bad")), 1, now); !dead {
+		t.Errorf("permanent errors should dead-letter at once")
+	}
+}
+
+// TestEventsForOneSubjectApplyInOrder checks against a real database that
+// two events about one user, received in the same batch, are applied one
+// at a time in order, the second waiting while the first is retried. Point
+// CHIRPY_TEST_DB_URL at a migrated, disposable database to run it.
+func TestEventsForOneSubjectApplyInOrder(t *testing.T) {
+	dbURL := os.Getenv("CHIRPY_TEST_DB_URL")
+	if dbURL == "" {
+		t.Skip("CHIRPY_TEST_DB_URL is not set")
+	}
+	db, err := sql.Open("postgres", dbURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+	q := database.New(db)
+
+	provider := "test-" + uuid.NewString()
+	subject := sql.NullString{String: uuid.NewString(), Valid: true}
+	for _, eventType := range []string{"user.upgraded", "user.cancelled"} {
+		if _, err := q.CreateInboundEvent(ctx, database.CreateInboundEventParams{
+			Provider:  provider,
+			EventType: eventType,
+			Payload:   json.RawMessage(`{}`),
+			Subject:   subject,
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	evs, err := q.GetInboundEvents(ctx, database.GetInboundEventsParams{
+		BeforeID:  1<<63 - 1,
+		Provider:  sql.NullString{String: provider, Valid: true},
+		MaxEvents: 10,
+	})
+	if err != nil || len(evs) != 2 {
+		t.Fatalf("got %d events, %v", len(evs), err)
+	}
+	first, second := evs[1], evs[0]
+
+	var applied []string
+	failed := false
+	w := inbox.NewWorker(db, map[string]inbox.Handler{
+		provider: func(ctx context.Context, q *database.Queries, ev database.InboundEvent) error {
+			if !failed {
+				failed = true
+				return errors.New("try again")
+			}
+			applied = append(applied, ev.EventType)
+			return nil
+		},
+	})
+	w.BatchSize = 100
+
+	// The first event fails and is retried later; the second waits for it.
+	for range 2 {
+		if _, err := w.RunOnce(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if ev, err := q.GetInboundEvent(ctx, second.ID); err != nil || ev.Attempts != 0 {
+		t.Fatalf("the second event was claimed before the first was applied: %+v, %v", ev, err)
+	}
+	if err := q.RescheduleInboundEvent(ctx, database.RescheduleInboundEventParams{
+		ID:            first.ID,
+		NextAttemptAt: time.Now().Add(-time.Hour),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	for range 2 {
+		if _, err := w.RunOnce(ctx); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if want := []string{"user.upgraded", "user.cancelled"}; !slices.Equal(applied, want) {
+		t.Errorf("applied %v, want %v", applied, want)
+	}
+}
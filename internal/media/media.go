@@ -0,0 +1,118 @@
+// Package media validates, processes and cleans up uploads.
+//
+// An upload is stored under Key(id) in a blobstore.Store and recorded in
+// media_files as pending. A Processor then strips its metadata, stores
+// thumbnails under VariantKey and marks it ready, or failed if it can not
+// be decoded. It stays unattached until a chirp references it; uploads
+// still unattached after GCAge are deleted by Collect. Media whose chirp is
+// deleted become unattached again, can not be reused, and are collected
+// the same way.
+package media
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/RemcoVeens/httpserver/internal/blobstore"
+	"github.com/RemcoVeens/httpserver/internal/database"
+	"github.com/RemcoVeens/httpserver/internal/imaging"
+	"github.com/google/uuid"
+)
+
+const (
+	// MaxSize is the largest upload accepted, in bytes.
+	MaxSize = 5 << 20
+
+	// GCAge is how long an upload may stay unattached.
+	GCAge = 24 * time.Hour
+	// GCInterval is how often RunGC looks for unattached uploads.
+	GCInterval = time.Hour
+
+	gcBatchSize = 100
+)
+
+// ContentTypes lists the types that can be uploaded.
+var ContentTypes = []string{"image/gif", "image/jpeg", "image/png", "image/webp"}
+
+var ErrUnsupportedType = errors.New("unsupported media type")
+
+// Sniff returns the content type of data, judged by its content rather than
+// anything the client claims. Types outside ContentTypes are rejected with
+// ErrUnsupportedType.
+func Sniff(data []byte) (string, error) {
+	ct := http.DetectContentType(data)
+	for _, allowed := range ContentTypes {
+		if ct == allowed {
+			return ct, nil
+		}
+	}
+	return "", fmt.Errorf("%w: %s", ErrUnsupportedType, ct)
+}
+
+// Key is where the upload with id is stored.
+func Key(id uuid.UUID) string {
+	return "media/" + id.String()
+}
+
+// Collect deletes uploads that have been unattached since before now-GCAge,
+// and their blobs and thumbnails. Rows are deleted first, only while still unattached, so
+// an upload attached meanwhile is kept.
+func Collect(ctx context.Context, q *database.Queries, store blobstore.Store, now time.Time) (int, error) {
+	n := 0
+	for {
+		files, err := q.GetUnattachedMediaFiles(ctx, database.GetUnattachedMediaFilesParams{
+			Before:   now.Add(-GCAge),
+			MaxFiles: gcBatchSize,
+		})
+		if err != nil {
+			return n, fmt.Errorf("could not get unattached media: %w", err)
+		}
+		deleted := 0
+		for _, f := range files {
+			rows, err := q.DeleteUnattachedMediaFile(ctx, f.ID)
+			if err != nil {
+				return n, fmt.Errorf("could not delete media %s: %w", f.ID, err)
+			}
+			if rows == 0 {
+				continue
+			}
+			deleted++
+			keys := []string{f.StorageKey}
+			for _, v := range imaging.Variants {
+				keys = append(keys, VariantKey(f.ID, v.Name))
+			}
+			for _, key := range keys {
+				if err := store.Delete(ctx, key); err != nil {
+					log.Printf("could not delete blob %s: %s", key, err)
+				}
+			}
+		}
+		n += deleted
+		if len(files) < gcBatchSize || deleted == 0 {
+			return n, nil
+		}
+	}
+}
+
+// RunGC calls Collect now and then every GCInterval until ctx is cancelled.
+func RunGC(ctx context.Context, q *database.Queries, store blobstore.Store) {
+	t := time.NewTicker(GCInterval)
+	defer t.Stop()
+	for {
+		n, err := Collect(ctx, q, store, time.Now())
+		if err != nil && ctx.Err() == nil {
+			log.Printf("could not collect media: %s", err)
+		} else if n > 0 {
+			log.Printf("collected %d unattached media", n)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package media_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/RemcoVeens/httpserver/internal/media"
+)
+
+func TestSniff(t *testing.T) {
+	cases := map[string]string{
+		"\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR": "image/png",
+		"\xff\xd8\xff\xe0\x00\x10JFIF\x00":    "image/jpeg",
+		"GIF89a\x01\x00\x01\x00":              "image/gif",
+		"RIFF\x00\x00\x00\x00WEBPVP8 ":        "image/webp",
+	}
+	for data, want := range cases {
+		got, err := media.Sniff([]byte(data))
+		if err != nil || got != want {
+			t.Errorf("Sniff(%q) = %q, %v; want %q", data, got, err, want)
+		}
+	}
+	for _, data := range []string{"<svg xmlns=\"http://www.w3.org/2000/svg\"/>", "<html><script>", "%PDF-1.4", "plain text"} {
+		if _, err := media.Sniff([]byte(data)); !errors.Is(err, media.ErrUnsupportedType) {
+			t.Errorf("Sniff(%q) accepted", data)
+		}
+	}
+}
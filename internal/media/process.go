@@ -0,0 +1,185 @@
+package media
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/RemcoVeens/httpserver/internal/blobstore"
+	"github.com/RemcoVeens/httpserver/internal/database"
+	"github.com/RemcoVeens/httpserver/internal/imaging"
+	"github.com/google/uuid"
+)
+
+// Upload statuses.
+const (
+	StatusPending = "pending"
+	StatusReady   = "ready"
+	StatusFailed  = "failed"
+)
+
+const (
+	// DefaultWorkers is how many uploads are processed at once by default.
+	DefaultWorkers = 4
+
+	// ProcessAttempts is how often an upload is tried before it is marked
+	// failed. Images that can not be decoded fail at once.
+	ProcessAttempts = 5
+
+	// processLease is how long a claimed upload is reserved for one
+	// worker; an upload whose worker dies is retried after it.
+	processLease = 2 * time.Minute
+)
+
+// VariantKey is where the thumbnail named variant of upload id is stored.
+func VariantKey(id uuid.UUID, variant string) string {
+	return Key(id) + "-" + variant
+}
+
+// Processor runs uploads through imaging.Process on a bounded pool of
+// workers. The stored upload is replaced by its cleaned version, the
+// thumbnails are stored next to it, and the upload becomes ready.
+type Processor struct {
+	db    *sql.DB
+	q     *database.Queries
+	store blobstore.Store
+	wake  chan struct{}
+
+	// Workers is how many uploads are processed at once.
+	Workers int
+	// PollInterval is how often pending uploads are looked for when
+	// Notify is not called.
+	PollInterval time.Duration
+}
+
+func NewProcessor(db *sql.DB, store blobstore.Store, workers int) *Processor {
+	return &Processor{
+		db:           db,
+		q:            database.New(db),
+		store:        store,
+		wake:         make(chan struct{}, 1),
+		Workers:      workers,
+		PollInterval: 5 * time.Second,
+	}
+}
+
+// Notify tells Run an upload is waiting, so it need not wait for the next
+// poll.
+func (p *Processor) Notify() {
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run processes uploads until ctx is cancelled. Uploads it has claimed are
+// finished first, so they need not wait out their lease to be retried.
+func (p *Processor) Run(ctx context.Context) {
+	t := time.NewTicker(p.PollInterval)
+	defer t.Stop()
+	for {
+		n, err := p.RunOnce(context.WithoutCancel(ctx))
+		if err != nil {
+			log.Printf("media processor: %s", err)
+		}
+		if n == p.Workers && ctx.Err() == nil {
+			// There may be more waiting.
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		case <-p.wake:
+		}
+	}
+}
+
+// RunOnce claims up to Workers pending uploads and processes them
+// concurrently. It returns how many it claimed.
+func (p *Processor) RunOnce(ctx context.Context) (int, error) {
+	files, err := p.q.ClaimMediaFiles(ctx, database.ClaimMediaFilesParams{
+		LeaseSeconds: int32(processLease / time.Second),
+		BatchSize:    int32(p.Workers),
+	})
+	if err != nil {
+		return 0, fmt.Errorf("could not claim media: %w", err)
+	}
+	var wg sync.WaitGroup
+	for _, f := range files {
+		wg.Go(func() {
+			err := p.process(ctx, f)
+			if err == nil {
+				return
+			}
+			permanent := errors.Is(err, imaging.ErrInvalidImage)
+			if !permanent && int(f.Attempts) < ProcessAttempts {
+				log.Printf("could not process media %s, will retry: %s", f.ID, err)
+				return
+			}
+			if err := p.q.MarkMediaFileFailed(ctx, database.MarkMediaFileFailedParams{
+				ID:              f.ID,
+				ProcessingError: sql.NullString{String: err.Error(), Valid: true},
+			}); err != nil {
+				log.Printf("could not mark media %s failed: %s", f.ID, err)
+			}
+		})
+	}
+	wg.Wait()
+	return len(files), nil
+}
+
+func (p *Processor) process(ctx context.Context, f database.MediaFile) error {
+	blob, err := p.store.Get(ctx, f.StorageKey)
+	if err != nil {
+		return fmt.Errorf("could not read upload: %w", err)
+	}
+	data, err := io.ReadAll(blob)
+	blob.Close()
+	if err != nil {
+		return fmt.Errorf("could not read upload: %w", err)
+	}
+	res, err := imaging.Process(data)
+	if err != nil {
+		return err
+	}
+	for _, v := range res.Variants {
+		key := VariantKey(f.ID, v.Name)
+		if err := p.store.Put(ctx, key, bytes.NewReader(v.Data), int64(len(v.Data)), v.ContentType); err != nil {
+			return fmt.Errorf("could not store %s: %w", v.Name, err)
+		}
+	}
+	// Replacing the upload last keeps a retry working from the original.
+	o := res.Original
+	if err := p.store.Put(ctx, f.StorageKey, bytes.NewReader(o.Data), int64(len(o.Data)), o.ContentType); err != nil {
+		return fmt.Errorf("could not store cleaned upload: %w", err)
+	}
+	return database.RunInTx(ctx, p.db, func(q *database.Queries) error {
+		for _, v := range res.Variants {
+			if err := q.UpsertMediaVariant(ctx, database.UpsertMediaVariantParams{
+				MediaID:     f.ID,
+				Name:        v.Name,
+				StorageKey:  VariantKey(f.ID, v.Name),
+				ContentType: v.ContentType,
+				Width:       int32(v.Width),
+				Height:      int32(v.Height),
+				SizeBytes:   int64(len(v.Data)),
+			}); err != nil {
+				return fmt.Errorf("could not record %s: %w", v.Name, err)
+			}
+		}
+		return q.MarkMediaFileReady(ctx, database.MarkMediaFileReadyParams{
+			ID:        f.ID,
+			Width:     sql.NullInt32{Int32: int32(o.Width), Valid: true},
+			Height:    sql.NullInt32{Int32: int32(o.Height), Valid: true},
+			Blurhash:  sql.NullString{String: res.Blurhash, Valid: true},
+			SizeBytes: int64(len(o.Data)),
+		})
+	})
+}
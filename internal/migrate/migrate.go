@@ -0,0 +1,297 @@
+// Package migrate applies the migrations in sql/schema without the goose
+// binary. It reads goose's annotations and keeps goose's version table,
+// goose_db_version, so a database migrated with goose carries on where it
+// left off and goose can still be used on one migrated here.
+//
+// Up and Down hold a Postgres advisory lock while they run, so replicas
+// that migrate at startup wait for each other instead of racing.
+package migrate
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// lockKey identifies the advisory lock taken while migrating.
+const lockKey int64 = 0x6368697270790001
+
+// ErrNoVersion is returned by Down when no migration is applied.
+var ErrNoVersion = errors.New("no migration to roll back")
+
+// Migration is a schema change.
+type Migration struct {
+	Version int64
+	// Name is the file name.
+	Name string
+	Up   string
+	Down string
+	// NoTx is set by -- +goose NO TRANSACTION, for statements that can not
+	// run in a transaction.
+	NoTx bool
+}
+
+// Status is a migration and when it was applied.
+type Status struct {
+	Migration
+	// AppliedAt is zero for pending migrations.
+	AppliedAt time.Time
+}
+
+// Load reads the migrations in fsys, named <version>_<name>.sql, in order of
+// version.
+func Load(fsys fs.FS) ([]Migration, error) {
+	names, err := fs.Glob(fsys, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+	var out []Migration
+	for _, name := range names {
+		data, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, err
+		}
+		m, err := Parse(name, string(data))
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, m)
+	}
+	slices.SortFunc(out, func(a, b Migration) int { return cmp.Compare(a.Version, b.Version) })
+	for i := 1; i < len(out); i++ {
+		if out[i].Version == out[i-1].Version {
+			return nil, fmt.Errorf("%s and %s have the same version", out[i-1].Name, out[i].Name)
+		}
+	}
+	return out, nil
+}
+
+// Parse parses the migration in the file name.
+func Parse(name, data string) (Migration, error) {
+	m := Migration{Name: path.Base(name)}
+	prefix, _, ok := strings.Cut(m.Name, "_")
+	if !ok || !strings.HasSuffix(m.Name, ".sql") {
+		return m, fmt.Errorf("%s: want a name like 001_users.sql", m.Name)
+	}
+	version, err := strconv.ParseInt(prefix, 10, 64)
+	if err != nil || version < 1 {
+		return m, fmt.Errorf("%s: invalid version %q", m.Name, prefix)
+	}
+	m.Version = version
+
+	var up, down strings.Builder
+	var section *strings.Builder
+	sc := bufio.NewScanner(strings.NewReader(data))
+	for sc.Scan() {
+		line := sc.Text()
+		annotation, ok := strings.CutPrefix(strings.TrimSpace(line), "-- +goose ")
+		if !ok {
+			if section != nil {
+				section.WriteString(line)
+				section.WriteByte('\n')
+			}
+			continue
+		}
+		switch strings.ToUpper(strings.TrimSpace(annotation)) {
+		case "UP":
+			section = &up
+		case "DOWN":
+			section = &down
+		case "NO TRANSACTION":
+			m.NoTx = true
+		case "STATEMENTBEGIN", "STATEMENTEND":
+			// Each section is sent as a whole, so statements need no
+			// delimiting.
+		default:
+			return m, fmt.Errorf("%s: unknown annotation %q", m.Name, annotation)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return m, fmt.Errorf("%s: %w", m.Name, err)
+	}
+	m.Up, m.Down = strings.TrimSpace(up.String()), strings.TrimSpace(down.String())
+	if m.Up == "" {
+		return m, fmt.Errorf("%s: no -- +goose Up section", m.Name)
+	}
+	return m, nil
+}
+
+// Migrator applies Migrations to DB.
+type Migrator struct {
+	DB         *sql.DB
+	Migrations []Migration
+}
+
+// New returns a Migrator of the migrations in fsys.
+func New(db *sql.DB, fsys fs.FS) (*Migrator, error) {
+	migrations, err := Load(fsys)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{DB: db, Migrations: migrations}, nil
+}
+
+// Up applies the pending migrations in order and returns them.
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	var done []Migration
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, mig := range m.Migrations {
+			if _, ok := applied[mig.Version]; ok {
+				continue
+			}
+			if err := apply(ctx, conn, mig, mig.Up, "INSERT INTO goose_db_version (version_id, is_applied) VALUES ($1, true)"); err != nil {
+				return err
+			}
+			done = append(done, mig)
+		}
+		return nil
+	})
+	return done, err
+}
+
+// Down rolls back the latest applied migration and returns it.
+func (m *Migrator) Down(ctx context.Context) (Migration, error) {
+	var undone Migration
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		applied, err := appliedVersions(ctx, conn)
+		if err != nil {
+			return err
+		}
+		for _, mig := range slices.Backward(m.Migrations) {
+			if _, ok := applied[mig.Version]; !ok {
+				continue
+			}
+			undone = mig
+			return apply(ctx, conn, mig, mig.Down, "DELETE FROM goose_db_version WHERE version_id = $1")
+		}
+		return ErrNoVersion
+	})
+	return undone, err
+}
+
+// Status returns every migration with when it was applied.
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	conn, err := m.DB.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if err := ensureVersionTable(ctx, conn); err != nil {
+		return nil, err
+	}
+	applied, err := appliedVersions(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]Status, len(m.Migrations))
+	for i, mig := range m.Migrations {
+		out[i] = Status{Migration: mig, AppliedAt: applied[mig.Version]}
+	}
+	return out, nil
+}
+
+// locked runs fn on a connection holding the migration lock.
+func (m *Migrator) locked(ctx context.Context, fn func(*sql.Conn) error) error {
+	conn, err := m.DB.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("could not connect: %w", err)
+	}
+	defer conn.Close()
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", lockKey); err != nil {
+		return fmt.Errorf("could not take the migration lock: %w", err)
+	}
+	defer conn.ExecContext(context.WithoutCancel(ctx), "SELECT pg_advisory_unlock($1)", lockKey)
+	if err := ensureVersionTable(ctx, conn); err != nil {
+		return err
+	}
+	return fn(conn)
+}
+
+// ensureVersionTable creates goose_db_version the way goose does.
+func ensureVersionTable(ctx context.Context, conn *sql.Conn) error {
+	_, err := conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS goose_db_version (
+    id SERIAL PRIMARY KEY,
+    version_id BIGINT NOT NULL,
+    is_applied BOOLEAN NOT NULL,
+    tstamp TIMESTAMP NULL DEFAULT NOW()
+)`)
+	if err != nil {
+		return fmt.Errorf("could not create goose_db_version: %w", err)
+	}
+	_, err = conn.ExecContext(ctx, `INSERT INTO goose_db_version (version_id, is_applied)
+SELECT 0, true WHERE NOT EXISTS (SELECT 1 FROM goose_db_version)`)
+	if err != nil {
+		return fmt.Errorf("could not initialise goose_db_version: %w", err)
+	}
+	return nil
+}
+
+// appliedVersions returns when each applied version was applied. Older
+// goose versions recorded rollbacks as rows with is_applied false, so the
+// rows are replayed in order.
+func appliedVersions(ctx context.Context, conn *sql.Conn) (map[int64]time.Time, error) {
+	rows, err := conn.QueryContext(ctx, "SELECT version_id, is_applied, tstamp FROM goose_db_version ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("could not read goose_db_version: %w", err)
+	}
+	defer rows.Close()
+	applied := map[int64]time.Time{}
+	for rows.Next() {
+		var version int64
+		var ok bool
+		var at sql.NullTime
+		if err := rows.Scan(&version, &ok, &at); err != nil {
+			return nil, err
+		}
+		if ok {
+			applied[version] = at.Time
+		} else {
+			delete(applied, version)
+		}
+	}
+	return applied, rows.Err()
+}
+
+// apply runs script, the up or down section of mig, and records it with
+// record, all in one transaction unless mig says otherwise.
+func apply(ctx context.Context, conn *sql.Conn, mig Migration, script, record string) error {
+	if mig.NoTx {
+		if script != "" {
+			if _, err := conn.ExecContext(ctx, script); err != nil {
+				return fmt.Errorf("%s: %w", mig.Name, err)
+			}
+		}
+		if _, err := conn.ExecContext(ctx, record, mig.Version); err != nil {
+			return fmt.Errorf("%s: could not record version: %w", mig.Name, err)
+		}
+		return nil
+	}
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if script != "" {
+		if _, err := tx.ExecContext(ctx, script); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("%s: %w", mig.Name, err)
+		}
+	}
+	if _, err := tx.ExecContext(ctx, record, mig.Version); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("%s: could not record version: %w", mig.Name, err)
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,65 @@
+package migrate_test
+
+import (
+	"testing"
+	"testing/fstest"
+
+	"github.com/RemcoVeens/httpserver/internal/migrate"
+	"github.com/RemcoVeens/httpserver/sql/schema"
+)
+
+func TestSchema(t *testing.T) {
+	migrations, err := migrate.Load(schema.FS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrations) == 0 {
+		t.Fatal("no migrations embedded")
+	}
+	for i, m := range migrations {
+		if m.Version != int64(i+1) {
+			t.Errorf("%s has version %d, want %d", m.Name, m.Version, i+1)
+		}
+		if m.Down == "" {
+			t.Errorf("%s can not be rolled back", m.Name)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	m, err := migrate.Parse("007_things.sql", `-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE things(id INT);
+-- +goose StatementEnd
+
+-- +goose Down
+DROP TABLE things;
+`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m.Version != 7 || m.Up != "CREATE TABLE things(id INT);" || m.Down != "DROP TABLE things;" || m.NoTx {
+		t.Errorf("got %+v", m)
+	}
+
+	for name, data := range map[string]string{
+		"things.sql":     "-- +goose Up\nSELECT 1;",
+		"000_things.sql": "-- +goose Up\nSELECT 1;",
+		"001_things.sql": "SELECT 1;",
+		"002_things.sql": "-- +goose Up\nSELECT 1;\n-- +goose Sideways\n",
+	} {
+		if _, err := migrate.Parse(name, data); err == nil {
+			t.Errorf("%s parsed", name)
+		}
+	}
+}
+
+func TestLoadRejectsDuplicateVersions(t *testing.T) {
+	fsys := fstest.MapFS{
+		"001_a.sql": {Data: []byte("-- +goose Up\nSELECT 1;")},
+		"01_b.sql":  {Data: []byte("-- +goose Up\nSELECT 1;")},
+	}
+	if _, err := migrate.Load(fsys); err == nil {
+		t.Error("loaded two migrations with version 1")
+	}
+}
@@ -0,0 +1,55 @@
+// Package notifications describes the in-app notification types and how
+// notifications of the same kind are grouped and summarised.
+package notifications
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+type Type string
+
+const (
+	Reply   Type = "reply"
+	Mention Type = "mention"
+)
+
+// GroupKey returns the key notifications are grouped under: one entry per
+// type and chirp, so everyone replying to the same chirp collapses into one.
+func GroupKey(t Type, chirpID uuid.NullUUID) string {
+	if !chirpID.Valid {
+		return string(t)
+	}
+	return fmt.Sprintf("%s:%s", t, chirpID.UUID)
+}
+
+// Summary renders a grouped notification, e.g. "alice and 3 others replied
+// to your chirp". actors holds the handles of the most recent actors, newest first,
+// and count the number of distinct actors in the group.
+func Summary(t Type, actors []string, count int) string {
+	var who string
+	switch {
+	case len(actors) == 0:
+		who = "someone"
+	case count <= 1:
+		who = actors[0]
+	case count == 2 && len(actors) >= 2:
+		who = actors[0] + " and " + actors[1]
+	case count == 2:
+		who = actors[0] + " and 1 other"
+	default:
+		who = fmt.Sprintf("%s and %d others", actors[0], count-1)
+	}
+	var what string
+	switch t {
+	case Reply:
+		what = "replied to your chirp"
+	case Mention:
+		what = "mentioned you"
+	default:
+		what = strings.ReplaceAll(string(t), "_", " ")
+	}
+	return who + " " + what
+}
@@ -0,0 +1,36 @@
+package notifications_test
+
+import (
+	"testing"
+
+	"github.com/RemcoVeens/httpserver/internal/notifications"
+	"github.com/google/uuid"
+)
+
+func TestGroupKey(t *testing.T) {
+	chirp := uuid.NullUUID{UUID: uuid.New(), Valid: true}
+	if got := notifications.GroupKey(notifications.Reply, chirp); got != "reply:"+chirp.UUID.String() {
+		t.Errorf("unexpected reply group key %q", got)
+	}
+	if notifications.GroupKey(notifications.Reply, chirp) == notifications.GroupKey(notifications.Mention, chirp) {
+		t.Errorf("replies and mentions in the same chirp should not share a group")
+	}
+}
+
+func TestSummary(t *testing.T) {
+	cases := []struct {
+		actors []string
+		count  int
+		want   string
+	}{
+		{[]string{"alice"}, 1, "alice replied to your chirp"},
+		{[]string{"alice", "bob"}, 2, "alice and bob replied to your chirp"},
+		{[]string{"alice", "bob", "carol"}, 4, "alice and 3 others replied to your chirp"},
+		{nil, 0, "someone replied to your chirp"},
+	}
+	for _, c := range cases {
+		if got := notifications.Summary(notifications.Reply, c.actors, c.count); got != c.want {
+			t.Errorf("Summary(%v, %d) = %q, want %q", c.actors, c.count, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,272 @@
+// Package openapi holds the OpenAPI 3.1 description of Chirpy, serves it at
+// /api/openapi.json with a reference page at /api/docs, and validates
+// traffic against it (see Validator).
+//
+// openapi.json is maintained by hand next to the handlers: a route added to
+// the server without an operation here fails the server tests.
+package openapi
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+//go:embed openapi.json
+var spec []byte
+
+//go:embed docs.html
+var docs []byte
+
+// Spec returns the OpenAPI document.
+func Spec() []byte {
+	return spec
+}
+
+// ServeSpec serves the OpenAPI document.
+func ServeSpec(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(200)
+	w.Write(spec)
+}
+
+// ServeDocs serves a page that renders the OpenAPI document.
+func ServeDocs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(200)
+	w.Write(docs)
+}
+
+// methods are the operations a path item can have, in document order.
+var methods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+// Document is the part of an OpenAPI document that is needed to route and
+// validate requests.
+type Document struct {
+	Operations []*Operation
+}
+
+// Operation is an operation of the document, with its references resolved.
+type Operation struct {
+	Method      string
+	Path        string
+	OperationID string
+	Parameters  []*Parameter
+	RequestBody *RequestBody
+	Responses   map[string]*Response
+
+	// muxPattern overrides the ServeMux pattern of paths OpenAPI can not
+	// express, such as the /app/ subtree.
+	muxPattern string
+	// ptr is the JSON pointer to the operation.
+	ptr string
+}
+
+// Pattern returns the ServeMux pattern the operation is served at.
+func (op *Operation) Pattern() string {
+	if op.muxPattern != "" {
+		return op.muxPattern
+	}
+	path := op.Path
+	if strings.HasSuffix(path, "/") {
+		// OpenAPI paths match exactly; ServeMux patterns ending in a slash
+		// match the whole subtree.
+		path += "{$}"
+	}
+	return strings.ToUpper(op.Method) + " " + path
+}
+
+// Parameter is a path, query or header parameter.
+type Parameter struct {
+	Name     string
+	In       string
+	Required bool
+	// Type is the JSON type values are converted to before validation.
+	Type string
+	// Schema is the JSON pointer to the schema of the parameter.
+	Schema string
+}
+
+// RequestBody lists the media types a request may be sent as.
+type RequestBody struct {
+	Required bool
+	// Content maps media ranges to the JSON pointers of their schemas.
+	Content map[string]string
+}
+
+// Response lists the media types of a response.
+type Response struct {
+	Content map[string]string
+}
+
+type rawOperation struct {
+	OperationID string            `json:"operationId"`
+	Parameters  []json.RawMessage `json:"parameters"`
+	RequestBody json.RawMessage   `json:"requestBody"`
+	Responses   map[string]json.RawMessage
+	MuxPattern  string `json:"x-mux-pattern"`
+}
+
+type rawDocument struct {
+	Paths      map[string]map[string]json.RawMessage `json:"paths"`
+	Components struct {
+		Parameters    map[string]json.RawMessage `json:"parameters"`
+		RequestBodies map[string]json.RawMessage `json:"requestBodies"`
+		Responses     map[string]json.RawMessage `json:"responses"`
+	} `json:"components"`
+}
+
+// Load parses the embedded document.
+func Load() (*Document, error) {
+	return Parse(spec)
+}
+
+// Parse parses an OpenAPI document. Only local references to components
+// are supported.
+func Parse(data []byte) (*Document, error) {
+	var raw rawDocument
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("could not parse OpenAPI document: %w", err)
+	}
+	paths := make([]string, 0, len(raw.Paths))
+	for path := range raw.Paths {
+		paths = append(paths, path)
+	}
+	slices.Sort(paths)
+	doc := &Document{}
+	for _, path := range paths {
+		for _, method := range methods {
+			item, ok := raw.Paths[path][method]
+			if !ok {
+				continue
+			}
+			op, err := raw.operation(path, method, item)
+			if err != nil {
+				return nil, fmt.Errorf("%s %s: %w", strings.ToUpper(method), path, err)
+			}
+			doc.Operations = append(doc.Operations, op)
+		}
+	}
+	return doc, nil
+}
+
+func (raw *rawDocument) operation(path, method string, data json.RawMessage) (*Operation, error) {
+	var r rawOperation
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, err
+	}
+	op := &Operation{
+		Method:      method,
+		Path:        path,
+		OperationID: r.OperationID,
+		Responses:   map[string]*Response{},
+		muxPattern:  r.MuxPattern,
+		ptr:         pointer("paths", path, method),
+	}
+	for i, p := range r.Parameters {
+		data, ptr, err := raw.resolve(p, op.ptr+pointer("parameters", fmt.Sprint(i)), "parameters")
+		if err != nil {
+			return nil, err
+		}
+		var param struct {
+			Name     string
+			In       string
+			Required bool
+			Schema   struct {
+				Type string
+			}
+		}
+		if err := json.Unmarshal(data, &param); err != nil {
+			return nil, fmt.Errorf("parameter %d: %w", i, err)
+		}
+		op.Parameters = append(op.Parameters, &Parameter{
+			Name:     param.Name,
+			In:       param.In,
+			Required: param.Required,
+			Type:     param.Schema.Type,
+			Schema:   ptr + pointer("schema"),
+		})
+	}
+	if r.RequestBody != nil {
+		data, ptr, err := raw.resolve(r.RequestBody, op.ptr+pointer("requestBody"), "requestBodies")
+		if err != nil {
+			return nil, err
+		}
+		var body struct {
+			Required bool
+			Content  map[string]json.RawMessage
+		}
+		if err := json.Unmarshal(data, &body); err != nil {
+			return nil, fmt.Errorf("request body: %w", err)
+		}
+		op.RequestBody = &RequestBody{Required: body.Required, Content: content(ptr, body.Content)}
+	}
+	for status, resp := range r.Responses {
+		data, ptr, err := raw.resolve(resp, op.ptr+pointer("responses", status), "responses")
+		if err != nil {
+			return nil, err
+		}
+		var response struct {
+			Content map[string]json.RawMessage
+		}
+		if err := json.Unmarshal(data, &response); err != nil {
+			return nil, fmt.Errorf("response %s: %w", status, err)
+		}
+		op.Responses[status] = &Response{Content: content(ptr, response.Content)}
+	}
+	return op, nil
+}
+
+// resolve follows data if it is a reference to a component of kind, and
+// returns the object with its JSON pointer.
+func (raw *rawDocument) resolve(data json.RawMessage, ptr, kind string) (json.RawMessage, string, error) {
+	var ref struct {
+		Ref string `json:"$ref"`
+	}
+	if err := json.Unmarshal(data, &ref); err != nil || ref.Ref == "" {
+		return data, ptr, nil
+	}
+	prefix := "#/components/" + kind + "/"
+	name, ok := strings.CutPrefix(ref.Ref, prefix)
+	if !ok {
+		return nil, "", fmt.Errorf("unsupported reference %q", ref.Ref)
+	}
+	var components map[string]json.RawMessage
+	switch kind {
+	case "parameters":
+		components = raw.Components.Parameters
+	case "requestBodies":
+		components = raw.Components.RequestBodies
+	case "responses":
+		components = raw.Components.Responses
+	}
+	target, ok := components[name]
+	if !ok {
+		return nil, "", fmt.Errorf("unresolved reference %q", ref.Ref)
+	}
+	return target, pointer("components", kind, name), nil
+}
+
+func content(ptr string, media map[string]json.RawMessage) map[string]string {
+	c := make(map[string]string, len(media))
+	for mt := range media {
+		c[mt] = ptr + pointer("content", mt, "schema")
+	}
+	return c
+}
+
+// pointer joins tokens into a JSON pointer, escaped for use as a URI
+// fragment.
+func pointer(tokens ...string) string {
+	var b strings.Builder
+	for _, t := range tokens {
+		t = strings.ReplaceAll(t, "~", "~0")
+		t = strings.ReplaceAll(t, "/", "~1")
+		b.WriteString("/" + url.PathEscape(t))
+	}
+	return b.String()
+}
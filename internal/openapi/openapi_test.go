@@ -0,0 +1,160 @@
+package openapi_test
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/RemcoVeens/httpserver/internal/apierror"
+	"github.com/RemcoVeens/httpserver/internal/openapi"
+)
+
+func newValidator(t *testing.T) *openapi.Validator {
+	t.Helper()
+	doc, err := openapi.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := openapi.NewValidator(doc, openapi.Spec())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return v
+}
+
+func TestOperations(t *testing.T) {
+	doc, err := openapi.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := map[string]bool{}
+	wildcard := regexp.MustCompile(`\{([a-z_]+)\}`)
+	for _, op := range doc.Operations {
+		name := op.Pattern()
+		if op.OperationID == "" || ids[op.OperationID] {
+			t.Errorf("%s: operationId %q is missing or taken", name, op.OperationID)
+		}
+		ids[op.OperationID] = true
+		if _, ok := op.Responses["default"]; !ok {
+			t.Errorf("%s: no default response", name)
+		}
+		var params []string
+		for _, p := range op.Parameters {
+			if p.In == "path" {
+				params = append(params, p.Name)
+			}
+		}
+		var wildcards []string
+		for _, m := range wildcard.FindAllStringSubmatch(op.Path, -1) {
+			wildcards = append(wildcards, m[1])
+		}
+		slices.Sort(params)
+		slices.Sort(wildcards)
+		if !slices.Equal(params, wildcards) {
+			t.Errorf("%s: path parameters %v, path has %v", name, params, wildcards)
+		}
+	}
+}
+
+func TestValidatorRejectsRequests(t *testing.T) {
+	h := newValidator(t).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("%s %s reached the handler", r.Method, r.URL)
+	}))
+	for _, tc := range []struct {
+		method, target, body string
+		code                 apierror.Code
+	}{
+		{"POST", "/api/chirps", `{}`, apierror.InvalidRequest},
+		{"POST", "/api/chirps", `{"body":"hi","media":["nope"]}`, apierror.InvalidRequest},
+		{"POST", "/api/chirps", `[`, apierror.InvalidJSON},
+		{"POST", "/api/chirps", ``, apierror.InvalidRequest},
+		{"GET", "/api/chirps/not-a-uuid", ``, apierror.InvalidRequest},
+		{"GET", "/api/notifications?limit=zero", ``, apierror.InvalidRequest},
+		{"GET", "/api/hashtags/trending?limit=51", ``, apierror.InvalidRequest},
+	} {
+		name := tc.method + " " + tc.target + " " + tc.body
+		req := httptest.NewRequest(tc.method, tc.target, strings.NewReader(tc.body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		p, err := apierror.Parse(rec.Body.Bytes())
+		if err != nil {
+			t.Errorf("%s: %s in %q", name, err, rec.Body)
+			continue
+		}
+		if p.Code != tc.code {
+			t.Errorf("%s: code %q, want %q (%s)", name, p.Code, tc.code, p.Detail)
+		}
+	}
+}
+
+func TestValidatorPassesRequests(t *testing.T) {
+	var body string
+	h := newValidator(t).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body = string(b)
+		w.WriteHeader(http.StatusTeapot)
+	}))
+	for _, target := range []string{"/api/chirps", "/api/chirps?sort=asc", "/api/unknown"} {
+		body = ""
+		req := httptest.NewRequest("POST", target, strings.NewReader(`{"body":"hi"}`))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != http.StatusTeapot {
+			t.Errorf("POST %s: status %d, body %s", target, rec.Code, rec.Body)
+		}
+		if body != `{"body":"hi"}` {
+			t.Errorf("POST %s: handler read %q", target, body)
+		}
+	}
+}
+
+func TestValidatorReportsResponses(t *testing.T) {
+	for _, tc := range []struct {
+		name    string
+		handler http.HandlerFunc
+		report  bool
+	}{
+		{"valid", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"unread":3}`))
+		}, false},
+		{"problem", func(w http.ResponseWriter, r *http.Request) {
+			apierror.Write(w, r, apierror.New(apierror.Unavailable, "down"))
+		}, false},
+		{"wrong content type", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`{"unread":3}`))
+		}, true},
+		{"wrong shape", func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"unread":"3"}`))
+		}, true},
+		{"error without problem", func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "down", http.StatusServiceUnavailable)
+		}, true},
+	} {
+		var reported error
+		v := newValidator(t)
+		v.Responses = true
+		v.Report = func(r *http.Request, err error) { reported = errors.Join(reported, err) }
+		rec := httptest.NewRecorder()
+		v.Middleware(tc.handler).ServeHTTP(rec, httptest.NewRequest("GET", "/api/notifications/unread_count", nil))
+		if (reported != nil) != tc.report {
+			t.Errorf("%s: reported %v", tc.name, reported)
+		}
+		want := httptest.NewRecorder()
+		tc.handler(want, httptest.NewRequest("GET", "/api/notifications/unread_count", nil))
+		if rec.Code != want.Code || rec.Body.String() != want.Body.String() {
+			t.Errorf("%s: response %d %q, want %d %q", tc.name, rec.Code, rec.Body, want.Code, want.Body)
+		}
+	}
+}
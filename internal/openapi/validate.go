@@ -0,0 +1,387 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/RemcoVeens/httpserver/internal/apierror"
+	"github.com/santhosh-tekuri/jsonschema/v6"
+)
+
+// maxBody bounds the JSON request bodies the validator reads. Handlers
+// enforce their own, usually smaller, limits.
+const maxBody = 1 << 20
+
+// docURL is where the document is loaded into the schema compiler.
+const docURL = "mem:///openapi.json"
+
+// Validator enforces the document on requests and, optionally, checks the
+// responses of the handlers against it.
+//
+// Requests with parameters or a body the document does not allow are
+// answered with a problem before they reach the handler. Requests that do
+// not match any operation are passed on, so the handler can answer them
+// with a 404 or 405.
+type Validator struct {
+	// Responses enables checking responses. The whole response is buffered
+	// to do so, except for streams.
+	Responses bool
+	// Report is called for each response that does not match the
+	// document. It defaults to logging the error.
+	Report func(r *http.Request, err error)
+
+	mux *http.ServeMux
+	ops map[string]*operation
+}
+
+// operation is an Operation with its schemas compiled.
+type operation struct {
+	*Operation
+	params []*jsonschema.Schema
+	// bodies and responses hold the schemas of JSON media types, by
+	// media range.
+	bodies    map[string]*jsonschema.Schema
+	responses map[string]map[string]*jsonschema.Schema
+	// stream is set for operations whose responses can not be buffered.
+	stream bool
+}
+
+// NewValidator compiles every schema doc refers to. data is the raw
+// document doc was parsed from.
+func NewValidator(doc *Document, data []byte) (*Validator, error) {
+	root, err := jsonschema.UnmarshalJSON(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("could not parse OpenAPI document: %w", err)
+	}
+	c := jsonschema.NewCompiler()
+	c.DefaultDraft(jsonschema.Draft2020)
+	c.AssertFormat()
+	if err := c.AddResource(docURL, root); err != nil {
+		return nil, err
+	}
+	compile := func(ptr string) (*jsonschema.Schema, error) {
+		return c.Compile(docURL + "#" + ptr)
+	}
+	v := &Validator{mux: http.NewServeMux(), ops: map[string]*operation{}}
+	for _, op := range doc.Operations {
+		o := &operation{
+			Operation: op,
+			bodies:    map[string]*jsonschema.Schema{},
+			responses: map[string]map[string]*jsonschema.Schema{},
+		}
+		for _, p := range op.Parameters {
+			s, err := compile(p.Schema)
+			if err != nil {
+				return nil, fmt.Errorf("%s: parameter %s: %w", op.Pattern(), p.Name, err)
+			}
+			o.params = append(o.params, s)
+		}
+		if op.RequestBody != nil {
+			for mt, ptr := range op.RequestBody.Content {
+				if !isJSON(mt) {
+					continue
+				}
+				if o.bodies[mt], err = compile(ptr); err != nil {
+					return nil, fmt.Errorf("%s: request body: %w", op.Pattern(), err)
+				}
+			}
+		}
+		for status, resp := range op.Responses {
+			if status == "101" {
+				o.stream = true
+			}
+			o.responses[status] = map[string]*jsonschema.Schema{}
+			for mt, ptr := range resp.Content {
+				if mt == "text/event-stream" {
+					o.stream = true
+				}
+				if !isJSON(mt) {
+					continue
+				}
+				if o.responses[status][mt], err = compile(ptr); err != nil {
+					return nil, fmt.Errorf("%s: response %s: %w", op.Pattern(), status, err)
+				}
+			}
+		}
+		v.mux.Handle(op.Pattern(), http.NotFoundHandler())
+		v.ops[op.Pattern()] = o
+	}
+	return v, nil
+}
+
+// Middleware validates the requests to next and, if enabled, its
+// responses.
+func (v *Validator) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, pattern := v.mux.Handler(r)
+		op, ok := v.ops[pattern]
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if err := op.checkRequest(r); err != nil {
+			apierror.Write(w, r, err)
+			return
+		}
+		if !v.Responses || op.stream {
+			next.ServeHTTP(w, r)
+			return
+		}
+		rec := &recorder{header: http.Header{}}
+		next.ServeHTTP(rec, r)
+		if err := op.checkResponse(r, rec); err != nil {
+			v.report(r, fmt.Errorf("%s %s: %w", r.Method, r.URL.Path, err))
+		}
+		for k, vs := range rec.header {
+			w.Header()[k] = vs
+		}
+		w.WriteHeader(rec.code())
+		w.Write(rec.body.Bytes())
+	})
+}
+
+func (v *Validator) report(r *http.Request, err error) {
+	if v.Report != nil {
+		v.Report(r, err)
+		return
+	}
+	log.Printf("openapi: %s", err)
+}
+
+func (op *operation) checkRequest(r *http.Request) error {
+	var path map[string]string
+	if op.muxPattern == "" {
+		path = pathValues(op.Path, r.URL.Path)
+	}
+	query := r.URL.Query()
+	for i, p := range op.Parameters {
+		var values []string
+		switch p.In {
+		case "path":
+			if path == nil {
+				continue
+			}
+			values = []string{path[p.Name]}
+		case "query":
+			values = query[p.Name]
+		case "header":
+			values = r.Header.Values(p.Name)
+		default:
+			continue
+		}
+		if len(values) == 0 {
+			if p.Required {
+				return apierror.Errorf(apierror.InvalidRequest, "missing %s parameter %s", p.In, p.Name)
+			}
+			continue
+		}
+		if err := op.params[i].Validate(coerce(p.Type, values)); err != nil {
+			return apierror.Errorf(apierror.InvalidRequest, "invalid %s parameter %s: %s", p.In, p.Name, describe(err))
+		}
+	}
+	return op.checkBody(r)
+}
+
+func (op *operation) checkBody(r *http.Request) error {
+	if op.RequestBody == nil {
+		return nil
+	}
+	if r.ContentLength == 0 || r.Body == nil || r.Body == http.NoBody {
+		if op.RequestBody.Required {
+			return apierror.New(apierror.InvalidRequest, "a request body is required")
+		}
+		return nil
+	}
+	mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	declared, ok := matchMediaType(op.RequestBody.Content, mt)
+	if !ok {
+		return apierror.Errorf(apierror.UnsupportedMediaType, "Content-Type must be one of %s", mediaTypes(op.RequestBody.Content))
+	}
+	schema := op.bodies[declared]
+	if schema == nil {
+		return nil
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxBody+1))
+	r.Body.Close()
+	if err != nil {
+		return apierror.Errorf(apierror.InvalidRequest, "could not read body: %s", err)
+	}
+	if len(body) > maxBody {
+		return apierror.Errorf(apierror.PayloadTooLarge, "the body can be at most %d bytes", maxBody)
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	inst, err := jsonschema.UnmarshalJSON(bytes.NewReader(body))
+	if err != nil {
+		return apierror.Errorf(apierror.InvalidJSON, "could not decode body: %s", err)
+	}
+	if err := schema.Validate(inst); err != nil {
+		return apierror.Errorf(apierror.InvalidRequest, "invalid body: %s", describe(err))
+	}
+	return nil
+}
+
+func (op *operation) checkResponse(r *http.Request, rec *recorder) error {
+	status := strconv.Itoa(rec.code())
+	schemas, ok := op.responses[status]
+	if !ok {
+		schemas, ok = op.responses[status[:1]+"XX"]
+	}
+	if !ok {
+		schemas, ok = op.responses["default"]
+	}
+	if !ok {
+		return fmt.Errorf("undocumented status %s", status)
+	}
+	resp := op.Responses[status]
+	if resp == nil {
+		resp = op.Responses[status[:1]+"XX"]
+	}
+	if resp == nil {
+		resp = op.Responses["default"]
+	}
+	if rec.body.Len() == 0 || r.Method == http.MethodHead {
+		return nil
+	}
+	if len(resp.Content) == 0 {
+		return fmt.Errorf("status %s must not have a body", status)
+	}
+	mt, _, _ := mime.ParseMediaType(rec.header.Get("Content-Type"))
+	declared, ok := matchMediaType(resp.Content, mt)
+	if !ok {
+		return fmt.Errorf("status %s: Content-Type %q is not one of %s", status, mt, mediaTypes(resp.Content))
+	}
+	schema := schemas[declared]
+	if schema == nil {
+		return nil
+	}
+	inst, err := jsonschema.UnmarshalJSON(bytes.NewReader(rec.body.Bytes()))
+	if err != nil {
+		return fmt.Errorf("status %s: invalid JSON: %w", status, err)
+	}
+	if err := schema.Validate(inst); err != nil {
+		return fmt.Errorf("status %s: %s", status, describe(err))
+	}
+	return nil
+}
+
+// pathValues matches path against template and returns the values of its
+// parameters.
+func pathValues(template, path string) map[string]string {
+	values := map[string]string{}
+	want := strings.Split(template, "/")
+	got := strings.Split(path, "/")
+	for i, seg := range want {
+		if i >= len(got) {
+			break
+		}
+		if name, ok := strings.CutPrefix(seg, "{"); ok {
+			values[strings.TrimSuffix(name, "}")] = got[i]
+		}
+	}
+	return values
+}
+
+// coerce converts parameter values to the JSON type of their schema, so
+// that a value of the wrong type fails validation rather than conversion.
+func coerce(typ string, values []string) any {
+	if typ == "array" {
+		items := make([]any, len(values))
+		for i, v := range values {
+			items[i] = v
+		}
+		return items
+	}
+	v := values[0]
+	switch typ {
+	case "integer", "number":
+		if _, err := strconv.ParseFloat(v, 64); err == nil {
+			return json.Number(v)
+		}
+	case "boolean":
+		if b, err := strconv.ParseBool(v); err == nil {
+			return b
+		}
+	}
+	return v
+}
+
+// describe flattens a validation error into one line.
+func describe(err error) string {
+	var ve *jsonschema.ValidationError
+	if !errors.As(err, &ve) {
+		return err.Error()
+	}
+	var msgs []string
+	for _, line := range strings.Split(ve.Error(), "\n")[1:] {
+		line = strings.TrimPrefix(strings.TrimSpace(line), "- ")
+		if !strings.HasSuffix(line, "validation failed") {
+			msgs = append(msgs, line)
+		}
+	}
+	if len(msgs) == 0 {
+		return ve.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+func isJSON(mt string) bool {
+	return mt == "application/json" || strings.HasSuffix(mt, "+json")
+}
+
+// matchMediaType returns the media range in content that mt falls in.
+func matchMediaType(content map[string]string, mt string) (string, bool) {
+	if _, ok := content[mt]; ok {
+		return mt, true
+	}
+	typ, _, _ := strings.Cut(mt, "/")
+	if _, ok := content[typ+"/*"]; ok {
+		return typ + "/*", true
+	}
+	if _, ok := content["*/*"]; ok {
+		return "*/*", true
+	}
+	return "", false
+}
+
+func mediaTypes(content map[string]string) string {
+	mts := make([]string, 0, len(content))
+	for mt := range content {
+		mts = append(mts, mt)
+	}
+	return strings.Join(mts, ", ")
+}
+
+// recorder buffers a response.
+type recorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (rec *recorder) Header() http.Header { return rec.header }
+
+func (rec *recorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+}
+
+func (rec *recorder) Write(b []byte) (int, error) {
+	rec.WriteHeader(http.StatusOK)
+	return rec.body.Write(b)
+}
+
+func (rec *recorder) code() int {
+	if rec.status == 0 {
+		return http.StatusOK
+	}
+	return rec.status
+}
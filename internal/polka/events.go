@@ -0,0 +1,74 @@
+package polka
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/RemcoVeens/httpserver/internal/database"
+	"github.com/RemcoVeens/httpserver/internal/inbox"
+	"github.com/RemcoVeens/httpserver/internal/subscriptions"
+	"github.com/google/uuid"
+)
+
+// Provider is the inbox provider name of Polka events.
+const Provider = "polka"
+
+// Event is the body of a Polka webhook.
+type Event struct {
+	ID    string `json:"id"`
+	Event string `json:"event"`
+	Data  struct {
+		UserID    string     `json:"user_id"`
+		Plan      string     `json:"plan"`
+		PeriodEnd *time.Time `json:"period_end"`
+	} `json:"data"`
+}
+
+// Parse decodes a webhook body. It only checks that the body is an event;
+// the data is validated when the event is processed.
+func Parse(body []byte) (Event, error) {
+	var e Event
+	if err := json.Unmarshal(body, &e); err != nil {
+		return Event{}, err
+	}
+	if e.Event == "" {
+		return Event{}, errors.New("event is required")
+	}
+	return e, nil
+}
+
+// Process is the inbox.Handler for Polka events. Events other than
+// subscription events are ignored.
+func Process(ctx context.Context, q *database.Queries, ev database.InboundEvent) error {
+	e, err := Parse(ev.Payload)
+	if err != nil {
+		return inbox.Permanent(fmt.Errorf("invalid payload: %w", err))
+	}
+	if !subscriptions.IsEvent(e.Event) {
+		return nil
+	}
+	userID, err := uuid.Parse(e.Data.UserID)
+	if err != nil {
+		return inbox.Permanent(fmt.Errorf("invalid user_id: %w", err))
+	}
+	sub := subscriptions.Event{
+		Type:   e.Event,
+		UserID: userID,
+		Plan:   e.Data.Plan,
+	}
+	if e.Data.PeriodEnd != nil {
+		sub.PeriodEnd = sql.NullTime{Time: *e.Data.PeriodEnd, Valid: true}
+	}
+	if err := sub.Validate(); err != nil {
+		return inbox.Permanent(err)
+	}
+	err = subscriptions.Apply(ctx, q, sub)
+	if errors.Is(err, sql.ErrNoRows) {
+		return inbox.Permanent(err)
+	}
+	return err
+}
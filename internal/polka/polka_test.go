@@ -0,0 +1,101 @@
+package polka_test
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/RemcoVeens/httpserver/internal/polka"
+)
+
+var body = []byte(`{"id":"evt_1","event":"user.upgraded","data":{"user_id":"x"}}`)
+
+func signed(secret string, ts time.Time, b []byte) http.Header {
+	h := http.Header{}
+	h.Set(polka.TimestampHeader, strconv.FormatInt(ts.Unix(), 10))
+	h.Set(polka.SignatureHeader, polka.Sign(secret, ts, b))
+	return h
+}
+
+func TestSignedRequests(t *testing.T) {
+	v := polka.Verifier{Secret: "s3cret", APIKey: "key"}
+	now := time.Unix(1700000000, 0)
+	cases := []struct {
+		name   string
+		header http.Header
+		now    time.Time
+		want   error
+	}{
+		{"valid", signed("s3cret", now, body), now, nil},
+		{"within tolerance", signed("s3cret", now, body), now.Add(4 * time.Minute), nil},
+		{"stale", signed("s3cret", now, body), now.Add(6 * time.Minute), polka.ErrStaleTimestamp},
+		{"from the future", signed("s3cret", now.Add(6*time.Minute), body), now, polka.ErrStaleTimestamp},
+		{"wrong secret", signed("other", now, body), now, polka.ErrInvalidSignature},
+		{"other body", signed("s3cret", now, []byte(`{}`)), now, polka.ErrInvalidSignature},
+	}
+	for _, c := range cases {
+		signed, err := v.Verify(c.header, body, c.now)
+		if !signed || !errors.Is(err, c.want) {
+			t.Errorf("%s: got signed=%v err=%v, want %v", c.name, signed, err, c.want)
+		}
+	}
+}
+
+func TestBadSignatureDoesNotFallBackToAPIKey(t *testing.T) {
+	v := polka.Verifier{Secret: "s3cret", APIKey: "key"}
+	now := time.Now()
+	h := signed("other", now, body)
+	h.Set("Authorization", "ApiKey key")
+	if _, err := v.Verify(h, body, now); err == nil {
+		t.Errorf("bad signature accepted because of the API key")
+	}
+}
+
+func TestAPIKeyFallback(t *testing.T) {
+	v := polka.Verifier{Secret: "s3cret", APIKey: "key"}
+	cases := map[string]error{
+		"ApiKey key":   nil,
+		"ApiKey wrong": polka.ErrInvalidAPIKey,
+		"":             polka.ErrUnauthenticated,
+	}
+	for auth, want := range cases {
+		h := http.Header{}
+		if auth != "" {
+			h.Set("Authorization", auth)
+		}
+		signed, err := v.Verify(h, body, time.Now())
+		if signed || !errors.Is(err, want) {
+			t.Errorf("%q: got signed=%v err=%v, want %v", auth, signed, err, want)
+		}
+	}
+	if _, err := (polka.Verifier{}).Verify(http.Header{"Authorization": {"ApiKey "}}, body, time.Now()); err == nil {
+		t.Errorf("empty API key accepted when none is configured")
+	}
+}
+
+func TestSignatureIgnoredWithoutSecret(t *testing.T) {
+	v := polka.Verifier{APIKey: "key"}
+	now := time.Now()
+	h := signed("anything", now, body)
+	if _, err := v.Verify(h, body, now); err == nil {
+		t.Errorf("signature accepted with no secret configured")
+	}
+	h.Set("Authorization", "ApiKey key")
+	if signed, err := v.Verify(h, body, now); signed || err != nil {
+		t.Errorf("API key should still work, got signed=%v err=%v", signed, err)
+	}
+}
+
+func TestParse(t *testing.T) {
+	e, err := polka.Parse(body)
+	if err != nil || e.ID != "evt_1" || e.Event != "user.upgraded" || e.Data.UserID != "x" {
+		t.Errorf("Parse = %+v, %v", e, err)
+	}
+	for _, b := range []string{`not json`, `{}`, `{"data":{"user_id":"x"}}`} {
+		if _, err := polka.Parse([]byte(b)); err == nil {
+			t.Errorf("Parse(%s) accepted", b)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+// Package pubsub is the in-process event broker. Events emitted by handlers
+// reach it through the events.Listener once the change that caused them is
+// committed, and long-lived connections such as the SSE chirp stream and the
+// WebSocket gateway subscribe to it.
+//
+// Every event gets a process-local, strictly increasing ID. The broker keeps
+// the most recent events in a bounded replay buffer so a client that
+// reconnects with the last ID it saw can catch up without gaps, as long as it
+// has not fallen further behind than the buffer reaches.
+package pubsub
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultReplaySize       = 1024
+	DefaultSubscriberBuffer = 64
+)
+
+// Event types published by Chirpy.
+const (
+	ChirpCreated = "chirp.created"
+	ChirpDeleted = "chirp.deleted"
+
+	NotificationCreated = "notification.created"
+
+	UserCreated    = "user.created"
+	UserUpgraded   = "user.upgraded"
+	UserDowngraded = "user.downgraded"
+)
+
+var ErrClosed = errors.New("broker is closed")
+
+type Event struct {
+	ID        uint64          `json:"id"`
+	Type      string          `json:"type"`
+	Data      json.RawMessage `json:"data"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// Filter decides whether a subscriber wants an event.
+type Filter func(Event) bool
+
+type Broker struct {
+	mu         sync.Mutex
+	nextID     uint64
+	replay     []Event
+	replaySize int
+	bufferSize int
+	subs       map[*Subscription]struct{}
+	closed     bool
+}
+
+func NewBroker(replaySize, subscriberBuffer int) *Broker {
+	if replaySize <= 0 {
+		replaySize = DefaultReplaySize
+	}
+	if subscriberBuffer <= 0 {
+		subscriberBuffer = DefaultSubscriberBuffer
+	}
+	return &Broker{
+		nextID:     1,
+		replaySize: replaySize,
+		bufferSize: subscriberBuffer,
+		subs:       map[*Subscription]struct{}{},
+	}
+}
+
+// Publish marshals data and delivers it to every matching subscriber. It
+// never blocks: a subscriber whose buffer is full is considered too slow and
+// is dropped, which closes its channel.
+func (b *Broker) Publish(eventType string, data any) (Event, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return Event{}, fmt.Errorf("could not marshal %s event: %w", eventType, err)
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return Event{}, ErrClosed
+	}
+	e := Event{
+		ID:        b.nextID,
+		Type:      eventType,
+		Data:      raw,
+		CreatedAt: time.Now().UTC(),
+	}
+	b.nextID++
+	b.replay = append(b.replay, e)
+	if len(b.replay) > b.replaySize {
+		b.replay = b.replay[len(b.replay)-b.replaySize:]
+	}
+	for sub := range b.subs {
+		if sub.filter != nil && !sub.filter(e) {
+			continue
+		}
+		select {
+		case sub.ch <- e:
+		default:
+			sub.dropped = true
+			b.removeLocked(sub)
+		}
+	}
+	return e, nil
+}
+
+// Subscribe registers a subscriber. When lastID is non-zero the events after
+// it that are still in the replay buffer are returned, filtered, so the
+// caller can send them before reading from the subscription. truncated is
+// true when events after lastID have already left the buffer.
+func (b *Broker) Subscribe(lastID uint64, filter Filter) (sub *Subscription, replay []Event, truncated bool) {
+	sub = &Subscription{
+		broker: b,
+		filter: filter,
+		ch:     make(chan Event, b.bufferSize),
+	}
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		close(sub.ch)
+		return sub, nil, false
+	}
+	if lastID > 0 {
+		if len(b.replay) > 0 && b.replay[0].ID > lastID+1 {
+			truncated = true
+		}
+		for _, e := range b.replay {
+			if e.ID <= lastID || (filter != nil && !filter(e)) {
+				continue
+			}
+			replay = append(replay, e)
+		}
+	}
+	b.subs[sub] = struct{}{}
+	return sub, replay, truncated
+}
+
+// Close disconnects every subscriber and makes further publishes fail. It is
+// used during shutdown so streaming handlers return promptly.
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.closed = true
+	for sub := range b.subs {
+		b.removeLocked(sub)
+	}
+}
+
+// Subscribers returns the number of active subscriptions.
+func (b *Broker) Subscribers() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subs)
+}
+
+func (b *Broker) removeLocked(sub *Subscription) {
+	if _, ok := b.subs[sub]; !ok {
+		return
+	}
+	delete(b.subs, sub)
+	close(sub.ch)
+}
+
+type Subscription struct {
+	broker  *Broker
+	filter  Filter
+	ch      chan Event
+	dropped bool
+}
+
+// C delivers events. It is closed when the subscription ends, either through
+// Close, because the broker shut down, or because the subscriber was dropped
+// for being too slow.
+func (s *Subscription) C() <-chan Event {
+	return s.ch
+}
+
+// Dropped reports whether the broker ended the subscription because the
+// subscriber did not keep up.
+func (s *Subscription) Dropped() bool {
+	s.broker.mu.Lock()
+	defer s.broker.mu.Unlock()
+	return s.dropped
+}
+
+func (s *Subscription) Close() {
+	s.broker.mu.Lock()
+	defer s.broker.mu.Unlock()
+	s.broker.removeLocked(s)
+}
@@ -0,0 +1,92 @@
+package pubsub_test
+
+import (
+	"testing"
+
+	"github.com/RemcoVeens/httpserver/internal/pubsub"
+)
+
+func TestPublishDeliversInOrder(t *testing.T) {
+	b := pubsub.NewBroker(10, 10)
+	sub, _, _ := b.Subscribe(0, nil)
+	defer sub.Close()
+	for i := range 3 {
+		if _, err := b.Publish("chirp.created", map[string]int{"n": i}); err != nil {
+			t.Fatalf("Publish failed: %v", err)
+		}
+	}
+	for want := uint64(1); want <= 3; want++ {
+		e := <-sub.C()
+		if e.ID != want {
+			t.Fatalf("got event %d, want %d", e.ID, want)
+		}
+	}
+}
+
+func TestFilter(t *testing.T) {
+	b := pubsub.NewBroker(10, 10)
+	sub, _, _ := b.Subscribe(0, func(e pubsub.Event) bool { return e.Type == "keep" })
+	defer sub.Close()
+	b.Publish("skip", nil)
+	b.Publish("keep", nil)
+	if e := <-sub.C(); e.Type != "keep" {
+		t.Fatalf("filter let through %q", e.Type)
+	}
+}
+
+func TestReplayFromLastID(t *testing.T) {
+	b := pubsub.NewBroker(3, 10)
+	for range 5 {
+		b.Publish("chirp.created", nil)
+	}
+	_, replay, truncated := b.Subscribe(3, nil)
+	if truncated {
+		t.Errorf("replay from 3 should not be truncated with events 3..5 buffered")
+	}
+	if len(replay) != 2 || replay[0].ID != 4 || replay[1].ID != 5 {
+		t.Fatalf("unexpected replay: %+v", replay)
+	}
+	_, replay, truncated = b.Subscribe(1, nil)
+	if !truncated {
+		t.Errorf("replay from 1 should be truncated, event 2 is gone")
+	}
+	if len(replay) != 3 {
+		t.Fatalf("expected the 3 buffered events, got %d", len(replay))
+	}
+}
+
+func TestSlowSubscriberIsDropped(t *testing.T) {
+	b := pubsub.NewBroker(10, 1)
+	slow, _, _ := b.Subscribe(0, nil)
+	fast, _, _ := b.Subscribe(0, nil)
+	defer fast.Close()
+	b.Publish("a", nil)
+	<-fast.C()
+	b.Publish("b", nil)
+	<-fast.C()
+	if !slow.Dropped() {
+		t.Fatalf("slow subscriber should have been dropped")
+	}
+	<-slow.C()
+	if _, ok := <-slow.C(); ok {
+		t.Fatalf("dropped subscription channel should be closed")
+	}
+	if fast.Dropped() {
+		t.Fatalf("fast subscriber should not have been dropped")
+	}
+	if n := b.Subscribers(); n != 1 {
+		t.Fatalf("expected 1 subscriber left, got %d", n)
+	}
+}
+
+func TestCloseEndsSubscriptions(t *testing.T) {
+	b := pubsub.NewBroker(10, 10)
+	sub, _, _ := b.Subscribe(0, nil)
+	b.Close()
+	if _, ok := <-sub.C(); ok {
+		t.Fatalf("subscription should be closed")
+	}
+	if _, err := b.Publish("a", nil); err != pubsub.ErrClosed {
+		t.Fatalf("expected ErrClosed, got %v", err)
+	}
+}
@@ -0,0 +1,102 @@
+// Package ratelimit implements per-key token buckets.
+//
+// Each key gets a bucket that holds up to a minute's worth of requests and
+// refills continuously. The rate of a bucket is looked up when it is created
+// and again every RateTTL, so a change of plan takes effect within that
+// time. Buckets are kept in memory, so every replica limits on its own.
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+const (
+	// RateTTL is how long a bucket keeps the rate it was given.
+	RateTTL = time.Minute
+
+	// idleTTL is how long an unused bucket is kept. A bucket idle for a
+	// minute is full anyway, so dropping it loses nothing.
+	idleTTL = 2 * time.Minute
+)
+
+type bucket struct {
+	tokens    float64
+	perMinute int
+	last      time.Time
+	rateAt    time.Time
+}
+
+// Limiter hands out tokens for keys.
+type Limiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastPrune time.Time
+}
+
+func New() *Limiter {
+	return &Limiter{buckets: map[string]*bucket{}}
+}
+
+// Allow takes a token from key's bucket. rate returns the requests per
+// minute for key; it is only called when the bucket needs a rate, and
+// outside the limiter's lock so it may be slow. If no token is available
+// Allow returns false and how long until one is.
+func (l *Limiter) Allow(key string, now time.Time, rate func() int) (bool, time.Duration) {
+	l.mu.Lock()
+	b, ok := l.buckets[key]
+	stale := !ok || now.Sub(b.rateAt) >= RateTTL
+	l.mu.Unlock()
+
+	perMinute := 0
+	if stale {
+		perMinute = rate()
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.prune(now)
+	b, ok = l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(perMinute), perMinute: perMinute, last: now, rateAt: now}
+		l.buckets[key] = b
+	} else if stale {
+		b.perMinute = perMinute
+		b.rateAt = now
+	}
+	perSecond := float64(b.perMinute) / 60
+	if elapsed := now.Sub(b.last).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(float64(b.perMinute), b.tokens+elapsed*perSecond)
+		b.last = now
+	}
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	if perSecond <= 0 {
+		return false, RateTTL
+	}
+	wait := time.Duration((1 - b.tokens) / perSecond * float64(time.Second))
+	return false, wait
+}
+
+// prune drops idle buckets, at most once per idleTTL. l.mu must be held.
+func (l *Limiter) prune(now time.Time) {
+	if now.Sub(l.lastPrune) < idleTTL {
+		return
+	}
+	l.lastPrune = now
+	for key, b := range l.buckets {
+		if now.Sub(b.last) >= idleTTL {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// Len returns how many buckets are held.
+func (l *Limiter) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.buckets)
+}
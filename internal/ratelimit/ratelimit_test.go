@@ -0,0 +1,65 @@
+package ratelimit_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/RemcoVeens/httpserver/internal/ratelimit"
+)
+
+func TestAllowRefills(t *testing.T) {
+	l := ratelimit.New()
+	now := time.Unix(1700000000, 0)
+	rate := func() int { return 60 }
+	for i := range 60 {
+		if ok, _ := l.Allow("a", now, rate); !ok {
+			t.Fatalf("request %d denied within the burst", i)
+		}
+	}
+	ok, wait := l.Allow("a", now, rate)
+	if ok || wait != time.Second {
+		t.Fatalf("61st request: ok=%v wait=%s, want denied for 1s", ok, wait)
+	}
+	if ok, _ := l.Allow("b", now, rate); !ok {
+		t.Errorf("keys should not share a bucket")
+	}
+	if ok, _ := l.Allow("a", now.Add(time.Second), rate); !ok {
+		t.Errorf("bucket did not refill")
+	}
+}
+
+func TestRateIsRefreshed(t *testing.T) {
+	l := ratelimit.New()
+	now := time.Unix(1700000000, 0)
+	calls := 0
+	perMinute := 1
+	rate := func() int { calls++; return perMinute }
+	l.Allow("a", now, rate)
+	if ok, _ := l.Allow("a", now, rate); ok {
+		t.Fatalf("second request allowed at 1/min")
+	}
+	if calls != 1 {
+		t.Errorf("rate looked up %d times, want once", calls)
+	}
+	perMinute = 120
+	later := now.Add(ratelimit.RateTTL)
+	l.Allow("a", later, rate)
+	if calls != 2 {
+		t.Errorf("rate not refreshed after RateTTL")
+	}
+	if ok, _ := l.Allow("a", later.Add(time.Second), rate); !ok {
+		t.Errorf("new rate not applied")
+	}
+}
+
+func TestIdleBucketsArePruned(t *testing.T) {
+	l := ratelimit.New()
+	now := time.Unix(1700000000, 0)
+	rate := func() int { return 10 }
+	l.Allow("a", now, rate)
+	l.Allow("b", now, rate)
+	l.Allow("c", now.Add(time.Hour), rate)
+	if n := l.Len(); n != 1 {
+		t.Errorf("%d buckets left, want 1", n)
+	}
+}
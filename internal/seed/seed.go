@@ -0,0 +1,304 @@
+// Package seed fills a database with fake users and chirps for development
+// and load testing.
+//
+// The data is meant to look like real use: a few users write most of the
+// chirps, chirps cluster in the daytime over the last weeks, some reply to
+// earlier ones and some carry hashtags or mention other users. Generate
+// returns the same data for the same seed.
+package seed
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/RemcoVeens/httpserver/internal/auth"
+	"github.com/RemcoVeens/httpserver/internal/database"
+	"github.com/RemcoVeens/httpserver/internal/entities"
+	"github.com/RemcoVeens/httpserver/internal/handles"
+	"github.com/google/uuid"
+)
+
+// MaxBody is the longest body generated, the length the free plan allows.
+const MaxBody = 140
+
+// batchSize is how many rows are inserted per transaction.
+const batchSize = 500
+
+// Options say how much to generate.
+type Options struct {
+	Users  int
+	Chirps int
+	// Seed makes the data reproducible.
+	Seed uint64
+	// Now is when the newest chirps are posted; Span is how far back the
+	// oldest go.
+	Now  time.Time
+	Span time.Duration
+	// Password is the password of every user.
+	Password string
+}
+
+// User is a generated user.
+type User struct {
+	Email       string
+	Handle      string
+	DisplayName string
+	Bio         string
+	CreatedAt   time.Time
+}
+
+// Chirp is a generated chirp. Author and ReplyTo index the users and the
+// chirps before it; ReplyTo is -1 for chirps that are not replies.
+type Chirp struct {
+	Author    int
+	ReplyTo   int
+	Body      string
+	CreatedAt time.Time
+}
+
+// Data is what Generate returns.
+type Data struct {
+	Users  []User
+	Chirps []Chirp
+}
+
+// Generate makes up the users and chirps of opts. Chirps are in the order
+// they were posted.
+func Generate(opts Options) Data {
+	g := &generator{r: rand.New(rand.NewPCG(opts.Seed, opts.Seed^0x9e3779b97f4a7c15)), opts: opts}
+	var d Data
+	taken := map[string]bool{}
+	for range opts.Users {
+		d.Users = append(d.Users, g.user(taken))
+	}
+	if len(d.Users) == 0 {
+		return d
+	}
+	times := make([]time.Time, opts.Chirps)
+	for i := range times {
+		times[i] = g.postedAt()
+	}
+	slices.SortFunc(times, time.Time.Compare)
+	for i, at := range times {
+		d.Chirps = append(d.Chirps, g.chirp(d, i, at))
+	}
+	return d
+}
+
+type generator struct {
+	r    *rand.Rand
+	opts Options
+}
+
+func (g *generator) pick(words []string) string {
+	return words[g.r.IntN(len(words))]
+}
+
+func (g *generator) user(taken map[string]bool) User {
+	first, last := g.pick(firstNames), g.pick(lastNames)
+	base := strings.ToLower(first)
+	switch g.r.IntN(4) {
+	case 0:
+		base += "_" + strings.ToLower(last)
+	case 1:
+		base += strings.ToLower(last[:1])
+	case 2:
+		base = strings.ToLower(first[:1] + last)
+	}
+	base = strings.Map(func(r rune) rune {
+		if handles.IsHandleRune(r) {
+			return r
+		}
+		return -1
+	}, base)
+	base = base[:min(len(base), handles.MaxLength-5)]
+	handle := base
+	for n := 2; taken[strings.ToLower(handle)] || handles.Validate(handle) != nil; n++ {
+		handle = fmt.Sprintf("%s%d", base, n)
+	}
+	taken[strings.ToLower(handle)] = true
+	u := User{
+		Email:       strings.ToLower(handle) + "@example.com",
+		Handle:      handle,
+		DisplayName: first + " " + last,
+		CreatedAt:   g.opts.Now.Add(-g.opts.Span - time.Duration(g.r.Int64N(int64(30*24*time.Hour)))),
+	}
+	if g.r.IntN(5) > 0 {
+		u.Bio = fmt.Sprintf(g.pick(bios), g.pick(jobs), g.pick(topics))
+	}
+	return u
+}
+
+// postedAt picks a time in the span, more often in the daytime.
+func (g *generator) postedAt() time.Time {
+	for {
+		at := g.opts.Now.Add(-time.Duration(g.r.Int64N(int64(g.opts.Span) + 1)))
+		hour := at.Hour()
+		if hour >= 8 && hour < 23 || g.r.IntN(4) == 0 {
+			return at
+		}
+	}
+}
+
+// author picks a user so that the first users write most of the chirps.
+func (g *generator) author(users int) int {
+	return int(float64(users) * math.Pow(g.r.Float64(), 2.5))
+}
+
+func (g *generator) chirp(d Data, i int, at time.Time) Chirp {
+	c := Chirp{Author: g.author(len(d.Users)), ReplyTo: -1, CreatedAt: at}
+	var parts []string
+	if i > 0 && g.r.IntN(6) == 0 {
+		// Replies go to recent chirps, and name who they answer.
+		c.ReplyTo = max(0, i-1-g.r.IntN(min(i, 50)))
+		parts = append(parts, "@"+d.Users[d.Chirps[c.ReplyTo].Author].Handle, g.pick(replies))
+	} else {
+		parts = append(parts, fmt.Sprintf(g.pick(posts), g.pick(topics)))
+	}
+	if g.r.IntN(10) == 0 {
+		parts = append(parts, "cc @"+d.Users[g.r.IntN(len(d.Users))].Handle)
+	}
+	for range g.r.IntN(3) {
+		parts = append(parts, "#"+g.pick(hashtags))
+	}
+	// Drop what does not fit rather than cut a word or handle in half.
+	for len(strings.Join(parts, " ")) > MaxBody && len(parts) > 1 {
+		parts = parts[:len(parts)-1]
+	}
+	c.Body = strings.Join(parts, " ")
+	if len(c.Body) > MaxBody {
+		c.Body = c.Body[:MaxBody]
+	}
+	return c
+}
+
+// Stats say what Run inserted.
+type Stats struct {
+	Users  int
+	Chirps int
+	// Skipped counts users whose email or handle was taken, and their
+	// chirps.
+	Skipped int
+}
+
+// Run generates the data of opts and inserts it into db. Chirps get their
+// hashtags and mentions, but no notifications, events or webhook
+// deliveries are made for them.
+func Run(ctx context.Context, db *sql.DB, opts Options) (Stats, error) {
+	d := Generate(opts)
+	var stats Stats
+	hash, err := auth.HashPassword(opts.Password)
+	if err != nil {
+		return stats, fmt.Errorf("could not hash password: %w", err)
+	}
+
+	userIDs := make([]uuid.UUID, len(d.Users))
+	for start := 0; start < len(d.Users); start += batchSize {
+		err := database.RunInTx(ctx, db, func(q *database.Queries) error {
+			for i := start; i < min(start+batchSize, len(d.Users)); i++ {
+				u := d.Users[i]
+				user, err := q.SeedUser(ctx, database.SeedUserParams{
+					CreatedAt:      u.CreatedAt,
+					Email:          u.Email,
+					HashedPassword: hash,
+					Handle:         u.Handle,
+					DisplayName:    u.DisplayName,
+					Bio:            u.Bio,
+				})
+				if errors.Is(err, sql.ErrNoRows) {
+					stats.Skipped++
+					continue
+				}
+				if err != nil {
+					return fmt.Errorf("could not insert user %s: %w", u.Handle, err)
+				}
+				userIDs[i] = user.ID
+				stats.Users++
+			}
+			return nil
+		})
+		if err != nil {
+			return stats, err
+		}
+	}
+
+	handleIDs := make(map[string]uuid.UUID, len(d.Users))
+	for i, u := range d.Users {
+		if userIDs[i] != uuid.Nil {
+			handleIDs[strings.ToLower(u.Handle)] = userIDs[i]
+		}
+	}
+	chirpIDs := make([]uuid.UUID, len(d.Chirps))
+	for start := 0; start < len(d.Chirps); start += batchSize {
+		err := database.RunInTx(ctx, db, func(q *database.Queries) error {
+			for i := start; i < min(start+batchSize, len(d.Chirps)); i++ {
+				c := d.Chirps[i]
+				if userIDs[c.Author] == uuid.Nil {
+					stats.Skipped++
+					continue
+				}
+				var replyTo uuid.NullUUID
+				if c.ReplyTo >= 0 && chirpIDs[c.ReplyTo] != uuid.Nil {
+					replyTo = uuid.NullUUID{UUID: chirpIDs[c.ReplyTo], Valid: true}
+				}
+				chirp, err := q.SeedChirp(ctx, database.SeedChirpParams{
+					CreatedAt: c.CreatedAt,
+					Body:      c.Body,
+					UserID:    userIDs[c.Author],
+					ReplyToID: replyTo,
+				})
+				if err != nil {
+					return fmt.Errorf("could not insert chirp: %w", err)
+				}
+				chirpIDs[i] = chirp.ID
+				if err := addEntities(ctx, q, chirp, handleIDs); err != nil {
+					return err
+				}
+				stats.Chirps++
+			}
+			return nil
+		})
+		if err != nil {
+			return stats, err
+		}
+	}
+	return stats, nil
+}
+
+// addEntities stores the hashtags and mentions of chirp, as posting it
+// would.
+func addEntities(ctx context.Context, q *database.Queries, chirp database.Chirp, handleIDs map[string]uuid.UUID) error {
+	parsed := entities.Parse(chirp.Body)
+	for _, tag := range parsed.Tags() {
+		if err := q.AddChirpHashtag(ctx, database.AddChirpHashtagParams{
+			ChirpID:   chirp.ID,
+			Tag:       tag,
+			CreatedAt: chirp.CreatedAt,
+		}); err != nil {
+			return fmt.Errorf("could not store hashtag %q: %w", tag, err)
+		}
+	}
+	for _, m := range parsed.Mentions {
+		var userID uuid.NullUUID
+		if id, ok := handleIDs[m.Handle]; ok {
+			userID = uuid.NullUUID{UUID: id, Valid: true}
+		}
+		if err := q.AddChirpMention(ctx, database.AddChirpMentionParams{
+			ChirpID:     chirp.ID,
+			Handle:      m.Handle,
+			UserID:      userID,
+			StartOffset: int32(m.Start),
+			EndOffset:   int32(m.End),
+		}); err != nil {
+			return fmt.Errorf("could not store mention %q: %w", m.Handle, err)
+		}
+	}
+	return nil
+}
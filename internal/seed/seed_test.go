@@ -0,0 +1,95 @@
+package seed_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/RemcoVeens/httpserver/internal/entities"
+	"github.com/RemcoVeens/httpserver/internal/handles"
+	"github.com/RemcoVeens/httpserver/internal/seed"
+)
+
+func options(seedValue uint64) seed.Options {
+	return seed.Options{
+		Users:  300,
+		Chirps: 3000,
+		Seed:   seedValue,
+		Now:    time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC),
+		Span:   30 * 24 * time.Hour,
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	opts := options(1)
+	d := seed.Generate(opts)
+	if len(d.Users) != opts.Users || len(d.Chirps) != opts.Chirps {
+		t.Fatalf("got %d users and %d chirps", len(d.Users), len(d.Chirps))
+	}
+
+	emails := map[string]bool{}
+	handleSet := map[string]bool{}
+	for _, u := range d.Users {
+		if err := handles.Validate(u.Handle); err != nil {
+			t.Errorf("handle %q: %s", u.Handle, err)
+		}
+		if handleSet[strings.ToLower(u.Handle)] || emails[u.Email] {
+			t.Errorf("%s is taken twice", u.Handle)
+		}
+		handleSet[strings.ToLower(u.Handle)], emails[u.Email] = true, true
+		if !u.CreatedAt.Before(opts.Now.Add(-opts.Span)) {
+			t.Errorf("%s signed up at %s, after the first chirps", u.Handle, u.CreatedAt)
+		}
+	}
+
+	posts := make([]int, len(d.Users))
+	var replies, tagged int
+	for i, c := range d.Chirps {
+		if len(c.Body) == 0 || len(c.Body) > seed.MaxBody || strings.Contains(c.Body, "%!") {
+			t.Errorf("chirp %d has body %q", i, c.Body)
+		}
+		if c.CreatedAt.After(opts.Now) || c.CreatedAt.Before(opts.Now.Add(-opts.Span)) {
+			t.Errorf("chirp %d posted at %s, outside the span", i, c.CreatedAt)
+		}
+		if i > 0 && c.CreatedAt.Before(d.Chirps[i-1].CreatedAt) {
+			t.Errorf("chirp %d is out of order", i)
+		}
+		if c.ReplyTo >= i {
+			t.Errorf("chirp %d replies to a later chirp", i)
+		}
+		if c.ReplyTo >= 0 {
+			replies++
+		}
+		parsed := entities.Parse(c.Body)
+		if len(parsed.Hashtags) > 0 {
+			tagged++
+		}
+		for _, m := range parsed.Mentions {
+			if !handleSet[m.Handle] {
+				t.Errorf("chirp %d mentions unknown @%s", i, m.Handle)
+			}
+		}
+		posts[c.Author]++
+	}
+	if replies == 0 || tagged == 0 {
+		t.Errorf("%d replies and %d chirps with hashtags", replies, tagged)
+	}
+	// A tenth of the users write a good part of the chirps.
+	top := 0
+	for _, n := range posts[:len(posts)/10] {
+		top += n
+	}
+	if top < len(d.Chirps)/5 {
+		t.Errorf("the first tenth of the users wrote %d of %d chirps", top, len(d.Chirps))
+	}
+}
+
+func TestGenerateIsReproducible(t *testing.T) {
+	if !reflect.DeepEqual(seed.Generate(options(7)), seed.Generate(options(7))) {
+		t.Error("the same seed generated different data")
+	}
+	if reflect.DeepEqual(seed.Generate(options(7)), seed.Generate(options(8))) {
+		t.Error("different seeds generated the same data")
+	}
+}
@@ -0,0 +1,68 @@
+package seed
+
+// The words fake users and chirps are made of. Bios and posts are format
+// strings; posts take a topic.
+
+var firstNames = []string{
+	"Ada", "Amara", "Ben", "Carlos", "Chen", "Chloe", "Daan", "Dmitri", "Elif", "Emma",
+	"Fatima", "Finn", "Grace", "Hana", "Hugo", "Ines", "Isaac", "Jonas", "Kai", "Kofi",
+	"Lars", "Lea", "Lucia", "Mateo", "Maya", "Mei", "Milan", "Nadia", "Noah", "Nora",
+	"Olu", "Omar", "Priya", "Quinn", "Ravi", "Rosa", "Sam", "Sanne", "Sofia", "Tariq",
+	"Thijs", "Uma", "Victor", "Wen", "Yara", "Yusuf", "Zoe", "Zainab",
+}
+
+var lastNames = []string{
+	"Abe", "Adeyemi", "Bakker", "Berg", "Costa", "de Vries", "Dubois", "Eriksen", "Fischer", "Garcia",
+	"Haddad", "Hansen", "Ivanova", "Jansen", "Kim", "Kowalski", "Lopez", "Mensah", "Meyer", "Mori",
+	"Nakamura", "Nguyen", "Novak", "Okafor", "Ortiz", "Patel", "Peeters", "Rossi", "Santos", "Schmidt",
+	"Silva", "Singh", "Smit", "Tanaka", "Visser", "Wang", "Weber", "Williams", "Yilmaz", "Zhang",
+}
+
+var jobs = []string{
+	"Backend developer", "Barista", "Data engineer", "Designer", "Gardener", "Nurse",
+	"PhD student", "Photographer", "Product manager", "SRE", "Teacher", "Writer",
+}
+
+var bios = []string{
+	"%s. Mostly here for %s.",
+	"%s by day, %s by night.",
+	"%s who won't stop talking about %s.",
+	"%s | %s | opinions are my own",
+}
+
+var topics = []string{
+	"bread baking", "bouldering", "cycling", "board games", "coffee", "distributed systems",
+	"film photography", "gardening", "Go", "houseplants", "jazz", "mechanical keyboards",
+	"open source", "Postgres", "retro games", "running", "sourdough", "trains",
+}
+
+var posts = []string{
+	"Spent the whole afternoon on %s and regret nothing.",
+	"Hot take: %s is better in the winter.",
+	"Anyone have good recommendations for getting into %s?",
+	"Day 12 of learning %s. It's starting to click.",
+	"Today's small win: finally fixed my %s setup.",
+	"Can't believe how much time I lose to %s.",
+	"Reading up on %s before bed again.",
+	"Weekend plans: %s and nothing else.",
+	"%s meetup tonight, who's coming?",
+	"Nothing beats a quiet morning and some %s.",
+}
+
+var replies = []string{
+	"so true!",
+	"this made my day",
+	"wait, really? tell me more",
+	"hard disagree, but I respect it",
+	"same here, every single time",
+	"saving this for later",
+	"ha, I was just thinking about that",
+	"did you try turning it off and on again?",
+	"congrats, well deserved",
+	"source?",
+}
+
+var hashtags = []string{
+	"golang", "coffee", "weekend", "til", "100DaysOfCode", "photography",
+	"running", "postgres", "opensource", "mondays", "gardening", "music",
+}
@@ -0,0 +1,71 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Serve serves srv on l until ctx is done, then shuts srv down gracefully:
+// it stops accepting connections, calls the functions registered with
+// srv.RegisterOnShutdown so streams end, and waits up to timeout for the
+// requests in flight to finish. WebSockets are waited for too, which
+// srv.Shutdown alone does not do for hijacked connections.
+//
+// Serve returns nil after a clean shutdown. If requests are still running
+// at the deadline it closes their connections and returns an error.
+func Serve(ctx context.Context, srv *http.Server, l net.Listener, timeout time.Duration) error {
+	var active sync.WaitGroup
+	next := srv.Handler
+	if next == nil {
+		next = http.DefaultServeMux
+	}
+	srv.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		active.Add(1)
+		defer active.Done()
+		next.ServeHTTP(w, r)
+	})
+
+	served := make(chan error, 1)
+	go func() { served <- srv.Serve(l) }()
+	select {
+	case err := <-served:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdown, cancel := context.WithTimeout(context.WithoutCancel(ctx), timeout)
+	defer cancel()
+	err := srv.Shutdown(shutdown)
+	if err == nil {
+		err = wait(shutdown, &active)
+	}
+	if err != nil {
+		srv.Close()
+		return fmt.Errorf("requests still running after %s: %w", timeout, err)
+	}
+	if err := <-served; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// wait waits for wg until ctx is done, returning ctx's error if it is
+// first.
+func wait(ctx context.Context, wg *sync.WaitGroup) error {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
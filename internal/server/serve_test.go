@@ -0,0 +1,181 @@
+package server_test
+
+import (
+	"bufio"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/RemcoVeens/httpserver/internal/pubsub"
+	"github.com/RemcoVeens/httpserver/internal/server"
+)
+
+// serve runs server.Serve with srv on a free port until the returned cancel
+// is called. Serve's result arrives on the channel.
+func serve(t *testing.T, srv *http.Server, timeout time.Duration) (addr string, cancel context.CancelFunc, result <-chan error) {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	errc := make(chan error, 1)
+	go func() { errc <- server.Serve(ctx, srv, l, timeout) }()
+	return "http://" + l.Addr().String(), cancel, errc
+}
+
+// TestServeDrainsRequests starts shutting down while a request is being
+// handled, and checks that it still gets its response while new
+// connections are refused.
+func TestServeDrainsRequests(t *testing.T) {
+	started, release := make(chan struct{}), make(chan struct{})
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		io.WriteString(w, "done")
+	})}
+	addr, cancel, result := serve(t, srv, 10*time.Second)
+
+	type response struct {
+		body string
+		err  error
+	}
+	responses := make(chan response, 1)
+	go func() {
+		resp, err := http.Get(addr + "/slow")
+		if err != nil {
+			responses <- response{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		responses <- response{string(body), err}
+	}()
+	<-started
+	cancel()
+
+	// The listener is closed right away...
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		conn, err := net.Dial("tcp", strings.TrimPrefix(addr, "http://"))
+		if err != nil {
+			break
+		}
+		conn.Close()
+		if time.Now().After(deadline) {
+			t.Fatal("still accepting connections after shutdown began")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	// ...but the request in flight is waited for.
+	select {
+	case err := <-result:
+		t.Fatalf("Serve returned before the request finished: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	close(release)
+	if resp := <-responses; resp.err != nil || resp.body != "done" {
+		t.Errorf("got %q, %v; want the response of the request in flight", resp.body, resp.err)
+	}
+	if err := <-result; err != nil {
+		t.Errorf("Serve: %v", err)
+	}
+}
+
+// TestServeEndsStreams checks that closing the broker on shutdown ends the
+// chirp stream, so shutting down does not wait for its clients.
+func TestServeEndsStreams(t *testing.T) {
+	cfg := newConfig(t)
+	cfg.Broker = pubsub.NewBroker(0, 0)
+	srv := &http.Server{Handler: server.New(cfg, http.NotFoundHandler(), newValidator(t))}
+	srv.RegisterOnShutdown(cfg.Broker.Close)
+	addr, cancel, result := serve(t, srv, 10*time.Second)
+
+	resp, err := http.Get(addr + "/api/stream/chirps")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status %d", resp.StatusCode)
+	}
+	// The stream starts with its retry interval.
+	if line, err := bufio.NewReader(resp.Body).ReadString('\n'); err != nil || !strings.HasPrefix(line, "retry:") {
+		t.Fatalf("got %q, %v", line, err)
+	}
+
+	cancel()
+	select {
+	case err := <-result:
+		if err != nil {
+			t.Errorf("Serve: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("shutdown waited for the stream")
+	}
+	if _, err := io.ReadAll(resp.Body); err != nil {
+		t.Errorf("stream did not end cleanly: %v", err)
+	}
+}
+
+// TestServeWaitsForHijackedConnections checks that connections taken over
+// from the server, as WebSockets are, are waited for, and that Serve gives
+// up on them at the deadline.
+func TestServeWaitsForHijackedConnections(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		hold    time.Duration
+		timeout time.Duration
+		wantErr bool
+	}{
+		{"finishes", 200 * time.Millisecond, 10 * time.Second, false},
+		{"overruns", time.Hour, 200 * time.Millisecond, true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			hijacked, ended := make(chan struct{}), make(chan struct{})
+			t.Cleanup(func() { close(ended) })
+			srv := &http.Server{}
+			stopping := make(chan struct{})
+			srv.RegisterOnShutdown(func() { close(stopping) })
+			srv.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				conn, _, err := http.NewResponseController(w).Hijack()
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				defer conn.Close()
+				close(hijacked)
+				// Like the gateway, say goodbye when told to, which
+				// takes a while.
+				<-stopping
+				select {
+				case <-time.After(tt.hold):
+				case <-ended:
+				}
+			})
+			addr, cancel, result := serve(t, srv, tt.timeout)
+			conn, err := net.Dial("tcp", strings.TrimPrefix(addr, "http://"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer conn.Close()
+			io.WriteString(conn, "GET /ws HTTP/1.1\r\nHost: test\r\n\r\n")
+			<-hijacked
+
+			start := time.Now()
+			cancel()
+			err = <-result
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Serve: %v", err)
+			}
+			if !tt.wantErr && time.Since(start) < tt.hold {
+				t.Errorf("Serve returned after %s, before the connection was done", time.Since(start))
+			}
+		})
+	}
+}
@@ -0,0 +1,142 @@
+// Package server wires the handlers into the HTTP routes the service
+// exposes.
+package server
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/RemcoVeens/httpserver/internal/apierror"
+	"github.com/RemcoVeens/httpserver/internal/gateway"
+	"github.com/RemcoVeens/httpserver/internal/handlers"
+	"github.com/RemcoVeens/httpserver/internal/openapi"
+)
+
+// Route is a handler and the ServeMux pattern it is registered with.
+type Route struct {
+	Pattern string
+	Handler http.Handler
+}
+
+// Routes returns every route of the service. site serves the web app under
+// /app/.
+func Routes(cfg *handlers.APIConfig, site http.Handler) []Route {
+	return []Route{
+		{"GET /app/", http.StripPrefix("/app", cfg.MiddlewareMetricsInc(site))},
+		{"GET /{$}", http.HandlerFunc(cfg.TimelinePage)},
+		{"GET /c/{chirp_id}", http.HandlerFunc(cfg.ThreadPage)},
+		{"GET /u/{handle}", http.HandlerFunc(cfg.ProfilePage)},
+		{"GET /login", http.HandlerFunc(cfg.LoginPage)},
+		{"POST /login", http.HandlerFunc(cfg.Login)},
+		{"GET /signup", http.HandlerFunc(cfg.SignupPage)},
+		{"POST /signup", http.HandlerFunc(cfg.Signup)},
+		{"POST /logout", http.HandlerFunc(cfg.Logout)},
+		{"POST /chirps", http.HandlerFunc(cfg.PostChirp)},
+		{"GET /api/healthz", http.HandlerFunc(handlers.HealthCodeHandler)},
+		{"GET /api/openapi.json", http.HandlerFunc(openapi.ServeSpec)},
+		{"GET /api/docs", http.HandlerFunc(openapi.ServeDocs)},
+		{"POST /api/users", http.HandlerFunc(cfg.CreateUserHandel)},
+		{"PUT /api/users", http.HandlerFunc(cfg.UpdateUserHandel)},
+		{"GET /api/users/me", http.HandlerFunc(cfg.GetMe)},
+		{"PATCH /api/users/me", http.HandlerFunc(cfg.UpdateProfile)},
+		{"GET /api/users/{handle}", http.HandlerFunc(cfg.GetUserProfile)},
+		{"GET /api/users/me/subscriptions", http.HandlerFunc(cfg.GetSubscriptions)},
+		{"POST /api/login", http.HandlerFunc(cfg.LoginHandler)},
+		{"POST /api/users/{user_id}/block", http.HandlerFunc(cfg.BlockUser)},
+		{"DELETE /api/users/{user_id}/block", http.HandlerFunc(cfg.UnblockUser)},
+		{"POST /api/users/{user_id}/mute", http.HandlerFunc(cfg.MuteUser)},
+		{"DELETE /api/users/{user_id}/mute", http.HandlerFunc(cfg.UnmuteUser)},
+		{"GET /api/blocks", http.HandlerFunc(cfg.GetBlocks)},
+		{"GET /api/mutes", http.HandlerFunc(cfg.GetMutes)},
+		{"GET /api/chirps", http.HandlerFunc(cfg.GetChirps)},
+		{"GET /api/chirps/{chirp_id}", http.HandlerFunc(cfg.GetChirp)},
+		{"GET /api/chirps/{chirp_id}/replies", http.HandlerFunc(cfg.GetChirpReplies)},
+		{"DELETE /api/chirps/{chirp_id}", http.HandlerFunc(cfg.RemoveChirp)},
+		{"POST /api/refresh", http.HandlerFunc(cfg.RefreshHandel)},
+		{"POST /api/revoke", http.HandlerFunc(cfg.RevokeHandel)},
+		{"POST /api/chirps", http.HandlerFunc(cfg.Chirps)},
+		{"POST /api/media", http.HandlerFunc(cfg.UploadMedia)},
+		{"GET /api/media/{media_id}", http.HandlerFunc(cfg.GetMedia)},
+		{"GET /api/media/{media_id}/{variant}", http.HandlerFunc(cfg.GetMediaFile)},
+		{"GET /api/stream/chirps", http.HandlerFunc(cfg.StreamChirps)},
+		{"GET /api/ws", gateway.New(cfg.Broker, cfg.WebSocketUserID, cfg.Queries.GetHiddenAuthorIDs, cfg.ChirpVisibleTo)},
+		{"GET /api/hashtags/trending", http.HandlerFunc(cfg.GetTrendingHashtags)},
+		{"GET /api/hashtags/{tag}/chirps", http.HandlerFunc(cfg.GetHashtagChirps)},
+		{"GET /api/notifications", http.HandlerFunc(cfg.GetNotifications)},
+		{"POST /api/notifications/read", http.HandlerFunc(cfg.MarkNotificationsRead)},
+		{"GET /api/notifications/unread_count", http.HandlerFunc(cfg.GetUnreadNotificationCount)},
+		{"POST /api/conversations", http.HandlerFunc(cfg.StartConversation)},
+		{"GET /api/conversations", http.HandlerFunc(cfg.GetConversations)},
+		{"POST /api/conversations/{conversation_id}/messages", http.HandlerFunc(cfg.SendMessage)},
+		{"GET /api/conversations/{conversation_id}/messages", http.HandlerFunc(cfg.GetMessages)},
+		{"POST /api/webhooks", http.HandlerFunc(cfg.CreateWebhook)},
+		{"GET /api/webhooks", http.HandlerFunc(cfg.GetWebhooks)},
+		{"DELETE /api/webhooks/{webhook_id}", http.HandlerFunc(cfg.DeleteWebhook)},
+		{"GET /api/webhooks/{webhook_id}/deliveries", http.HandlerFunc(cfg.GetWebhookDeliveries)},
+		{"POST /api/webhooks/{webhook_id}/deliveries/{delivery_id}/replay", http.HandlerFunc(cfg.ReplayWebhookDelivery)},
+		{"GET /admin/metrics", http.HandlerFunc(cfg.HitCounterHandler)},
+		{"POST /admin/reset", http.HandlerFunc(cfg.Reset)},
+		{"GET /admin/inbound_events", http.HandlerFunc(cfg.GetInboundEvents)},
+		{"GET /admin/inbound_events/{event_id}", http.HandlerFunc(cfg.GetInboundEvent)},
+		{"POST /admin/inbound_events/{event_id}/replay", http.HandlerFunc(cfg.ReplayInboundEvent)},
+		{"POST /api/polka/webhooks", http.HandlerFunc(cfg.PolkaWebhook)},
+	}
+}
+
+// New returns the handler serving every route, with rate limiting, session
+// renewal and validation against the OpenAPI document by v in front.
+func New(cfg *handlers.APIConfig, site http.Handler, v *openapi.Validator) http.Handler {
+	mux := http.NewServeMux()
+	for _, route := range Routes(cfg, site) {
+		mux.Handle(route.Pattern, route.Handler)
+	}
+	return cfg.MiddlewareRateLimit(cfg.MiddlewareSession(v.Middleware(problemFallback(mux))))
+}
+
+// problemFallback answers API requests mux has no route for with a problem
+// instead of ServeMux's plain text 404 and 405 responses.
+func problemFallback(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h, pattern := mux.Handler(r)
+		if pattern != "" || !isAPI(r.URL.Path) {
+			mux.ServeHTTP(w, r)
+			return
+		}
+		rec := &statusRecorder{header: http.Header{}}
+		h.ServeHTTP(rec, r)
+		switch rec.status {
+		case http.StatusNotFound:
+			apierror.Write(w, r, apierror.New(apierror.NotFound, "no such endpoint"))
+		case http.StatusMethodNotAllowed:
+			w.Header()["Allow"] = rec.header["Allow"]
+			apierror.Write(w, r, apierror.Errorf(apierror.MethodNotAllowed, "%s is not allowed here", r.Method))
+		default:
+			// Redirects to the canonical path.
+			mux.ServeHTTP(w, r)
+		}
+	})
+}
+
+func isAPI(path string) bool {
+	return strings.HasPrefix(path, "/api/") || strings.HasPrefix(path, "/admin/")
+}
+
+// statusRecorder captures the status and headers of ServeMux's fallback
+// handlers, dropping their body.
+type statusRecorder struct {
+	header http.Header
+	status int
+}
+
+func (s *statusRecorder) Header() http.Header { return s.header }
+
+func (s *statusRecorder) WriteHeader(status int) {
+	if s.status == 0 {
+		s.status = status
+	}
+}
+
+func (s *statusRecorder) Write(b []byte) (int, error) {
+	s.WriteHeader(http.StatusOK)
+	return len(b), nil
+}
@@ -0,0 +1,262 @@
+package server_test
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/RemcoVeens/httpserver/internal/apierror"
+	"github.com/RemcoVeens/httpserver/internal/auth"
+	"github.com/RemcoVeens/httpserver/internal/database"
+	"github.com/RemcoVeens/httpserver/internal/entitlements"
+	"github.com/RemcoVeens/httpserver/internal/handlers"
+	"github.com/RemcoVeens/httpserver/internal/openapi"
+	"github.com/RemcoVeens/httpserver/internal/ratelimit"
+	"github.com/RemcoVeens/httpserver/internal/server"
+	"github.com/google/uuid"
+)
+
+// secret stands in for what database errors can carry, such as hosts,
+// credentials and row contents. It must never reach a client.
+const secret = "s3cr3t-dsn-password"
+
+const jwtSecret = "test-secret"
+
+// brokenDriver fails every connection, so each handler that touches the
+// database runs into an internal error.
+type brokenDriver struct{}
+
+func (brokenDriver) Open(string) (driver.Conn, error) {
+	return nil, errors.New("dial postgres://chirpy:" + secret + "@db: connection refused")
+}
+
+func init() {
+	sql.Register("broken", brokenDriver{})
+}
+
+func newConfig(t *testing.T) *handlers.APIConfig {
+	db, err := sql.Open("broken", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return &handlers.APIConfig{
+		DB:           db,
+		Queries:      database.New(db),
+		Secret:       jwtSecret,
+		PolkaKey:     "polka-key",
+		Entitlements: entitlements.Default(),
+		Limiter:      ratelimit.New(),
+	}
+}
+
+// newValidator returns a validator that fails t on responses that do not
+// match the document.
+func newValidator(t *testing.T) *openapi.Validator {
+	doc, err := openapi.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := openapi.NewValidator(doc, openapi.Spec())
+	if err != nil {
+		t.Fatal(err)
+	}
+	v.Responses = true
+	v.Report = func(r *http.Request, err error) { t.Errorf("%s", err) }
+	return v
+}
+
+// bodies are valid request bodies, so requests get past validation and
+// into the handlers. Other routes are sent {}.
+var bodies = map[string]string{
+	"POST /api/users":         `{"email":"a@b.c","password":"pw"}`,
+	"PUT /api/users":          `{"email":"a@b.c","password":"pw"}`,
+	"POST /api/login":         `{"email":"a@b.c","password":"pw"}`,
+	"POST /api/chirps":        `{"body":"hi"}`,
+	"POST /api/conversations": `{"member_ids":["` + uuid.NewString() + `"]}`,
+	"POST /api/conversations/{conversation_id}/messages": `{"body":"hi"}`,
+	"POST /api/webhooks":       `{"url":"https://example.com","event_types":["chirp.created"]}`,
+	"POST /api/polka/webhooks": `{"event":"user.upgraded","data":{}}`,
+}
+
+var pathParam = regexp.MustCompile(`\{([a-zA-Z_]+)\}`)
+
+// path fills in the wildcards of a route pattern.
+func path(pattern string) string {
+	_, p, _ := strings.Cut(pattern, " ")
+	p = strings.TrimSuffix(p, "{$}")
+	return pathParam.ReplaceAllStringFunc(p, func(w string) string {
+		switch w {
+		case "{handle}":
+			return "someone"
+		case "{tag}":
+			return "golang"
+		case "{variant}":
+			return "thumb"
+		case "{event_id}", "{delivery_id}":
+			return "1"
+		}
+		return uuid.NewString()
+	})
+}
+
+func checkProblem(t *testing.T, name string, rec *httptest.ResponseRecorder) apierror.Problem {
+	t.Helper()
+	body := rec.Body.String()
+	if strings.Contains(body, secret) {
+		t.Errorf("%s: response leaks the database error: %s", name, body)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != apierror.ContentType {
+		t.Errorf("%s: Content-Type %q, want %q (body %q)", name, ct, apierror.ContentType, body)
+	}
+	p, err := apierror.Parse(rec.Body.Bytes())
+	if err != nil {
+		t.Errorf("%s: %s in %q", name, err, body)
+		return p
+	}
+	if p.Status != rec.Code {
+		t.Errorf("%s: problem status %d, response status %d", name, p.Status, rec.Code)
+	}
+	if !slices.Contains(apierror.Codes(), p.Code) {
+		t.Errorf("%s: unknown code %q", name, p.Code)
+	}
+	if p.Code.Status() != rec.Code {
+		t.Errorf("%s: code %q comes with %d, got %d", name, p.Code, p.Code.Status(), rec.Code)
+	}
+	return p
+}
+
+// TestErrorsAreProblems calls every API route against a database that is
+// down, anonymously and as a user, and checks that every error comes back
+// as a problem without the cause, as the OpenAPI document says.
+func TestErrorsAreProblems(t *testing.T) {
+	cfg := newConfig(t)
+	h := server.New(cfg, http.NotFoundHandler(), newValidator(t))
+	for _, route := range server.Routes(cfg, http.NotFoundHandler()) {
+		method, _, _ := strings.Cut(route.Pattern, " ")
+		p := path(route.Pattern)
+		if !strings.HasPrefix(p, "/api/") && !strings.HasPrefix(p, "/admin/") {
+			continue
+		}
+		failed := false
+		for _, as := range []string{"anonymous", "user"} {
+			body, ok := bodies[route.Pattern]
+			if !ok {
+				body = "{}"
+			}
+			req := httptest.NewRequest(method, p, strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			if route.Pattern == "POST /api/media" {
+				req.Header.Set("Content-Type", "multipart/form-data; boundary=x")
+			}
+			if as == "user" {
+				// A user per request keeps clear of the rate limit.
+				token, err := auth.MakeJWT(uuid.New(), jwtSecret, 3600)
+				if err != nil {
+					t.Fatal(err)
+				}
+				req.Header.Set("Authorization", "Bearer "+token)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			if rec.Code < 400 {
+				continue
+			}
+			failed = true
+			checkProblem(t, route.Pattern+" as "+as, rec)
+		}
+		if !failed && !slices.Contains(noDatabase, route.Pattern) {
+			t.Errorf("%s succeeded without a database", route.Pattern)
+		}
+	}
+}
+
+// noDatabase are the API routes that work without a database.
+var noDatabase = []string{
+	"GET /api/healthz",
+	"GET /api/openapi.json",
+	"GET /api/docs",
+	"GET /admin/metrics",
+}
+
+// TestRoutesAreDocumented checks that the OpenAPI document describes
+// exactly the routes the server has.
+func TestRoutesAreDocumented(t *testing.T) {
+	doc, err := openapi.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	documented := map[string]bool{}
+	for _, op := range doc.Operations {
+		documented[op.Pattern()] = true
+	}
+	served := map[string]bool{}
+	for _, route := range server.Routes(newConfig(t), http.NotFoundHandler()) {
+		served[route.Pattern] = true
+		if !documented[route.Pattern] {
+			t.Errorf("%s is not in openapi.json", route.Pattern)
+		}
+	}
+	for pattern := range documented {
+		if !served[pattern] {
+			t.Errorf("openapi.json describes %s, which is not served", pattern)
+		}
+	}
+}
+
+func TestInvalidRequestsAreRejected(t *testing.T) {
+	h := server.New(newConfig(t), http.NotFoundHandler(), newValidator(t))
+	for _, tc := range []struct {
+		method, path, contentType, body string
+		code                            apierror.Code
+	}{
+		{"POST", "/api/users", "application/json", `{"email":"a@b.c"}`, apierror.InvalidRequest},
+		{"POST", "/api/users", "application/json", `{"email":`, apierror.InvalidJSON},
+		{"POST", "/api/users", "text/plain", `hi`, apierror.UnsupportedMediaType},
+		{"GET", "/api/notifications?limit=1000", "", "", apierror.InvalidRequest},
+		{"GET", "/admin/inbound_events/abc", "", "", apierror.InvalidRequest},
+	} {
+		name := tc.method + " " + tc.path
+		req := httptest.NewRequest(tc.method, tc.path, strings.NewReader(tc.body))
+		if tc.contentType != "" {
+			req.Header.Set("Content-Type", tc.contentType)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if p := checkProblem(t, name, rec); p.Code != tc.code {
+			t.Errorf("%s: code %q, want %q (%s)", name, p.Code, tc.code, p.Detail)
+		}
+	}
+}
+
+func TestUnknownRoutes(t *testing.T) {
+	h := server.New(newConfig(t), http.NotFoundHandler(), newValidator(t))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/api/nope", nil))
+	if p := checkProblem(t, "GET /api/nope", rec); p.Code != apierror.NotFound {
+		t.Errorf("GET /api/nope: code %q, want %q", p.Code, apierror.NotFound)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("PUT", "/api/healthz", nil))
+	if p := checkProblem(t, "PUT /api/healthz", rec); p.Code != apierror.MethodNotAllowed {
+		t.Errorf("PUT /api/healthz: code %q, want %q", p.Code, apierror.MethodNotAllowed)
+	}
+	if allow := rec.Header().Get("Allow"); !strings.Contains(allow, "GET") {
+		t.Errorf("PUT /api/healthz: Allow %q, want GET", allow)
+	}
+
+	// Outside the API, ServeMux answers as usual.
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/nope", nil))
+	if rec.Code != 404 || rec.Header().Get("Content-Type") == apierror.ContentType {
+		t.Errorf("GET /nope: %d %s, want a plain 404", rec.Code, rec.Header().Get("Content-Type"))
+	}
+}
@@ -0,0 +1,191 @@
+// Package static serves the web app's files: index.html and everything
+// under assets/. Nothing else in the file system it is given is served, so
+// it can be handed the repository root in development.
+//
+// Assets are also served under a fingerprinted name, such as
+// assets/logo.3f2a9c1b.png, that changes whenever their content does; those
+// are cached forever. Every response carries a strong ETag. With
+// SPAFallback, page requests for unknown paths get index.html so a
+// single-page app can route them.
+package static
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	index     = "index.html"
+	assetsDir = "assets"
+
+	immutable  = "public, max-age=31536000, immutable"
+	revalidate = "no-cache"
+)
+
+// Options configure a Server.
+type Options struct {
+	// Dev re-reads files on every request, so edits show up without a
+	// restart, and makes Path return plain names.
+	Dev bool
+	// SPAFallback serves index.html for page requests that match no file.
+	SPAFallback bool
+}
+
+type file struct {
+	data        []byte
+	etag        string
+	contentType string
+	cache       string
+}
+
+// Server serves the files of a file system. It implements http.Handler and
+// expects paths relative to where it is mounted, e.g. "/assets/logo.png".
+type Server struct {
+	fsys fs.FS
+	opts Options
+
+	mu      sync.Mutex
+	files   map[string]*file
+	renamed map[string]string
+}
+
+// New reads index.html and assets/ from fsys.
+func New(fsys fs.FS, opts Options) (*Server, error) {
+	s := &Server{fsys: fsys, opts: opts}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// load indexes the servable files of s.fsys.
+func (s *Server) load() error {
+	files := map[string]*file{}
+	renamed := map[string]string{}
+	add := func(name string, cache string) (*file, error) {
+		data, err := fs.ReadFile(s.fsys, name)
+		if err != nil {
+			return nil, err
+		}
+		sum := sha256.Sum256(data)
+		ct := mime.TypeByExtension(path.Ext(name))
+		if ct == "" {
+			ct = http.DetectContentType(data)
+		}
+		f := &file{
+			data:        data,
+			etag:        `"` + hex.EncodeToString(sum[:16]) + `"`,
+			contentType: ct,
+			cache:       cache,
+		}
+		files[name] = f
+		return f, nil
+	}
+	if _, err := add(index, revalidate); err != nil {
+		return fmt.Errorf("could not read %s: %w", index, err)
+	}
+	err := fs.WalkDir(s.fsys, assetsDir, func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if strings.HasPrefix(d.Name(), ".") && name != assetsDir {
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+		f, err := add(name, revalidate)
+		if err != nil {
+			return err
+		}
+		if s.opts.Dev {
+			return nil
+		}
+		fingerprinted := fingerprint(name, f.etag[1:9])
+		files[fingerprinted] = &file{data: f.data, etag: f.etag, contentType: f.contentType, cache: immutable}
+		renamed[name] = fingerprinted
+		return nil
+	})
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("could not read %s: %w", assetsDir, err)
+	}
+	s.mu.Lock()
+	s.files, s.renamed = files, renamed
+	s.mu.Unlock()
+	return nil
+}
+
+// fingerprint inserts hash before name's extension.
+func fingerprint(name, hash string) string {
+	ext := path.Ext(name)
+	return strings.TrimSuffix(name, ext) + "." + hash + ext
+}
+
+// Path returns the path, relative to where s is mounted, to serve the
+// asset name (e.g. "logo.png") from. Outside dev mode it is fingerprinted.
+func (s *Server) Path(name string) string {
+	full := path.Join(assetsDir, name)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if fingerprinted, ok := s.renamed[full]; ok {
+		return "/" + fingerprinted
+	}
+	return "/" + full
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		w.WriteHeader(405)
+		return
+	}
+	if s.opts.Dev {
+		if err := s.load(); err != nil {
+			w.WriteHeader(500)
+			w.Write(fmt.Appendf([]byte(""), "could not load files: %s", err))
+			return
+		}
+	}
+	name := strings.TrimPrefix(path.Clean("/"+r.URL.Path), "/")
+	if name == "" {
+		name = index
+	}
+	s.mu.Lock()
+	f, ok := s.files[name]
+	if !ok && s.opts.SPAFallback && isPage(r, name) {
+		f, ok = s.files[index]
+	}
+	s.mu.Unlock()
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	h := w.Header()
+	h.Set("Content-Type", f.contentType)
+	h.Set("ETag", f.etag)
+	h.Set("Cache-Control", f.cache)
+	h.Set("X-Content-Type-Options", "nosniff")
+	// ServeContent answers If-None-Match, HEAD and Range requests.
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(f.data))
+}
+
+// isPage reports whether r asks for a page rather than a missing asset.
+func isPage(r *http.Request, name string) bool {
+	if name == assetsDir || strings.HasPrefix(name, assetsDir+"/") || path.Ext(name) != "" {
+		return false
+	}
+	return strings.Contains(r.Header.Get("Accept"), "text/html")
+}
@@ -0,0 +1,130 @@
+package static_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/RemcoVeens/httpserver/internal/static"
+)
+
+func testFS() fstest.MapFS {
+	return fstest.MapFS{
+		"index.html":          {Data: []byte("<html>chirpy</html>")},
+		"assets/app.css":      {Data: []byte("body{}")},
+		"assets/img/logo.png": {Data: []byte("\x89PNG\r\n\x1a\n")},
+		"assets/.secret":      {Data: []byte("hidden")},
+		".env":                {Data: []byte("SECRET=hunter2")},
+		"go.mod":              {Data: []byte("module chirpy")},
+		"sql/schema/001.sql":  {Data: []byte("CREATE TABLE")},
+	}
+}
+
+func get(t *testing.T, h http.Handler, path string, header ...string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestServesOnlyTheApp(t *testing.T) {
+	s, err := static.New(testFS(), static.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, path := range []string{"/.env", "/go.mod", "/sql/schema/001.sql", "/assets/.secret", "/../.env", "/assets/../.env"} {
+		if rec := get(t, s, path); rec.Code != 404 {
+			t.Errorf("%s: %d, want 404", path, rec.Code)
+		}
+	}
+	rec := get(t, s, "/")
+	if rec.Code != 200 || rec.Body.String() != "<html>chirpy</html>" {
+		t.Fatalf("/: %d %q", rec.Code, rec.Body)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "text/html; charset=utf-8" {
+		t.Errorf("/ has Content-Type %q", ct)
+	}
+	if rec := get(t, s, "/assets/app.css"); rec.Header().Get("Content-Type") != "text/css; charset=utf-8" {
+		t.Errorf("css has Content-Type %q", rec.Header().Get("Content-Type"))
+	}
+}
+
+func TestFingerprintsAndETags(t *testing.T) {
+	s, err := static.New(testFS(), static.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := s.Path("img/logo.png")
+	if !strings.HasPrefix(path, "/assets/img/logo.") || !strings.HasSuffix(path, ".png") || path == "/assets/img/logo.png" {
+		t.Fatalf("Path = %q, want a fingerprinted name", path)
+	}
+	rec := get(t, s, path)
+	if rec.Code != 200 || rec.Header().Get("Content-Type") != "image/png" {
+		t.Fatalf("%s: %d %q", path, rec.Code, rec.Header().Get("Content-Type"))
+	}
+	if cc := rec.Header().Get("Cache-Control"); !strings.Contains(cc, "immutable") {
+		t.Errorf("fingerprinted asset has Cache-Control %q", cc)
+	}
+	etag := rec.Header().Get("ETag")
+	if !strings.HasPrefix(etag, `"`) {
+		t.Errorf("ETag %q is not strong", etag)
+	}
+	if rec := get(t, s, path, "If-None-Match", etag); rec.Code != 304 {
+		t.Errorf("If-None-Match: %d, want 304", rec.Code)
+	}
+	plain := get(t, s, "/assets/img/logo.png")
+	if plain.Code != 200 || plain.Header().Get("Cache-Control") != "no-cache" || plain.Header().Get("ETag") != etag {
+		t.Errorf("plain name: %d %q %q", plain.Code, plain.Header().Get("Cache-Control"), plain.Header().Get("ETag"))
+	}
+}
+
+func TestSPAFallback(t *testing.T) {
+	s, err := static.New(testFS(), static.Options{SPAFallback: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec := get(t, s, "/chirps/123", "Accept", "text/html"); rec.Code != 200 || rec.Body.String() != "<html>chirpy</html>" {
+		t.Errorf("page route: %d %q", rec.Code, rec.Body)
+	}
+	for _, path := range []string{"/assets/missing.js", "/missing.js", "/.env"} {
+		if rec := get(t, s, path, "Accept", "text/html"); rec.Code != 404 {
+			t.Errorf("%s: %d, want 404", path, rec.Code)
+		}
+	}
+	if rec := get(t, s, "/chirps/123", "Accept", "application/json"); rec.Code != 404 {
+		t.Errorf("non-page request fell back: %d", rec.Code)
+	}
+}
+
+func TestDevReloads(t *testing.T) {
+	fsys := testFS()
+	s, err := static.New(fsys, static.Options{Dev: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path := s.Path("app.css"); path != "/assets/app.css" {
+		t.Errorf("dev Path = %q", path)
+	}
+	fsys["assets/app.css"] = &fstest.MapFile{Data: []byte("body{color:red}")}
+	if rec := get(t, s, "/assets/app.css"); rec.Body.String() != "body{color:red}" {
+		t.Errorf("edit not picked up: %q", rec.Body)
+	}
+}
+
+func TestMethods(t *testing.T) {
+	s, err := static.New(testFS(), static.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", nil))
+	if rec.Code != 405 {
+		t.Errorf("POST: %d, want 405", rec.Code)
+	}
+}
@@ -0,0 +1,173 @@
+// Package subscriptions keeps Chirpy Red subscriptions in step with Polka.
+//
+// Each Polka event moves the user's current subscription between states:
+//
+//	user.upgraded, subscription.renewed  -> active (created if needed)
+//	payment.failed                       -> past_due
+//	subscription.cancelled               -> cancelled
+//	user.downgraded                      -> expired, immediately
+//
+// Active, past_due and cancelled subscriptions grant Chirpy Red until their
+// period ends; a subscription without a period end never lapses on its own.
+// Expire marks lapsed subscriptions expired. users.is_chirpy_red is
+// recomputed from the table after every change, and user.upgraded or
+// user.downgraded is emitted when it flips.
+package subscriptions
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/RemcoVeens/httpserver/internal/database"
+	"github.com/RemcoVeens/httpserver/internal/events"
+	"github.com/RemcoVeens/httpserver/internal/pubsub"
+	"github.com/google/uuid"
+)
+
+// Polka event types.
+const (
+	UserUpgraded          = "user.upgraded"
+	UserDowngraded        = "user.downgraded"
+	SubscriptionRenewed   = "subscription.renewed"
+	SubscriptionCancelled = "subscription.cancelled"
+	PaymentFailed         = "payment.failed"
+)
+
+const (
+	// DefaultPlan is used when Polka does not name one.
+	DefaultPlan = "chirpy_red"
+
+	// ExpiryInterval is how often RunExpiry looks for lapsed subscriptions.
+	ExpiryInterval = 24 * time.Hour
+)
+
+var ErrPeriodEndRequired = errors.New("period_end is required")
+
+// Event is a Polka subscription event.
+type Event struct {
+	Type      string
+	UserID    uuid.UUID
+	Plan      string
+	PeriodEnd sql.NullTime
+}
+
+// IsEvent reports whether t is a subscription event.
+func IsEvent(t string) bool {
+	switch t {
+	case UserUpgraded, UserDowngraded, SubscriptionRenewed, SubscriptionCancelled, PaymentFailed:
+		return true
+	}
+	return false
+}
+
+// Validate checks e and fills in defaults. A renewal must say until when.
+func (e *Event) Validate() error {
+	if !IsEvent(e.Type) {
+		return fmt.Errorf("unknown subscription event %q", e.Type)
+	}
+	if e.Type == SubscriptionRenewed && !e.PeriodEnd.Valid {
+		return ErrPeriodEndRequired
+	}
+	if e.Plan == "" {
+		e.Plan = DefaultPlan
+	}
+	if e.PeriodEnd.Valid {
+		e.PeriodEnd.Time = e.PeriodEnd.Time.UTC()
+	}
+	return nil
+}
+
+// Apply records e. q must be bound to a transaction. Events about a user
+// without a current subscription, other than upgrades and renewals, change
+// nothing.
+func Apply(ctx context.Context, q *database.Queries, e Event) error {
+	if err := e.Validate(); err != nil {
+		return err
+	}
+	user, err := q.GetUserFromId(ctx, e.UserID)
+	if err != nil {
+		return fmt.Errorf("could not get user: %w", err)
+	}
+	switch e.Type {
+	case UserUpgraded, SubscriptionRenewed:
+		_, err = q.UpsertSubscription(ctx, database.UpsertSubscriptionParams{
+			UserID:           e.UserID,
+			Plan:             e.Plan,
+			CurrentPeriodEnd: e.PeriodEnd,
+		})
+	case PaymentFailed:
+		_, err = q.MarkSubscriptionPastDue(ctx, e.UserID)
+	case SubscriptionCancelled:
+		_, err = q.CancelSubscription(ctx, e.UserID)
+	case UserDowngraded:
+		_, err = q.EndSubscription(ctx, e.UserID)
+	}
+	if err != nil {
+		return fmt.Errorf("could not apply %s: %w", e.Type, err)
+	}
+	return sync(ctx, q, e.UserID, user.IsChirpyRed)
+}
+
+// sync recomputes is_chirpy_red and emits the change, if any.
+func sync(ctx context.Context, q *database.Queries, userID uuid.UUID, wasRed bool) error {
+	red, err := q.SyncChirpyRed(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("could not update chirpy red: %w", err)
+	}
+	switch {
+	case red && !wasRed:
+		return events.Emit(ctx, q, pubsub.UserUpgraded, userID, map[string]any{"user_id": userID})
+	case !red && wasRed:
+		return events.Emit(ctx, q, pubsub.UserDowngraded, userID, map[string]any{"user_id": userID})
+	}
+	return nil
+}
+
+// Expire marks every lapsed subscription expired and downgrades its user,
+// in one transaction. It returns how many subscriptions expired. Running it
+// on several replicas at once is harmless.
+func Expire(ctx context.Context, db *sql.DB) (int, error) {
+	var n int
+	err := database.RunInTx(ctx, db, func(q *database.Queries) error {
+		userIDs, err := q.ExpireSubscriptions(ctx)
+		if err != nil {
+			return fmt.Errorf("could not expire subscriptions: %w", err)
+		}
+		n = len(userIDs)
+		for _, id := range userIDs {
+			user, err := q.GetUserFromId(ctx, id)
+			if err != nil {
+				return fmt.Errorf("could not get user: %w", err)
+			}
+			if err := sync(ctx, q, id, user.IsChirpyRed); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return n, err
+}
+
+// RunExpiry calls Expire now and then every ExpiryInterval until ctx is
+// cancelled.
+func RunExpiry(ctx context.Context, db *sql.DB) {
+	t := time.NewTicker(ExpiryInterval)
+	defer t.Stop()
+	for {
+		n, err := Expire(ctx, db)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("could not expire subscriptions: %s", err)
+		} else if n > 0 {
+			log.Printf("expired %d subscriptions", n)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
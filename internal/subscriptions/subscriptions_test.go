@@ -0,0 +1,92 @@
+package subscriptions_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/RemcoVeens/httpserver/internal/database"
+	"github.com/RemcoVeens/httpserver/internal/subscriptions"
+	"github.com/google/uuid"
+	_ "github.com/lib/pq"
+)
+
+func TestValidate(t *testing.T) {
+	e := subscriptions.Event{Type: subscriptions.UserUpgraded}
+	if err := e.Validate(); err != nil || e.Plan != subscriptions.DefaultPlan {
+		t.Errorf("upgrade without plan: %v, plan %q", err, e.Plan)
+	}
+
+	e = subscriptions.Event{Type: subscriptions.SubscriptionRenewed, Plan: "yearly"}
+	if err := e.Validate(); !errors.Is(err, subscriptions.ErrPeriodEndRequired) {
+		t.Errorf("renewal without period end: got %v", err)
+	}
+
+	end := time.Date(2030, 1, 1, 12, 0, 0, 0, time.FixedZone("CET", 3600))
+	e = subscriptions.Event{Type: subscriptions.SubscriptionRenewed, Plan: "yearly", PeriodEnd: sql.NullTime{Time: end, Valid: true}}
+	if err := e.Validate(); err != nil || e.Plan != "yearly" || e.PeriodEnd.Time.Location() != time.UTC || !e.PeriodEnd.Time.Equal(end) {
+		t.Errorf("renewal: %v %+v", err, e)
+	}
+
+	e = subscriptions.Event{Type: "user.exploded"}
+	if err := e.Validate(); err == nil {
+		t.Errorf("unknown event accepted")
+	}
+}
+
+func TestIsEvent(t *testing.T) {
+	for _, ev := range []string{"user.upgraded", "user.downgraded", "subscription.renewed", "subscription.cancelled", "payment.failed"} {
+		if !subscriptions.IsEvent(ev) {
+			t.Errorf("IsEvent(%q) = false", ev)
+		}
+	}
+	if subscriptions.IsEvent("chirp.created") {
+		t.Errorf("IsEvent(chirp.created) = true")
+	}
+}
+
+// TestUpgradeKeepsPeriodEnd checks against a real database that an upgrade
+// without a period end does not clear the one the subscription has, which
+// would keep it from ever lapsing. Point CHIRPY_TEST_DB_URL at a migrated,
+// disposable database to run it.
+func TestUpgradeKeepsPeriodEnd(t *testing.T) {
+	dbURL := os.Getenv("CHIRPY_TEST_DB_URL")
+	if dbURL == "" {
+		t.Skip("CHIRPY_TEST_DB_URL is not set")
+	}
+	db, err := sql.Open("postgres", dbURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	ctx := context.Background()
+	q := database.New(db)
+
+	handle := "u" + strings.ReplaceAll(uuid.NewString(), "-", "")[:12]
+	user, err := q.CreateUser(ctx, database.CreateUserParams{Email: handle + "@example.com", HashedPassword: "x", Handle: handle})
+	if err != nil {
+		t.Fatal(err)
+	}
+	end := time.Now().Add(30 * 24 * time.Hour).UTC().Truncate(time.Second)
+	for _, e := range []subscriptions.Event{
+		{Type: subscriptions.SubscriptionRenewed, UserID: user.ID, PeriodEnd: sql.NullTime{Time: end, Valid: true}},
+		{Type: subscriptions.UserUpgraded, UserID: user.ID},
+	} {
+		if err := database.RunInTx(ctx, db, func(q *database.Queries) error {
+			return subscriptions.Apply(ctx, q, e)
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	subs, err := q.GetSubscriptionsForUser(ctx, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(subs) != 1 || !subs[0].CurrentPeriodEnd.Valid || !subs[0].CurrentPeriodEnd.Time.Equal(end) {
+		t.Errorf("got %+v, want one subscription ending at %s", subs, end)
+	}
+}
@@ -0,0 +1,72 @@
+package web
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+)
+
+// CSRF protection uses the double-submit pattern: a random token is kept in
+// a cookie and must be echoed by every unsafe request, in the CSRFField
+// form field or the CSRFHeader header. Another site can make a browser send
+// the cookie but can not read it to echo it.
+const (
+	CSRFCookie = "chirpy_csrf"
+	CSRFField  = "csrf_token"
+	CSRFHeader = "X-CSRF-Token"
+
+	csrfTokenBytes = 32
+)
+
+// CSRFToken returns the caller's CSRF token, issuing one in a cookie if it
+// has none. The cookie is readable by scripts so they can echo it in
+// CSRFHeader.
+func CSRFToken(w http.ResponseWriter, r *http.Request, secure bool) string {
+	if c, err := r.Cookie(CSRFCookie); err == nil && validCSRFToken(c.Value) {
+		return c.Value
+	}
+	b := make([]byte, csrfTokenBytes)
+	rand.Read(b)
+	token := base64.RawURLEncoding.EncodeToString(b)
+	http.SetCookie(w, &http.Cookie{
+		Name:     CSRFCookie,
+		Value:    token,
+		Path:     "/",
+		Secure:   secure,
+		SameSite: http.SameSiteLaxMode,
+	})
+	// Requests made while rendering this response see the new token too.
+	r.AddCookie(&http.Cookie{Name: CSRFCookie, Value: token})
+	return token
+}
+
+// CheckCSRF reports whether r echoes its CSRF cookie, in CSRFHeader or the
+// CSRFField form field. Form fields are only read from url-encoded and
+// multipart bodies.
+func CheckCSRF(r *http.Request) bool {
+	sent := r.Header.Get(CSRFHeader)
+	if sent == "" {
+		sent = r.PostFormValue(CSRFField)
+	}
+	return matchesCSRFCookie(r, sent)
+}
+
+// CheckCSRFHeader is CheckCSRF for API requests: only CSRFHeader is looked
+// at, so the body is left for the handler to read.
+func CheckCSRFHeader(r *http.Request) bool {
+	return matchesCSRFCookie(r, r.Header.Get(CSRFHeader))
+}
+
+func matchesCSRFCookie(r *http.Request, sent string) bool {
+	c, err := r.Cookie(CSRFCookie)
+	if err != nil || !validCSRFToken(c.Value) {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(sent), []byte(c.Value)) == 1
+}
+
+func validCSRFToken(token string) bool {
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	return err == nil && len(b) == csrfTokenBytes
+}
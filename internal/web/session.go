@@ -0,0 +1,65 @@
+package web
+
+import (
+	"net/http"
+	"time"
+)
+
+// A browser session is kept in two cookies: AccessCookie holds a short-lived
+// access token (a JWT) and SessionCookie the refresh token it is renewed
+// with. Both are HttpOnly and SameSite=Lax, so scripts can not read them
+// and other sites can not use them for unsafe requests.
+const (
+	AccessCookie  = "chirpy_access"
+	SessionCookie = "chirpy_session"
+)
+
+// SetSession stores a session's tokens, each until it expires.
+func SetSession(w http.ResponseWriter, access string, accessExpires time.Time, refresh string, refreshExpires time.Time, secure bool) {
+	SetAccess(w, access, accessExpires, secure)
+	http.SetCookie(w, sessionCookie(SessionCookie, refresh, refreshExpires, secure))
+}
+
+// SetAccess replaces only the access token of a session.
+func SetAccess(w http.ResponseWriter, access string, expires time.Time, secure bool) {
+	http.SetCookie(w, sessionCookie(AccessCookie, access, expires, secure))
+}
+
+// ClearSession removes the session cookies.
+func ClearSession(w http.ResponseWriter, secure bool) {
+	for _, name := range []string{AccessCookie, SessionCookie} {
+		c := sessionCookie(name, "", time.Time{}, secure)
+		c.MaxAge = -1
+		http.SetCookie(w, c)
+	}
+}
+
+func sessionCookie(name, value string, expires time.Time, secure bool) *http.Cookie {
+	return &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/",
+		Expires:  expires,
+		HttpOnly: true,
+		Secure:   secure,
+		SameSite: http.SameSiteLaxMode,
+	}
+}
+
+// AccessToken returns the access token of r's session, or "".
+func AccessToken(r *http.Request) string {
+	return cookieValue(r, AccessCookie)
+}
+
+// SessionToken returns the refresh token of r's session, or "".
+func SessionToken(r *http.Request) string {
+	return cookieValue(r, SessionCookie)
+}
+
+func cookieValue(r *http.Request, name string) string {
+	c, err := r.Cookie(name)
+	if err != nil {
+		return ""
+	}
+	return c.Value
+}
@@ -0,0 +1,140 @@
+// Package web renders Chirpy's server-side pages with html/template.
+//
+// Every page is templates/layout.html around the page's own "content"
+// template, with the partials of templates/partials.html available. Pages
+// work without JavaScript: every action is a plain form post protected by
+// a CSRF token (see CSRFToken). assets/app.js only adds conveniences.
+package web
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/RemcoVeens/httpserver/internal/entities"
+)
+
+//go:embed templates
+var templates embed.FS
+
+// Pages lists the templates Renderer can render.
+var Pages = []string{"timeline", "thread", "profile", "login", "signup", "error"}
+
+// Page is what every template is executed with.
+type Page struct {
+	Title string
+	// Viewer is the logged-in user, or nil.
+	Viewer *Viewer
+	// CSRF is the token forms must send back in CSRFField.
+	CSRF string
+	// Error is shown above the content.
+	Error string
+	// Compose shows the chirp form when set.
+	Compose *Compose
+	// Data is specific to the page.
+	Data any
+}
+
+type Viewer struct {
+	Handle      string
+	DisplayName string
+}
+
+// Compose configures the chirp form.
+type Compose struct {
+	MaxLength int
+	// ReplyTo is the id of the chirp replied to, if any.
+	ReplyTo string
+}
+
+// Renderer renders pages.
+type Renderer struct {
+	pages map[string]*template.Template
+}
+
+// NewRenderer parses the templates. asset returns the URL of a file under
+// assets/, such as "app.css".
+func NewRenderer(asset func(name string) string) (*Renderer, error) {
+	funcs := template.FuncMap{
+		"asset": asset,
+		"body":  Body,
+		"since": func(t time.Time) string { return Since(t, time.Now()) },
+		"iso":   func(t time.Time) string { return t.UTC().Format(time.RFC3339) },
+	}
+	r := &Renderer{pages: map[string]*template.Template{}}
+	for _, page := range Pages {
+		t, err := template.New(page).Funcs(funcs).ParseFS(templates,
+			"templates/layout.html",
+			"templates/partials.html",
+			"templates/"+page+".html",
+		)
+		if err != nil {
+			return nil, fmt.Errorf("could not parse %s: %w", page, err)
+		}
+		r.pages[page] = t
+	}
+	return r, nil
+}
+
+// Render writes page with status. The page is rendered in full before
+// anything is written, so a template error still yields a clean 500.
+func (r *Renderer) Render(w http.ResponseWriter, status int, page string, data Page) {
+	t, ok := r.pages[page]
+	if !ok {
+		w.WriteHeader(500)
+		w.Write(fmt.Appendf([]byte(""), "unknown page %q", page))
+		return
+	}
+	var buf bytes.Buffer
+	if err := t.ExecuteTemplate(&buf, "layout", data); err != nil {
+		w.WriteHeader(500)
+		w.Write(fmt.Appendf([]byte(""), "could not render %s: %s", page, err))
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.WriteHeader(status)
+	w.Write(buf.Bytes())
+}
+
+// Body renders a chirp body as HTML, linking mentions to profiles. All text
+// is escaped.
+func Body(body string, e entities.Entities) template.HTML {
+	runes := []rune(body)
+	var sb strings.Builder
+	last := 0
+	for _, m := range e.Mentions {
+		if m.Start < last || m.End > len(runes) || m.Start >= m.End {
+			continue
+		}
+		sb.WriteString(template.HTMLEscapeString(string(runes[last:m.Start])))
+		fmt.Fprintf(&sb, `<a href="/u/%s">%s</a>`,
+			template.HTMLEscapeString(m.Handle),
+			template.HTMLEscapeString(string(runes[m.Start:m.End])))
+		last = m.End
+	}
+	sb.WriteString(template.HTMLEscapeString(string(runes[last:])))
+	return template.HTML(sb.String())
+}
+
+// Since formats how long before now t was, e.g. "5m" or "Jan 2".
+func Since(t, now time.Time) string {
+	d := now.Sub(t)
+	switch {
+	case d < time.Minute:
+		return "now"
+	case d < time.Hour:
+		return fmt.Sprintf("%dm", int(d/time.Minute))
+	case d < 24*time.Hour:
+		return fmt.Sprintf("%dh", int(d/time.Hour))
+	case t.Year() == now.Year():
+		return t.Format("Jan 2")
+	default:
+		return t.Format("Jan 2, 2006")
+	}
+}
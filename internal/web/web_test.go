@@ -0,0 +1,228 @@
+package web_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/RemcoVeens/httpserver/internal/entities"
+	"github.com/RemcoVeens/httpserver/internal/web"
+	"github.com/google/uuid"
+)
+
+// The templates only need these fields of the chirps they are given.
+type author struct{ Handle, DisplayName, AvatarUrl string }
+
+type variant struct {
+	Name, Url     string
+	Width, Height int32
+}
+
+type media struct {
+	Url, Status   string
+	Width, Height *int32
+	Variants      []variant
+}
+
+type chirp struct {
+	ID        uuid.UUID
+	Body      string
+	Entities  entities.Entities
+	Author    author
+	CreatedAt time.Time
+	ReplyToID uuid.NullUUID
+	Media     []media
+}
+
+func newChirp(body string) chirp {
+	return chirp{
+		ID:        uuid.New(),
+		Body:      body,
+		Entities:  entities.Parse(body),
+		Author:    author{Handle: "alice", DisplayName: "Alice"},
+		CreatedAt: time.Now().Add(-5 * time.Minute),
+	}
+}
+
+func render(t *testing.T, page string, data web.Page) string {
+	t.Helper()
+	r, err := web.NewRenderer(func(name string) string { return "/app/assets/" + name })
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	r.Render(rec, 200, page, data)
+	if rec.Code != 200 {
+		t.Fatalf("%s: %d %s", page, rec.Code, rec.Body)
+	}
+	return rec.Body.String()
+}
+
+func TestPagesRender(t *testing.T) {
+	w, h := int32(640), int32(480)
+	withMedia := newChirp("look at this @bob")
+	withMedia.Media = []media{
+		{Url: "/api/media/1/original", Status: "ready", Width: &w, Height: &h, Variants: []variant{{Name: "small", Url: "/api/media/1/small", Width: 160}}},
+		{Url: "/api/media/2/original", Status: "pending"},
+	}
+	reply := newChirp("me too")
+	reply.ReplyToID = uuid.NullUUID{UUID: withMedia.ID, Valid: true}
+	viewer := &web.Viewer{Handle: "alice"}
+
+	pages := map[string]web.Page{
+		"timeline": {Viewer: viewer, CSRF: "tok", Compose: &web.Compose{MaxLength: 140}, Data: map[string]any{"Chirps": []chirp{withMedia, reply}}},
+		"thread": {Title: "Chirp", Data: map[string]any{
+			"Ancestors": []chirp{newChirp("first")},
+			"Chirp":     withMedia,
+			"Replies":   []chirp{reply},
+		}},
+		"profile": {Title: "Alice", Data: map[string]any{
+			"Profile": map[string]any{"Handle": "alice", "DisplayName": "Alice", "Bio": "hi", "AvatarUrl": "", "IsChirpyRed": true, "CreatedAt": time.Now()},
+			"Chirps":  []chirp{},
+		}},
+		"login":  {CSRF: "tok", Data: map[string]any{"Email": "a@example.com", "Next": "/c/1"}},
+		"signup": {CSRF: "tok", Data: map[string]any{"Email": "", "Handle": ""}},
+		"error":  {Title: "Not found", Data: "no such chirp"},
+	}
+	for _, page := range web.Pages {
+		html := render(t, page, pages[page])
+		if !strings.Contains(html, `href="/app/assets/app.css"`) {
+			t.Errorf("%s: stylesheet missing", page)
+		}
+	}
+
+	html := render(t, "timeline", pages["timeline"])
+	for _, want := range []string{
+		`<a href="/u/bob">@bob</a>`,
+		`width="640" height="480"`,
+		`srcset="/api/media/1/small 160w"`,
+		`Image pending`,
+		`name="csrf_token" value="tok"`,
+		`maxlength="140"`,
+		`href="/c/` + withMedia.ID.String() + `">In reply to`,
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("timeline lacks %s", want)
+		}
+	}
+	if html := render(t, "thread", pages["thread"]); strings.Contains(html, `action="/chirps"`) {
+		t.Errorf("compose form shown to a logged-out viewer")
+	}
+}
+
+func TestBodyEscapes(t *testing.T) {
+	body := `<script>alert(1)</script> hi @bob & "friends" 😀 @carol`
+	got := string(web.Body(body, entities.Parse(body)))
+	want := `&lt;script&gt;alert(1)&lt;/script&gt; hi <a href="/u/bob">@bob</a> &amp; &#34;friends&#34; 😀 <a href="/u/carol">@carol</a>`
+	if got != want {
+		t.Errorf("Body:\n got  %s\n want %s", got, want)
+	}
+}
+
+func TestSince(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC)
+	cases := map[time.Duration]string{
+		10 * time.Second:     "now",
+		5 * time.Minute:      "5m",
+		3 * time.Hour:        "3h",
+		48 * time.Hour:       "Mar 8",
+		400 * 24 * time.Hour: "Feb 3, 2025",
+	}
+	for ago, want := range cases {
+		if got := web.Since(now.Add(-ago), now); got != want {
+			t.Errorf("Since(-%s) = %q, want %q", ago, got, want)
+		}
+	}
+}
+
+func TestCSRF(t *testing.T) {
+	rec := httptest.NewRecorder()
+	token := web.CSRFToken(rec, httptest.NewRequest("GET", "/", nil), true)
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != web.CSRFCookie || cookies[0].Value != token || !cookies[0].Secure {
+		t.Fatalf("unexpected cookies %v", cookies)
+	}
+
+	post := func(cookie, field, header string) *http.Request {
+		form := url.Values{}
+		if field != "" {
+			form.Set(web.CSRFField, field)
+		}
+		r := httptest.NewRequest("POST", "/chirps", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if cookie != "" {
+			r.AddCookie(&http.Cookie{Name: web.CSRFCookie, Value: cookie})
+		}
+		if header != "" {
+			r.Header.Set(web.CSRFHeader, header)
+		}
+		return r
+	}
+	other := web.CSRFToken(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil), false)
+	if !web.CheckCSRF(post(token, token, "")) {
+		t.Errorf("matching form field rejected")
+	}
+	if !web.CheckCSRF(post(token, "", token)) {
+		t.Errorf("matching header rejected")
+	}
+	for name, r := range map[string]*http.Request{
+		"no cookie":     post("", token, ""),
+		"no token":      post(token, "", ""),
+		"wrong token":   post(token, other, ""),
+		"forged cookie": post("x", "x", ""),
+	} {
+		if web.CheckCSRF(r) {
+			t.Errorf("%s: accepted", name)
+		}
+	}
+
+	if !web.CheckCSRFHeader(post(token, "", token)) {
+		t.Errorf("CheckCSRFHeader: matching header rejected")
+	}
+	if web.CheckCSRFHeader(post(token, token, "")) {
+		t.Errorf("CheckCSRFHeader: form field accepted")
+	}
+
+	// An existing token is kept.
+	r := httptest.NewRequest("GET", "/", nil)
+	r.AddCookie(&http.Cookie{Name: web.CSRFCookie, Value: token})
+	rec = httptest.NewRecorder()
+	if got := web.CSRFToken(rec, r, true); got != token || len(rec.Result().Cookies()) != 0 {
+		t.Errorf("token not reused")
+	}
+}
+
+func TestSessionCookies(t *testing.T) {
+	rec := httptest.NewRecorder()
+	expires := time.Now().Add(time.Hour).Truncate(time.Second)
+	web.SetSession(rec, "access", expires, "refresh", expires.Add(time.Hour), true)
+	r := httptest.NewRequest("GET", "/", nil)
+	for _, c := range rec.Result().Cookies() {
+		if !c.HttpOnly || !c.Secure || c.SameSite != http.SameSiteLaxMode || c.Path != "/" {
+			t.Errorf("%s: insecure cookie %v", c.Name, c)
+		}
+		r.AddCookie(c)
+	}
+	if got := web.AccessToken(r); got != "access" {
+		t.Errorf("AccessToken = %q", got)
+	}
+	if got := web.SessionToken(r); got != "refresh" {
+		t.Errorf("SessionToken = %q", got)
+	}
+
+	rec = httptest.NewRecorder()
+	web.ClearSession(rec, true)
+	cleared := map[string]bool{}
+	for _, c := range rec.Result().Cookies() {
+		cleared[c.Name] = c.MaxAge < 0 && c.Value == ""
+	}
+	if !cleared[web.AccessCookie] || !cleared[web.SessionCookie] {
+		t.Errorf("ClearSession left cookies: %v", rec.Result().Cookies())
+	}
+	if got := web.SessionToken(httptest.NewRequest("GET", "/", nil)); got != "" {
+		t.Errorf("SessionToken without cookie = %q", got)
+	}
+}
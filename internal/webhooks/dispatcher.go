@@ -0,0 +1,254 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/RemcoVeens/httpserver/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	// MaxAttempts is how often a delivery is tried before it is marked
+	// failed.
+	MaxAttempts = 10
+
+	// RequestTimeout bounds a single delivery attempt.
+	RequestTimeout = 10 * time.Second
+
+	minBackoff = 30 * time.Second
+	maxBackoff = 6 * time.Hour
+
+	// leaseDuration is how long a claimed delivery is hidden from other
+	// dispatchers. A replica that dies mid-attempt leaves the delivery to be
+	// retried once the lease runs out.
+	leaseDuration = 2 * RequestTimeout
+
+	defaultPollInterval = 5 * time.Second
+	defaultBatchSize    = 20
+)
+
+// Backoff returns how long to wait after the given failed attempt, counting
+// from 1: 30s, 1m, 2m, ... capped at six hours.
+func Backoff(attempt int) time.Duration {
+	if attempt < 1 {
+		attempt = 1
+	}
+	d := minBackoff
+	for i := 1; i < attempt && d < maxBackoff; i++ {
+		d *= 2
+	}
+	return min(d, maxBackoff)
+}
+
+// Store is the part of database.Queries a Dispatcher needs.
+type Store interface {
+	ClaimWebhookDeliveries(ctx context.Context, arg database.ClaimWebhookDeliveriesParams) ([]database.WebhookDelivery, error)
+	GetWebhook(ctx context.Context, id uuid.UUID) (database.Webhook, error)
+	RecordWebhookAttempt(ctx context.Context, arg database.RecordWebhookAttemptParams) error
+	MarkWebhookDelivered(ctx context.Context, id int64) error
+	RescheduleWebhookDelivery(ctx context.Context, arg database.RescheduleWebhookDeliveryParams) error
+	FailWebhookDelivery(ctx context.Context, arg database.FailWebhookDeliveryParams) error
+}
+
+// Body is the JSON body POSTed to endpoints.
+type Body struct {
+	ID        int64           `json:"id"`
+	Type      string          `json:"type"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// Dispatcher sends queued deliveries. Several dispatchers may run against
+// the same database; each delivery is claimed by one of them at a time.
+type Dispatcher struct {
+	store        Store
+	client       *http.Client
+	PollInterval time.Duration
+	BatchSize    int
+}
+
+func NewDispatcher(store Store, client *http.Client) *Dispatcher {
+	return &Dispatcher{
+		store:        store,
+		client:       client,
+		PollInterval: defaultPollInterval,
+		BatchSize:    defaultBatchSize,
+	}
+}
+
+// Run delivers due deliveries until ctx is cancelled. Deliveries it has
+// claimed are attempted first, so they need not wait out their lease.
+func (d *Dispatcher) Run(ctx context.Context) {
+	t := time.NewTicker(d.PollInterval)
+	defer t.Stop()
+	for {
+		for ctx.Err() == nil {
+			n, err := d.RunOnce(context.WithoutCancel(ctx))
+			if err != nil {
+				log.Printf("could not dispatch webhooks: %s", err)
+			}
+			if n < d.BatchSize {
+				break
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+		}
+	}
+}
+
+// RunOnce claims one batch of due deliveries and attempts them
+// concurrently. It returns how many were claimed.
+func (d *Dispatcher) RunOnce(ctx context.Context) (int, error) {
+	deliveries, err := d.store.ClaimWebhookDeliveries(ctx, database.ClaimWebhookDeliveriesParams{
+		LeaseSeconds: int32(leaseDuration / time.Second),
+		BatchSize:    int32(d.BatchSize),
+	})
+	if err != nil {
+		return 0, fmt.Errorf("could not claim deliveries: %w", err)
+	}
+	var wg sync.WaitGroup
+	for _, dl := range deliveries {
+		wg.Go(func() {
+			if err := d.attempt(ctx, dl); err != nil {
+				log.Printf("could not record webhook delivery %d: %s", dl.ID, err)
+			}
+		})
+	}
+	wg.Wait()
+	return len(deliveries), nil
+}
+
+// attempt sends one claimed delivery and records the outcome. dl.Attempts
+// already counts this attempt.
+func (d *Dispatcher) attempt(ctx context.Context, dl database.WebhookDelivery) error {
+	hook, err := d.store.GetWebhook(ctx, dl.WebhookID)
+	if errors.Is(err, sql.ErrNoRows) {
+		// The endpoint was deleted after the claim; its deliveries went
+		// with it.
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("could not get webhook: %w", err)
+	}
+	start := time.Now()
+	status, sendErr := d.send(ctx, hook, dl)
+	attempt := database.RecordWebhookAttemptParams{
+		DeliveryID: dl.ID,
+		DurationMs: int32(time.Since(start) / time.Millisecond),
+	}
+	if status != 0 {
+		attempt.StatusCode = sql.NullInt32{Int32: int32(status), Valid: true}
+	}
+	if sendErr != nil {
+		attempt.Error = sendErr.Error()
+	}
+	if err := d.store.RecordWebhookAttempt(ctx, attempt); err != nil {
+		return fmt.Errorf("could not record attempt: %w", err)
+	}
+	if sendErr == nil {
+		return d.store.MarkWebhookDelivered(ctx, dl.ID)
+	}
+	lastErr := sql.NullString{String: sendErr.Error(), Valid: true}
+	if dl.Attempts >= MaxAttempts {
+		return d.store.FailWebhookDelivery(ctx, database.FailWebhookDeliveryParams{
+			ID:        dl.ID,
+			LastError: lastErr,
+		})
+	}
+	wait := Backoff(int(dl.Attempts))
+	wait += rand.N(wait / 10)
+	return d.store.RescheduleWebhookDelivery(ctx, database.RescheduleWebhookDeliveryParams{
+		ID:            dl.ID,
+		NextAttemptAt: time.Now().Add(wait),
+		LastError:     lastErr,
+	})
+}
+
+// send POSTs a signed delivery. Any 2xx response counts as delivered; the
+// returned status is 0 if no response was received.
+func (d *Dispatcher) send(ctx context.Context, hook database.Webhook, dl database.WebhookDelivery) (int, error) {
+	body, err := json.Marshal(Body{
+		ID:        dl.ID,
+		Type:      dl.EventType,
+		CreatedAt: dl.CreatedAt,
+		Data:      dl.Payload,
+	})
+	if err != nil {
+		return 0, fmt.Errorf("could not marshal body: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(ctx, RequestTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.Url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Chirpy-Webhooks/1")
+	req.Header.Set(EventHeader, dl.EventType)
+	req.Header.Set(DeliveryHeader, strconv.FormatInt(dl.ID, 10))
+	req.Header.Set(SignatureHeader, Sign(hook.Secret, time.Now(), body))
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("endpoint responded %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// NewClient returns the HTTP client deliveries are sent with. It does not
+// follow redirects and, unless allowPrivate is set, refuses to connect to
+// loopback, private and link-local addresses so endpoints can not be used
+// to reach internal services.
+func NewClient(allowPrivate bool) *http.Client {
+	dialer := &net.Dialer{Timeout: RequestTimeout}
+	if !allowPrivate {
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			ip := net.ParseIP(host)
+			if ip == nil || !isPublic(ip) {
+				return fmt.Errorf("refusing to connect to non-public address %s", host)
+			}
+			return nil
+		}
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = dialer.DialContext
+	transport.Proxy = nil
+	return &http.Client{
+		Transport: transport,
+		Timeout:   RequestTimeout,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+func isPublic(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast())
+}
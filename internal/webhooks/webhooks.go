@@ -0,0 +1,154 @@
+// Package webhooks delivers Chirpy events to endpoints registered by users.
+//
+// Deliveries are queued by events.Emit in the transaction that makes the
+// change, so a delivery exists if and only if the change was committed. A
+// Dispatcher on every replica claims due deliveries, POSTs them and retries
+// failures with exponential backoff until MaxAttempts, after which the
+// delivery is marked failed and can be replayed by its owner.
+//
+// # Signatures
+//
+// Every request carries a Chirpy-Signature header of the form
+//
+//	t=<unix seconds>,v1=<hex HMAC-SHA256>
+//
+// where the HMAC is computed with the endpoint's secret over the timestamp,
+// a dot and the raw request body. Receivers should recompute it, compare in
+// constant time and reject timestamps too far from their own clock.
+package webhooks
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/RemcoVeens/httpserver/internal/pubsub"
+)
+
+const (
+	SignatureHeader = "Chirpy-Signature"
+	EventHeader     = "Chirpy-Event"
+	DeliveryHeader  = "Chirpy-Delivery"
+
+	// MaxURLLength bounds registered endpoint URLs.
+	MaxURLLength = 2048
+
+	secretPrefix = "whsec_"
+)
+
+// EventTypes are the events endpoints can subscribe to.
+var EventTypes = []string{
+	pubsub.ChirpCreated,
+	pubsub.ChirpDeleted,
+	pubsub.UserCreated,
+	pubsub.UserUpgraded,
+	pubsub.UserDowngraded,
+}
+
+var (
+	ErrNoEventTypes     = errors.New("at least one event type is required")
+	ErrInvalidSignature = errors.New("invalid signature")
+)
+
+// IsEventType reports whether endpoints can subscribe to t.
+func IsEventType(t string) bool {
+	return slices.Contains(EventTypes, t)
+}
+
+// ValidateEventTypes checks that types is non-empty and only holds known
+// event types. It returns the types sorted and without duplicates.
+func ValidateEventTypes(types []string) ([]string, error) {
+	if len(types) == 0 {
+		return nil, ErrNoEventTypes
+	}
+	out := slices.Clone(types)
+	slices.Sort(out)
+	out = slices.Compact(out)
+	for _, t := range out {
+		if !IsEventType(t) {
+			return nil, fmt.Errorf("unknown event type %q", t)
+		}
+	}
+	return out, nil
+}
+
+// ValidateURL checks that raw is an absolute http(s) URL without
+// credentials. Where the URL may point is enforced when delivering; see
+// NewClient.
+func ValidateURL(raw string) error {
+	if len(raw) > MaxURLLength {
+		return fmt.Errorf("must be at most %d bytes", MaxURLLength)
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("must be an absolute http(s) URL")
+	}
+	if u.User != nil {
+		return errors.New("must not contain credentials")
+	}
+	return nil
+}
+
+// GenerateSecret returns a new random signing secret.
+func GenerateSecret() string {
+	b := make([]byte, 32)
+	rand.Read(b)
+	return secretPrefix + hex.EncodeToString(b)
+}
+
+// Sign returns the Chirpy-Signature header value for body sent at ts.
+func Sign(secret string, ts time.Time, body []byte) string {
+	t := strconv.FormatInt(ts.Unix(), 10)
+	return "t=" + t + ",v1=" + hex.EncodeToString(mac(secret, t, body))
+}
+
+// Verify checks a Chirpy-Signature header against body. Signatures older or
+// newer than tolerance relative to now are rejected.
+func Verify(secret, header string, body []byte, now time.Time, tolerance time.Duration) error {
+	var t string
+	var sigs [][]byte
+	for part := range strings.SplitSeq(header, ",") {
+		k, v, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch k {
+		case "t":
+			t = v
+		case "v1":
+			if sig, err := hex.DecodeString(v); err == nil {
+				sigs = append(sigs, sig)
+			}
+		}
+	}
+	sec, err := strconv.ParseInt(t, 10, 64)
+	if err != nil || len(sigs) == 0 {
+		return ErrInvalidSignature
+	}
+	if d := now.Sub(time.Unix(sec, 0)); d > tolerance || d < -tolerance {
+		return fmt.Errorf("%w: timestamp outside tolerance", ErrInvalidSignature)
+	}
+	want := mac(secret, t, body)
+	for _, sig := range sigs {
+		if hmac.Equal(sig, want) {
+			return nil
+		}
+	}
+	return ErrInvalidSignature
+}
+
+func mac(secret, t string, body []byte) []byte {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(t))
+	h.Write([]byte("."))
+	h.Write(body)
+	return h.Sum(nil)
+}
@@ -0,0 +1,231 @@
+package webhooks_test
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/RemcoVeens/httpserver/internal/database"
+	"github.com/RemcoVeens/httpserver/internal/pubsub"
+	"github.com/RemcoVeens/httpserver/internal/webhooks"
+	"github.com/google/uuid"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	body := []byte(`{"id":1}`)
+	now := time.Unix(1700000000, 0)
+	sig := webhooks.Sign("whsec_test", now, body)
+	if err := webhooks.Verify("whsec_test", sig, body, now.Add(time.Minute), 5*time.Minute); err != nil {
+		t.Errorf("valid signature rejected: %v", err)
+	}
+	if err := webhooks.Verify("whsec_other", sig, body, now, 5*time.Minute); err == nil {
+		t.Errorf("signature with the wrong secret accepted")
+	}
+	if err := webhooks.Verify("whsec_test", sig, []byte(`{"id":2}`), now, 5*time.Minute); err == nil {
+		t.Errorf("signature over a different body accepted")
+	}
+	if err := webhooks.Verify("whsec_test", sig, body, now.Add(10*time.Minute), 5*time.Minute); err == nil {
+		t.Errorf("stale signature accepted")
+	}
+	if err := webhooks.Verify("whsec_test", "garbage", body, now, 5*time.Minute); err == nil {
+		t.Errorf("malformed header accepted")
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	cases := map[int]time.Duration{
+		0:  30 * time.Second,
+		1:  30 * time.Second,
+		2:  time.Minute,
+		4:  4 * time.Minute,
+		10: 256 * time.Minute,
+		50: 6 * time.Hour,
+	}
+	for attempt, want := range cases {
+		if got := webhooks.Backoff(attempt); got != want {
+			t.Errorf("Backoff(%d) = %s, want %s", attempt, got, want)
+		}
+	}
+}
+
+func TestValidateEventTypes(t *testing.T) {
+	got, err := webhooks.ValidateEventTypes([]string{pubsub.UserCreated, pubsub.ChirpCreated, pubsub.UserCreated})
+	if err != nil || len(got) != 2 || got[0] != pubsub.ChirpCreated {
+		t.Errorf("unexpected result %v, %v", got, err)
+	}
+	if _, err := webhooks.ValidateEventTypes(nil); err == nil {
+		t.Errorf("empty event types accepted")
+	}
+	if _, err := webhooks.ValidateEventTypes([]string{pubsub.NotificationCreated}); err == nil {
+		t.Errorf("private event type accepted")
+	}
+}
+
+func TestValidateURL(t *testing.T) {
+	for _, u := range []string{"https://example.com/hook", "http://example.com:8080/"} {
+		if err := webhooks.ValidateURL(u); err != nil {
+			t.Errorf("ValidateURL(%q) = %v", u, err)
+		}
+	}
+	for _, u := range []string{"", "example.com", "ftp://example.com", "https://user:pw@example.com/"} {
+		if err := webhooks.ValidateURL(u); err == nil {
+			t.Errorf("ValidateURL(%q) accepted", u)
+		}
+	}
+}
+
+func TestClientRefusesPrivateAddresses(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	if _, err := webhooks.NewClient(false).Get(srv.URL); err == nil {
+		t.Errorf("connected to a loopback address")
+	}
+	resp, err := webhooks.NewClient(true).Get(srv.URL)
+	if err != nil {
+		t.Fatalf("allowPrivate client failed: %v", err)
+	}
+	resp.Body.Close()
+}
+
+// fakeStore keeps deliveries in memory and hands each out once. The
+// dispatcher attempts a batch concurrently, so it is guarded by mu.
+type fakeStore struct {
+	mu          sync.Mutex
+	hook        database.Webhook
+	pending     []database.WebhookDelivery
+	attempts    []database.RecordWebhookAttemptParams
+	delivered   []int64
+	rescheduled []database.RescheduleWebhookDeliveryParams
+	failed      []database.FailWebhookDeliveryParams
+}
+
+func (f *fakeStore) ClaimWebhookDeliveries(_ context.Context, arg database.ClaimWebhookDeliveriesParams) ([]database.WebhookDelivery, error) {
+	out := f.pending
+	f.pending = nil
+	for i := range out {
+		out[i].Attempts++
+	}
+	return out, nil
+}
+
+func (f *fakeStore) GetWebhook(_ context.Context, id uuid.UUID) (database.Webhook, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if id != f.hook.ID {
+		return database.Webhook{}, sql.ErrNoRows
+	}
+	return f.hook, nil
+}
+
+func (f *fakeStore) RecordWebhookAttempt(_ context.Context, arg database.RecordWebhookAttemptParams) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.attempts = append(f.attempts, arg)
+	return nil
+}
+
+func (f *fakeStore) MarkWebhookDelivered(_ context.Context, id int64) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.delivered = append(f.delivered, id)
+	return nil
+}
+
+func (f *fakeStore) RescheduleWebhookDelivery(_ context.Context, arg database.RescheduleWebhookDeliveryParams) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.rescheduled = append(f.rescheduled, arg)
+	return nil
+}
+
+func (f *fakeStore) FailWebhookDelivery(_ context.Context, arg database.FailWebhookDeliveryParams) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.failed = append(f.failed, arg)
+	return nil
+}
+
+func newStore(url string, deliveries ...database.WebhookDelivery) *fakeStore {
+	hook := database.Webhook{ID: uuid.New(), Url: url, Secret: "whsec_test"}
+	for i := range deliveries {
+		deliveries[i].WebhookID = hook.ID
+		deliveries[i].EventType = pubsub.ChirpCreated
+		deliveries[i].Payload = json.RawMessage(`{"body":"hello"}`)
+	}
+	return &fakeStore{hook: hook, pending: deliveries}
+}
+
+func TestDispatcherDeliversSignedRequests(t *testing.T) {
+	var got *http.Request
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer srv.Close()
+	store := newStore(srv.URL, database.WebhookDelivery{ID: 7})
+	n, err := webhooks.NewDispatcher(store, webhooks.NewClient(true)).RunOnce(context.Background())
+	if err != nil || n != 1 {
+		t.Fatalf("RunOnce = %d, %v", n, err)
+	}
+	if len(store.delivered) != 1 || store.delivered[0] != 7 {
+		t.Fatalf("delivery not marked delivered: %v", store.delivered)
+	}
+	if got.Header.Get(webhooks.EventHeader) != pubsub.ChirpCreated || got.Header.Get(webhooks.DeliveryHeader) != "7" {
+		t.Errorf("missing event headers: %v", got.Header)
+	}
+	if err := webhooks.Verify("whsec_test", got.Header.Get(webhooks.SignatureHeader), body, time.Now(), time.Minute); err != nil {
+		t.Errorf("signature does not verify: %v", err)
+	}
+	var b webhooks.Body
+	if err := json.Unmarshal(body, &b); err != nil || b.ID != 7 || string(b.Data) != `{"body":"hello"}` {
+		t.Errorf("unexpected body %s", body)
+	}
+	if len(store.attempts) != 1 || store.attempts[0].StatusCode.Int32 != 200 {
+		t.Errorf("attempt not recorded: %v", store.attempts)
+	}
+}
+
+func TestDispatcherRetriesAndFails(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(503)
+	}))
+	defer srv.Close()
+	store := newStore(srv.URL,
+		database.WebhookDelivery{ID: 1},
+		database.WebhookDelivery{ID: 2, Attempts: webhooks.MaxAttempts - 1},
+	)
+	before := time.Now()
+	if _, err := webhooks.NewDispatcher(store, webhooks.NewClient(true)).RunOnce(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(store.rescheduled) != 1 || store.rescheduled[0].ID != 1 {
+		t.Fatalf("first attempt should be rescheduled: %v", store.rescheduled)
+	}
+	if next := store.rescheduled[0].NextAttemptAt; next.Before(before.Add(webhooks.Backoff(1))) {
+		t.Errorf("rescheduled too early: %s", next)
+	}
+	if len(store.failed) != 1 || store.failed[0].ID != 2 {
+		t.Fatalf("last attempt should fail the delivery: %v", store.failed)
+	}
+	if len(store.attempts) != 2 || store.attempts[0].StatusCode.Int32 != 503 {
+		t.Errorf("attempts not recorded: %v", store.attempts)
+	}
+}
+
+func TestDispatcherSkipsDeletedWebhooks(t *testing.T) {
+	store := newStore("http://127.0.0.1:1", database.WebhookDelivery{ID: 1})
+	store.hook.ID = uuid.New()
+	if _, err := webhooks.NewDispatcher(store, webhooks.NewClient(true)).RunOnce(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(store.attempts) != 0 {
+		t.Errorf("attempted a delivery for a deleted webhook")
+	}
+}
@@ -1,35 +1,85 @@
 package main
 
 import (
-	"net/http"
+	"context"
+	"embed"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 
-	"github.com/RemcoVeens/httpserver/internal/database"
-	"github.com/RemcoVeens/httpserver/internal/handlers"
+	"github.com/RemcoVeens/httpserver/internal/blobstore"
+	"github.com/RemcoVeens/httpserver/internal/config"
+	"github.com/RemcoVeens/httpserver/internal/static"
+	"github.com/joho/godotenv"
 
 	_ "github.com/lib/pq"
 )
 
+// appFiles holds the files served under /app/.
+//
+//go:embed index.html assets
+var appFiles embed.FS
+
 func main() {
-	var apiC handlers.APIConfig
-	apiC.Queries, apiC.Platform, apiC.Secret, apiC.PolkaKey = database.LoadDB()
-	servemux := http.NewServeMux()
-	servemux.Handle("/app/", http.StripPrefix("/app", apiC.MiddlewareMetricsInc(http.FileServer(http.Dir(".")))))
-	servemux.HandleFunc("GET /api/healthz", handlers.HealthCodeHandler)
-	servemux.HandleFunc("POST /api/users", apiC.CreateUserHandel)
-	servemux.HandleFunc("PUT /api/users", apiC.UpdateUserHandel)
-	servemux.HandleFunc("POST /api/login", apiC.LoginHandler)
-	servemux.HandleFunc("GET /api/chirps", apiC.GetChirps)
-	servemux.HandleFunc("GET /api/chirps/{chirp_id}", apiC.GetChirp)
-	servemux.HandleFunc("DELETE /api/chirps/{chirpID}", apiC.RemoveChirp)
-	servemux.HandleFunc("POST /api/refresh", apiC.RefreshHandel)
-	servemux.HandleFunc("POST /api/revoke", apiC.RevokeHandel)
-	servemux.HandleFunc("POST /api/chirps", apiC.Chirps)
-	servemux.HandleFunc("GET /admin/metrics", apiC.HitCounterHandler)
-	servemux.HandleFunc("POST /admin/reset", apiC.Reset)
-	servemux.HandleFunc("POST /api/polka/webhooks", apiC.PolkaWebhook)
-	server := http.Server{
-		Handler: servemux,
-		Addr:    ":8080",
+	args := os.Args[1:]
+	name := "serve"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	}
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", name)
+		printUsage()
+		os.Exit(2)
+	}
+	// A .env file fills in the environment while developing; variables
+	// already set win.
+	godotenv.Load()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	// The first signal asks the command to stop; a second one kills it.
+	context.AfterFunc(ctx, stop)
+	err := cmd.run(ctx, args)
+	stop()
+	var usage usageError
+	switch {
+	case err == nil, errors.Is(err, flag.ErrHelp), errors.Is(err, errPrinted):
+	case errors.As(err, &usage):
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	default:
+		log.Fatal(err)
+	}
+}
+
+// newBlobStore returns the store cfg selects: "local" keeps media in a
+// directory, "s3" in a bucket. Validate has checked the settings it needs.
+func newBlobStore(cfg config.Media) (blobstore.Store, error) {
+	switch cfg.Store {
+	case "local":
+		return blobstore.NewLocal(cfg.Dir)
+	case "s3":
+		return &blobstore.S3{
+			Endpoint:  cfg.S3.Endpoint,
+			Region:    cfg.S3.Region,
+			Bucket:    cfg.S3.Bucket,
+			AccessKey: cfg.S3.AccessKey,
+			SecretKey: cfg.S3.SecretKey,
+		}, nil
+	default:
+		return nil, fmt.Errorf("unknown media store %q", cfg.Store)
+	}
+}
+
+// newSite serves the embedded web app, or in dev the one on disk so edits
+// show up without a rebuild.
+func newSite(dev bool) (*static.Server, error) {
+	if dev {
+		return static.New(os.DirFS("."), static.Options{Dev: true, SPAFallback: true})
 	}
-	server.ListenAndServe()
+	return static.New(appFiles, static.Options{SPAFallback: true})
 }
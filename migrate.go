@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/RemcoVeens/httpserver/internal/database"
+	"github.com/RemcoVeens/httpserver/internal/migrate"
+	"github.com/RemcoVeens/httpserver/sql/schema"
+)
+
+// runMigrate applies, rolls back or lists the migrations in sql/schema.
+func runMigrate(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ContinueOnError)
+	cfg, err := loadConfig(fs, args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return usagef("usage: migrate [flags] up|down|status")
+	}
+	if err := cfg.Database.Validate(); err != nil {
+		return err
+	}
+	db, err := database.Open(cfg.Database)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	m, err := migrate.New(db, schema.FS)
+	if err != nil {
+		return err
+	}
+	switch fs.Arg(0) {
+	case "up":
+		return migrateUp(ctx, db)
+	case "down":
+		undone, err := m.Down(ctx)
+		if errors.Is(err, migrate.ErrNoVersion) {
+			log.Print("no migrations are applied")
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		log.Printf("rolled back %s", undone.Name)
+		return nil
+	case "status":
+		statuses, err := m.Status(ctx)
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tAPPLIED AT\tMIGRATION")
+		for _, s := range statuses {
+			applied := "pending"
+			if !s.AppliedAt.IsZero() {
+				applied = s.AppliedAt.Format(time.DateTime)
+			}
+			fmt.Fprintf(w, "%d\t%s\t%s\n", s.Version, applied, s.Name)
+		}
+		return w.Flush()
+	}
+	return usagef("unknown migrate command %q; want up, down or status", fs.Arg(0))
+}
+
+// migrateUp applies the pending migrations to db.
+func migrateUp(ctx context.Context, db *sql.DB) error {
+	m, err := migrate.New(db, schema.FS)
+	if err != nil {
+		return err
+	}
+	applied, err := m.Up(ctx)
+	for _, mig := range applied {
+		log.Printf("applied %s", mig.Name)
+	}
+	if err != nil {
+		return fmt.Errorf("could not migrate: %w", err)
+	}
+	if len(applied) == 0 {
+		log.Print("the schema is up to date")
+	}
+	return nil
+}
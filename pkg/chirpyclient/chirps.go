@@ -0,0 +1,72 @@
+package chirpyclient
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/google/uuid"
+)
+
+// CreateChirpParams are the fields of a new chirp. Media are the ids of
+// uploads to attach.
+type CreateChirpParams struct {
+	Body    string        `json:"body"`
+	Media   []uuid.UUID   `json:"media,omitempty"`
+	ReplyTo uuid.NullUUID `json:"reply_to_id"`
+}
+
+// CreateChirp posts a chirp as the logged in user.
+func (c *Client) CreateChirp(ctx context.Context, params CreateChirpParams) (Chirp, error) {
+	var chirp Chirp
+	err := c.do(ctx, request{method: "POST", path: "/api/chirps", body: params, auth: accessAuth}, &chirp)
+	return chirp, err
+}
+
+// GetChirp returns the chirp with id.
+func (c *Client) GetChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	var chirp Chirp
+	err := c.do(ctx, request{method: "GET", path: "/api/chirps/" + id.String(), auth: accessAuth}, &chirp)
+	return chirp, err
+}
+
+// DeleteChirp deletes a chirp of the logged in user.
+func (c *Client) DeleteChirp(ctx context.Context, id uuid.UUID) error {
+	return c.do(ctx, request{method: "DELETE", path: "/api/chirps/" + id.String(), auth: accessAuth}, nil)
+}
+
+// ListChirpsParams filter and order ListChirps.
+type ListChirpsParams struct {
+	// AuthorID limits the chirps to one author.
+	AuthorID uuid.UUID
+	// Newest lists the newest chirps first.
+	Newest bool
+}
+
+// ListChirps returns every chirp, hiding authors the user blocked or
+// muted.
+func (c *Client) ListChirps(ctx context.Context, params ListChirpsParams) ([]Chirp, error) {
+	query := url.Values{}
+	if params.AuthorID != uuid.Nil {
+		query.Set("author_id", params.AuthorID.String())
+	}
+	if params.Newest {
+		query.Set("sort", "desc")
+	}
+	var chirps []Chirp
+	err := c.do(ctx, request{method: "GET", path: "/api/chirps", query: query, auth: accessAuth}, &chirps)
+	return chirps, err
+}
+
+// Replies returns the direct replies to a chirp, oldest first.
+func (c *Client) Replies(ctx context.Context, id uuid.UUID) ([]Chirp, error) {
+	var chirps []Chirp
+	err := c.do(ctx, request{method: "GET", path: "/api/chirps/" + id.String() + "/replies", auth: accessAuth}, &chirps)
+	return chirps, err
+}
+
+// HashtagChirps returns the chirps with a hashtag, newest first.
+func (c *Client) HashtagChirps(ctx context.Context, tag string) ([]Chirp, error) {
+	var chirps []Chirp
+	err := c.do(ctx, request{method: "GET", path: "/api/hashtags/" + url.PathEscape(tag) + "/chirps", auth: accessAuth}, &chirps)
+	return chirps, err
+}
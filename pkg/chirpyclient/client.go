@@ -0,0 +1,290 @@
+// Package chirpyclient is a Go client for the Chirpy API, following the
+// OpenAPI document served at /api/openapi.json.
+//
+// A Client keeps the tokens of the user it logged in as. When the access
+// token is rejected it is renewed with the refresh token and the request is
+// sent again, so callers only see an Unauthenticated error once the refresh
+// token is revoked or expired. Requests that fail with a rate limit, an
+// unavailable server or, if they are idempotent, a network error are
+// retried with backoff until the context is done.
+//
+// Errors from the API are *Error values carrying the problem's code:
+//
+//	if errors.Is(err, chirpyclient.NotFound) { ... }
+package chirpyclient
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Defaults of a Client made by New.
+const (
+	DefaultMaxRetries = 3
+	DefaultBackoff    = 250 * time.Millisecond
+	maxBackoff        = 10 * time.Second
+)
+
+// Tokens are the credentials of a logged in user.
+type Tokens struct {
+	Access  string `json:"token"`
+	Refresh string `json:"refresh_token"`
+}
+
+// Client calls the Chirpy API. Set its fields before the first request; a
+// Client is safe for concurrent use afterwards.
+type Client struct {
+	// BaseURL is where the server is, such as https://chirpy.example.com.
+	BaseURL string
+	// HTTPClient sends the requests. It defaults to http.DefaultClient.
+	HTTPClient *http.Client
+	// MaxRetries is how often a failed request is sent again.
+	MaxRetries int
+	// Backoff is the wait before the first retry. It doubles with every
+	// retry, with jitter, unless the server sends Retry-After.
+	Backoff time.Duration
+	// OnTokens, if set, is called whenever the tokens change, so they can
+	// be stored. It is called with empty tokens after Revoke.
+	OnTokens func(Tokens)
+
+	mu     sync.Mutex
+	tokens Tokens
+	// refreshing serializes refreshes, so concurrent requests that are
+	// rejected together refresh once.
+	refreshing sync.Mutex
+}
+
+// New returns a client for the server at baseURL.
+func New(baseURL string) *Client {
+	return &Client{
+		BaseURL:    strings.TrimSuffix(baseURL, "/"),
+		MaxRetries: DefaultMaxRetries,
+		Backoff:    DefaultBackoff,
+	}
+}
+
+// Tokens returns the current tokens.
+func (c *Client) Tokens() Tokens {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.tokens
+}
+
+// SetTokens sets the tokens, for instance ones stored by OnTokens.
+func (c *Client) SetTokens(t Tokens) {
+	c.mu.Lock()
+	c.tokens = t
+	c.mu.Unlock()
+	if c.OnTokens != nil {
+		c.OnTokens(t)
+	}
+}
+
+// auth says which token a request is sent with.
+type auth int
+
+const (
+	noAuth auth = iota
+	accessAuth
+	refreshAuth
+)
+
+// request describes a call to the API.
+type request struct {
+	method string
+	path   string
+	query  url.Values
+	body   any
+	auth   auth
+}
+
+// do sends req, decoding the response into out unless it is nil. It renews
+// the access token once if the server rejects it.
+func (c *Client) do(ctx context.Context, req request, out any) error {
+	sent := c.Tokens().Access
+	err := c.send(ctx, req, out)
+	if req.auth != accessAuth || !errors.Is(err, Unauthenticated) {
+		return err
+	}
+	if rerr := c.refreshAfter(ctx, sent); rerr != nil {
+		if errors.Is(rerr, Unauthenticated) {
+			return err
+		}
+		return rerr
+	}
+	return c.send(ctx, req, out)
+}
+
+// refreshAfter renews the access token unless another request already
+// replaced the rejected one.
+func (c *Client) refreshAfter(ctx context.Context, rejected string) error {
+	c.refreshing.Lock()
+	defer c.refreshing.Unlock()
+	t := c.Tokens()
+	if t.Refresh == "" {
+		return &Error{StatusCode: http.StatusUnauthorized, Code: Unauthenticated, Detail: "no refresh token"}
+	}
+	if t.Access != rejected {
+		return nil
+	}
+	var resp struct {
+		Token string `json:"token"`
+	}
+	if err := c.send(ctx, request{method: "POST", path: "/api/refresh", auth: refreshAuth}, &resp); err != nil {
+		return err
+	}
+	t.Access = resp.Token
+	c.SetTokens(t)
+	return nil
+}
+
+// send sends req, retrying as the Client is configured to.
+func (c *Client) send(ctx context.Context, req request, out any) error {
+	var body []byte
+	if req.body != nil {
+		var err error
+		if body, err = json.Marshal(req.body); err != nil {
+			return fmt.Errorf("could not encode request: %w", err)
+		}
+	}
+	// The token is read before each attempt, so retries pick up tokens
+	// renewed meanwhile.
+	token := func() string {
+		switch req.auth {
+		case accessAuth:
+			return c.Tokens().Access
+		case refreshAuth:
+			return c.Tokens().Refresh
+		}
+		return ""
+	}
+	for attempt := 0; ; attempt++ {
+		resp, err := c.attempt(ctx, req, body, token())
+		if err == nil {
+			err = decode(resp, out)
+		}
+		wait, retry := c.retryable(req.method, resp, err)
+		if !retry || attempt >= c.MaxRetries {
+			return err
+		}
+		if wait == 0 {
+			wait = c.backoff(attempt)
+		}
+		t := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			t.Stop()
+			return errors.Join(err, ctx.Err())
+		case <-t.C:
+		}
+	}
+}
+
+func (c *Client) attempt(ctx context.Context, req request, body []byte, token string) (*http.Response, error) {
+	u := c.BaseURL + req.path
+	if len(req.query) > 0 {
+		u += "?" + req.query.Encode()
+	}
+	var r io.Reader
+	if body != nil {
+		r = bytes.NewReader(body)
+	}
+	hr, err := http.NewRequestWithContext(ctx, req.method, u, r)
+	if err != nil {
+		return nil, err
+	}
+	hr.Header.Set("Accept", "application/json, application/problem+json")
+	if body != nil {
+		hr.Header.Set("Content-Type", "application/json")
+	}
+	if token != "" {
+		hr.Header.Set("Authorization", "Bearer "+token)
+	}
+	hc := c.HTTPClient
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	return hc.Do(hr)
+}
+
+// decode reads resp into out, or into an *Error if it failed.
+func decode(resp *http.Response, out any) error {
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("could not read response: %w", err)
+	}
+	if resp.StatusCode >= 400 {
+		return newError(resp, data)
+	}
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("could not decode response: %w", err)
+	}
+	return nil
+}
+
+// retryable says whether a failed attempt should be retried, and how long
+// the server asked to wait first.
+func (c *Client) retryable(method string, resp *http.Response, err error) (time.Duration, bool) {
+	var apiErr *Error
+	if errors.As(err, &apiErr) {
+		switch apiErr.StatusCode {
+		case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+			// The request was turned away before it was handled.
+			return apiErr.RetryAfter, true
+		case http.StatusBadGateway, http.StatusGatewayTimeout:
+			return apiErr.RetryAfter, idempotent(method)
+		}
+		return 0, false
+	}
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return 0, false
+	}
+	// A network error: the server may have handled the request.
+	return 0, resp == nil && idempotent(method)
+}
+
+func idempotent(method string) bool {
+	switch method {
+	case "GET", "HEAD", "PUT", "DELETE", "OPTIONS":
+		return true
+	}
+	return false
+}
+
+func (c *Client) backoff(attempt int) time.Duration {
+	d := c.Backoff
+	if d <= 0 {
+		d = DefaultBackoff
+	}
+	d <<= attempt
+	if d <= 0 || d > maxBackoff {
+		d = maxBackoff
+	}
+	// Full jitter keeps clients that failed together from retrying
+	// together.
+	return d/2 + rand.N(d/2+1)
+}
+
+// retryAfter parses a Retry-After header in seconds.
+func retryAfter(h http.Header) time.Duration {
+	n, err := strconv.Atoi(h.Get("Retry-After"))
+	if err != nil || n < 0 {
+		return 0
+	}
+	return time.Duration(n) * time.Second
+}
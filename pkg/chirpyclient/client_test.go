@@ -0,0 +1,248 @@
+package chirpyclient_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/RemcoVeens/httpserver/internal/apierror"
+	"github.com/RemcoVeens/httpserver/pkg/chirpyclient"
+	"github.com/google/uuid"
+)
+
+// newClient returns a client of a server running h that retries quickly.
+func newClient(t *testing.T, h http.Handler) *chirpyclient.Client {
+	t.Helper()
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	c := chirpyclient.New(srv.URL)
+	c.Backoff = time.Millisecond
+	return c
+}
+
+func problem(code apierror.Code) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		apierror.Write(w, r, apierror.New(code, "from the test"))
+	}
+}
+
+func TestErrors(t *testing.T) {
+	c := newClient(t, problem(apierror.NotFound))
+	_, err := c.GetChirp(context.Background(), uuid.New())
+	if !errors.Is(err, chirpyclient.NotFound) || errors.Is(err, chirpyclient.Forbidden) {
+		t.Fatalf("got %v, want not_found", err)
+	}
+	var apiErr *chirpyclient.Error
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != 404 || apiErr.Detail != "from the test" {
+		t.Errorf("got %#v", apiErr)
+	}
+
+	// Errors that are not problems, say from a proxy, get a code by status.
+	c = newClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "no", http.StatusForbidden)
+	}))
+	if _, err := c.ListWebhooks(context.Background()); !errors.Is(err, chirpyclient.Forbidden) {
+		t.Errorf("got %v, want forbidden", err)
+	}
+}
+
+func TestCodesMatchServer(t *testing.T) {
+	for _, code := range apierror.Codes() {
+		c := newClient(t, problem(code))
+		c.MaxRetries = 0
+		err := c.DeleteChirp(context.Background(), uuid.New())
+		if !errors.Is(err, chirpyclient.Code(code)) {
+			t.Errorf("%s: got %v", code, err)
+		}
+	}
+}
+
+func TestRefreshesExpiredAccessToken(t *testing.T) {
+	var refreshes atomic.Int32
+	var mu sync.Mutex
+	access := "access-1"
+	c := newClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.URL.Path {
+		case "/api/refresh":
+			if r.Header.Get("Authorization") != "Bearer refresh" {
+				problem(apierror.Unauthenticated)(w, r)
+				return
+			}
+			n := refreshes.Add(1)
+			access = fmt.Sprintf("access-%d", n+1)
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprintf(w, `{"token":%q}`, access)
+		default:
+			if r.Header.Get("Authorization") != "Bearer "+access {
+				problem(apierror.Unauthenticated)(w, r)
+				return
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`[]`))
+		}
+	}))
+	var stored chirpyclient.Tokens
+	c.OnTokens = func(t chirpyclient.Tokens) { stored = t }
+	c.SetTokens(chirpyclient.Tokens{Access: "expired", Refresh: "refresh"})
+
+	// Requests rejected together refresh once.
+	var wg sync.WaitGroup
+	for range 5 {
+		wg.Go(func() {
+			if _, err := c.ListWebhooks(context.Background()); err != nil {
+				t.Error(err)
+			}
+		})
+	}
+	wg.Wait()
+	if n := refreshes.Load(); n != 1 {
+		t.Errorf("refreshed %d times, want once", n)
+	}
+	if want := (chirpyclient.Tokens{Access: "access-2", Refresh: "refresh"}); stored != want || c.Tokens() != want {
+		t.Errorf("tokens %+v, stored %+v, want %+v", c.Tokens(), stored, want)
+	}
+
+	// Without a valid refresh token, the original error comes back.
+	c.SetTokens(chirpyclient.Tokens{Access: "expired", Refresh: "revoked"})
+	if _, err := c.ListWebhooks(context.Background()); !errors.Is(err, chirpyclient.Unauthenticated) {
+		t.Errorf("got %v, want unauthenticated", err)
+	}
+}
+
+func TestRetries(t *testing.T) {
+	var calls atomic.Int32
+	c := newClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			w.Header().Set("Retry-After", "0")
+			problem(apierror.RateLimited)(w, r)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	if err := c.DeleteChirp(context.Background(), uuid.New()); err != nil {
+		t.Fatal(err)
+	}
+	if n := calls.Load(); n != 3 {
+		t.Errorf("%d calls, want 3", n)
+	}
+
+	// The last error is returned once retries run out.
+	calls.Store(0)
+	c.MaxRetries = 1
+	if err := c.DeleteChirp(context.Background(), uuid.New()); !errors.Is(err, chirpyclient.RateLimited) {
+		t.Errorf("got %v, want rate_limited", err)
+	}
+	if n := calls.Load(); n != 2 {
+		t.Errorf("%d calls, want 2", n)
+	}
+}
+
+func TestRetriesStopWithContext(t *testing.T) {
+	c := newClient(t, problem(apierror.Unavailable))
+	c.MaxRetries = 100
+	c.Backoff = time.Hour
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := c.ListWebhooks(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) || !errors.Is(err, chirpyclient.Unavailable) {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestNoRetryOfUnsafeRequests(t *testing.T) {
+	var calls atomic.Int32
+	c := newClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		// The server may have created the chirp before the gateway gave up.
+		http.Error(w, "timeout", http.StatusGatewayTimeout)
+	}))
+	if _, err := c.CreateChirp(context.Background(), chirpyclient.CreateChirpParams{Body: "hi"}); !errors.Is(err, chirpyclient.Internal) {
+		t.Errorf("got %v, want internal", err)
+	}
+	if n := calls.Load(); n != 1 {
+		t.Errorf("POST sent %d times", n)
+	}
+}
+
+func TestPagination(t *testing.T) {
+	// Three pages of two, counting down from 6.
+	var requests atomic.Int32
+	c := newClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		before := 7
+		if v := r.URL.Query().Get("cursor"); v != "" {
+			before, _ = strconv.Atoi(v)
+		}
+		next := "null"
+		if before-2 > 1 {
+			next = strconv.Quote(strconv.Itoa(before - 2))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"notifications":[{"cursor":"%d"},{"cursor":"%d"}],"next_cursor":%s}`, before-1, before-2, next)
+	}))
+	var got []string
+	for n, err := range c.Notifications(context.Background(), 2) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, n.Cursor)
+	}
+	if fmt.Sprint(got) != "[6 5 4 3 2 1]" {
+		t.Errorf("got %v", got)
+	}
+
+	// Breaking out of the loop stops fetching.
+	requests.Store(0)
+	for range c.Notifications(context.Background(), 2) {
+		break
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("%d requests after break, want 1", n)
+	}
+}
+
+func TestStreamResumes(t *testing.T) {
+	var connections atomic.Int32
+	c := newClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		switch connections.Add(1) {
+		case 1:
+			fmt.Fprint(w, "retry: 1\n\n: heartbeat\n\n")
+			fmt.Fprint(w, "id: 1\nevent: chirp.created\ndata: {\"body\":\"one\"}\n\n")
+			fmt.Fprint(w, "id: 2\nevent: chirp.created\ndata: {\"body\":\"two\"}\n\n")
+			// The connection drops here.
+		case 2:
+			if got := r.Header.Get("Last-Event-ID"); got != "2" {
+				t.Errorf("reconnected with Last-Event-ID %q, want 2", got)
+			}
+			fmt.Fprint(w, "id: 3\nevent: chirp.deleted\ndata: {\"id\":\""+uuid.Nil.String()+"\"}\n\n")
+		default:
+			problem(apierror.Forbidden)(w, r)
+		}
+	}))
+	var got []string
+	var err error
+	for e, ierr := range c.StreamChirps(context.Background(), chirpyclient.StreamParams{Hashtags: []string{"go"}}) {
+		if ierr != nil {
+			err = ierr
+			break
+		}
+		got = append(got, fmt.Sprintf("%d %s %s", e.ID, e.Type, e.Chirp.Body))
+	}
+	want := "[1 chirp.created one 2 chirp.created two 3 chirp.deleted ]"
+	if fmt.Sprint(got) != want {
+		t.Errorf("got %v, want %s", got, want)
+	}
+	if !errors.Is(err, chirpyclient.Forbidden) {
+		t.Errorf("got %v, want the refusal to end the stream", err)
+	}
+}
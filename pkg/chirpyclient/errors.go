@@ -0,0 +1,95 @@
+package chirpyclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Code identifies a kind of API error. See docs/errors.md for what each one
+// means. A Code is an error, so it can be matched with errors.Is.
+type Code string
+
+const (
+	InvalidJSON          Code = "invalid_json"
+	InvalidRequest       Code = "invalid_request"
+	PlanLimit            Code = "plan_limit"
+	Unauthenticated      Code = "unauthenticated"
+	InvalidCredentials   Code = "invalid_credentials"
+	CSRFFailed           Code = "csrf_failed"
+	Forbidden            Code = "forbidden"
+	NotFound             Code = "not_found"
+	MethodNotAllowed     Code = "method_not_allowed"
+	Conflict             Code = "conflict"
+	PayloadTooLarge      Code = "payload_too_large"
+	UnsupportedMediaType Code = "unsupported_media_type"
+	RateLimited          Code = "rate_limited"
+	Internal             Code = "internal"
+	Unavailable          Code = "unavailable"
+)
+
+func (c Code) Error() string { return string(c) }
+
+// Error is a failed request, made from the problem the server answered
+// with.
+type Error struct {
+	StatusCode int
+	Code       Code
+	Title      string
+	Detail     string
+	Instance   string
+	// RetryAfter is how long the server asked to wait before retrying.
+	RetryAfter time.Duration
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("chirpy: %d %s", e.StatusCode, e.Code)
+	if e.Detail != "" {
+		msg += ": " + e.Detail
+	}
+	return msg
+}
+
+// Is reports whether e has the code target.
+func (e *Error) Is(target error) bool {
+	c, ok := target.(Code)
+	return ok && c == e.Code
+}
+
+// newError makes an Error of a failed response. Responses that are not
+// problems, such as those of a proxy in front of the server, get the code
+// that is closest to their status.
+func newError(resp *http.Response, body []byte) *Error {
+	e := &Error{StatusCode: resp.StatusCode, RetryAfter: retryAfter(resp.Header)}
+	var p struct {
+		Title    string `json:"title"`
+		Code     Code   `json:"code"`
+		Detail   string `json:"detail"`
+		Instance string `json:"instance"`
+	}
+	if json.Unmarshal(body, &p) == nil && p.Code != "" {
+		e.Code, e.Title, e.Detail, e.Instance = p.Code, p.Title, p.Detail, p.Instance
+		return e
+	}
+	e.Title = http.StatusText(resp.StatusCode)
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized:
+		e.Code = Unauthenticated
+	case resp.StatusCode == http.StatusForbidden:
+		e.Code = Forbidden
+	case resp.StatusCode == http.StatusNotFound:
+		e.Code = NotFound
+	case resp.StatusCode == http.StatusRequestEntityTooLarge:
+		e.Code = PayloadTooLarge
+	case resp.StatusCode == http.StatusTooManyRequests:
+		e.Code = RateLimited
+	case resp.StatusCode == http.StatusServiceUnavailable:
+		e.Code = Unavailable
+	case resp.StatusCode >= 500:
+		e.Code = Internal
+	default:
+		e.Code = InvalidRequest
+	}
+	return e
+}
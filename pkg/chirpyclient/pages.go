@@ -0,0 +1,102 @@
+package chirpyclient
+
+import (
+	"context"
+	"iter"
+	"net/url"
+	"strconv"
+
+	"github.com/google/uuid"
+)
+
+// fetchPage gets the page after cursor, which is empty for the first one.
+// next is empty on the last page.
+type fetchPage[T any] func(ctx context.Context, cursor string) (items []T, next string, err error)
+
+// paginate yields the items of every page in turn. It stops at the first
+// error, yielding it with the zero T.
+func paginate[T any](ctx context.Context, fetch fetchPage[T]) iter.Seq2[T, error] {
+	return func(yield func(T, error) bool) {
+		cursor := ""
+		for {
+			items, next, err := fetch(ctx, cursor)
+			if err != nil {
+				var zero T
+				yield(zero, err)
+				return
+			}
+			for _, item := range items {
+				if !yield(item, nil) {
+					return
+				}
+			}
+			if next == "" {
+				return
+			}
+			cursor = next
+		}
+	}
+}
+
+// pageQuery returns the query of a page request.
+func pageQuery(cursorParam, cursor string, pageSize int) url.Values {
+	query := url.Values{}
+	if cursor != "" {
+		query.Set(cursorParam, cursor)
+	}
+	if pageSize > 0 {
+		query.Set("limit", strconv.Itoa(pageSize))
+	}
+	return query
+}
+
+// Notifications iterates over the notifications of the logged in user,
+// newest first, fetching pageSize at a time; the server's default applies
+// when it is 0.
+//
+//	for n, err := range client.Notifications(ctx, 50) {
+//		if err != nil {
+//			return err
+//		}
+//		...
+//	}
+func (c *Client) Notifications(ctx context.Context, pageSize int) iter.Seq2[Notification, error] {
+	return paginate(ctx, func(ctx context.Context, cursor string) ([]Notification, string, error) {
+		var page struct {
+			Notifications []Notification `json:"notifications"`
+			NextCursor    *string        `json:"next_cursor"`
+		}
+		err := c.do(ctx, request{
+			method: "GET",
+			path:   "/api/notifications",
+			query:  pageQuery("cursor", cursor, pageSize),
+			auth:   accessAuth,
+		}, &page)
+		return page.Notifications, deref(page.NextCursor), err
+	})
+}
+
+// Messages iterates over a conversation, newest first, fetching pageSize
+// messages at a time; the server's default applies when it is 0.
+func (c *Client) Messages(ctx context.Context, conversationID uuid.UUID, pageSize int) iter.Seq2[Message, error] {
+	return paginate(ctx, func(ctx context.Context, cursor string) ([]Message, string, error) {
+		var page struct {
+			Messages   []Message `json:"messages"`
+			NextCursor *string   `json:"next_cursor"`
+		}
+		err := c.do(ctx, request{
+			method: "GET",
+			path:   "/api/conversations/" + conversationID.String() + "/messages",
+			query:  pageQuery("before", cursor, pageSize),
+			auth:   accessAuth,
+		}, &page)
+		return page.Messages, deref(page.NextCursor), err
+	})
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,220 @@
+package chirpyclient_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/RemcoVeens/httpserver/internal/auth"
+	"github.com/RemcoVeens/httpserver/internal/database"
+	"github.com/RemcoVeens/httpserver/internal/entitlements"
+	"github.com/RemcoVeens/httpserver/internal/handlers"
+	"github.com/RemcoVeens/httpserver/internal/openapi"
+	"github.com/RemcoVeens/httpserver/internal/ratelimit"
+	"github.com/RemcoVeens/httpserver/internal/server"
+	"github.com/RemcoVeens/httpserver/pkg/chirpyclient"
+	"github.com/google/uuid"
+	_ "github.com/lib/pq"
+)
+
+const secret = "test-secret"
+
+// newServerClient returns a client of the real handlers using the database
+// at dbURL. Requests and responses that do not match the OpenAPI document
+// fail t.
+func newServerClient(t *testing.T, dbURL string) *chirpyclient.Client {
+	t.Helper()
+	db, err := sql.Open("postgres", dbURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	cfg := &handlers.APIConfig{
+		DB:           db,
+		Queries:      database.New(db),
+		Secret:       secret,
+		Entitlements: entitlements.Default(),
+		Limiter:      ratelimit.New(),
+	}
+	doc, err := openapi.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, err := openapi.NewValidator(doc, openapi.Spec())
+	if err != nil {
+		t.Fatal(err)
+	}
+	v.Responses = true
+	v.Report = func(r *http.Request, err error) { t.Errorf("%s", err) }
+	return newClient(t, server.New(cfg, http.NotFoundHandler(), v))
+}
+
+// TestRequestsReachHandlers checks that every method sends a request the
+// server routes and accepts: with the database down, each one makes it to
+// a handler and fails there.
+func TestRequestsReachHandlers(t *testing.T) {
+	// Nothing listens on a port that was just closed.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Close()
+	c := newServerClient(t, "postgres://chirpy@"+l.Addr().String()+"/chirpy?sslmode=disable&connect_timeout=1")
+	access, err := auth.MakeJWT(uuid.New(), secret, 3600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.SetTokens(chirpyclient.Tokens{Access: access, Refresh: "refresh"})
+
+	ctx := context.Background()
+	id := uuid.New()
+	calls := map[string]func() error{
+		"CreateUser": func() error {
+			_, err := c.CreateUser(ctx, chirpyclient.CreateUserParams{Email: "a@example.com", Password: "pw"})
+			return err
+		},
+		"UpdateUser": func() error { _, err := c.UpdateUser(ctx, "a@example.com", "pw"); return err },
+		"GetProfile": func() error { _, err := c.GetProfile(ctx, "someone"); return err },
+		"Me":         func() error { _, err := c.Me(ctx); return err },
+		"Login":      func() error { _, err := c.Login(ctx, "a@example.com", "pw"); return err },
+		"Refresh":    func() error { return c.Refresh(ctx) },
+		"Revoke":     func() error { return c.Revoke(ctx) },
+		"CreateChirp": func() error {
+			_, err := c.CreateChirp(ctx, chirpyclient.CreateChirpParams{Body: "hi", ReplyTo: uuid.NullUUID{UUID: id, Valid: true}})
+			return err
+		},
+		"GetChirp":    func() error { _, err := c.GetChirp(ctx, id); return err },
+		"DeleteChirp": func() error { return c.DeleteChirp(ctx, id) },
+		"ListChirps": func() error {
+			_, err := c.ListChirps(ctx, chirpyclient.ListChirpsParams{AuthorID: id, Newest: true})
+			return err
+		},
+		"Replies":       func() error { _, err := c.Replies(ctx, id); return err },
+		"HashtagChirps": func() error { _, err := c.HashtagChirps(ctx, "golang"); return err },
+		"CreateWebhook": func() error {
+			_, err := c.CreateWebhook(ctx, chirpyclient.CreateWebhookParams{URL: "https://example.com/hook", EventTypes: []string{"chirp.created"}})
+			return err
+		},
+		"ListWebhooks":  func() error { _, err := c.ListWebhooks(ctx); return err },
+		"DeleteWebhook": func() error { return c.DeleteWebhook(ctx, id) },
+		"Deliveries": func() error {
+			_, err := c.Deliveries(ctx, id, chirpyclient.DeliveriesParams{Status: "failed", Limit: 5})
+			return err
+		},
+		"ReplayDelivery": func() error { return c.ReplayDelivery(ctx, id, 1) },
+		"Notifications": func() error {
+			for _, err := range c.Notifications(ctx, 10) {
+				return err
+			}
+			return nil
+		},
+		"Messages": func() error {
+			for _, err := range c.Messages(ctx, id, 10) {
+				return err
+			}
+			return nil
+		},
+	}
+	for name, call := range calls {
+		if err := call(); !errors.Is(err, chirpyclient.Internal) {
+			t.Errorf("%s: got %v, want the database error", name, err)
+		}
+	}
+}
+
+// TestAgainstDatabase runs through the API with a real database. Point
+// CHIRPY_TEST_DB_URL at a migrated, disposable database to run it.
+func TestAgainstDatabase(t *testing.T) {
+	dbURL := os.Getenv("CHIRPY_TEST_DB_URL")
+	if dbURL == "" {
+		t.Skip("CHIRPY_TEST_DB_URL is not set")
+	}
+	c := newServerClient(t, dbURL)
+	ctx := context.Background()
+
+	email := uuid.NewString() + "@example.com"
+	if _, err := c.CreateUser(ctx, chirpyclient.CreateUserParams{Email: email, Password: "hunter2"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.CreateUser(ctx, chirpyclient.CreateUserParams{Email: email, Password: "hunter2"}); !errors.Is(err, chirpyclient.Conflict) {
+		t.Errorf("second signup: got %v, want conflict", err)
+	}
+	if _, err := c.Login(ctx, email, "wrong"); !errors.Is(err, chirpyclient.InvalidCredentials) {
+		t.Errorf("wrong password: got %v, want invalid_credentials", err)
+	}
+	user, err := c.Login(ctx, email, "hunter2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if me, err := c.Me(ctx); err != nil || me.ID != user.ID {
+		t.Errorf("Me: %+v, %v", me, err)
+	}
+
+	chirp, err := c.CreateChirp(ctx, chirpyclient.CreateChirpParams{Body: "hello #golang"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if chirp.UserID != user.ID || len(chirp.Entities.Hashtags) != 1 {
+		t.Errorf("chirp %+v", chirp)
+	}
+	chirps, err := c.ListChirps(ctx, chirpyclient.ListChirpsParams{AuthorID: user.ID})
+	if err != nil || len(chirps) != 1 || chirps[0].ID != chirp.ID {
+		t.Errorf("ListChirps: %v, %v", chirps, err)
+	}
+
+	// An expired access token is renewed with the refresh token.
+	expired, err := auth.MakeJWT(user.ID, secret, -time.Minute/time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokens := c.Tokens()
+	c.SetTokens(chirpyclient.Tokens{Access: expired, Refresh: tokens.Refresh})
+	if _, err := c.ListWebhooks(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if c.Tokens().Access == expired {
+		t.Error("the access token was not renewed")
+	}
+
+	hook, err := c.CreateWebhook(ctx, chirpyclient.CreateWebhookParams{URL: "https://example.com/hook", EventTypes: []string{"chirp.created"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hook.Secret == "" {
+		t.Error("new webhook has no secret")
+	}
+	if hooks, err := c.ListWebhooks(ctx); err != nil || len(hooks) != 1 || hooks[0].Secret != "" {
+		t.Errorf("ListWebhooks: %+v, %v", hooks, err)
+	}
+	if _, err := c.Deliveries(ctx, hook.ID, chirpyclient.DeliveriesParams{}); err != nil {
+		t.Error(err)
+	}
+	if err := c.DeleteWebhook(ctx, hook.ID); err != nil {
+		t.Error(err)
+	}
+	for _, err := range c.Notifications(ctx, 5) {
+		if err != nil {
+			t.Error(err)
+		}
+	}
+
+	if err := c.DeleteChirp(ctx, chirp.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetChirp(ctx, chirp.ID); !errors.Is(err, chirpyclient.NotFound) {
+		t.Errorf("deleted chirp: got %v, want not_found", err)
+	}
+
+	if err := c.Revoke(ctx); err != nil {
+		t.Fatal(err)
+	}
+	c.SetTokens(chirpyclient.Tokens{Access: expired, Refresh: tokens.Refresh})
+	if _, err := c.ListWebhooks(ctx); !errors.Is(err, chirpyclient.Unauthenticated) {
+		t.Errorf("after revoking: got %v, want unauthenticated", err)
+	}
+}
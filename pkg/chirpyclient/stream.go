@@ -0,0 +1,216 @@
+package chirpyclient
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"iter"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Stream event types.
+const (
+	ChirpCreated = "chirp.created"
+	ChirpDeleted = "chirp.deleted"
+	// StreamReset means the client fell too far behind to be sent the
+	// events it missed; what it shows should be fetched again.
+	StreamReset = "reset"
+)
+
+// defaultReconnect is how long a dropped stream waits before reconnecting
+// until the server says otherwise.
+const defaultReconnect = 3 * time.Second
+
+// StreamEvent is an event of StreamChirps.
+type StreamEvent struct {
+	ID   uint64
+	Type string
+	// Chirp is the new chirp of a chirp.created event. Of a chirp.deleted
+	// event only ID and UserID are set.
+	Chirp Chirp
+}
+
+// StreamParams filter StreamChirps.
+type StreamParams struct {
+	AuthorID uuid.UUID
+	Hashtags []string
+}
+
+// StreamChirps follows new and deleted chirps as they happen. A dropped
+// connection is resumed where it left off, so the loop only ends when the
+// context is done, the caller breaks out of it, or the server keeps
+// refusing the stream; then the error is yielded last.
+//
+// HTTPClient must not have a Timeout, or it cuts the stream off.
+func (c *Client) StreamChirps(ctx context.Context, params StreamParams) iter.Seq2[StreamEvent, error] {
+	query := url.Values{}
+	if params.AuthorID != uuid.Nil {
+		query.Set("author_id", params.AuthorID.String())
+	}
+	for _, tag := range params.Hashtags {
+		query.Add("hashtag", tag)
+	}
+	return func(yield func(StreamEvent, error) bool) {
+		s := &stream{c: c, query: query, reconnect: defaultReconnect}
+		failures := 0
+		for {
+			connected, err := s.run(ctx, yield)
+			if s.stopped || ctx.Err() != nil {
+				return
+			}
+			if connected {
+				failures = 0
+			}
+			wait := s.reconnect
+			var apiErr *Error
+			if errors.As(err, &apiErr) {
+				// The server turned the stream down rather than dropping it.
+				if _, retry := c.retryable("GET", nil, err); !retry || failures >= c.MaxRetries {
+					yield(StreamEvent{}, err)
+					return
+				}
+				if apiErr.RetryAfter > 0 {
+					wait = apiErr.RetryAfter
+				}
+				failures++
+			}
+			t := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				t.Stop()
+				return
+			case <-t.C:
+			}
+		}
+	}
+}
+
+// stream is the state of a StreamChirps loop across connections.
+type stream struct {
+	c         *Client
+	query     url.Values
+	lastID    uint64
+	reconnect time.Duration
+	// stopped is set once the caller broke out of the loop.
+	stopped bool
+}
+
+// run reads one connection until it ends. connected reports whether the
+// server accepted it.
+func (s *stream) run(ctx context.Context, yield func(StreamEvent, error) bool) (connected bool, err error) {
+	resp, err := s.connect(ctx)
+	if errors.Is(err, Unauthenticated) && s.c.Tokens().Refresh != "" {
+		if err := s.c.refreshAfter(ctx, s.c.Tokens().Access); err != nil {
+			return false, err
+		}
+		resp, err = s.connect(ctx)
+	}
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	var id, event string
+	var data strings.Builder
+	sc := bufio.NewScanner(resp.Body)
+	sc.Buffer(make([]byte, 64<<10), 1<<20)
+	for sc.Scan() {
+		field, value, _ := strings.Cut(sc.Text(), ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "":
+			if sc.Text() != "" {
+				// A comment, such as a heartbeat.
+				continue
+			}
+			if event == "" && data.Len() == 0 {
+				continue
+			}
+			e, err := parseEvent(id, event, data.String())
+			id, event = "", ""
+			data.Reset()
+			if err != nil {
+				// Reconnecting would only get the same event again.
+				yield(e, err)
+				s.stopped = true
+				return true, nil
+			}
+			if e.ID > 0 {
+				s.lastID = e.ID
+			}
+			if !yield(e, nil) {
+				s.stopped = true
+				return true, nil
+			}
+		case "id":
+			id = value
+		case "event":
+			event = value
+		case "data":
+			if data.Len() > 0 {
+				data.WriteByte('\n')
+			}
+			data.WriteString(value)
+		case "retry":
+			if ms, err := strconv.Atoi(value); err == nil && ms > 0 {
+				s.reconnect = time.Duration(ms) * time.Millisecond
+			}
+		}
+	}
+	return true, sc.Err()
+}
+
+func (s *stream) connect(ctx context.Context) (*http.Response, error) {
+	u := s.c.BaseURL + "/api/stream/chirps"
+	if len(s.query) > 0 {
+		u += "?" + s.query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+	if token := s.c.Tokens().Access; token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	if s.lastID > 0 {
+		req.Header.Set("Last-Event-ID", strconv.FormatUint(s.lastID, 10))
+	}
+	hc := s.c.HTTPClient
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	resp, err := hc.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 400 {
+		return nil, decode(resp, nil)
+	}
+	return resp, nil
+}
+
+func parseEvent(id, event, data string) (StreamEvent, error) {
+	e := StreamEvent{Type: event}
+	if id != "" {
+		n, err := strconv.ParseUint(id, 10, 64)
+		if err != nil {
+			return e, fmt.Errorf("invalid event id %q", id)
+		}
+		e.ID = n
+	}
+	if event == ChirpCreated || event == ChirpDeleted {
+		if err := json.Unmarshal([]byte(data), &e.Chirp); err != nil {
+			return e, fmt.Errorf("could not decode %s event: %w", event, err)
+		}
+	}
+	return e, nil
+}
@@ -0,0 +1,147 @@
+package chirpyclient
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// User is the logged in user's own account.
+type User struct {
+	ID          uuid.UUID `json:"id"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	Email       string    `json:"email"`
+	IsChirpyRed bool      `json:"is_chirpy_red"`
+	Handle      string    `json:"handle"`
+	DisplayName string    `json:"display_name"`
+	Bio         string    `json:"bio"`
+	AvatarURL   string    `json:"avatar_url"`
+}
+
+// Profile is a user as anyone can see them.
+type Profile struct {
+	ID          uuid.UUID `json:"id"`
+	Handle      string    `json:"handle"`
+	DisplayName string    `json:"display_name"`
+	Bio         string    `json:"bio"`
+	AvatarURL   string    `json:"avatar_url"`
+	IsChirpyRed bool      `json:"is_chirpy_red"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Author is the author of a chirp or the actor of a notification.
+type Author struct {
+	ID          uuid.UUID `json:"id"`
+	Handle      string    `json:"handle"`
+	DisplayName string    `json:"display_name"`
+	AvatarURL   string    `json:"avatar_url"`
+}
+
+// Chirp is a post.
+type Chirp struct {
+	ID        uuid.UUID     `json:"id"`
+	CreatedAt time.Time     `json:"created_at"`
+	UpdatedAt time.Time     `json:"updated_at"`
+	Body      string        `json:"body"`
+	UserID    uuid.UUID     `json:"user_id"`
+	ReplyToID uuid.NullUUID `json:"reply_to_id"`
+	Author    Author        `json:"author"`
+	Entities  Entities      `json:"entities"`
+	Media     []Media       `json:"media"`
+}
+
+// Entities are the hashtags and mentions in a chirp body, as byte offsets.
+type Entities struct {
+	Hashtags []Hashtag `json:"hashtags"`
+	Mentions []Mention `json:"mentions"`
+}
+
+type Hashtag struct {
+	Tag   string `json:"tag"`
+	Start int    `json:"start"`
+	End   int    `json:"end"`
+}
+
+// Mention is an @handle. UserID is null if no user has the handle.
+type Mention struct {
+	Handle string        `json:"handle"`
+	UserID uuid.NullUUID `json:"user_id"`
+	Start  int           `json:"start"`
+	End    int           `json:"end"`
+}
+
+// Media is an upload attached to a chirp. Its dimensions, blurhash and
+// variants are set once it is processed.
+type Media struct {
+	ID          uuid.UUID      `json:"id"`
+	URL         string         `json:"url"`
+	ContentType string         `json:"content_type"`
+	SizeBytes   int64          `json:"size_bytes"`
+	Status      string         `json:"status"`
+	Width       *int           `json:"width"`
+	Height      *int           `json:"height"`
+	Blurhash    *string        `json:"blurhash"`
+	Variants    []MediaVariant `json:"variants"`
+	CreatedAt   time.Time      `json:"created_at"`
+}
+
+type MediaVariant struct {
+	Name   string `json:"name"`
+	URL    string `json:"url"`
+	Width  int    `json:"width"`
+	Height int    `json:"height"`
+}
+
+// Webhook is a subscription to events. Secret is only set on the webhook
+// CreateWebhook returns.
+type Webhook struct {
+	ID         uuid.UUID `json:"id"`
+	URL        string    `json:"url"`
+	EventTypes []string  `json:"event_types"`
+	Secret     string    `json:"secret,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// Delivery is an event sent, or to be sent, to a webhook.
+type Delivery struct {
+	ID            int64             `json:"id"`
+	EventType     string            `json:"event_type"`
+	Payload       json.RawMessage   `json:"payload"`
+	Status        string            `json:"status"`
+	Attempts      []DeliveryAttempt `json:"attempts"`
+	NextAttemptAt *time.Time        `json:"next_attempt_at"`
+	LastError     *string           `json:"last_error"`
+	CreatedAt     time.Time         `json:"created_at"`
+	DeliveredAt   *time.Time        `json:"delivered_at"`
+}
+
+type DeliveryAttempt struct {
+	AttemptedAt time.Time `json:"attempted_at"`
+	StatusCode  *int      `json:"status_code"`
+	Error       string    `json:"error"`
+	DurationMs  int64     `json:"duration_ms"`
+}
+
+// Notification is a group of notifications of the same type about the same
+// chirp.
+type Notification struct {
+	Type       string        `json:"type"`
+	ChirpID    uuid.NullUUID `json:"chirp_id"`
+	Summary    string        `json:"summary"`
+	ActorCount int           `json:"actor_count"`
+	Actors     []Author      `json:"actors"`
+	Unread     bool          `json:"unread"`
+	LatestAt   time.Time     `json:"latest_at"`
+	Cursor     string        `json:"cursor"`
+}
+
+// Message is a direct message in a conversation.
+type Message struct {
+	ID             int64     `json:"id"`
+	ConversationID uuid.UUID `json:"conversation_id"`
+	SenderID       uuid.UUID `json:"sender_id"`
+	Body           string    `json:"body"`
+	CreatedAt      time.Time `json:"created_at"`
+}
@@ -0,0 +1,83 @@
+package chirpyclient
+
+import (
+	"context"
+	"net/url"
+)
+
+// CreateUserParams are the fields of a new account. Handle is generated
+// when left empty.
+type CreateUserParams struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+	Handle   string `json:"handle,omitempty"`
+}
+
+// CreateUser signs up. It does not log in.
+func (c *Client) CreateUser(ctx context.Context, params CreateUserParams) (User, error) {
+	var user User
+	err := c.do(ctx, request{method: "POST", path: "/api/users", body: params}, &user)
+	return user, err
+}
+
+// UpdateUser changes the email and password of the logged in user.
+func (c *Client) UpdateUser(ctx context.Context, email, password string) (User, error) {
+	var user User
+	err := c.do(ctx, request{
+		method: "PUT",
+		path:   "/api/users",
+		body:   map[string]string{"email": email, "password": password},
+		auth:   accessAuth,
+	}, &user)
+	return user, err
+}
+
+// GetProfile returns the public profile of the user with handle.
+func (c *Client) GetProfile(ctx context.Context, handle string) (Profile, error) {
+	var p Profile
+	err := c.do(ctx, request{method: "GET", path: "/api/users/" + url.PathEscape(handle), auth: accessAuth}, &p)
+	return p, err
+}
+
+// Login logs in and keeps the tokens for later requests.
+func (c *Client) Login(ctx context.Context, email, password string) (User, error) {
+	var resp struct {
+		User
+		Tokens
+	}
+	err := c.do(ctx, request{
+		method: "POST",
+		path:   "/api/login",
+		body:   map[string]string{"email": email, "password": password},
+	}, &resp)
+	if err != nil {
+		return User{}, err
+	}
+	c.SetTokens(resp.Tokens)
+	return resp.User, nil
+}
+
+// Refresh renews the access token with the refresh token. Requests do so
+// by themselves when the access token expires.
+func (c *Client) Refresh(ctx context.Context) error {
+	return c.refreshAfter(ctx, c.Tokens().Access)
+}
+
+// Revoke revokes the refresh token and forgets the tokens, logging out.
+func (c *Client) Revoke(ctx context.Context) error {
+	if c.Tokens().Refresh == "" {
+		return nil
+	}
+	if err := c.do(ctx, request{method: "POST", path: "/api/revoke", auth: refreshAuth}, nil); err != nil {
+		return err
+	}
+	c.SetTokens(Tokens{})
+	return nil
+}
+
+// Me returns the logged in user's account.
+func (c *Client) Me(ctx context.Context) (User, error) {
+	var user User
+	err := c.do(ctx, request{method: "GET", path: "/api/users/me", auth: accessAuth}, &user)
+	return user, err
+}
@@ -0,0 +1,73 @@
+package chirpyclient
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+
+	"github.com/google/uuid"
+)
+
+// CreateWebhookParams are the fields of a new webhook. EventTypes are the
+// events it receives, such as chirp.created; at least one is required.
+type CreateWebhookParams struct {
+	URL        string   `json:"url"`
+	EventTypes []string `json:"event_types"`
+}
+
+// CreateWebhook registers a webhook. The returned webhook carries the
+// secret deliveries are signed with; it is not shown again.
+func (c *Client) CreateWebhook(ctx context.Context, params CreateWebhookParams) (Webhook, error) {
+	var hook Webhook
+	err := c.do(ctx, request{method: "POST", path: "/api/webhooks", body: params, auth: accessAuth}, &hook)
+	return hook, err
+}
+
+// ListWebhooks returns the webhooks of the logged in user.
+func (c *Client) ListWebhooks(ctx context.Context) ([]Webhook, error) {
+	var hooks []Webhook
+	err := c.do(ctx, request{method: "GET", path: "/api/webhooks", auth: accessAuth}, &hooks)
+	return hooks, err
+}
+
+// DeleteWebhook deletes a webhook and its deliveries.
+func (c *Client) DeleteWebhook(ctx context.Context, id uuid.UUID) error {
+	return c.do(ctx, request{method: "DELETE", path: "/api/webhooks/" + id.String(), auth: accessAuth}, nil)
+}
+
+// DeliveriesParams filter Deliveries.
+type DeliveriesParams struct {
+	// Status is pending, delivered or failed; empty means all.
+	Status string
+	// Limit caps the number of deliveries; the server's default applies
+	// when it is 0.
+	Limit int
+}
+
+// Deliveries returns the latest deliveries of a webhook, newest first.
+func (c *Client) Deliveries(ctx context.Context, webhookID uuid.UUID, params DeliveriesParams) ([]Delivery, error) {
+	query := url.Values{}
+	if params.Status != "" {
+		query.Set("status", params.Status)
+	}
+	if params.Limit > 0 {
+		query.Set("limit", strconv.Itoa(params.Limit))
+	}
+	var deliveries []Delivery
+	err := c.do(ctx, request{
+		method: "GET",
+		path:   "/api/webhooks/" + webhookID.String() + "/deliveries",
+		query:  query,
+		auth:   accessAuth,
+	}, &deliveries)
+	return deliveries, err
+}
+
+// ReplayDelivery queues a failed delivery again.
+func (c *Client) ReplayDelivery(ctx context.Context, webhookID uuid.UUID, deliveryID int64) error {
+	return c.do(ctx, request{
+		method: "POST",
+		path:   "/api/webhooks/" + webhookID.String() + "/deliveries/" + strconv.FormatInt(deliveryID, 10) + "/replay",
+		auth:   accessAuth,
+	}, nil)
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"time"
+
+	"github.com/RemcoVeens/httpserver/internal/database"
+	"github.com/RemcoVeens/httpserver/internal/seed"
+)
+
+// runSeed fills the database with fake users and chirps.
+func runSeed(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("seed", flag.ContinueOnError)
+	opts := seed.Options{Now: time.Now(), Span: 30 * 24 * time.Hour}
+	fs.IntVar(&opts.Users, "users", 50, "number of users")
+	fs.IntVar(&opts.Chirps, "chirps", 1000, "number of chirps")
+	fs.Uint64Var(&opts.Seed, "seed", rand.Uint64(), "seed of the random data, to generate the same data again (default random)")
+	fs.DurationVar(&opts.Span, "span", opts.Span, "how far back the chirps go")
+	fs.StringVar(&opts.Password, "password", "password", "password of every user")
+	force := fs.Bool("force", false, "seed even when PLATFORM is not dev")
+	cfg, err := loadConfig(fs, args)
+	if err != nil {
+		return err
+	}
+	switch {
+	case opts.Users < 1 || opts.Chirps < 0:
+		return usagef("seed needs at least one user and no negative number of chirps")
+	case opts.Span <= 0:
+		return usagef("-span must be positive")
+	}
+	if cfg.Platform != "dev" && !*force {
+		return fmt.Errorf("refusing to seed a database with PLATFORM=%q; use -force if you mean it", cfg.Platform)
+	}
+	if err := cfg.Database.Validate(); err != nil {
+		return err
+	}
+	db, err := database.Open(cfg.Database)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	log.Printf("seeding %d users and %d chirps with -seed %d", opts.Users, opts.Chirps, opts.Seed)
+	start := time.Now()
+	stats, err := seed.Run(ctx, db, opts)
+	log.Printf("inserted %d users and %d chirps in %s; skipped %d whose email or handle was taken", stats.Users, stats.Chirps, time.Since(start).Round(time.Millisecond), stats.Skipped)
+	return err
+}
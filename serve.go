@@ -0,0 +1,153 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/RemcoVeens/httpserver/internal/database"
+	"github.com/RemcoVeens/httpserver/internal/entitlements"
+	"github.com/RemcoVeens/httpserver/internal/events"
+	"github.com/RemcoVeens/httpserver/internal/handlers"
+	"github.com/RemcoVeens/httpserver/internal/inbox"
+	"github.com/RemcoVeens/httpserver/internal/media"
+	"github.com/RemcoVeens/httpserver/internal/openapi"
+	"github.com/RemcoVeens/httpserver/internal/polka"
+	"github.com/RemcoVeens/httpserver/internal/pubsub"
+	"github.com/RemcoVeens/httpserver/internal/ratelimit"
+	"github.com/RemcoVeens/httpserver/internal/server"
+	"github.com/RemcoVeens/httpserver/internal/subscriptions"
+	"github.com/RemcoVeens/httpserver/internal/web"
+	"github.com/RemcoVeens/httpserver/internal/webhooks"
+)
+
+// runServe runs the server until ctx is cancelled, then stops accepting
+// connections, drains the requests and streams in flight, lets the
+// background workers finish what they claimed and closes the database, all
+// within http.shutdown_timeout.
+func runServe(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ContinueOnError)
+	cfg, err := loadConfig(fs, args)
+	if err != nil {
+		return err
+	}
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+
+	apiC := handlers.APIConfig{
+		Platform:    cfg.Platform,
+		Secret:      cfg.Secret,
+		PolkaKey:    cfg.Polka.Key,
+		PolkaSecret: cfg.Polka.Secret,
+		AccessTTL:   cfg.Tokens.AccessTTL,
+		RefreshTTL:  cfg.Tokens.RefreshTTL,
+	}
+	apiC.DB, err = database.Open(cfg.Database)
+	if err != nil {
+		return err
+	}
+	defer apiC.DB.Close()
+	if cfg.MigrateOnStart {
+		// Replicas starting together take turns; all but the first find
+		// nothing left to do.
+		if err := migrateUp(ctx, apiC.DB); err != nil {
+			return err
+		}
+	}
+	apiC.Queries = database.New(apiC.DB)
+	limits, err := entitlements.Load(cfg.EntitlementsFile)
+	if err != nil {
+		return err
+	}
+	apiC.Entitlements = limits
+	apiC.Limiter = ratelimit.New()
+	apiC.Blobs, err = newBlobStore(cfg.Media)
+	if err != nil {
+		return err
+	}
+	apiC.Broker = pubsub.NewBroker(pubsub.DefaultReplaySize, pubsub.DefaultSubscriberBuffer)
+
+	// The workers outlive ctx: they are stopped once the requests that may
+	// give them work are done.
+	work, stopWork := context.WithCancel(context.WithoutCancel(ctx))
+	defer stopWork()
+	var workers sync.WaitGroup
+	workers.Go(func() {
+		if err := events.NewListener(cfg.Database.URL, apiC.Queries, apiC.Broker).Run(work); err != nil {
+			log.Printf("event listener stopped: %s", err)
+		}
+	})
+	workers.Go(func() {
+		webhooks.NewDispatcher(apiC.Queries, webhooks.NewClient(apiC.Platform == "dev")).Run(work)
+	})
+	workers.Go(func() { subscriptions.RunExpiry(work, apiC.DB) })
+	apiC.Processor = media.NewProcessor(apiC.DB, apiC.Blobs, cfg.Media.Workers)
+	workers.Go(func() { apiC.Processor.Run(work) })
+	workers.Go(func() { media.RunGC(work, apiC.Queries, apiC.Blobs) })
+	workers.Go(func() {
+		inbox.NewWorker(apiC.DB, map[string]inbox.Handler{polka.Provider: polka.Process}).Run(work)
+	})
+	site, err := newSite(apiC.Platform == "dev")
+	if err != nil {
+		return err
+	}
+	apiC.Pages, err = web.NewRenderer(func(name string) string { return "/app" + site.Path(name) })
+	if err != nil {
+		return err
+	}
+	doc, err := openapi.Load()
+	if err != nil {
+		return err
+	}
+	validator, err := openapi.NewValidator(doc, openapi.Spec())
+	if err != nil {
+		return err
+	}
+	// Checking responses buffers them, so only do it while developing.
+	validator.Responses = apiC.Platform == "dev"
+	srv := &http.Server{
+		Handler:           server.New(&apiC, site, validator),
+		Addr:              cfg.Listen,
+		ReadTimeout:       cfg.HTTP.ReadTimeout,
+		ReadHeaderTimeout: cfg.HTTP.ReadHeaderTimeout,
+		WriteTimeout:      cfg.HTTP.WriteTimeout,
+		IdleTimeout:       cfg.HTTP.IdleTimeout,
+	}
+	// Closing the broker ends the event streams and WebSockets, which
+	// would otherwise keep shutting down waiting until the deadline.
+	srv.RegisterOnShutdown(apiC.Broker.Close)
+	l, err := net.Listen("tcp", cfg.Listen)
+	if err != nil {
+		return err
+	}
+	log.Printf("listening on %s", l.Addr())
+	// Requests and background work share the shutdown timeout.
+	stopping := make(chan time.Time, 1)
+	context.AfterFunc(ctx, func() {
+		log.Print("shutting down")
+		stopping <- time.Now()
+	})
+	err = server.Serve(ctx, srv, l, cfg.HTTP.ShutdownTimeout)
+	deadline := time.Now().Add(cfg.HTTP.ShutdownTimeout)
+	if ctx.Err() != nil {
+		deadline = (<-stopping).Add(cfg.HTTP.ShutdownTimeout)
+	}
+
+	stopWork()
+	done := make(chan struct{})
+	go func() {
+		workers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Until(deadline)):
+		log.Print("background work still running; stopping anyway")
+	}
+	return err
+}
@@ -0,0 +1,6 @@
+-- name: IsAdmin :one
+SELECT EXISTS (SELECT 1 FROM admins WHERE user_id = $1)::bool;
+
+-- name: AddAdmin :exec
+INSERT INTO admins (user_id, created_at) VALUES ($1, NOW())
+ON CONFLICT (user_id) DO NOTHING;
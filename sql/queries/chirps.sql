@@ -1,21 +1,72 @@
 -- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, reply_to_id)
 VALUES (
     gen_random_uuid(),
     NOW(),
     NOW(),
-    $1, $2
+    $1, $2, $3
 )
 RETURNING *;
 
 -- name: GetChirps :many
-SELECT * FROM chirps ORDER BY created_at;
+SELECT c.* FROM chirps c
+WHERE NOT EXISTS (
+    SELECT 1 FROM blocks b
+    WHERE (b.blocker_id = c.user_id AND b.blocked_id = sqlc.arg(viewer_id))
+       OR (b.blocker_id = sqlc.arg(viewer_id) AND b.blocked_id = c.user_id)
+)
+AND NOT EXISTS (
+    SELECT 1 FROM mutes m WHERE m.muter_id = sqlc.arg(viewer_id) AND m.muted_id = c.user_id
+)
+ORDER BY c.created_at;
+
+-- name: GetLatestChirps :many
+SELECT c.* FROM chirps c
+WHERE NOT EXISTS (
+    SELECT 1 FROM blocks b
+    WHERE (b.blocker_id = c.user_id AND b.blocked_id = sqlc.arg(viewer_id))
+       OR (b.blocker_id = sqlc.arg(viewer_id) AND b.blocked_id = c.user_id)
+)
+AND NOT EXISTS (
+    SELECT 1 FROM mutes m WHERE m.muter_id = sqlc.arg(viewer_id) AND m.muted_id = c.user_id
+)
+ORDER BY c.created_at DESC
+LIMIT sqlc.arg(max_chirps);
 
 -- name: GetChirpFromId :one
 SELECT * FROM chirps WHERE id = $1;
 
+-- name: GetVisibleChirpFromId :one
+SELECT c.* FROM chirps c
+WHERE c.id = sqlc.arg(id)
+AND NOT EXISTS (
+    SELECT 1 FROM blocks b
+    WHERE (b.blocker_id = c.user_id AND b.blocked_id = sqlc.arg(viewer_id))
+       OR (b.blocker_id = sqlc.arg(viewer_id) AND b.blocked_id = c.user_id)
+);
+
 -- name: DeleteChirpFromID :exec
 DELETE FROM chirps WHERE id = $1;
 
 -- name: GetChirpsFromAuthorID :many
-SELECT * FROM chirps WHERE user_id = $1 ORDER BY created_at;
+SELECT c.* FROM chirps c
+WHERE c.user_id = sqlc.arg(user_id)
+AND NOT EXISTS (
+    SELECT 1 FROM blocks b
+    WHERE (b.blocker_id = c.user_id AND b.blocked_id = sqlc.arg(viewer_id))
+       OR (b.blocker_id = sqlc.arg(viewer_id) AND b.blocked_id = c.user_id)
+)
+ORDER BY c.created_at;
+
+-- name: GetChirpReplies :many
+SELECT c.* FROM chirps c
+WHERE c.reply_to_id = sqlc.arg(reply_to_id)
+AND NOT EXISTS (
+    SELECT 1 FROM blocks b
+    WHERE (b.blocker_id = c.user_id AND b.blocked_id = sqlc.arg(viewer_id))
+       OR (b.blocker_id = sqlc.arg(viewer_id) AND b.blocked_id = c.user_id)
+)
+AND NOT EXISTS (
+    SELECT 1 FROM mutes m WHERE m.muter_id = sqlc.arg(viewer_id) AND m.muted_id = c.user_id
+)
+ORDER BY c.created_at;
@@ -0,0 +1,13 @@
+-- name: NotifyEvent :exec
+SELECT pg_notify(sqlc.arg(channel)::text, sqlc.arg(payload)::text);
+
+-- name: CreateEventPayload :one
+INSERT INTO event_payloads (payload, created_at)
+VALUES ($1, NOW())
+RETURNING id;
+
+-- name: GetEventPayload :one
+SELECT payload FROM event_payloads WHERE id = $1;
+
+-- name: DeleteEventPayloadsBefore :exec
+DELETE FROM event_payloads WHERE created_at < $1;
@@ -0,0 +1,35 @@
+-- name: AddChirpHashtag :exec
+INSERT INTO chirp_hashtags (chirp_id, tag, created_at)
+VALUES ($1, $2, $3)
+ON CONFLICT DO NOTHING;
+
+-- name: AddChirpMention :exec
+INSERT INTO chirp_mentions (chirp_id, handle, user_id, start_offset, end_offset)
+VALUES ($1, $2, $3, $4, $5);
+
+-- name: GetMentionsForChirps :many
+SELECT * FROM chirp_mentions
+WHERE chirp_id = ANY(sqlc.arg(chirp_ids)::uuid[])
+ORDER BY chirp_id, start_offset;
+
+-- name: GetChirpsForHashtag :many
+SELECT c.* FROM chirps c
+INNER JOIN chirp_hashtags h ON h.chirp_id = c.id
+WHERE h.tag = sqlc.arg(tag)
+AND NOT EXISTS (
+    SELECT 1 FROM blocks b
+    WHERE (b.blocker_id = c.user_id AND b.blocked_id = sqlc.arg(viewer_id))
+       OR (b.blocker_id = sqlc.arg(viewer_id) AND b.blocked_id = c.user_id)
+)
+AND NOT EXISTS (
+    SELECT 1 FROM mutes m WHERE m.muter_id = sqlc.arg(viewer_id) AND m.muted_id = c.user_id
+)
+ORDER BY c.created_at DESC;
+
+-- name: GetTrendingHashtags :many
+SELECT tag, COUNT(*) AS uses, MAX(created_at)::timestamp AS last_used
+FROM chirp_hashtags
+WHERE created_at > NOW() - sqlc.arg(window_seconds)::float8 * INTERVAL '1 second'
+GROUP BY tag
+ORDER BY uses DESC, last_used DESC
+LIMIT sqlc.arg(max_tags);
@@ -0,0 +1,49 @@
+-- name: CreateInboundEvent :execrows
+INSERT INTO inbound_events (provider, provider_event_id, event_type, payload, subject, status, attempts, next_attempt_at, received_at)
+VALUES ($1, $2, $3, $4, $5, 'pending', 0, NOW(), NOW())
+ON CONFLICT (provider, provider_event_id) DO NOTHING;
+
+-- name: ClaimInboundEvents :many
+-- An event waits while an older one with the same subject is pending, so
+-- they are applied in order even when the older one is being retried.
+UPDATE inbound_events
+SET attempts = attempts + 1,
+    next_attempt_at = NOW() + sqlc.arg(lease_seconds)::int * INTERVAL '1 second'
+WHERE id IN (
+    SELECT e.id FROM inbound_events e
+    WHERE e.status = 'pending' AND e.next_attempt_at <= NOW()
+    AND NOT EXISTS (
+        SELECT 1 FROM inbound_events older
+        WHERE older.provider = e.provider AND older.subject = e.subject
+        AND older.status = 'pending' AND older.id < e.id
+    )
+    ORDER BY e.id
+    LIMIT sqlc.arg(batch_size)
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: MarkInboundEventProcessed :exec
+UPDATE inbound_events SET status = 'processed', processed_at = NOW(), last_error = NULL WHERE id = $1;
+
+-- name: RescheduleInboundEvent :exec
+UPDATE inbound_events SET next_attempt_at = $2, last_error = $3 WHERE id = $1;
+
+-- name: MarkInboundEventDead :exec
+UPDATE inbound_events SET status = 'dead', last_error = $2 WHERE id = $1;
+
+-- name: GetInboundEvent :one
+SELECT * FROM inbound_events WHERE id = $1;
+
+-- name: GetInboundEvents :many
+SELECT * FROM inbound_events
+WHERE id < sqlc.arg(before_id)::bigint
+AND (sqlc.narg(status)::text IS NULL OR status = sqlc.narg(status)::text)
+AND (sqlc.narg(provider)::text IS NULL OR provider = sqlc.narg(provider)::text)
+ORDER BY id DESC
+LIMIT sqlc.arg(max_events);
+
+-- name: ReplayInboundEvent :execrows
+UPDATE inbound_events
+SET status = 'pending', attempts = 0, next_attempt_at = NOW(), processed_at = NULL
+WHERE id = $1 AND status <> 'pending';
@@ -0,0 +1,66 @@
+-- name: CreateMediaFile :one
+INSERT INTO media_files (id, user_id, storage_key, content_type, size_bytes, created_at)
+VALUES ($1, $2, $3, $4, $5, NOW())
+RETURNING *;
+
+-- name: GetMediaFile :one
+SELECT * FROM media_files WHERE id = $1;
+
+-- name: AttachMediaFile :execrows
+UPDATE media_files
+SET chirp_id = sqlc.arg(chirp_id), position = sqlc.arg(position), attached_at = NOW()
+WHERE id = sqlc.arg(id) AND user_id = sqlc.arg(user_id)
+  AND chirp_id IS NULL AND attached_at IS NULL AND status <> 'failed';
+
+-- name: GetMediaForChirps :many
+SELECT * FROM media_files
+WHERE chirp_id = ANY(sqlc.arg(chirp_ids)::uuid[])
+ORDER BY chirp_id, position;
+
+-- name: GetUnattachedMediaFiles :many
+SELECT * FROM media_files
+WHERE chirp_id IS NULL AND created_at < sqlc.arg(before)
+ORDER BY created_at
+LIMIT sqlc.arg(max_files);
+
+-- name: DeleteUnattachedMediaFile :execrows
+DELETE FROM media_files WHERE id = $1 AND chirp_id IS NULL;
+
+-- name: ClaimMediaFiles :many
+UPDATE media_files
+SET attempts = attempts + 1,
+    next_attempt_at = NOW() + sqlc.arg(lease_seconds)::int * INTERVAL '1 second'
+WHERE id IN (
+    SELECT id FROM media_files
+    WHERE status = 'pending' AND next_attempt_at <= NOW()
+    ORDER BY next_attempt_at
+    LIMIT sqlc.arg(batch_size)
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: MarkMediaFileReady :exec
+UPDATE media_files
+SET status = 'ready', width = $2, height = $3, blurhash = $4, size_bytes = $5,
+    processing_error = NULL, processed_at = NOW()
+WHERE id = $1;
+
+-- name: MarkMediaFileFailed :exec
+UPDATE media_files
+SET status = 'failed', processing_error = $2, processed_at = NOW()
+WHERE id = $1;
+
+-- name: UpsertMediaVariant :exec
+INSERT INTO media_variants (media_id, name, storage_key, content_type, width, height, size_bytes)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+ON CONFLICT (media_id, name) DO UPDATE
+SET storage_key = EXCLUDED.storage_key,
+    content_type = EXCLUDED.content_type,
+    width = EXCLUDED.width,
+    height = EXCLUDED.height,
+    size_bytes = EXCLUDED.size_bytes;
+
+-- name: GetMediaVariants :many
+SELECT * FROM media_variants
+WHERE media_id = ANY(sqlc.arg(media_ids)::uuid[])
+ORDER BY media_id, width;
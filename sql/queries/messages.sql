@@ -0,0 +1,89 @@
+-- name: CreateConversation :one
+INSERT INTO conversations (id, created_at, updated_at, is_group)
+VALUES (gen_random_uuid(), NOW(), NOW(), $1)
+RETURNING *;
+
+-- name: CreateDirectConversation :one
+-- Returns no row when the two users already have a conversation.
+INSERT INTO conversations (id, created_at, updated_at, is_group, direct_user_a, direct_user_b)
+VALUES (gen_random_uuid(), NOW(), NOW(), false, sqlc.arg(user_a)::uuid, sqlc.arg(user_b)::uuid)
+ON CONFLICT DO NOTHING
+RETURNING *;
+
+-- name: AddConversationMember :exec
+INSERT INTO conversation_members (conversation_id, user_id, joined_at)
+VALUES ($1, $2, NOW());
+
+-- name: GetDirectConversation :one
+SELECT c.* FROM conversations c
+WHERE c.direct_user_a IS NOT NULL AND c.direct_user_b IS NOT NULL
+AND LEAST(c.direct_user_a, c.direct_user_b) = LEAST(sqlc.arg(user_a)::uuid, sqlc.arg(user_b)::uuid)
+AND GREATEST(c.direct_user_a, c.direct_user_b) = GREATEST(sqlc.arg(user_a)::uuid, sqlc.arg(user_b)::uuid);
+
+-- name: IsConversationMember :one
+SELECT EXISTS (
+    SELECT 1 FROM conversation_members WHERE conversation_id = $1 AND user_id = $2
+)::bool;
+
+-- name: HasBlockWithUsers :one
+SELECT EXISTS (
+    SELECT 1 FROM blocks
+    WHERE (blocker_id = sqlc.arg(user_id) AND blocked_id = ANY(sqlc.arg(other_ids)::uuid[]))
+       OR (blocked_id = sqlc.arg(user_id) AND blocker_id = ANY(sqlc.arg(other_ids)::uuid[]))
+)::bool;
+
+-- name: GetConversationMemberIDs :many
+SELECT user_id FROM conversation_members WHERE conversation_id = $1 ORDER BY joined_at, user_id;
+
+-- name: GetMembersForConversations :many
+SELECT cm.conversation_id, u.id, u.handle, u.display_name, u.avatar_url
+FROM conversation_members cm
+INNER JOIN users u ON u.id = cm.user_id
+WHERE cm.conversation_id = ANY(sqlc.arg(conversation_ids)::uuid[])
+ORDER BY cm.conversation_id, cm.joined_at, u.id;
+
+-- name: CreateMessage :one
+INSERT INTO messages (conversation_id, sender_id, body, created_at)
+VALUES ($1, $2, $3, NOW())
+RETURNING *;
+
+-- name: TouchConversation :exec
+UPDATE conversations SET updated_at = NOW() WHERE id = $1;
+
+-- name: MarkConversationRead :exec
+UPDATE conversation_members
+SET last_read_message_id = GREATEST(last_read_message_id, sqlc.arg(message_id)::bigint)
+WHERE conversation_id = sqlc.arg(conversation_id) AND user_id = sqlc.arg(user_id);
+
+-- name: GetConversationsForUser :many
+SELECT
+    c.id,
+    c.is_group,
+    c.created_at,
+    c.updated_at,
+    lm.id AS last_message_id,
+    lm.sender_id AS last_message_sender_id,
+    lm.body AS last_message_body,
+    lm.created_at AS last_message_at,
+    (
+        SELECT COUNT(*) FROM messages m
+        WHERE m.conversation_id = c.id
+        AND m.id > cm.last_read_message_id
+        AND m.sender_id <> cm.user_id
+    ) AS unread_count
+FROM conversation_members cm
+INNER JOIN conversations c ON c.id = cm.conversation_id
+LEFT JOIN LATERAL (
+    SELECT id, sender_id, body, created_at FROM messages
+    WHERE conversation_id = c.id
+    ORDER BY id DESC
+    LIMIT 1
+) lm ON true
+WHERE cm.user_id = $1
+ORDER BY c.updated_at DESC;
+
+-- name: GetMessages :many
+SELECT * FROM messages
+WHERE conversation_id = sqlc.arg(conversation_id) AND id < sqlc.arg(before_id)::bigint
+ORDER BY id DESC
+LIMIT sqlc.arg(max_messages);
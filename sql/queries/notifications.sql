@@ -0,0 +1,38 @@
+-- name: CreateNotification :execrows
+INSERT INTO notifications (user_id, actor_id, type, chirp_id, group_key, created_at)
+SELECT sqlc.arg(user_id)::uuid, sqlc.arg(actor_id)::uuid, sqlc.arg(type)::text, sqlc.narg(chirp_id)::uuid, sqlc.arg(group_key)::text, NOW()
+WHERE sqlc.arg(user_id)::uuid <> sqlc.arg(actor_id)::uuid
+AND NOT EXISTS (
+    SELECT 1 FROM blocks WHERE blocker_id = sqlc.arg(user_id)::uuid AND blocked_id = sqlc.arg(actor_id)::uuid
+);
+
+-- name: GetNotificationGroups :many
+SELECT
+    group_key,
+    type,
+    chirp_id,
+    COUNT(DISTINCT actor_id) AS actor_count,
+    ARRAY(
+        SELECT r.actor_id FROM notifications r
+        WHERE r.user_id = sqlc.arg(user_id) AND r.group_key = n.group_key
+        AND r.type = n.type AND r.chirp_id IS NOT DISTINCT FROM n.chirp_id
+        GROUP BY r.actor_id
+        ORDER BY MAX(r.id) DESC
+        LIMIT 3
+    )::uuid[] AS recent_actor_ids,
+    MAX(id)::bigint AS latest_id,
+    MAX(created_at)::timestamp AS latest_at,
+    BOOL_OR(read_at IS NULL) AS unread
+FROM notifications n
+WHERE user_id = sqlc.arg(user_id)
+GROUP BY group_key, type, chirp_id
+HAVING MAX(id) < sqlc.arg(before_id)::bigint
+ORDER BY latest_id DESC
+LIMIT sqlc.arg(max_groups);
+
+-- name: MarkNotificationsRead :exec
+UPDATE notifications SET read_at = NOW()
+WHERE user_id = sqlc.arg(user_id) AND id <= sqlc.arg(up_to_id) AND read_at IS NULL;
+
+-- name: CountUnreadNotifications :one
+SELECT COUNT(*) FROM notifications WHERE user_id = $1 AND read_at IS NULL;
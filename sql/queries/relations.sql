@@ -0,0 +1,37 @@
+-- name: BlockUser :exec
+INSERT INTO blocks (blocker_id, blocked_id, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT DO NOTHING;
+
+-- name: UnblockUser :exec
+DELETE FROM blocks WHERE blocker_id = $1 AND blocked_id = $2;
+
+-- name: MuteUser :exec
+INSERT INTO mutes (muter_id, muted_id, created_at)
+VALUES ($1, $2, NOW())
+ON CONFLICT DO NOTHING;
+
+-- name: UnmuteUser :exec
+DELETE FROM mutes WHERE muter_id = $1 AND muted_id = $2;
+
+-- name: IsBlockedBy :one
+SELECT EXISTS (
+    SELECT 1 FROM blocks WHERE blocker_id = sqlc.arg(blocker_id) AND blocked_id = sqlc.arg(blocked_id)
+)::bool;
+
+-- name: GetBlockedUsers :many
+SELECT blocked_id, created_at FROM blocks
+WHERE blocker_id = $1
+ORDER BY created_at;
+
+-- name: GetMutedUsers :many
+SELECT muted_id, created_at FROM mutes
+WHERE muter_id = $1
+ORDER BY created_at;
+
+-- name: GetHiddenAuthorIDs :many
+SELECT blocked_id AS user_id FROM blocks WHERE blocker_id = sqlc.arg(viewer_id)
+UNION
+SELECT blocker_id FROM blocks WHERE blocked_id = sqlc.arg(viewer_id)
+UNION
+SELECT muted_id FROM mutes WHERE muter_id = sqlc.arg(viewer_id);
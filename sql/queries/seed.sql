@@ -0,0 +1,10 @@
+-- name: SeedUser :one
+INSERT INTO users (id, created_at, updated_at, email, hashed_password, handle, display_name, bio)
+VALUES (gen_random_uuid(), $1, $1, $2, $3, $4, $5, $6)
+ON CONFLICT DO NOTHING
+RETURNING *;
+
+-- name: SeedChirp :one
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, reply_to_id)
+VALUES (gen_random_uuid(), $1, $1, $2, $3, $4)
+RETURNING *;
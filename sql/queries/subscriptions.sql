@@ -0,0 +1,44 @@
+-- name: UpsertSubscription :one
+INSERT INTO subscriptions (id, user_id, plan, status, current_period_end, created_at, updated_at)
+VALUES (gen_random_uuid(), $1, $2, 'active', $3, NOW(), NOW())
+ON CONFLICT (user_id) WHERE status <> 'expired' DO UPDATE
+SET plan = EXCLUDED.plan,
+    status = 'active',
+    current_period_end = COALESCE(EXCLUDED.current_period_end, subscriptions.current_period_end),
+    updated_at = NOW()
+RETURNING *;
+
+-- name: CancelSubscription :execrows
+UPDATE subscriptions
+SET status = 'cancelled', current_period_end = COALESCE(current_period_end, NOW()), updated_at = NOW()
+WHERE user_id = $1 AND status <> 'expired';
+
+-- name: MarkSubscriptionPastDue :execrows
+UPDATE subscriptions
+SET status = 'past_due', updated_at = NOW()
+WHERE user_id = $1 AND status IN ('active', 'past_due');
+
+-- name: EndSubscription :execrows
+UPDATE subscriptions
+SET status = 'expired', current_period_end = LEAST(COALESCE(current_period_end, NOW()), NOW()), updated_at = NOW()
+WHERE user_id = $1 AND status <> 'expired';
+
+-- name: ExpireSubscriptions :many
+UPDATE subscriptions
+SET status = 'expired', updated_at = NOW()
+WHERE status <> 'expired' AND current_period_end <= NOW()
+RETURNING user_id;
+
+-- name: GetSubscriptionsForUser :many
+SELECT * FROM subscriptions WHERE user_id = $1 ORDER BY created_at DESC;
+
+-- name: SyncChirpyRed :one
+UPDATE users
+SET is_chirpy_red = EXISTS (
+    SELECT 1 FROM subscriptions
+    WHERE subscriptions.user_id = users.id
+    AND status <> 'expired'
+    AND (current_period_end IS NULL OR current_period_end > NOW())
+), updated_at = NOW()
+WHERE id = $1
+RETURNING is_chirpy_red;
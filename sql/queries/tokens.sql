@@ -17,3 +17,14 @@ WHERE token =$1;
 SELECT u.* FROM users u
 INNER JOIN refresh_token rt ON rt.user_id = u.id
 WHERE rt.token = $1;
+
+-- name: RevokeActiveToken :one
+UPDATE refresh_token
+SET revoked_at = NOW(), updated_at = NOW()
+WHERE token = $1 AND revoked_at IS NULL AND expires_at > NOW()
+RETURNING *;
+
+-- name: RevokeUserTokens :exec
+UPDATE refresh_token
+SET revoked_at = NOW(), updated_at = NOW()
+WHERE user_id = $1 AND revoked_at IS NULL;
@@ -1,10 +1,10 @@
 -- name: CreateUser :one
-INSERT INTO users (id, created_at, updated_at, email, hashed_password)
+INSERT INTO users (id, created_at, updated_at, email, hashed_password, handle)
 VALUES (
     gen_random_uuid(),
     NOW(),
     NOW(),
-    $1,$2
+    $1,$2,$3
 )
 RETURNING *;
 
@@ -17,8 +17,37 @@ SELECT * FROM users WHERE id=$1;
 -- name: GetUserFromEmail :one
 SELECT * FROM users WHERE email=$1;
 
+-- name: GetUserFromHandle :one
+SELECT * FROM users WHERE LOWER(handle) = LOWER($1);
+
 -- name: UpdateUser :exec
 UPDATE users SET email=$1, hashed_password=$2 WHERE id = $3;
 
--- name: UpgradeUserFromID :exec
-UPDATE users SET is_chirpy_red=True WHERE id = $1;
+-- name: UpdateUserProfile :one
+UPDATE users SET
+    handle = COALESCE(sqlc.narg(handle), handle),
+    display_name = COALESCE(sqlc.narg(display_name), display_name),
+    bio = COALESCE(sqlc.narg(bio), bio),
+    avatar_url = COALESCE(sqlc.narg(avatar_url), avatar_url),
+    updated_at = NOW()
+WHERE id = sqlc.arg(id)
+RETURNING *;
+
+-- name: GetAuthorsFromIDs :many
+SELECT id, handle, display_name, avatar_url FROM users
+WHERE id = ANY(sqlc.arg(ids)::uuid[]);
+
+-- name: GetUserIDsFromHandles :many
+SELECT id, LOWER(handle)::text AS handle FROM users
+WHERE LOWER(handle) = ANY(sqlc.arg(handles)::text[]);
+
+-- name: GetActiveUserFromId :one
+SELECT * FROM users
+WHERE id = $1 AND NOT EXISTS (SELECT 1 FROM disabled_users d WHERE d.user_id = users.id);
+
+-- name: IsDisabled :one
+SELECT EXISTS (SELECT 1 FROM disabled_users WHERE user_id = $1)::bool;
+
+-- name: DisableUser :exec
+INSERT INTO disabled_users (user_id, reason, disabled_at) VALUES ($1, $2, NOW())
+ON CONFLICT (user_id) DO NOTHING;
@@ -0,0 +1,72 @@
+-- name: CreateWebhook :one
+INSERT INTO webhooks (id, user_id, url, secret, event_types, created_at, updated_at)
+VALUES (gen_random_uuid(), $1, $2, $3, $4, NOW(), NOW())
+RETURNING *;
+
+-- name: GetWebhook :one
+SELECT * FROM webhooks WHERE id = $1;
+
+-- name: GetWebhooksForUser :many
+SELECT * FROM webhooks WHERE user_id = $1 ORDER BY created_at;
+
+-- name: DeleteWebhook :execrows
+DELETE FROM webhooks WHERE id = $1 AND user_id = $2;
+
+-- name: EnqueueWebhookDeliveries :exec
+-- user_id is the user the event is about. Public events reach the endpoints
+-- of everyone without a block with that user, others only that user's own.
+INSERT INTO webhook_deliveries (webhook_id, event_type, payload, status, attempts, next_attempt_at, created_at)
+SELECT w.id, sqlc.arg(event_type)::text, sqlc.arg(payload)::jsonb, 'pending', 0, NOW(), NOW()
+FROM webhooks w
+WHERE sqlc.arg(event_type)::text = ANY(w.event_types)
+AND (
+    w.user_id = sqlc.arg(user_id)
+    OR (sqlc.arg(public)::bool AND NOT EXISTS (
+        SELECT 1 FROM blocks b
+        WHERE (b.blocker_id = sqlc.arg(user_id) AND b.blocked_id = w.user_id)
+           OR (b.blocker_id = w.user_id AND b.blocked_id = sqlc.arg(user_id))
+    ))
+);
+
+-- name: ClaimWebhookDeliveries :many
+UPDATE webhook_deliveries
+SET attempts = attempts + 1,
+    next_attempt_at = NOW() + sqlc.arg(lease_seconds)::int * INTERVAL '1 second'
+WHERE id IN (
+    SELECT id FROM webhook_deliveries
+    WHERE status = 'pending' AND next_attempt_at <= NOW()
+    ORDER BY next_attempt_at
+    LIMIT sqlc.arg(batch_size)
+    FOR UPDATE SKIP LOCKED
+)
+RETURNING *;
+
+-- name: RecordWebhookAttempt :exec
+INSERT INTO webhook_delivery_attempts (delivery_id, attempted_at, status_code, error, duration_ms)
+VALUES ($1, NOW(), $2, $3, $4);
+
+-- name: MarkWebhookDelivered :exec
+UPDATE webhook_deliveries SET status = 'delivered', delivered_at = NOW(), last_error = NULL WHERE id = $1;
+
+-- name: RescheduleWebhookDelivery :exec
+UPDATE webhook_deliveries SET next_attempt_at = $2, last_error = $3 WHERE id = $1;
+
+-- name: FailWebhookDelivery :exec
+UPDATE webhook_deliveries SET status = 'failed', last_error = $2 WHERE id = $1;
+
+-- name: GetWebhookDeliveries :many
+SELECT * FROM webhook_deliveries
+WHERE webhook_id = sqlc.arg(webhook_id)
+AND (sqlc.narg(status)::text IS NULL OR status = sqlc.narg(status)::text)
+ORDER BY id DESC
+LIMIT sqlc.arg(max_deliveries);
+
+-- name: GetAttemptsForDeliveries :many
+SELECT * FROM webhook_delivery_attempts
+WHERE delivery_id = ANY(sqlc.arg(delivery_ids)::bigint[])
+ORDER BY delivery_id, id;
+
+-- name: ReplayWebhookDelivery :execrows
+UPDATE webhook_deliveries
+SET status = 'pending', attempts = 0, next_attempt_at = NOW()
+WHERE id = $1 AND webhook_id = $2 AND status = 'failed';
@@ -0,0 +1,16 @@
+-- +goose Up
+CREATE TABLE notifications(
+    id BIGSERIAL PRIMARY KEY,
+    user_id UUID REFERENCES users(id) ON DELETE CASCADE NOT NULL,
+    actor_id UUID REFERENCES users(id) ON DELETE CASCADE NOT NULL,
+    type TEXT NOT NULL CHECK (type IN ('reply', 'mention', 'like', 'follow', 'rechirp')),
+    chirp_id UUID REFERENCES chirps(id) ON DELETE CASCADE,
+    group_key TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    read_at TIMESTAMP
+);
+CREATE INDEX notifications_user_id_id_idx ON notifications(user_id, id DESC);
+CREATE INDEX notifications_unread_idx ON notifications(user_id) WHERE read_at IS NULL;
+
+-- +goose Down
+DROP TABLE notifications;
@@ -0,0 +1,10 @@
+-- +goose Up
+CREATE TABLE event_payloads(
+    id BIGSERIAL PRIMARY KEY,
+    payload JSONB NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+CREATE INDEX event_payloads_created_at_idx ON event_payloads(created_at);
+
+-- +goose Down
+DROP TABLE event_payloads;
@@ -0,0 +1,9 @@
+-- +goose Up
+CREATE TABLE polka_events(
+    event_id TEXT PRIMARY KEY,
+    event TEXT NOT NULL,
+    received_at TIMESTAMP NOT NULL
+);
+
+-- +goose Down
+DROP TABLE polka_events;
@@ -0,0 +1,23 @@
+-- +goose Up
+-- A user has at most one current subscription; expired ones are kept as
+-- history. users.is_chirpy_red is derived from this table whenever a
+-- subscription changes.
+CREATE TABLE subscriptions(
+    id UUID PRIMARY KEY,
+    user_id UUID REFERENCES users(id) ON DELETE CASCADE NOT NULL,
+    plan TEXT NOT NULL,
+    status TEXT NOT NULL CHECK (status IN ('active', 'past_due', 'cancelled', 'expired')),
+    current_period_end TIMESTAMP,
+    created_at TIMESTAMP NOT NULL,
+    updated_at TIMESTAMP NOT NULL
+);
+CREATE UNIQUE INDEX subscriptions_current_idx ON subscriptions(user_id) WHERE status <> 'expired';
+CREATE INDEX subscriptions_period_end_idx ON subscriptions(current_period_end) WHERE status <> 'expired';
+
+-- Users upgraded before subscriptions were tracked keep an open-ended one.
+INSERT INTO subscriptions (id, user_id, plan, status, current_period_end, created_at, updated_at)
+SELECT gen_random_uuid(), id, 'chirpy_red', 'active', NULL, NOW(), NOW()
+FROM users WHERE is_chirpy_red;
+
+-- +goose Down
+DROP TABLE subscriptions;
@@ -0,0 +1,34 @@
+-- +goose Up
+CREATE TABLE inbound_events(
+    id BIGSERIAL PRIMARY KEY,
+    provider TEXT NOT NULL,
+    provider_event_id TEXT,
+    event_type TEXT NOT NULL,
+    payload JSONB NOT NULL,
+    status TEXT NOT NULL CHECK (status IN ('pending', 'processed', 'dead')),
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP NOT NULL,
+    last_error TEXT,
+    received_at TIMESTAMP NOT NULL,
+    processed_at TIMESTAMP
+);
+CREATE UNIQUE INDEX inbound_events_provider_event_idx ON inbound_events(provider, provider_event_id);
+CREATE INDEX inbound_events_due_idx ON inbound_events(next_attempt_at) WHERE status = 'pending';
+
+-- Polka event ids seen so far keep rejecting replays.
+INSERT INTO inbound_events (provider, provider_event_id, event_type, payload, status, attempts, next_attempt_at, received_at, processed_at)
+SELECT 'polka', event_id, event, '{}', 'processed', 1, received_at, received_at, received_at
+FROM polka_events;
+DROP TABLE polka_events;
+
+-- +goose Down
+CREATE TABLE polka_events(
+    event_id TEXT PRIMARY KEY,
+    event TEXT NOT NULL,
+    received_at TIMESTAMP NOT NULL
+);
+INSERT INTO polka_events (event_id, event, received_at)
+SELECT provider_event_id, event_type, received_at
+FROM inbound_events
+WHERE provider = 'polka' AND provider_event_id IS NOT NULL;
+DROP TABLE inbound_events;
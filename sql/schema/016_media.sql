@@ -0,0 +1,17 @@
+-- +goose Up
+CREATE TABLE media_files(
+    id UUID PRIMARY KEY,
+    user_id UUID REFERENCES users(id) ON DELETE CASCADE NOT NULL,
+    chirp_id UUID REFERENCES chirps(id) ON DELETE SET NULL,
+    position SMALLINT NOT NULL DEFAULT 0,
+    storage_key TEXT NOT NULL,
+    content_type TEXT NOT NULL,
+    size_bytes BIGINT NOT NULL,
+    created_at TIMESTAMP NOT NULL,
+    attached_at TIMESTAMP
+);
+CREATE INDEX media_files_chirp_id_idx ON media_files(chirp_id, position);
+CREATE INDEX media_files_unattached_idx ON media_files(created_at) WHERE chirp_id IS NULL;
+
+-- +goose Down
+DROP TABLE media_files;
@@ -0,0 +1,35 @@
+-- +goose Up
+ALTER TABLE media_files
+    ADD COLUMN status TEXT NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'ready', 'failed')),
+    ADD COLUMN attempts INTEGER NOT NULL DEFAULT 0,
+    ADD COLUMN next_attempt_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    ADD COLUMN width INTEGER,
+    ADD COLUMN height INTEGER,
+    ADD COLUMN blurhash TEXT,
+    ADD COLUMN processing_error TEXT,
+    ADD COLUMN processed_at TIMESTAMP;
+CREATE INDEX media_files_pending_idx ON media_files(next_attempt_at) WHERE status = 'pending';
+
+CREATE TABLE media_variants(
+    media_id UUID REFERENCES media_files(id) ON DELETE CASCADE NOT NULL,
+    name TEXT NOT NULL,
+    storage_key TEXT NOT NULL,
+    content_type TEXT NOT NULL,
+    width INTEGER NOT NULL,
+    height INTEGER NOT NULL,
+    size_bytes BIGINT NOT NULL,
+    PRIMARY KEY (media_id, name)
+);
+
+-- +goose Down
+DROP TABLE media_variants;
+DROP INDEX media_files_pending_idx;
+ALTER TABLE media_files
+    DROP COLUMN status,
+    DROP COLUMN attempts,
+    DROP COLUMN next_attempt_at,
+    DROP COLUMN width,
+    DROP COLUMN height,
+    DROP COLUMN blurhash,
+    DROP COLUMN processing_error,
+    DROP COLUMN processed_at;
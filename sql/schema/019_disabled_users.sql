@@ -0,0 +1,9 @@
+-- +goose Up
+CREATE TABLE disabled_users(
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    reason TEXT NOT NULL DEFAULT '',
+    disabled_at TIMESTAMP NOT NULL
+);
+
+-- +goose Down
+DROP TABLE disabled_users;
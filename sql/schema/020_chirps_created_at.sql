@@ -0,0 +1,5 @@
+-- +goose Up
+CREATE INDEX chirps_created_at_idx ON chirps(created_at);
+
+-- +goose Down
+DROP INDEX chirps_created_at_idx;
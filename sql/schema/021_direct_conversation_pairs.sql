@@ -0,0 +1,34 @@
+-- +goose Up
+ALTER TABLE conversations
+    ADD COLUMN direct_user_a UUID REFERENCES users(id) ON DELETE SET NULL,
+    ADD COLUMN direct_user_b UUID REFERENCES users(id) ON DELETE SET NULL;
+
+-- Record the members of existing one-to-one conversations. Where a pair
+-- already has several, only the oldest is kept as theirs.
+UPDATE conversations c
+SET direct_user_a = pair.user_a, direct_user_b = pair.user_b
+FROM (
+    SELECT DISTINCT ON (user_a, user_b) conversation_id, user_a, user_b
+    FROM (
+        SELECT cm.conversation_id,
+            MIN(cm.user_id::text)::uuid AS user_a,
+            MAX(cm.user_id::text)::uuid AS user_b,
+            c.created_at
+        FROM conversation_members cm
+        INNER JOIN conversations c ON c.id = cm.conversation_id
+        WHERE NOT c.is_group
+        GROUP BY cm.conversation_id, c.created_at
+        HAVING COUNT(*) = 2
+    ) members
+    ORDER BY user_a, user_b, created_at
+) pair
+WHERE c.id = pair.conversation_id;
+
+CREATE UNIQUE INDEX conversations_direct_pair_idx
+    ON conversations (LEAST(direct_user_a, direct_user_b), GREATEST(direct_user_a, direct_user_b))
+    WHERE direct_user_a IS NOT NULL AND direct_user_b IS NOT NULL;
+
+-- +goose Down
+ALTER TABLE conversations
+    DROP COLUMN direct_user_a,
+    DROP COLUMN direct_user_b;
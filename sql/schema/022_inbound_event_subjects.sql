@@ -0,0 +1,9 @@
+-- +goose Up
+-- subject names what an event is about, such as the user a Polka event
+-- changes. Events with the same subject are applied in the order received.
+ALTER TABLE inbound_events ADD COLUMN subject TEXT;
+UPDATE inbound_events SET subject = payload->'data'->>'user_id' WHERE provider = 'polka';
+CREATE INDEX inbound_events_pending_subject_idx ON inbound_events(provider, subject, id) WHERE status = 'pending';
+
+-- +goose Down
+ALTER TABLE inbound_events DROP COLUMN subject;
@@ -0,0 +1,10 @@
+-- +goose Up
+-- Databases migrated before this table had its own migration got it from
+-- 015_inbound_events.sql.
+CREATE TABLE IF NOT EXISTS admins(
+    user_id UUID PRIMARY KEY REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP NOT NULL
+);
+
+-- +goose Down
+DROP TABLE admins;
@@ -0,0 +1,8 @@
+-- +goose Up
+-- Only replies and mentions create notifications.
+ALTER TABLE notifications DROP CONSTRAINT notifications_type_check;
+ALTER TABLE notifications ADD CONSTRAINT notifications_type_check CHECK (type IN ('reply', 'mention'));
+
+-- +goose Down
+ALTER TABLE notifications DROP CONSTRAINT notifications_type_check;
+ALTER TABLE notifications ADD CONSTRAINT notifications_type_check CHECK (type IN ('reply', 'mention', 'like', 'follow', 'rechirp'));
@@ -0,0 +1,11 @@
+// Package schema embeds the database migrations. They are written for
+// goose: each file is a version, with -- +goose Up and -- +goose Down
+// sections. sqlc reads them too, to know the tables.
+package schema
+
+import "embed"
+
+// FS holds the migrations, named <version>_<name>.sql.
+//
+//go:embed *.sql
+var FS embed.FS
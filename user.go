@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/RemcoVeens/httpserver/internal/auth"
+	"github.com/RemcoVeens/httpserver/internal/database"
+	"github.com/RemcoVeens/httpserver/internal/handlers"
+	"github.com/RemcoVeens/httpserver/internal/handles"
+	"golang.org/x/term"
+)
+
+// runUser manages accounts:
+//
+//	user create -email address [-handle handle] [-admin]
+//	user promote <email or @handle>
+//	user disable [-reason text] <email or @handle>
+//
+// Configuration flags, such as -config, go before the command.
+func runUser(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("user", flag.ContinueOnError)
+	conf, err := loadConfig(fs, args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		return usagef("usage: user [flags] create|promote|disable")
+	}
+	if err := conf.Database.Validate(); err != nil {
+		return err
+	}
+	db, err := database.Open(conf.Database)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	cfg := &handlers.APIConfig{DB: db, Queries: database.New(db)}
+	args = fs.Args()
+	switch args[0] {
+	case "create":
+		return createUser(ctx, cfg, args[1:])
+	case "promote":
+		return promoteUser(ctx, cfg, args[1:])
+	case "disable":
+		return disableUser(ctx, cfg, args[1:])
+	}
+	return usagef("unknown user command %q; want create, promote or disable", args[0])
+}
+
+// createUser creates an account. The password is read from the terminal,
+// or as a line of stdin.
+func createUser(ctx context.Context, cfg *handlers.APIConfig, args []string) error {
+	fs := flag.NewFlagSet("user create", flag.ContinueOnError)
+	email := fs.String("email", "", "email `address` of the account")
+	handle := fs.String("handle", "", "`handle` of the account (default random)")
+	admin := fs.Bool("admin", false, "make the account an admin")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *email == "" || fs.NArg() > 0 {
+		return usagef("usage: user create -email address [-handle handle] [-admin]")
+	}
+	if *handle == "" {
+		*handle = handles.Generate()
+	} else if err := handles.Validate(*handle); err != nil {
+		return usagef("invalid handle: %s", err)
+	}
+	password, err := readPassword()
+	if err != nil {
+		return err
+	}
+	hash, err := auth.HashPassword(password)
+	if err != nil {
+		return fmt.Errorf("could not hash password: %w", err)
+	}
+	user, err := cfg.CreateUser(ctx, database.CreateUserParams{
+		Email:          *email,
+		HashedPassword: hash,
+		Handle:         *handle,
+	})
+	if err != nil {
+		return fmt.Errorf("could not create user: %w", err)
+	}
+	if *admin {
+		if err := cfg.Queries.AddAdmin(ctx, user.ID); err != nil {
+			return fmt.Errorf("could not make @%s an admin: %w", user.Handle, err)
+		}
+	}
+	log.Printf("created @%s (%s), id %s", user.Handle, user.Email, user.ID)
+	return nil
+}
+
+func promoteUser(ctx context.Context, cfg *handlers.APIConfig, args []string) error {
+	if len(args) != 1 {
+		return usagef("usage: user promote <email or @handle>")
+	}
+	user, err := findUser(ctx, cfg.Queries, args[0])
+	if err != nil {
+		return err
+	}
+	if err := cfg.Queries.AddAdmin(ctx, user.ID); err != nil {
+		return fmt.Errorf("could not make @%s an admin: %w", user.Handle, err)
+	}
+	log.Printf("@%s is an admin", user.Handle)
+	return nil
+}
+
+// disableUser keeps a user from logging in and ends their sessions. Access
+// tokens already issued stop working too.
+func disableUser(ctx context.Context, cfg *handlers.APIConfig, args []string) error {
+	fs := flag.NewFlagSet("user disable", flag.ContinueOnError)
+	reason := fs.String("reason", "", "why the account is disabled, for the record")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return usagef("usage: user disable [-reason text] <email or @handle>")
+	}
+	user, err := findUser(ctx, cfg.Queries, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	err = database.RunInTx(ctx, cfg.DB, func(q *database.Queries) error {
+		if err := q.DisableUser(ctx, database.DisableUserParams{UserID: user.ID, Reason: *reason}); err != nil {
+			return fmt.Errorf("could not disable @%s: %w", user.Handle, err)
+		}
+		if err := q.RevokeUserTokens(ctx, user.ID); err != nil {
+			return fmt.Errorf("could not revoke the tokens of @%s: %w", user.Handle, err)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	log.Printf("@%s is disabled", user.Handle)
+	return nil
+}
+
+// findUser finds a user by email or handle.
+func findUser(ctx context.Context, q *database.Queries, ref string) (database.User, error) {
+	var user database.User
+	var err error
+	if handle, ok := strings.CutPrefix(ref, "@"); ok || !strings.Contains(ref, "@") {
+		user, err = q.GetUserFromHandle(ctx, handle)
+	} else {
+		user, err = q.GetUserFromEmail(ctx, ref)
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return user, fmt.Errorf("no user %s", ref)
+	}
+	if err != nil {
+		return user, fmt.Errorf("could not find user %s: %w", ref, err)
+	}
+	return user, nil
+}
+
+// readPassword reads a password without echoing it from a terminal, or as
+// a line of stdin when it is piped in.
+func readPassword() (string, error) {
+	if term.IsTerminal(int(os.Stdin.Fd())) {
+		fmt.Fprint(os.Stderr, "Password: ")
+		password, err := term.ReadPassword(int(os.Stdin.Fd()))
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", fmt.Errorf("could not read the password: %w", err)
+		}
+		return string(password), nil
+	}
+	line, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	line = strings.TrimRight(line, "\r\n")
+	if line == "" {
+		return "", usagef("no password on stdin")
+	}
+	return line, nil
+}